@@ -0,0 +1,124 @@
+// Package ir is the CI-agnostic model of a pipeline: the jobs, their steps, the
+// pipeline parameters and the workflows ordering the jobs. The input parsers fill it
+// in, whatever CI system they read, and the output emitters write it out.
+//
+// Values keep the references of the converted config: pipeline parameters and values
+// as << pipeline.parameters.name >> and << pipeline.git.branch >>, job parameters as
+// << parameters.name >>.
+package ir
+
+// Pipeline is a converted config
+type Pipeline struct {
+	Source     string     // CI system of the input, as shown to users
+	Parameters []Param    // pipeline parameters, by name
+	Jobs       []Job      // by name
+	Workflows  []Workflow // by name
+}
+
+// Job returns the job named name
+func (p *Pipeline) Job(name string) (Job, bool) {
+	for _, job := range p.Jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Param is a pipeline parameter
+type Param struct {
+	Name        string
+	Type        string // string, boolean, integer, enum, ...
+	Default     string // the default as text (true or false for booleans)
+	HasDefault  bool
+	Enum        []string // choices of enum parameters
+	Description string
+}
+
+// Job is one unit of work, run on one executor
+type Job struct {
+	Name        string
+	Executor    Executor
+	Environment map[string]string
+	Parallelism int      // 0 or 1 for a single run
+	Parameters  []string // names of the pipeline parameters the job reads
+	Steps       []Step
+	Caches      []Cache
+	Artifacts   []Artifact
+}
+
+// Executor is where a job runs
+type Executor struct {
+	Kind             string   // docker, machine or macos; empty when it could not be resolved
+	Image            string   // primary docker image or machine image
+	Services         []string // images of the service containers
+	Platform         string   // linux, darwin or windows
+	ResourceClass    string
+	WorkingDirectory string
+	Shell            string
+}
+
+// Kinds of steps
+const (
+	StepRun        = "run"        // a shell command
+	StepCheckout   = "checkout"   // check the code out
+	StepRestore    = "restore"    // restore a cache
+	StepSave       = "save"       // save a cache
+	StepArtifact   = "artifact"   // keep files, artifacts or test results
+	StepPersist    = "persist"    // pass files to the jobs after this one
+	StepAttach     = "attach"     // get the files the jobs before this one passed
+	StepInvocation = "invocation" // a command or orb step, run by name
+	StepOther      = "other"      // a step of the input CI system
+)
+
+// Step is one step of a job
+type Step struct {
+	Kind        string
+	Name        string            // display name; the command or step name of invocations
+	Command     string            // command of run steps
+	Shell       string            // shell of run steps, empty for the job's
+	Dir         string            // working directory of run steps
+	Environment map[string]string // environment of run steps
+	When        string            // on_success, always or on_fail
+	Paths       []string          // paths of caches, artifacts and workspaces
+}
+
+// Cache is a cache a job restores or saves
+type Cache struct {
+	Keys  []string // keys, the first tried first
+	Paths []string // saved paths; empty for restores
+}
+
+// Artifact is a path a job keeps after its run
+type Artifact struct {
+	Path        string
+	Destination string // name in the artifacts, empty for the path
+	TestResults bool   // test results rather than artifacts
+}
+
+// Workflow orders the runs of jobs
+type Workflow struct {
+	Name      string
+	Schedules []string // cron schedules (UTC) running the workflow
+	Condition string   // description of the condition the workflow runs under, empty when it always runs
+	Error     string   // why the runs could not be ordered, empty when they were
+	Runs      []Run    // in an order respecting their requirements
+}
+
+// Run is one job run by a workflow
+type Run struct {
+	Name     string   // name of the run in the workflow (matrix variants have their own)
+	Job      string   // job run, empty for approvals
+	Task     string   // task running the job
+	Args     []string // KEY=value arguments of the run
+	Requires []string // runs before this one
+	Approval bool     // waits for a manual approval
+	Branches Filter
+	Tags     Filter
+}
+
+// Filter is a list of branch or tag patterns: names, or /regexes/
+type Filter struct {
+	Only   []string
+	Ignore []string
+}
@@ -0,0 +1,319 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nichecode/circle-to-task/ir"
+	"gopkg.in/yaml.v3"
+)
+
+// targetGitHubActions writes GitHub Actions workflows running the Taskfile's tasks
+const targetGitHubActions = "github-actions"
+
+// githubActionsDir holds the workflows written with -target github-actions
+const githubActionsDir = ".github/workflows"
+
+// githubActionsRunners are the runner labels of jobs by executor platform
+var githubActionsRunners = map[string]string{"linux": "ubuntu-latest", "darwin": "macos-latest", "windows": "windows-latest"}
+
+// githubActionsEmitter writes a GitHub Actions workflow per workflow
+type githubActionsEmitter struct{}
+
+func (githubActionsEmitter) Name() string { return targetGitHubActions }
+
+func (githubActionsEmitter) Emit(out emission) ([]string, error) {
+	written, err := generateGitHubActions(out.Pipeline, out.OutputDir, out.Report)
+	if err != nil {
+		return nil, fmt.Errorf("error writing GitHub Actions workflows: %w", err)
+	}
+	if !written {
+		return nil, nil
+	}
+	return []string{githubActionsDir + "/ (GitHub Actions workflows running the tasks)"}, nil
+}
+
+// githubActionsMapping returns a YAML mapping of key, value pairs in their order;
+// nil values are left out
+func githubActionsMapping(pairs ...interface{}) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] == nil {
+			continue
+		}
+		value, ok := pairs[i+1].(*yaml.Node)
+		if !ok {
+			value = &yaml.Node{}
+			if err := value.Encode(pairs[i+1]); err != nil {
+				continue
+			}
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: pairs[i].(string)}, value)
+	}
+	return node
+}
+
+// githubActionsString returns s as a single-quoted string of a GitHub expression
+func githubActionsString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// githubActionsMatch returns the expression matching github.ref_name against branch or
+// tag patterns, "true" when a pattern matches every name, and false when a pattern is
+// a regular expression, which expressions cannot test
+func githubActionsMatch(patterns []string) (string, bool) {
+	var terms []string
+	for _, pattern := range patterns {
+		if pattern == "/.*/" || pattern == "/.+/" {
+			return "true", true
+		}
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			return "", false
+		}
+		terms = append(terms, "github.ref_name == "+githubActionsString(pattern))
+	}
+	return strings.Join(terms, " || "), true
+}
+
+// githubActionsCondition returns the `if:` of a run's job from its branch and tag
+// filters (empty when it runs on every push), and whether the filters were converted.
+// Workflows triggered by tags run only the jobs with tag filters on them.
+func githubActionsCondition(run ir.Run, tags bool) (string, bool) {
+	converted := true
+	branch := []string{}
+	if tags {
+		branch = append(branch, "github.ref_type == 'branch'")
+	}
+	if match, ok := githubActionsMatch(run.Branches.Only); !ok {
+		converted = false
+	} else if match != "" && match != "true" {
+		branch = append(branch, "("+match+")")
+	}
+	if match, ok := githubActionsMatch(run.Branches.Ignore); !ok {
+		converted = false
+	} else if match == "true" {
+		branch = []string{"false"}
+	} else if match != "" {
+		branch = append(branch, "!("+match+")")
+	}
+	condition := strings.Join(branch, " && ")
+
+	if len(run.Tags.Only) > 0 {
+		tag := []string{"github.ref_type == 'tag'"}
+		if match, ok := githubActionsMatch(run.Tags.Only); !ok {
+			converted = false
+		} else if match != "true" {
+			tag = append(tag, "("+match+")")
+		}
+		if match, ok := githubActionsMatch(run.Tags.Ignore); !ok {
+			converted = false
+		} else if match != "" {
+			tag = append(tag, "!("+match+")")
+		}
+		switch {
+		case len(tag) == 1 && condition == "github.ref_type == 'branch'":
+			// Every branch and every tag
+			condition = ""
+		case condition == "false":
+			condition = strings.Join(tag, " && ")
+		case condition == "":
+			condition = fmt.Sprintf("github.ref_type != 'tag' || (%s)", strings.Join(tag, " && "))
+		default:
+			condition = fmt.Sprintf("(%s) || (%s)", condition, strings.Join(tag, " && "))
+		}
+	}
+	if condition == "github.ref_type == 'branch'" && !tags {
+		return "", converted
+	}
+	return condition, converted
+}
+
+// githubActionsJobID returns the job id of a run: letters, digits, - and _, starting
+// with a letter or _
+func githubActionsJobID(name string) string {
+	id := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, name)
+	if id == "" || (id[0] >= '0' && id[0] <= '9') || id[0] == '-' {
+		id = "_" + id
+	}
+	return id
+}
+
+// githubActionsJob returns the job of a workflow run: an environment awaiting approval
+// for approval jobs, else a checkout, go-task, and the run's task on a runner of the
+// job's platform, in its docker image
+func githubActionsJob(pipeline *ir.Pipeline, run ir.Run, ids map[string]string, tags bool, report *ConversionReport) *yaml.Node {
+	var needs []string
+	for _, req := range run.Requires {
+		needs = append(needs, ids[req])
+	}
+	var needsValue interface{}
+	if len(needs) > 0 {
+		needsValue = needs
+	}
+	var ifValue interface{}
+	condition, converted := githubActionsCondition(run, tags)
+	if condition != "" {
+		ifValue = condition
+	}
+	if !converted {
+		report.Add("GitHub Actions", run.Name, "branch and tag regexes have no GitHub Actions expression; the task's precondition stops it on other refs")
+	}
+
+	if run.Approval {
+		environment := "approve-" + travisSlug(run.Name)
+		report.Add("GitHub Actions", run.Name, "the approval waits on the environment %s; give it required reviewers", environment)
+		steps := []interface{}{map[string]interface{}{"run": fmt.Sprintf("echo %s", shellQuote("Approved "+run.Name))}}
+		return githubActionsMapping("name", run.Name, "needs", needsValue, "if", ifValue, "runs-on", "ubuntu-latest", "environment", environment, "steps", steps)
+	}
+
+	job, _ := pipeline.Job(run.Job)
+	executor := job.Executor
+	runner := githubActionsRunners[executor.Platform]
+	if runner == "" {
+		runner = "ubuntu-latest"
+	}
+	var container interface{}
+	switch {
+	case executor.Kind == "docker" && executor.Image != "" && !strings.Contains(executor.Image, "<<"):
+		container = executor.Image
+	case executor.Kind == "docker" && executor.Image != "":
+		report.Add("GitHub Actions", run.Name, "image %s depends on parameters; the job runs on the runner", executor.Image)
+	}
+	if len(executor.Services) > 0 {
+		report.Add("GitHub Actions", run.Name, "the job runs without its %d service containers; add them as `services:`", len(executor.Services))
+	}
+	steps := []interface{}{
+		map[string]interface{}{"uses": "actions/checkout@v4"},
+		map[string]interface{}{"uses": "arduino/setup-task@v2"},
+		map[string]interface{}{"run": runTaskCommand(pipeline, run)},
+	}
+	return githubActionsMapping("name", run.Name, "needs", needsValue, "if", ifValue, "runs-on", runner, "container", container, "steps", steps)
+}
+
+// generateGitHubActions writes .github/workflows/<workflow>.yml for each workflow: its
+// jobs run the tasks in dependency order, on pushes or the workflow's schedules, and
+// on demand with the pipeline parameters as inputs. It reports whether a workflow was
+// written.
+func generateGitHubActions(pipeline *ir.Pipeline, outputDir string, report *ConversionReport) (bool, error) {
+	if len(pipeline.Workflows) == 0 {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Join(outputDir, githubActionsDir), 0755); err != nil {
+		return false, err
+	}
+
+	// Pipeline parameters are inputs of manual runs, with their defaults on pushes
+	inputs := githubActionsMapping()
+	env := githubActionsMapping(
+		"CIRCLE_BRANCH", "${{ github.ref_type == 'branch' && github.ref_name || '' }}",
+		"CIRCLE_TAG", "${{ github.ref_type == 'tag' && github.ref_name || '' }}",
+		"CIRCLE_SHA1", "${{ github.sha }}",
+	)
+	for _, param := range pipeline.Parameters {
+		input := []interface{}{"description", param.Description, "required", false}
+		if param.Description == "" {
+			input[1] = fmt.Sprintf("Pipeline parameter %s", param.Name)
+		}
+		switch param.Type {
+		case "boolean":
+			input = append(input, "type", "boolean")
+		case "enum":
+			input = append(input, "type", "choice", "options", param.Enum)
+		default:
+			input = append(input, "type", "string")
+		}
+		if param.HasDefault {
+			input = append(input, "default", param.Default)
+		}
+		inputs.Content = append(inputs.Content, githubActionsMapping(param.Name, githubActionsMapping(input...)).Content...)
+		value := fmt.Sprintf("${{ inputs.%s }}", param.Name)
+		if param.HasDefault {
+			value = fmt.Sprintf("${{ inputs.%s || %s }}", param.Name, githubActionsString(param.Default))
+		}
+		env.Content = append(env.Content, githubActionsMapping(taskVarName(param.Name), value).Content...)
+	}
+	var dispatch interface{} = map[string]interface{}{}
+	if len(inputs.Content) > 0 {
+		dispatch = githubActionsMapping("inputs", inputs)
+	}
+
+	used := make(map[string]bool)
+	for _, workflow := range pipeline.Workflows {
+		if workflow.Error != "" {
+			report.Add("GitHub Actions", workflow.Name, "workflow has no GitHub Actions workflow: %s", workflow.Error)
+			continue
+		}
+		if workflow.Condition != "" {
+			report.Add("GitHub Actions", workflow.Name, "runs only when %s; its GitHub Actions workflow runs unconditionally", workflow.Condition)
+		}
+
+		// Pushes of tags trigger the workflow when one of its jobs has tag filters
+		tags := false
+		for _, run := range workflow.Runs {
+			if len(run.Tags.Only) > 0 {
+				tags = true
+			}
+		}
+		var on *yaml.Node
+		if len(workflow.Schedules) > 0 {
+			var schedules []interface{}
+			for _, cron := range workflow.Schedules {
+				schedules = append(schedules, map[string]interface{}{"cron": cron})
+			}
+			on = githubActionsMapping("schedule", schedules, "workflow_dispatch", dispatch)
+		} else {
+			push := githubActionsMapping("branches", []string{"**"})
+			if tags {
+				push = githubActionsMapping("branches", []string{"**"}, "tags", []string{"**"})
+			}
+			on = githubActionsMapping("push", push, "workflow_dispatch", dispatch)
+		}
+
+		ids := make(map[string]string)
+		taken := make(map[string]bool)
+		jobs := githubActionsMapping()
+		for _, run := range workflow.Runs {
+			id := githubActionsJobID(run.Name)
+			for i := 2; taken[id]; i++ {
+				id = fmt.Sprintf("%s-%d", githubActionsJobID(run.Name), i)
+			}
+			ids[run.Name] = id
+			taken[id] = true
+			jobs.Content = append(jobs.Content, githubActionsMapping(id, githubActionsJob(pipeline, run, ids, tags, report)).Content...)
+		}
+
+		doc := githubActionsMapping(
+			"name", workflow.Name,
+			"on", on,
+			"env", env,
+			"defaults", map[string]interface{}{"run": map[string]interface{}{"shell": "bash"}},
+			"jobs", jobs,
+		)
+		var b strings.Builder
+		b.WriteString("# Generated by circle-to-task from the CircleCI workflow " + workflow.Name + ". The jobs run\n")
+		b.WriteString("# the Taskfile's tasks, installing go-task (https://taskfile.dev) first.\n")
+		encoder := yaml.NewEncoder(&b)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return false, err
+		}
+		encoder.Close()
+
+		file := travisSlug(workflow.Name)
+		for i := 2; used[file]; i++ {
+			file = fmt.Sprintf("%s-%d", travisSlug(workflow.Name), i)
+		}
+		used[file] = true
+		if err := os.WriteFile(filepath.Join(outputDir, githubActionsDir, file+".yml"), []byte(b.String()), 0644); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,139 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stepHaltRegex matches `circleci-agent step halt` (or `circleci step halt`), which
+// ends a job successfully once the current step finishes
+var stepHaltRegex = regexp.MustCompile(`\bcircleci(-agent)?\s+step\s+halt\b`)
+
+// agentCommandRegex matches other calls of the agent, which only exists on CircleCI,
+// up to the end of the pipeline stage. `circleci-agent tests` calls are left to the
+// test splitting emulation.
+var agentCommandRegex = regexp.MustCompile("\\bcircleci-agent\\s+([a-z-]+)\\b[^|;&)<>\\n`]*")
+
+// haltFile is the marker a local `step halt` leaves for the rest of a task's commands
+func haltFile(taskName string) string {
+	return fmt.Sprintf("{{.ROOT_DIR}}/.task/halt/%s", strings.NewReplacer("/", "-", ":", "-").Replace(taskName))
+}
+
+// haltMarker is the marker of a task in its commands. Job tasks set CIRCLE_HALT_FILE,
+// which the nested `task` calls of their commands inherit, so a command halting stops
+// the job.
+func haltMarker(taskName string) string {
+	return fmt.Sprintf(`"${CIRCLE_HALT_FILE:-%s}"`, haltFile(taskName))
+}
+
+// haltGuard skips a command once the task has halted
+func haltGuard(taskName string) string {
+	return fmt.Sprintf("[ ! -e %s ] || exit 0", haltMarker(taskName))
+}
+
+// convertAgentCommands rewrites the agent calls of a task's command: `step halt` leaves
+// the halt marker outside CircleCI, and other agent calls do nothing there. It returns
+// the command, whether it halts, and the calls made no-ops.
+func convertAgentCommands(cmd, taskName string) (string, bool, []string) {
+	halts := false
+	cmd = stepHaltRegex.ReplaceAllStringFunc(cmd, func(match string) string {
+		halts = true
+		marker := haltMarker(taskName)
+		return onCircleCI(match, fmt.Sprintf(`mkdir -p "$(dirname %s)" && touch %s`, marker, marker))
+	})
+
+	var skipped []string
+	cmd = agentCommandRegex.ReplaceAllStringFunc(cmd, func(match string) string {
+		if agentCommandRegex.FindStringSubmatch(match)[1] == "tests" || stepHaltRegex.MatchString(match) {
+			return match
+		}
+		call := strings.TrimSpace(match)
+		skipped = append(skipped, call)
+		return onCircleCI(call, fmt.Sprintf("echo %s >&2", shellQuote("Skipping "+call+" (CircleCI only)"))) + match[len(strings.TrimRight(match, " \t")):]
+	})
+	return cmd, halts, skipped
+}
+
+// applyAgentCommands makes the CircleCI agent calls of tasks runnable locally. A task
+// halting with `circleci-agent step halt` skips its later commands, and those of the
+// jobs invoking it, once the step ends; the marker is removed when a job task starts.
+// Other agent calls run on CircleCI only.
+func applyAgentCommands(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	halting := make(map[string]bool)
+	for _, name := range names {
+		task := taskfile.Tasks[name]
+		changed := false
+		task.Cmds = append(TaskCmds(nil), task.Cmds...)
+		for i, cmd := range task.Cmds {
+			converted, halts, skipped := convertAgentCommands(cmd.Cmd, name)
+			for _, call := range skipped {
+				report.Add("CircleCI agent", name, "`%s` has no local equivalent; it only runs on CircleCI", firstLine(call))
+			}
+			if converted != cmd.Cmd {
+				task.Cmds[i] = cmd.withCmd(converted)
+				changed = true
+			}
+			halting[name] = halting[name] || halts
+		}
+		if changed {
+			taskfile.Tasks[name] = task
+		}
+	}
+
+	// Jobs invoking a halting command stop with it
+	for _, name := range names {
+		job, isJob := config.Jobs[name]
+		if !isJob {
+			continue
+		}
+		commands := make(map[string]Command)
+		referencedCommands(job.Steps, config.Commands, commands)
+		for command := range commands {
+			if !halting[command] {
+				continue
+			}
+			halting[name] = true
+			if containsString(taskfile.Tasks[name].Deps, command) {
+				report.Add("CircleCI agent", name, "command %s runs as a dependency, so its `step halt` does not stop the job's commands", command)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if !halting[name] {
+			continue
+		}
+		task := taskfile.Tasks[name]
+		guard := haltGuard(name)
+		var cmds TaskCmds
+		if _, isJob := config.Jobs[name]; isJob {
+			if task.Env == nil {
+				task.Env = make(map[string]string)
+			}
+			task.Env["CIRCLE_HALT_FILE"] = haltFile(name)
+			cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf(`rm -f "%s"`, haltFile(name))})
+			report.Add("CircleCI agent", name, "`step halt` skips the job's remaining commands (deferred ones still run); locally the marker is %s", strings.TrimPrefix(haltFile(name), "{{.ROOT_DIR}}/"))
+		} else {
+			// Run on its own, a command starts without a marker
+			cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf(`[ -n "${CIRCLE_HALT_FILE:-}" ] || rm -f "%s"`, haltFile(name))})
+			report.Add("CircleCI agent", name, "`step halt` skips the command's remaining commands, and those of the jobs calling it with `task`")
+		}
+		for _, cmd := range task.Cmds {
+			if strings.HasPrefix(cmd.Cmd, "#") || cmd.Defer {
+				cmds = append(cmds, cmd)
+				continue
+			}
+			cmds = append(cmds, cmd.withCmd(guard+"\n"+cmd.Cmd))
+		}
+		task.Cmds = cmds
+		taskfile.Tasks[name] = task
+	}
+}
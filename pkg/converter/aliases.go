@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jobTaskCall calls a job's task with the parameter values passed by an invocation
+func jobTaskCall(taskName string, job Job, args map[string]interface{}) string {
+	call := "task " + taskName
+	for _, argName := range sortedKeys(args) {
+		call += fmt.Sprintf(" %s=%s", taskVarName(argName), shellQuote(formatParamValue(job.Parameters[argName], args[argName])))
+	}
+	return call
+}
+
+// aliasKey identifies an invocation with a `name:` alias within its workflow
+func aliasKey(workflow, name string) string {
+	return workflow + "/" + name
+}
+
+// aliasTaskNames maps the invocations of local jobs with a `name:` alias, by aliasKey,
+// to the name of their task: the alias, or the alias followed by the workflow's name
+// when the alias also names a job or command, or invocations of another job or with
+// other arguments in another workflow
+func aliasTaskNames(config CircleCIConfig) map[string]string {
+	first := make(map[string]WorkflowJobInvocation)
+	ambiguous := make(map[string]bool)
+	var invocations []WorkflowJobInvocation
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		name := invocation.Name
+		if name == "" || name == invocation.Job || invocation.Matrix != nil {
+			continue
+		}
+		if _, isLocal := config.Jobs[invocation.Job]; !isLocal {
+			continue
+		}
+		invocations = append(invocations, invocation)
+		if _, isJob := config.Jobs[name]; isJob || isDefinedCommand(name, config.Commands) {
+			ambiguous[name] = true
+		}
+		if previous, seen := first[name]; !seen {
+			first[name] = invocation
+		} else if previous.Job != invocation.Job || !reflect.DeepEqual(previous.Arguments, invocation.Arguments) {
+			ambiguous[name] = true
+		}
+	}
+
+	names := make(map[string]string, len(invocations))
+	for _, invocation := range invocations {
+		name := invocation.Name
+		if ambiguous[name] {
+			name += "-" + invocation.Workflow
+		}
+		names[aliasKey(invocation.Workflow, invocation.Name)] = name
+	}
+	return names
+}
+
+// addAliasTasks adds a task for each workflow invocation with a `name:` alias, calling
+// the job's task with the invocation's arguments, so a job invoked several times keeps
+// a task per invocation. Invocations already given a task of their own (executor,
+// pre-steps and post-steps) keep it.
+func addAliasTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	names := aliasTaskNames(config)
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		name, ok := names[aliasKey(invocation.Workflow, invocation.Name)]
+		if !ok {
+			continue
+		}
+		if _, exists := taskfile.Tasks[name]; exists {
+			continue
+		}
+
+		call := jobTaskCall(invocation.Job, config.Jobs[invocation.Job], invocation.Arguments)
+		desc := fmt.Sprintf("Task converted from CircleCI job: %s (invoked as %s)", invocation.Job, invocation.Name)
+		if len(invocation.Arguments) > 0 {
+			desc = fmt.Sprintf("Task converted from CircleCI job: %s (invoked as %s with %s)", invocation.Job, invocation.Name, strings.TrimPrefix(call, "task "+invocation.Job+" "))
+		}
+		taskfile.Tasks[name] = Task{
+			Desc: desc,
+			Cmds: shellCommands(call),
+		}
+		report.Add("Job aliases", invocation.Job, "invocation %s in workflow %s converted to task %s (%s)", invocation.Name, invocation.Workflow, name, call)
+	}
+}
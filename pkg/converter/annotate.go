@@ -0,0 +1,210 @@
+package converter
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// annotationPrefix starts every comment written by `annotate`
+const annotationPrefix = "# circle-to-task: "
+
+// runAnnotateCommand implements the `annotate` subcommand: it writes a copy of the
+// config with a comment on every job and step describing how it would convert
+func runAnnotateCommand(args []string) error {
+	fs := flag.NewFlagSet("annotate", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input CircleCI config file (required)")
+	outputFile := fs.String("output", "", "Annotated copy to write (default <input>.annotated.yml; - for stdout)")
+	orbsDir := fs.String("orbs-dir", "orbs", "Directory of vendored orb sources (see 'orbs vendor')")
+	offline := fs.Bool("offline", false, "Forbid network access; resolve orbs only from the vendor directory")
+	fs.Parse(args)
+
+	if *inputFile == "" {
+		fs.Usage()
+		return fmt.Errorf("-input is required")
+	}
+
+	data, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseConfig(*inputFile, data)
+	if err != nil {
+		return err
+	}
+	if config.source == nil || len(config.source.Content) == 0 {
+		return fmt.Errorf("%s is empty", *inputFile)
+	}
+
+	opts := ConvertOptions{Orbs: NewOrbResolver(*orbsDir, *offline, "", "")}
+	_, taskfile := convertConfig(config, opts, &ConversionReport{})
+	annotateConfig(config.source.Content[0], config, taskfile, analyzePatterns(config))
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.source); err != nil {
+		return fmt.Errorf("error writing annotated config: %w", err)
+	}
+
+	if *outputFile == "-" {
+		_, err := os.Stdout.Write(out.Bytes())
+		return err
+	}
+	if *outputFile == "" {
+		*outputFile = strings.TrimSuffix(strings.TrimSuffix(*inputFile, ".yml"), ".yaml") + ".annotated.yml"
+	}
+	if err := writeFileContent(*outputFile, out.Bytes()); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote annotated config to %s\n", *outputFile)
+	return nil
+}
+
+// annotateConfig adds conversion notes to the jobs and commands of a config document
+func annotateConfig(root *yaml.Node, config CircleCIConfig, taskfile Taskfile, patterns map[string]Task) {
+	if jobs := mappingValue(root, "jobs"); jobs != nil {
+		for i := 0; i+1 < len(jobs.Content); i += 2 {
+			name := jobs.Content[i].Value
+			if name == "<<" {
+				continue
+			}
+			note := fmt.Sprintf("job → task %s (the slim config runs `task %s`)", name, name)
+			if deps := taskfile.Tasks[name].Deps; len(deps) > 0 {
+				note += fmt.Sprintf("; depends on %s", strings.Join(deps, ", "))
+			}
+			addComment(jobs.Content[i], note)
+			annotateSteps(mappingValue(jobs.Content[i+1], "steps"), name, config, taskfile, patterns)
+		}
+	}
+
+	if commands := mappingValue(root, "commands"); commands != nil {
+		for i := 0; i+1 < len(commands.Content); i += 2 {
+			name := commands.Content[i].Value
+			if name == "<<" {
+				continue
+			}
+			addComment(commands.Content[i], fmt.Sprintf("command → task %s, called by the jobs that use it", name))
+			annotateSteps(mappingValue(commands.Content[i+1], "steps"), name, config, taskfile, patterns)
+		}
+	}
+}
+
+// annotateSteps comments every step of a steps sequence
+func annotateSteps(steps *yaml.Node, owner string, config CircleCIConfig, taskfile Taskfile, patterns map[string]Task) {
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return
+	}
+	// Comments need one step per line
+	steps.Style &^= yaml.FlowStyle
+	for _, item := range steps.Content {
+		item.Style &^= yaml.FlowStyle
+		var step Step
+		if err := item.Decode(&step); err != nil {
+			continue
+		}
+		addComment(item, describeStep(step, owner, config, taskfile, patterns))
+	}
+}
+
+// describeStep explains how a step converts, following convertJobToTask
+func describeStep(step Step, owner string, config CircleCIConfig, taskfile Taskfile, patterns map[string]Task) string {
+	if cmd := extractCommand(step); cmd != "" {
+		switch runStepWhen(step) {
+		case "always":
+			return fmt.Sprintf("when: always → deferred command in task %s, run when the task ends", owner)
+		case "on_fail":
+			return fmt.Sprintf("when: on_fail → deferred command in task %s, run only when the task fails", owner)
+		}
+		if isBackgroundStep(step) {
+			return fmt.Sprintf("background step → started detached in task %s and stopped when the job's task ends", owner)
+		}
+		if reasons := detectRiskyCommand(cmd); len(reasons) > 0 {
+			return fmt.Sprintf("blocked: %s (emitted only with -allow-risky)", strings.Join(reasons, ", "))
+		}
+		if _, _, isRetry := parseRetryLoop(cmd); isRetry {
+			return fmt.Sprintf("retry loop → retry wrapper in task %s", owner)
+		}
+		if name := findPatternTask(normalizeCommand(convertParameterSyntax(cmd)), patterns); name != "" {
+			return fmt.Sprintf("repeated command → shared task %s, run as a dependency of %s", name, owner)
+		}
+		return fmt.Sprintf("→ command in task %s", owner)
+	}
+
+	if name, ok := step.(string); ok {
+		switch {
+		case name == "checkout":
+			return "lossy → `git checkout HEAD`: the local working copy is used as is"
+		case taskfile.Tasks[name].Cmds != nil:
+			return fmt.Sprintf("→ dependency on task %s", name)
+		}
+		return unresolvedCommand(name)
+	}
+
+	if _, policy, isRetry := retryOrbStep(step); isRetry {
+		return fmt.Sprintf("retry step → retry wrapper (%d attempts) in task %s", policy.Attempts, owner)
+	}
+
+	stepMap, _ := step.(map[string]interface{})
+	for key, value := range stepMap {
+		switch key {
+		case "checkout":
+			if path := checkoutPath(value); path != "" {
+				return fmt.Sprintf("lossy → clones the local repo's HEAD into %s; uncommitted changes are not included", path)
+			}
+			return "lossy → `git checkout HEAD`: the local working copy is used as is"
+		case "setup_remote_docker":
+			return "skipped: CircleCI-only, the local Docker daemon is used"
+		case "add_ssh_keys":
+			return "→ adds the keys with its fingerprints from ~/.ssh to ssh-agent; needs them locally"
+		case "restore_cache":
+			return "skipped: caches are CircleCI-only"
+		case "save_cache":
+			return "lossy: kept as a comment, caches are CircleCI-only"
+		case "persist_to_workspace":
+			return "→ copies the paths under `root` to ./workspace, later copies replacing earlier files"
+		case "attach_workspace":
+			return "→ copies ./workspace to `at`, if a previous task filled it"
+		case "store_artifacts":
+			return "→ copies the path to ./artifacts, listed by `task artifacts:open`"
+		case "store_test_results":
+			return "→ copies the path to ./test-results/<task>, summarized by `task test-report`"
+		case "when", "unless":
+			if body, ok := stepMap[key].(map[string]interface{}); ok {
+				if _, err := templateCondition(body["condition"], nil); err != nil {
+					return fmt.Sprintf("not converted: %v", err)
+				}
+			}
+			return fmt.Sprintf("→ nested commands guarded by `{{if}}` on the condition in task %s", owner)
+		}
+	}
+
+	if name, ok := isCommandInvocation(step); ok {
+		if _, exists := taskfile.Tasks[name]; exists {
+			return fmt.Sprintf("→ `%s` in task %s", generateTaskCallWithParams(name, step, config.Commands), owner)
+		}
+		return unresolvedCommand(name)
+	}
+	return "not converted: unknown step type"
+}
+
+// unresolvedCommand explains a step calling a command that has no task
+func unresolvedCommand(name string) string {
+	if strings.Contains(name, "/") {
+		return fmt.Sprintf("not converted: orb command %s could not be resolved (see `orbs vendor`)", name)
+	}
+	return fmt.Sprintf("not converted: unknown command %s", name)
+}
+
+// addComment appends an annotation to the comments above a node
+func addComment(node *yaml.Node, note string) {
+	comment := annotationPrefix + note
+	if node.HeadComment != "" {
+		comment = node.HeadComment + "\n" + comment
+	}
+	node.HeadComment = comment
+}
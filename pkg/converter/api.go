@@ -0,0 +1,202 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is a parsed CI config, of any of the input formats
+type Config struct {
+	Source string // path of the config, used to name the slim config and the project
+	System string // CI system of the config
+
+	data   []byte
+	config CircleCIConfig
+}
+
+// Options are the conversion options of the command line
+type Options struct {
+	Targets    []string // output targets (see -target); the Taskfile when empty
+	Project    string   // project name of the templates; guessed from the source when empty
+	OrbsDir    string   // vendored orb sources, orbs when empty
+	Offline    bool     // resolve orbs only from OrbsDir
+	AllowRisky bool
+	Docker     bool
+	HostDocker bool
+	ShellLib   bool
+	Retry      int // attempts of every command, 0 for none
+	RetryDelay int // seconds before the first retry
+	EmitJSON   bool
+	Plugins    []string // step-converter plugins, run as circle-to-task-plugin-<name> from PATH
+}
+
+// File is a file of the conversion outputs
+type File struct {
+	Path string // relative to the output directory, with slashes
+	Data []byte
+	Mode fs.FileMode
+}
+
+// Outputs are the files of a conversion, with its report
+type Outputs struct {
+	System   string
+	Jobs     int
+	Tasks    int
+	Files    []File // sorted by path
+	Report   *ConversionReport
+	Warnings []string
+}
+
+// FS is where Write writes outputs
+type FS interface {
+	MkdirAll(path string, perm fs.FileMode) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// dirFS is an FS writing under a directory
+type dirFS string
+
+func (d dirFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(filepath.Join(string(d), filepath.FromSlash(path)), perm)
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(filepath.Join(string(d), filepath.FromSlash(name)), data, perm)
+}
+
+// DirFS returns an FS writing under dir
+func DirFS(dir string) FS {
+	return dirFS(dir)
+}
+
+// Parse parses a config read from r, detecting its format from its contents
+func Parse(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseData("", data)
+}
+
+// ParseFile parses the config at path, detecting its format from the path and contents
+func ParseFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseData(path, data)
+}
+
+// parseData parses a config of any input format
+func parseData(source string, data []byte) (*Config, error) {
+	config, err := parseInput(source, data, fromAuto)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{Source: source, System: inputFormatName(config), data: data, config: config}, nil
+}
+
+// Convert converts a parsed config into its output files. The outputs are written to a
+// temporary directory and read back, so a conversion does not see the files of a
+// previous one: hand-edited tasks are not kept.
+func Convert(cfg *Config, opts Options) (*Outputs, error) {
+	targets := []string{targetTaskfile}
+	if len(opts.Targets) > 0 {
+		parsed, err := parseTargets(strings.Join(opts.Targets, ","))
+		if err != nil {
+			return nil, err
+		}
+		targets = parsed
+	}
+	source := cfg.Source
+	if source == "" {
+		source = "config.yml"
+	}
+	project := opts.Project
+	if project == "" {
+		project = projectName(source)
+	}
+	orbsDir := opts.OrbsDir
+	if orbsDir == "" {
+		orbsDir = "orbs"
+	}
+	orbs := NewOrbResolver(orbsDir, opts.Offline, "", "")
+	plugins, err := lookupPlugins(opts.Plugins)
+	if err != nil {
+		return nil, err
+	}
+	settings := conversionSettings{
+		Options: ConvertOptions{
+			AllowRisky: opts.AllowRisky,
+			Docker:     opts.Docker,
+			HostDocker: opts.HostDocker,
+			Orbs:       orbs,
+			Retry:      RetryPolicy{Attempts: opts.Retry, Delay: opts.RetryDelay},
+			ShellLib:   opts.ShellLib,
+			Plugins:    plugins,
+		},
+		LockOptions: map[string]string{},
+		From:        fromAuto,
+		Targets:     targets,
+		NpmConflict: npmConflictPrefix,
+		EmitJSON:    opts.EmitJSON,
+	}
+
+	dir, err := os.MkdirTemp("", "circle-to-task-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	result, err := convertInput(source, project, cfg.data, cfg.config, dir, settings)
+	if err != nil {
+		return nil, err
+	}
+	files, err := readOutputs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Outputs{System: result.System, Jobs: result.Jobs, Tasks: result.Tasks, Files: files, Report: result.Report, Warnings: result.Warnings}, nil
+}
+
+// readOutputs reads the files written under dir
+func readOutputs(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Data: data, Mode: info.Mode().Perm()})
+		return nil
+	})
+	return files, err
+}
+
+// Write writes the output files to fsys
+func Write(fsys FS, outputs *Outputs) error {
+	for _, file := range outputs.Files {
+		dir := filepath.ToSlash(filepath.Dir(filepath.FromSlash(file.Path)))
+		if err := fsys.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating %s: %w", dir, err)
+		}
+		if err := fsys.WriteFile(file.Path, file.Data, file.Mode); err != nil {
+			return fmt.Errorf("error writing %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
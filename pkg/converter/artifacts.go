@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// artifactsManifest records every file a store_artifacts step copies as
+// "<run>\t<time>\t<task>\t<path>" lines, the path being relative to ./artifacts
+const artifactsManifest = "./artifacts/manifest.tsv"
+
+// artifactsIndexAwk renders the manifest as artifacts/index.html: one section per run,
+// newest first, listing the files each task stored, as recorded when it stored them. It
+// runs in ./artifacts and contains no single quotes, so it can be passed to awk in a
+// single-quoted argument.
+const artifactsIndexAwk = `BEGIN {
+  FS = "\t"
+}
+function esc(s) {
+  gsub(/&/, "\\&amp;", s); gsub(/</, "\\&lt;", s); gsub(/>/, "\\&gt;", s); gsub(/"/, "\\&quot;", s)
+  return s
+}
+NF >= 4 {
+  if (!($1 in count)) runs[n++] = $1
+  key = $1 SUBSEP $2 SUBSEP $3
+  if (!(key in row)) {
+    row[key] = count[$1]++
+    task[$1, row[key]] = $3
+    stored[$1, row[key]] = $2
+  }
+  files[$1, row[key]] = files[$1, row[key]] "<a href=\"" esc($4) "\">" esc($4) "</a><br>"
+}
+END {
+  print "<!DOCTYPE html>"
+  print "<html><head><meta charset=\"utf-8\"><title>Artifacts</title>"
+  print "<style>body { font-family: sans-serif; margin: 2em } td, th { padding: 2px 16px 2px 0; text-align: left; vertical-align: top }</style>"
+  print "</head><body><h1>Artifacts</h1>"
+  if (n == 0) print "<p>No artifacts stored yet.</p>"
+  for (i = n - 1; i >= 0; i--) {
+    print "<h2>Run " esc(runs[i]) "</h2>"
+    print "<table><tr><th>Task</th><th>Stored</th><th>Files</th></tr>"
+    for (j = 0; j < count[runs[i]]; j++) {
+      print "<tr><td>" esc(task[runs[i], j]) "</td><td>" esc(stored[runs[i], j]) "</td><td>" files[runs[i], j] "</td></tr>"
+    }
+    print "</table>"
+  }
+  print "</body></html>"
+}`
+
+// artifactDestination returns the `destination:` of a store_artifacts step as a path
+// under ./artifacts, or "" when unset. Leading slashes and ".." cannot leave the
+// directory.
+func artifactDestination(value interface{}) string {
+	destination, _ := value.(string)
+	if destination == "" {
+		return ""
+	}
+	return strings.Trim(path.Clean("/"+destination), "/")
+}
+
+// storeArtifactsCommand copies a store_artifacts path to ./artifacts and records the
+// files it copied in the manifest, so the index lists what each run stored. With a destination, a directory's contents or a file are copied to
+// that path under ./artifacts, as CircleCI names them; without, the path keeps its
+// base name. Runs are told apart by CIRCLE_WORKFLOW_ID, which `run` sets locally.
+func storeArtifactsCommand(source, destination string) string {
+	src := stepDirectory(source)
+	stored := fmt.Sprintf(`"$(basename %s)"`, src)
+	cmd := fmt.Sprintf("mkdir -p ./artifacts && cp -r %s ./artifacts/", src)
+	if destination != "" {
+		dest := shellQuote("./artifacts/" + destination)
+		stored = shellQuote(destination)
+		cmd = fmt.Sprintf(`if [ -d %s ]; then mkdir -p %s && cp -R %s/. %s/; else mkdir -p "$(dirname %s)" && cp %s %s; fi`,
+			src, dest, src, dest, dest, src, dest)
+	}
+	record := fmt.Sprintf(`stored_at="$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" && (cd ./artifacts && find %s -type f | sort) | while IFS= read -r file; do printf '%%s\t%%s\t%%s\t%%s\n' "${CIRCLE_WORKFLOW_ID:-manual}" "$stored_at" "{{.TASK}}" "$file"; done >> %s`, stored, artifactsManifest)
+	return cmd + " && " + record
+}
+
+// addArtifactTasks adds artifacts:index (alias artifacts-index), listing the collected
+// artifacts and writing artifacts/index.html from the manifest, and artifacts:open, opening it in the browser, when any task stores
+// artifacts. Together they approximate CircleCI's artifacts tab for local runs.
+func addArtifactTasks(taskfile *Taskfile) {
+	stores := false
+	for _, task := range taskfile.Tasks {
+		for _, cmd := range task.Cmds {
+			stores = stores || strings.Contains(cmd.Cmd, artifactsManifest)
+		}
+	}
+	if !stores {
+		return
+	}
+
+	taskfile.Tasks["artifacts:index"] = Task{
+		Desc:    "List the collected artifacts and write artifacts/index.html with those stored by each run",
+		Aliases: []string{"artifacts-index"},
+		Cmds: shellCommands(
+			"mkdir -p ./artifacts && touch "+artifactsManifest,
+			"cd ./artifacts && find . -type f ! -name manifest.tsv ! -name index.html | sed 's|^\\./||' | sort",
+			"cd ./artifacts && awk '{{.ARTIFACTS_INDEX}}' manifest.tsv > index.html",
+			"echo 'Wrote artifacts/index.html'",
+		),
+		Vars: map[string]string{"ARTIFACTS_INDEX": artifactsIndexAwk},
+	}
+	taskfile.Tasks["artifacts:open"] = Task{
+		Desc: "Open the local artifacts index in the browser",
+		Deps: []string{"artifacts:index"},
+		Cmds: shellCommands(
+			`{{if eq OS "darwin"}}open{{else if eq OS "windows"}}cmd /c start ""{{else}}xdg-open{{end}} artifacts/index.html`,
+		),
+	}
+}
@@ -0,0 +1,928 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromAzure reads Azure Pipelines configs (azure-pipelines.yml)
+const fromAzure = "azure"
+
+// azureDefaultImage is the VM image of jobs whose pool names none
+const azureDefaultImage = "ubuntu-latest"
+
+// azurePipelineValues are the predefined variables, by their environment names, with a
+// pipeline value equivalent, which the Taskfile computes from git
+var azurePipelineValues = map[string]string{
+	"BUILD_SOURCEVERSION":    "pipeline.git.revision",
+	"BUILD_SOURCEBRANCHNAME": "pipeline.git.branch",
+	"BUILD_BUILDID":          "pipeline.number",
+	"BUILD_BUILDNUMBER":      "pipeline.number",
+}
+
+// azureDirectories are the predefined directory variables, by their environment
+// names, and the directories they are locally
+var azureDirectories = map[string]string{
+	"BUILD_SOURCESDIRECTORY":           "$PWD",
+	"BUILD_REPOSITORY_LOCALPATH":       "$PWD",
+	"SYSTEM_DEFAULTWORKINGDIRECTORY":   "$PWD",
+	"PIPELINE_WORKSPACE":               "$PWD",
+	"AGENT_BUILDDIRECTORY":             "$PWD",
+	"BUILD_ARTIFACTSTAGINGDIRECTORY":   "$PWD/artifacts",
+	"BUILD_BINARIESDIRECTORY":          "$PWD/bin",
+	"SYSTEM_ARTIFACTSDIRECTORY":        "$PWD/artifacts",
+	"AGENT_TEMPDIRECTORY":              "${TMPDIR:-/tmp}",
+	"BUILD_STAGINGDIRECTORY":           "$PWD/artifacts",
+	"PIPELINE_WORKSPACE_ARTIFACTS_DIR": "$PWD/artifacts",
+}
+
+// azurePredefinedPrefixes are the prefixes of the variables Azure Pipelines sets
+var azurePredefinedPrefixes = []string{"BUILD_", "SYSTEM_", "AGENT_", "PIPELINE_", "ENVIRONMENT_", "RESOURCES_", "TF_BUILD"}
+
+// azureMacroRegex matches macro references to variables, $(name)
+var azureMacroRegex = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_.]*)\)`)
+
+// azureTemplateRegex matches template expressions reading a parameter or variable
+var azureTemplateRegex = regexp.MustCompile(`\$\{\{\s*(parameters|variables)\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// azureEnvRegex matches references to environment variables in scripts
+var azureEnvRegex = regexp.MustCompile(`\$(?:\{([A-Z][A-Z0-9_]*)\}|([A-Z][A-Z0-9_]*))`)
+
+// azureScriptKeys are the step keys running a script, and the shells running them
+var azureScriptKeys = map[string]string{"script": "", "bash": "", "pwsh": "pwsh", "powershell": "powershell.exe"}
+
+// azureScriptTasks are the tasks running a script, and the shells running them
+var azureScriptTasks = map[string]string{"CmdLine": "", "Bash": "", "PowerShell": "pwsh"}
+
+// azureJob is a job of an Azure Pipelines config
+type azureJob struct {
+	name  string     // the CircleCI job's name
+	id    string     // the job's id in its stage
+	stage string     // the id of the job's stage, empty without stages
+	node  *yaml.Node // the job's mapping (the root for configs with only steps)
+}
+
+// azureEnvName returns the environment variable of a pipeline variable, as Azure
+// Pipelines sets it
+func azureEnvName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+// azureJobs walks the jobs of a config in order, through its stages if it has any,
+// and names them after their ids; ids used in several stages get their stage's as a
+// prefix. A config with only steps is one job. It also returns the template entries
+// of the stages and jobs lists, which are not read.
+func azureJobs(root *yaml.Node) ([]azureJob, []string) {
+	var jobs []azureJob
+	var templates []string
+	used := make(map[string]int)
+	name := func(stage, id string) string {
+		base := travisSlug(id)
+		if base == "" {
+			base = "job"
+		}
+		if used[base] > 0 && stage != "" {
+			base = travisSlug(stage) + "-" + base
+		}
+		used[base]++
+		if used[base] > 1 {
+			base = fmt.Sprintf("%s-%d", base, used[base])
+		}
+		return base
+	}
+	addJobs := func(list *yaml.Node, stage string) {
+		list = resolveAlias(list)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range list.Content {
+			item = resolveAlias(item)
+			for _, key := range []string{"job", "deployment"} {
+				if id := mappingValue(item, key); id != nil {
+					jobs = append(jobs, azureJob{name: name(stage, id.Value), id: id.Value, stage: stage, node: item})
+				}
+			}
+			if template := mappingValue(item, "template"); template != nil {
+				templates = append(templates, template.Value)
+			}
+		}
+	}
+
+	if stages := resolveAlias(mappingValue(root, "stages")); stages != nil && stages.Kind == yaml.SequenceNode {
+		for _, item := range stages.Content {
+			item = resolveAlias(item)
+			if id := mappingValue(item, "stage"); id != nil {
+				addJobs(mappingValue(item, "jobs"), id.Value)
+			}
+			if template := mappingValue(item, "template"); template != nil {
+				templates = append(templates, template.Value)
+			}
+		}
+	} else if list := mappingValue(root, "jobs"); list != nil {
+		addJobs(list, "")
+	} else if mappingValue(root, "steps") != nil {
+		jobs = append(jobs, azureJob{name: "job", id: "Job", node: root})
+	}
+	return jobs, templates
+}
+
+// azureStepLists returns the step lists of a job in the order they run: its steps, or
+// the lifecycle hooks of a deployment job's strategy, with the `when:` of their steps
+func azureStepLists(job *yaml.Node) ([]*yaml.Node, []string) {
+	if steps := resolveAlias(mappingValue(job, "steps")); steps != nil {
+		return []*yaml.Node{steps}, []string{""}
+	}
+	var lists []*yaml.Node
+	var whens []string
+	strategy := resolveAlias(mappingValue(job, "strategy"))
+	for _, kind := range []string{"runOnce", "rolling", "canary"} {
+		hooks := resolveAlias(mappingValue(strategy, kind))
+		if hooks == nil {
+			continue
+		}
+		for _, hook := range []string{"preDeploy", "deploy", "routeTraffic", "postRouteTraffic"} {
+			if steps := resolveAlias(mappingValue(resolveAlias(mappingValue(hooks, hook)), "steps")); steps != nil {
+				lists = append(lists, steps)
+				whens = append(whens, "")
+			}
+		}
+		on := resolveAlias(mappingValue(hooks, "on"))
+		for _, outcome := range []string{"success", "failure"} {
+			if steps := resolveAlias(mappingValue(resolveAlias(mappingValue(on, outcome)), "steps")); steps != nil {
+				lists = append(lists, steps)
+				whens = append(whens, map[string]string{"success": "", "failure": "on_fail"}[outcome])
+			}
+		}
+	}
+	return lists, whens
+}
+
+// azureMatrixLegs returns the legs of a job's matrix strategy in order, and their
+// variables
+func azureMatrixLegs(job *yaml.Node) ([]string, map[string]map[string]interface{}) {
+	matrix := resolveAlias(mappingValue(resolveAlias(mappingValue(job, "strategy")), "matrix"))
+	if matrix == nil || matrix.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var legs []string
+	variables := make(map[string]map[string]interface{})
+	for i := 0; i+1 < len(matrix.Content); i += 2 {
+		leg := matrix.Content[i].Value
+		var values map[string]interface{}
+		resolveAlias(matrix.Content[i+1]).Decode(&values)
+		legs = append(legs, leg)
+		variables[leg] = values
+	}
+	return legs, variables
+}
+
+// azureScriptStep reports whether a step runs a script, which the task runs instead
+func azureScriptStep(step map[string]interface{}) bool {
+	for key := range azureScriptKeys {
+		if step[key] != nil {
+			return true
+		}
+	}
+	_, ok := azureScriptTasks[strings.SplitN(gitlabString(step["task"]), "@", 2)[0]]
+	return ok && step["task"] != nil
+}
+
+// azureTranslation translates the expressions and variables of a job and collects
+// notes for the report
+type azureTranslation struct {
+	job   string
+	notes *[]ReportEntry
+	known map[string]bool // the environment names of the config's variables
+}
+
+// note records a translation note, once
+func (t *azureTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "Azure Pipelines", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// predefined returns the local equivalent of a predefined variable: its pipeline
+// value, or its directory; other predefined variables are kept, and reported
+func (t *azureTranslation) predefined(env string) (string, bool) {
+	if t.known[env] {
+		return "", false
+	}
+	if value, ok := azurePipelineValues[env]; ok {
+		return "<< " + value + " >>", true
+	}
+	if dir, ok := azureDirectories[env]; ok {
+		return "${" + env + ":-" + dir + "}", true
+	}
+	for _, prefix := range azurePredefinedPrefixes {
+		if strings.HasPrefix(env, prefix) {
+			t.note("`$%s` is set by Azure Pipelines; set it in the environment (or .env) to run the task locally", env)
+			break
+		}
+	}
+	return "", false
+}
+
+// translate replaces template expressions reading parameters by pipeline parameters,
+// and macros by the environment variables Azure Pipelines sets for them (or their
+// local equivalent); macros of undefined names are kept, as bash reads them as
+// commands
+func (t *azureTranslation) translate(text string) string {
+	text = azureTemplateRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := azureTemplateRegex.FindStringSubmatch(ref)
+		if match[1] == "parameters" {
+			return "<< pipeline.parameters." + match[2] + " >>"
+		}
+		return "${" + azureEnvName(match[2]) + "}"
+	})
+	if strings.Contains(text, "${{") || strings.Contains(text, "$[") {
+		t.note("template and runtime expressions other than parameters and variables are not converted")
+	}
+	text = azureMacroRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		name := azureMacroRegex.FindStringSubmatch(ref)[1]
+		env := azureEnvName(name)
+		if value, ok := t.predefined(env); ok {
+			return value
+		}
+		if t.known[env] || strings.Contains(name, ".") {
+			return "${" + env + "}"
+		}
+		return ref
+	})
+	return azureEnvRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := azureEnvRegex.FindStringSubmatch(ref)
+		if value, ok := t.predefined(match[1] + match[2]); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// variables translates a `variables:` section, as a mapping or a list, into
+// environment values and, for values reading other variables, export lines; groups
+// and templates are reported
+func (t *azureTranslation) variables(value interface{}, env map[string]interface{}, exports *[]string) {
+	set := func(name string, value interface{}) {
+		env[azureEnvName(name)] = value
+		t.known[azureEnvName(name)] = true
+	}
+	var pairs [][2]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedKeys(v) {
+			pairs = append(pairs, [2]interface{}{name, v[name]})
+		}
+	case []interface{}:
+		for _, item := range v {
+			entry, _ := item.(map[string]interface{})
+			switch {
+			case entry["group"] != nil:
+				t.note("variable group %s is not converted; set its variables in the environment (or .env) to run the task locally", gitlabString(entry["group"]))
+			case entry["template"] != nil:
+				t.note("variable template %s is not read", gitlabString(entry["template"]))
+			case entry["name"] != nil:
+				pairs = append(pairs, [2]interface{}{gitlabString(entry["name"]), entry["value"]})
+			}
+		}
+	}
+	for _, pair := range pairs {
+		set(pair[0].(string), "")
+	}
+	for _, pair := range pairs {
+		name, text := azureEnvName(pair[0].(string)), t.translate(gitlabString(pair[1]))
+		if strings.Contains(text, "$") {
+			delete(env, name)
+			*exports = append(*exports, fmt.Sprintf(`export %s="%s"`, name, githubShellEscaper.Replace(text)))
+			continue
+		}
+		env[name] = text
+	}
+}
+
+// when translates a step condition: always() and failed() run the step whatever
+// happened or after a failure; others are reported
+func (t *azureTranslation) when(condition interface{}) string {
+	switch text := strings.ReplaceAll(gitlabString(condition), " ", ""); text {
+	case "", "succeeded()":
+		return ""
+	case "always()", "succeededOrFailed()":
+		return "always"
+	case "failed()":
+		return "on_fail"
+	default:
+		t.note("the step condition `%s` is not converted; the step runs when the steps before it pass", gitlabString(condition))
+		return ""
+	}
+}
+
+// cacheKey translates the key of a Cache task: quoted segments and variables are kept
+// as text, file paths become checksums
+func (t *azureTranslation) cacheKey(key string) string {
+	var parts []string
+	for _, segment := range strings.Split(key, "|") {
+		segment = strings.TrimSpace(segment)
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, `"`) || strings.Contains(segment, "$"):
+			parts = append(parts, travisSlug(t.translate(strings.Trim(segment, `"`))))
+		case strings.ContainsAny(segment, "./") && !strings.ContainsAny(segment, "*?"):
+			parts = append(parts, fmt.Sprintf(`{{ checksum "%s" }}`, segment))
+		default:
+			parts = append(parts, travisSlug(segment))
+		}
+	}
+	return "azure-" + strings.Join(parts, "-")
+}
+
+// steps translates the steps of a job: scripts into runs, artifacts, test results and
+// caches into their CircleCI steps; other tasks, templates and conditional insertions
+// are reported. It returns the steps, the cache saves to run at the end of the job,
+// and whether a step checks the code out.
+func (t *azureTranslation) steps(list []interface{}, when string) ([]interface{}, []interface{}, bool) {
+	var steps, saves []interface{}
+	checkout := false
+	run := func(step map[string]interface{}, command, shell string) {
+		def := map[string]interface{}{"command": t.translate(command)}
+		if name := gitlabString(step["displayName"]); name != "" {
+			def["name"] = name
+		}
+		if shell != "" {
+			def["shell"] = shell
+		}
+		if dir := gitlabString(step["workingDirectory"]); dir != "" {
+			def["working_directory"] = t.translate(dir)
+		}
+		if env, ok := step["env"].(map[string]interface{}); ok && len(env) > 0 {
+			environment := make(map[string]interface{})
+			for key, value := range env {
+				environment[key] = t.translate(gitlabString(value))
+			}
+			def["environment"] = environment
+		}
+		if w := t.when(step["condition"]); w != "" {
+			def["when"] = w
+		} else if when != "" {
+			def["when"] = when
+		}
+		steps = append(steps, map[string]interface{}{"run": def})
+	}
+	for _, item := range list {
+		step, _ := item.(map[string]interface{})
+		if step == nil || step["enabled"] == false {
+			continue
+		}
+		for key := range step {
+			if strings.HasPrefix(key, "${{") {
+				t.note("the conditional step insertion `%s` is not converted", key)
+			}
+		}
+		inputs, _ := step["inputs"].(map[string]interface{})
+		task := strings.SplitN(gitlabString(step["task"]), "@", 2)[0]
+		switch {
+		case step["checkout"] != nil:
+			switch repository := gitlabString(step["checkout"]); repository {
+			case "self":
+				steps = append(steps, "checkout")
+				checkout = true
+			case "none":
+				checkout = true
+			default:
+				t.note("the checkout of repository %s is not converted", repository)
+			}
+		case step["script"] != nil || step["bash"] != nil || step["pwsh"] != nil || step["powershell"] != nil:
+			for key, shell := range azureScriptKeys {
+				if step[key] != nil {
+					run(step, gitlabString(step[key]), shell)
+				}
+			}
+		case task == "CmdLine" || task == "PowerShell":
+			run(step, gitlabString(inputs["script"]), azureScriptTasks[task])
+		case task == "Bash":
+			if gitlabString(inputs["targetType"]) == "inline" {
+				run(step, gitlabString(inputs["script"]), "")
+			} else {
+				run(step, strings.TrimSpace("bash "+gitlabString(inputs["filePath"])+" "+gitlabString(inputs["arguments"])), "")
+			}
+		case step["publish"] != nil || task == "PublishPipelineArtifact":
+			path := gitlabString(step["publish"])
+			if path == "" {
+				path = gitlabString(inputs["targetPath"])
+				if path == "" {
+					path = gitlabString(inputs["path"])
+				}
+			}
+			steps = append(steps, map[string]interface{}{"persist_to_workspace": map[string]interface{}{"root": ".", "paths": []interface{}{t.translate(path)}}})
+		case step["download"] != nil || task == "DownloadPipelineArtifact":
+			if source := gitlabString(step["download"]); source != "" && source != "current" {
+				t.note("artifacts of pipeline resource %s are not downloaded", source)
+				continue
+			}
+			steps = append(steps, map[string]interface{}{"attach_workspace": map[string]interface{}{"at": "."}})
+		case task == "PublishTestResults":
+			results := gitlabString(inputs["testResultsFiles"])
+			if results == "" {
+				results = "**/TEST-*.xml"
+			}
+			path := jenkinsfileGlobRoot(results)
+			if folder := gitlabString(inputs["searchFolder"]); folder != "" {
+				path = strings.TrimSuffix(t.translate(folder)+"/"+path, "/.")
+			}
+			steps = append(steps, map[string]interface{}{"store_test_results": map[string]interface{}{"path": path}})
+		case task == "PublishBuildArtifacts":
+			path := gitlabString(inputs["PathtoPublish"])
+			if path == "" {
+				path = "$(Build.ArtifactStagingDirectory)"
+			}
+			steps = append(steps, map[string]interface{}{"store_artifacts": map[string]interface{}{"path": t.translate(path)}})
+		case task == "Cache":
+			key := t.cacheKey(gitlabString(inputs["key"]))
+			path := t.translate(gitlabString(inputs["path"]))
+			steps = append(steps, map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{key}}})
+			saves = append(saves, map[string]interface{}{"save_cache": map[string]interface{}{"key": key, "paths": []interface{}{path}}})
+		case step["task"] != nil:
+			t.note("task %s is not converted", gitlabString(step["task"]))
+		case step["template"] != nil:
+			t.note("step template %s is not read", gitlabString(step["template"]))
+		}
+	}
+	return steps, saves, checkout
+}
+
+// executor translates the pool and container of a job: a container becomes a docker
+// executor, with the job's services; a hosted pool a machine of its VM image's system
+func (t *azureTranslation) executor(pool, container, services interface{}, containers map[string]string) map[string]interface{} {
+	image := func(value interface{}) string {
+		if body, ok := value.(map[string]interface{}); ok {
+			return gitlabString(body["image"])
+		}
+		if resource, ok := containers[gitlabString(value)]; ok {
+			return resource
+		}
+		return gitlabString(value)
+	}
+	if container != nil {
+		images := []interface{}{map[string]interface{}{"image": t.translate(image(container))}}
+		if body, ok := services.(map[string]interface{}); ok {
+			for _, name := range sortedKeys(body) {
+				images = append(images, map[string]interface{}{"image": t.translate(image(body[name])), "name": name})
+			}
+		}
+		return map[string]interface{}{"docker": images}
+	}
+	if services != nil {
+		t.note("the job's services run only for container jobs; start them locally before the task")
+	}
+	vmImage := azureDefaultImage
+	switch body := pool.(type) {
+	case map[string]interface{}:
+		if body["vmImage"] == nil {
+			return nil
+		}
+		vmImage = gitlabString(body["vmImage"])
+	case string:
+		// A self-hosted agent pool
+		return nil
+	}
+	return githubExecutor(strings.ToLower(vmImage))
+}
+
+// triggerFilters translates the CI trigger into workflow filters
+func (t *azureTranslation) triggerFilters(trigger interface{}) map[string]interface{} {
+	filters := make(map[string]interface{})
+	patterns := func(list interface{}) []interface{} {
+		var regexes []interface{}
+		for _, pattern := range toStringList(list) {
+			pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "refs/heads/"), "refs/tags/")
+			regexes = append(regexes, bitbucketGlobRegex(pattern))
+		}
+		return regexes
+	}
+	switch v := trigger.(type) {
+	case string:
+		if v == "none" {
+			t.note("the pipeline has no CI trigger; the converted workflow has no filter")
+		}
+	case []interface{}:
+		filters["branches"] = map[string]interface{}{"only": patterns(v)}
+	case map[string]interface{}:
+		branches, _ := v["branches"].(map[string]interface{})
+		if include := patterns(branches["include"]); len(include) > 0 {
+			filters["branches"] = map[string]interface{}{"only": include}
+		} else if exclude := patterns(branches["exclude"]); len(exclude) > 0 {
+			filters["branches"] = map[string]interface{}{"ignore": exclude}
+		}
+		tags, _ := v["tags"].(map[string]interface{})
+		if include := patterns(tags["include"]); len(include) > 0 {
+			filters["tags"] = map[string]interface{}{"only": include}
+		}
+		if v["paths"] != nil {
+			t.note("the trigger's `paths:` are not converted")
+		}
+	}
+	return filters
+}
+
+// parameters translates the template parameters of a pipeline into pipeline
+// parameters
+func (t *azureTranslation) parameters(value interface{}) map[string]interface{} {
+	parameters := make(map[string]interface{})
+	list, _ := value.([]interface{})
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		name := gitlabString(entry["name"])
+		if name == "" {
+			continue
+		}
+		kind := gitlabString(entry["type"])
+		parameter := map[string]interface{}{"default": entry["default"]}
+		switch kind {
+		case "", "string":
+			parameter["type"] = "string"
+			parameter["default"] = gitlabString(entry["default"])
+		case "boolean":
+			parameter["type"] = "boolean"
+		case "number":
+			parameter["type"] = "integer"
+		default:
+			t.note("the %s parameter %s is not converted", kind, name)
+			continue
+		}
+		if values := toStringList(entry["values"]); len(values) > 0 && parameter["type"] == "string" {
+			parameter["type"] = "enum"
+			parameter["enum"] = values
+		}
+		if parameter["default"] == nil {
+			delete(parameter, "default")
+		}
+		if description := gitlabString(entry["displayName"]); description != "" {
+			parameter["description"] = description
+		}
+		parameters[name] = parameter
+	}
+	return parameters
+}
+
+// azureDependsOn returns the ids a `dependsOn:` names, and whether it is set
+func azureDependsOn(value interface{}) ([]string, bool) {
+	if value == nil {
+		return nil, false
+	}
+	return toStringList(value), true
+}
+
+// parseAzurePipelines translates an Azure Pipelines config into a CircleCI config: one
+// workflow running its jobs (each matrix leg a job of its own) after the jobs they
+// depend on, or else after the jobs of the stages their stage depends on, filtered by
+// the CI trigger. Scripts, artifacts, test results and caches are translated, template
+// parameters become pipeline parameters and predefined variables their local
+// equivalent; what has no equivalent is recorded in the config's notes.
+func parseAzurePipelines(file string, data []byte) (CircleCIConfig, error) {
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	var root *yaml.Node
+	if len(original.Content) > 0 {
+		root = original.Content[0]
+	}
+	if doc["extends"] != nil {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the pipeline extends a template, which is not read", Hint: "convert the template's pipeline instead"}
+	}
+	jobs, templates := azureJobs(root)
+	if len(jobs) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the config has no jobs or steps", Hint: "is this an Azure Pipelines config? Pass -from circleci for CircleCI configs"}
+	}
+
+	var notes []ReportEntry
+	pt := &azureTranslation{notes: &notes, known: make(map[string]bool)}
+	for _, template := range templates {
+		pt.note("template %s is not read; its stages and jobs are not converted", template)
+	}
+	parameters := pt.parameters(doc["parameters"])
+	filters := pt.triggerFilters(doc["trigger"])
+	for _, key := range []string{"pr", "schedules"} {
+		if doc[key] != nil {
+			pt.note("`%s:` triggers are not converted", key)
+		}
+	}
+	containers := make(map[string]string)
+	resources, _ := doc["resources"].(map[string]interface{})
+	list, _ := resources["containers"].([]interface{})
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		containers[gitlabString(entry["container"])] = gitlabString(entry["image"])
+	}
+	pipelineEnv := make(map[string]interface{})
+	var pipelineExports []string
+	pt.variables(doc["variables"], pipelineEnv, &pipelineExports)
+
+	// The stages in order, what they depend on, and the names of their jobs
+	stages := make(map[string]map[string]interface{})
+	var stageOrder []string
+	stageList, _ := doc["stages"].([]interface{})
+	for _, item := range stageList {
+		if stage, ok := item.(map[string]interface{}); ok && stage["stage"] != nil {
+			stages[gitlabString(stage["stage"])] = stage
+			stageOrder = append(stageOrder, gitlabString(stage["stage"]))
+		}
+	}
+	jobNames := make(map[string][]string)
+	stageJobs := make(map[string][]string)
+	for _, job := range jobs {
+		legs, _ := azureMatrixLegs(job.node)
+		names := []string{job.name}
+		if len(legs) > 0 {
+			names = nil
+			for _, leg := range legs {
+				names = append(names, job.name+"-"+travisSlug(leg))
+			}
+		}
+		jobNames[job.stage+"/"+job.id] = names
+		stageJobs[job.stage] = append(stageJobs[job.stage], names...)
+	}
+
+	defs := make(map[string]interface{})
+	var invocations []interface{}
+	for _, job := range jobs {
+		t := &azureTranslation{job: job.name, notes: &notes, known: make(map[string]bool)}
+		for name := range pt.known {
+			t.known[name] = true
+		}
+		var body map[string]interface{}
+		if err := job.node.Decode(&body); err != nil {
+			return CircleCIConfig{}, &ParseError{File: file, Line: job.node.Line, Column: job.node.Column, Message: err.Error()}
+		}
+		stage := stages[job.stage]
+
+		env := make(map[string]interface{})
+		for key, value := range pipelineEnv {
+			env[key] = value
+		}
+		exports := append([]string{}, pipelineExports...)
+		if job.node != root {
+			t.variables(stage["variables"], env, &exports)
+			t.variables(body["variables"], env, &exports)
+		}
+
+		pool, container := doc["pool"], doc["container"]
+		if stage["pool"] != nil {
+			pool = stage["pool"]
+		}
+		if body["pool"] != nil {
+			pool = body["pool"]
+		}
+		if job.node != root && body["container"] != nil {
+			container = body["container"]
+		}
+		// Deployment jobs do not check the code out unless a step does
+		deployment := body["deployment"] != nil
+		if deployment {
+			if environment := gitlabString(body["environment"]); environment != "" {
+				t.note("the deployment job deploys to the Azure environment %s; set its variables in the environment (or .env) to run the task locally", environment)
+			}
+			strategy, _ := body["strategy"].(map[string]interface{})
+			if strategy["rolling"] != nil || strategy["canary"] != nil {
+				t.note("the rolling or canary strategy runs its hooks once, on the host")
+			}
+		}
+		var steps, saves []interface{}
+		checkout := deployment
+		lists, whens := azureStepLists(job.node)
+		for i, list := range lists {
+			var items []interface{}
+			list.Decode(&items)
+			listSteps, listSaves, listCheckout := t.steps(items, whens[i])
+			steps = append(steps, listSteps...)
+			saves = append(saves, listSaves...)
+			checkout = checkout || listCheckout
+		}
+		if !checkout {
+			steps = append([]interface{}{"checkout"}, steps...)
+		}
+		steps = append(steps, saves...)
+		if len(exports) > 0 {
+			var lines []string
+			for _, export := range exports {
+				lines = append(lines, "echo "+shellQuote(export)+` >> "$BASH_ENV"`)
+			}
+			steps = append([]interface{}{map[string]interface{}{"run": map[string]interface{}{"name": "variables", "command": strings.Join(lines, "\n")}}}, steps...)
+		}
+		for _, key := range []string{"condition", "timeoutInMinutes", "continueOnError"} {
+			if body[key] != nil || stage[key] != nil {
+				t.note("the job's `%s:` is not converted", key)
+			}
+		}
+
+		// The jobs it depends on, or the jobs of the stages its stage depends on
+		var requires []string
+		if ids, ok := azureDependsOn(body["dependsOn"]); ok {
+			for _, id := range ids {
+				requires = append(requires, jobNames[job.stage+"/"+id]...)
+			}
+		} else if job.stage != "" {
+			ids, ok := azureDependsOn(stage["dependsOn"])
+			if !ok {
+				for i, id := range stageOrder {
+					if id == job.stage && i > 0 {
+						ids = []string{stageOrder[i-1]}
+					}
+				}
+			}
+			for _, id := range ids {
+				requires = append(requires, stageJobs[id]...)
+			}
+		}
+
+		legs, legVariables := azureMatrixLegs(job.node)
+		if len(legs) == 0 {
+			legs = []string{""}
+		}
+		for _, leg := range legs {
+			name := job.name
+			def := map[string]interface{}{"steps": steps}
+			legEnv := env
+			legPool := pool
+			if leg != "" {
+				name = job.name + "-" + travisSlug(leg)
+				legEnv = make(map[string]interface{})
+				for key, value := range env {
+					legEnv[key] = value
+				}
+				for key, value := range legVariables[leg] {
+					legEnv[azureEnvName(key)] = t.translate(gitlabString(value))
+				}
+				// Legs often pick their VM image with a variable
+				if body, ok := pool.(map[string]interface{}); ok {
+					vmImage := azureMacroRegex.ReplaceAllStringFunc(gitlabString(body["vmImage"]), func(ref string) string {
+						if value, ok := legVariables[leg][azureMacroRegex.FindStringSubmatch(ref)[1]]; ok {
+							return gitlabString(value)
+						}
+						return ref
+					})
+					legPool = map[string]interface{}{"vmImage": vmImage}
+				}
+			}
+			for key, value := range t.executor(legPool, container, body["services"], containers) {
+				def[key] = value
+			}
+			if len(legEnv) > 0 {
+				def["environment"] = legEnv
+			}
+			if strategy, ok := body["strategy"].(map[string]interface{}); ok && !deployment {
+				if parallel, ok := strategy["parallel"].(int); ok && parallel > 1 {
+					def["parallelism"] = parallel
+				}
+			}
+			defs[name] = def
+
+			invocation := make(map[string]interface{})
+			if len(requires) > 0 {
+				invocation["requires"] = requires
+			}
+			if len(filters) > 0 {
+				invocation["filters"] = filters
+			}
+			invocations = append(invocations, map[string]interface{}{name: invocation})
+		}
+	}
+
+	circle := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      defs,
+		"workflows": map[string]interface{}{"pipeline": map[string]interface{}{"jobs": invocations}},
+	}
+	if len(parameters) > 0 {
+		circle["parameters"] = parameters
+	}
+	translated, err := yaml.Marshal(circle)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	converted, err := parseConfig(file, translated)
+	if err != nil {
+		return converted, err
+	}
+	converted.format = fromAzure
+	converted.original = &original
+	converted.notes = notes
+	converted.source = nil
+	return converted, nil
+}
+
+// azurePruneHooks removes the lifecycle hooks of a deployment job's strategy left
+// without steps by the slim config
+func azurePruneHooks(job *yaml.Node) {
+	strategy := resolveAlias(mappingValue(job, "strategy"))
+	empty := func(hook *yaml.Node) bool {
+		steps := resolveAlias(mappingValue(resolveAlias(hook), "steps"))
+		return steps != nil && len(steps.Content) == 0
+	}
+	for _, kind := range []string{"runOnce", "rolling", "canary"} {
+		hooks := resolveAlias(mappingValue(strategy, kind))
+		if hooks == nil {
+			continue
+		}
+		for _, hook := range []string{"preDeploy", "routeTraffic", "postRouteTraffic"} {
+			if empty(mappingValue(hooks, hook)) {
+				gitlabDeleteKeys(hooks, hook)
+			}
+		}
+		if on := resolveAlias(mappingValue(hooks, "on")); on != nil {
+			for _, outcome := range []string{"success", "failure"} {
+				if empty(mappingValue(on, outcome)) {
+					gitlabDeleteKeys(on, outcome)
+				}
+			}
+			if len(on.Content) == 0 {
+				gitlabDeleteKeys(hooks, "on")
+			}
+		}
+	}
+}
+
+// writeAzurePipelines writes the slim config: the input config with the script steps of
+// each job replaced by one step calling the job's task (or script, for the script
+// targets), passing the parameters it uses; the other steps stay. Matrix legs get a
+// TASK_NAME variable naming their task.
+func writeAzurePipelines(path string, config CircleCIConfig, target string) error {
+	jobs, _ := azureJobs(config.original.Content[0])
+	for _, job := range jobs {
+		name := job.name
+		legs, _ := azureMatrixLegs(job.node)
+		if len(legs) > 0 {
+			matrix := resolveAlias(mappingValue(resolveAlias(mappingValue(job.node, "strategy")), "matrix"))
+			for i := 0; i+1 < len(matrix.Content); i += 2 {
+				leg := resolveAlias(matrix.Content[i+1])
+				value := name + "-" + travisSlug(matrix.Content[i].Value)
+				if scriptTarget(target) {
+					value = scriptFile(value)
+				}
+				leg.Content = append(leg.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "TASK_NAME"}, &yaml.Node{Kind: yaml.ScalarNode, Value: value})
+			}
+			name += "-" + travisSlug(legs[0])
+		}
+
+		call := "task " + job.name
+		if len(legs) > 0 {
+			call = "task $(TASK_NAME)"
+		}
+		if scriptTarget(target) {
+			call = "./" + scriptFile(job.name)
+			if len(legs) > 0 {
+				call = "./$(TASK_NAME)"
+			}
+		}
+		var args []string
+		for _, ref := range pipelineRefs(config.Jobs[name]) {
+			if variable := strings.TrimPrefix(ref, "pipeline.parameters."); variable != ref {
+				args = append(args, fmt.Sprintf(`%s="${{ parameters.%s }}"`, taskVarName(variable), variable))
+			}
+		}
+		if len(args) > 0 {
+			call += " " + strings.Join(args, " ")
+		}
+
+		var replacement yaml.Node
+		if err := replacement.Encode(map[string]string{"script": call, "displayName": job.id}); err != nil {
+			return err
+		}
+		lists, _ := azureStepLists(job.node)
+		for _, list := range lists {
+			var kept []*yaml.Node
+			for _, item := range list.Content {
+				var step map[string]interface{}
+				resolveAlias(item).Decode(&step)
+				if !azureScriptStep(step) {
+					kept = append(kept, item)
+				} else if call != "" {
+					kept = append(kept, &replacement)
+					call = ""
+				}
+			}
+			list.Content = kept
+		}
+		azurePruneHooks(job.node)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.original); err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
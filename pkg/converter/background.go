@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// backgroundDir holds the output and pid files of background steps
+const backgroundDir = "{{.ROOT_DIR}}/.task/background"
+
+// isBackgroundStep reports whether a step is a run step with `background: true`
+func isBackgroundStep(step Step) bool {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	run, ok := stepMap["run"].(map[string]interface{})
+	return ok && run["background"] == true
+}
+
+// backgroundFiles names the output and pid files of a background step of a job or
+// command, after the owner and the step's command
+func backgroundFiles(owner string, step Step) (string, string) {
+	name := fmt.Sprintf("%s/%s-%s", backgroundDir, strings.ReplaceAll(owner, "/", "-"), hashValue(extractCommand(step))[:8])
+	return name + ".log", name + ".pid"
+}
+
+// backgroundStart starts the converted command of a background step in a shell of its
+// own, so the task goes on with its next command, and records its pid
+func backgroundStart(owner string, step Step, cmd string) string {
+	output, pid := backgroundFiles(owner, step)
+	script := fmt.Sprintf(`(%s) < /dev/null > "%s" 2>&1 & echo $! > "%s"`, strings.TrimRight(cmd, "\n"), output, pid)
+	return fmt.Sprintf(`mkdir -p "%s" && bash -c %s && echo "Started in the background, output in %s"`, backgroundDir, shellQuote(script), output)
+}
+
+// backgroundStop stops the process of a background step (and its children), if it runs
+func backgroundStop(owner string, step Step) string {
+	_, pid := backgroundFiles(owner, step)
+	return fmt.Sprintf(`if [ -f "%s" ]; then pid=$(cat "%s"); pkill -P "$pid" 2>/dev/null; kill "$pid" 2>/dev/null; rm -f "%s"; fi; true`, pid, pid, pid)
+}
+
+// backgroundCommands converts a background step of a job: the command is started, and
+// stopped when the task ends as CircleCI does at the end of the job. Steps of commands
+// are only started; the jobs invoking the command stop them (see commandBackgroundStops).
+func backgroundCommands(owner string, step Step, cmd string, commands map[string]Command) TaskCmds {
+	cmds := shellCommands(backgroundStart(owner, step, cmd))
+	if !isDefinedCommand(owner, commands) {
+		cmds = append(cmds, deferredCommand(backgroundStop(owner, step)))
+	}
+	return cmds
+}
+
+// commandBackgroundStops returns the deferred commands stopping the background steps of
+// the commands a job invokes, directly or through other commands
+func commandBackgroundStops(job Job, commands map[string]Command) TaskCmds {
+	found := make(map[string]Command)
+	referencedCommands(job.Steps, commands, found)
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var stops TaskCmds
+	for _, name := range names {
+		for _, step := range backgroundSteps(found[name].Steps) {
+			stops = append(stops, deferredCommand(backgroundStop(name, step)))
+		}
+	}
+	return stops
+}
+
+// backgroundSteps lists the background steps among steps, including those nested in
+// when/unless steps
+func backgroundSteps(steps []Step) []Step {
+	var found []Step
+	for _, step := range steps {
+		if isBackgroundStep(step) {
+			found = append(found, step)
+			continue
+		}
+		if kind := stepType(step); kind == "when" || kind == "unless" {
+			if body, ok := step.(map[string]interface{})[kind].(map[string]interface{}); ok {
+				nested, _ := body["steps"].([]interface{})
+				for _, s := range nested {
+					found = append(found, backgroundSteps([]Step{s})...)
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,115 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bashEnvRegex matches references to $BASH_ENV
+var bashEnvRegex = regexp.MustCompile(`\$\{?BASH_ENV\b`)
+
+// bashEnvExportRegex matches the variables a command appends to $BASH_ENV
+// (echo 'export FOO=bar' >> $BASH_ENV)
+var bashEnvExportRegex = regexp.MustCompile(`export\s+([A-Za-z_][A-Za-z0-9_]*)=[^\n]*>>\s*"?\$\{?BASH_ENV\b`)
+
+// bashEnvFile is the local BASH_ENV file of a task, used when BASH_ENV is not set
+func bashEnvFile(taskName string) string {
+	return fmt.Sprintf("{{.ROOT_DIR}}/.task/bash_env/%s.sh", strings.NewReplacer("/", "-", ":", "-").Replace(taskName))
+}
+
+// bashEnvSource sources BASH_ENV before a command, as bash does at the start of each
+// CircleCI step. On CircleCI BASH_ENV is set and its file is used; locally the task's
+// file is, and the nested `task` calls of a job inherit it.
+func bashEnvSource(taskName string) string {
+	return fmt.Sprintf(`export BASH_ENV="${BASH_ENV:-%s}"; mkdir -p "${BASH_ENV%%/*}" && touch "$BASH_ENV" && . "$BASH_ENV"`, bashEnvFile(taskName))
+}
+
+// bashEnvExports lists the variables the config's steps append to $BASH_ENV
+func bashEnvExports(config CircleCIConfig) []string {
+	seen := make(map[string]bool)
+	var names []string
+	collect := func(steps []Step) {
+		for _, step := range steps {
+			for _, match := range bashEnvExportRegex.FindAllStringSubmatch(extractCommand(step), -1) {
+				if !seen[match[1]] {
+					seen[match[1]] = true
+					names = append(names, match[1])
+				}
+			}
+		}
+	}
+	for _, job := range config.Jobs {
+		collect(job.Steps)
+	}
+	for _, command := range config.Commands {
+		collect(command.Steps)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyBashEnv passes variables between the commands of tasks using $BASH_ENV: each
+// command sources the file first. Job tasks start from an empty file, and jobs
+// invoking commands that use it share theirs with the commands' tasks.
+func applyBashEnv(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	using := make(map[string]bool)
+	for name, task := range taskfile.Tasks {
+		for _, cmd := range task.Cmds {
+			if bashEnvRegex.MatchString(cmd.Cmd) {
+				using[name] = true
+			}
+		}
+	}
+	if len(using) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(taskfile.Tasks))
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		job, isJob := config.Jobs[name]
+		if !isJob {
+			continue
+		}
+		commands := make(map[string]Command)
+		referencedCommands(job.Steps, config.Commands, commands)
+		for command := range commands {
+			if !using[command] {
+				continue
+			}
+			using[name] = true
+			if containsString(taskfile.Tasks[name].Deps, command) {
+				report.Add("BASH_ENV", name, "command %s runs as a dependency, so the variables it exports to $BASH_ENV do not reach the job's commands", command)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if !using[name] {
+			continue
+		}
+		task := taskfile.Tasks[name]
+		source := bashEnvSource(name)
+		var cmds TaskCmds
+		if _, isJob := config.Jobs[name]; isJob {
+			cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf(`rm -f "%s"`, bashEnvFile(name))})
+		}
+		for _, cmd := range task.Cmds {
+			switch {
+			case strings.HasPrefix(cmd.Cmd, "#"):
+				cmds = append(cmds, cmd)
+			default:
+				cmds = append(cmds, cmd.withCmd(source+"\n"+cmd.Cmd))
+			}
+		}
+		task.Cmds = cmds
+		taskfile.Tasks[name] = task
+		report.Add("BASH_ENV", name, "commands source $BASH_ENV first; locally it is %s", strings.TrimPrefix(bashEnvFile(name), "{{.ROOT_DIR}}/"))
+	}
+}
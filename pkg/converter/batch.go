@@ -0,0 +1,197 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fleetReportName is the combined report of a batch, written to the output root
+const fleetReportName = "FLEET_REPORT.md"
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// readManifest lists the configs of a batch manifest: one path per line, relative to
+// the manifest, with blank lines and # comments ignored
+func readManifest(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var inputs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		inputs = append(inputs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%s lists no configs", path)
+	}
+	return inputs, nil
+}
+
+// FleetEntry is the outcome of one config of a batch
+type FleetEntry struct {
+	Project    string         `json:"project"`
+	Input      string         `json:"input"`
+	Output     string         `json:"output"`
+	Error      string         `json:"error,omitempty"`
+	Jobs       int            `json:"jobs"`
+	Tasks      int            `json:"tasks"`
+	Categories map[string]int `json:"categories,omitempty"` // report entries per category
+}
+
+// runBatch converts several configs, each into <output>/<project>, and writes a
+// combined FLEET_REPORT.md. Configs that fail are recorded and the others still convert.
+func runBatch(inputs []string, outputDir string, settings conversionSettings) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	var fleet []FleetEntry
+	used := make(map[string]int)
+	failed := 0
+	for _, input := range inputs {
+		// Configs of projects with the same name get numbered directories
+		project := projectName(input)
+		used[project]++
+		dirName := project
+		if used[project] > 1 {
+			dirName = fmt.Sprintf("%s-%d", project, used[project])
+		}
+		entry := FleetEntry{Project: dirName, Input: input, Output: filepath.Join(outputDir, dirName)}
+
+		result, err := convertBatchEntry(input, project, entry.Output, settings)
+		if err != nil {
+			failed++
+			entry.Error = err.Error()
+			logger.Error("conversion failed", "input", input, "error", err)
+			fmt.Printf("❌ %s: %s\n", dirName, firstLine(err.Error()))
+			fleet = append(fleet, entry)
+			continue
+		}
+
+		entry.Jobs, entry.Tasks = result.Jobs, result.Tasks
+		entry.Categories = make(map[string]int)
+		for _, note := range result.Report.Entries {
+			entry.Categories[note.Category]++
+		}
+		for _, warning := range result.Warnings {
+			fmt.Printf("⚠️  %s: %s\n", dirName, warning)
+		}
+		fmt.Printf("✅ %s: %d jobs → %s\n", dirName, result.Jobs, entry.Output)
+		fleet = append(fleet, entry)
+	}
+
+	reportPath := filepath.Join(outputDir, fleetReportName)
+	if err := writeTextFile(reportPath, fleetReport(fleet)); err != nil {
+		return err
+	}
+	if settings.EmitJSON {
+		if err := writeJSONFile(filepath.Join(outputDir, "FLEET_REPORT.json"), fleet); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("\n📋 Converted %d of %d configs; fleet report: %s\n", len(fleet)-failed, len(fleet), reportPath)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d configs failed to convert; see %s", failed, len(fleet), reportPath)
+	}
+	return nil
+}
+
+// convertBatchEntry reads, parses and converts one config of a batch
+func convertBatchEntry(input, project, outputDir string, settings conversionSettings) (conversionResult, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return conversionResult{}, fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseInput(input, data, settings.From)
+	if err != nil {
+		return conversionResult{}, err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return conversionResult{}, fmt.Errorf("error creating output directory: %w", err)
+	}
+	return convertInput(input, project, data, config, outputDir, settings)
+}
+
+// fleetReport renders FLEET_REPORT.md: one row per config, the report categories
+// across the fleet (what needs review where), and the failures
+func fleetReport(fleet []FleetEntry) string {
+	var b strings.Builder
+	b.WriteString("# Fleet Conversion Report\n\n")
+	b.WriteString(fmt.Sprintf("Converted with circle-to-task %s. Each project has its own CONVERSION_REPORT.md with the details.\n\n", Version))
+
+	totals := make(map[string]int)
+	projects := make(map[string][]string)
+	b.WriteString("| Project | Input | Status | Jobs | Tasks | Notes |\n")
+	b.WriteString("|---------|-------|--------|------|-------|-------|\n")
+	for _, entry := range fleet {
+		status := "✅ converted"
+		if entry.Error != "" {
+			status = "❌ failed"
+		}
+		notes := 0
+		for category, count := range entry.Categories {
+			notes += count
+			totals[category] += count
+			projects[category] = append(projects[category], entry.Project)
+		}
+		b.WriteString(fmt.Sprintf("| %s | `%s` | %s | %d | %d | %d |\n", entry.Project, entry.Input, status, entry.Jobs, entry.Tasks, notes))
+	}
+
+	if len(totals) > 0 {
+		categories := make([]string, 0, len(totals))
+		for category := range totals {
+			categories = append(categories, category)
+		}
+		sort.Strings(categories)
+		b.WriteString("\n## Notes by Category\n\n")
+		b.WriteString("| Category | Notes | Projects |\n")
+		b.WriteString("|----------|-------|----------|\n")
+		for _, category := range categories {
+			names := projects[category]
+			sort.Strings(names)
+			b.WriteString(fmt.Sprintf("| %s | %d | %s |\n", category, totals[category], strings.Join(names, ", ")))
+		}
+	}
+
+	var failures []FleetEntry
+	for _, entry := range fleet {
+		if entry.Error != "" {
+			failures = append(failures, entry)
+		}
+	}
+	if len(failures) > 0 {
+		b.WriteString("\n## Failures\n")
+		for _, entry := range failures {
+			b.WriteString(fmt.Sprintf("\n### %s\n\n```\n%s\n```\n", entry.Project, strings.TrimRight(entry.Error, "\n")))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package converter
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BenchPhase is the measured cost of one conversion phase
+type BenchPhase struct {
+	Name   string
+	Time   time.Duration // per iteration
+	Bytes  uint64        // allocated per iteration
+	Allocs uint64        // allocations per iteration
+}
+
+// generateSyntheticConfig builds a config with the given number of jobs, steps per job
+// and unique shell commands. Every fifth step calls a parameterized command, and the
+// workflow chains jobs in groups of five so the graph has both depth and fan-out.
+func generateSyntheticConfig(jobs, steps, uniqueCommands int) []byte {
+	var b strings.Builder
+
+	b.WriteString("version: 2.1\n\nexecutors:\n  default:\n    docker:\n      - image: cimg/go:1.22\n\n")
+	b.WriteString("commands:\n  setup:\n    parameters:\n      target:\n        type: string\n        default: all\n")
+	b.WriteString("    steps:\n      - run: make deps TARGET=<< parameters.target >>\n\njobs:\n")
+
+	command := 0
+	for j := 0; j < jobs; j++ {
+		b.WriteString(fmt.Sprintf("  job-%d:\n    executor: default\n    environment:\n      JOB_INDEX: \"%d\"\n    steps:\n      - checkout\n", j, j))
+		for s := 0; s < steps; s++ {
+			if s%5 == 4 {
+				b.WriteString(fmt.Sprintf("      - setup:\n          target: job-%d\n", j))
+				continue
+			}
+			b.WriteString(fmt.Sprintf("      - run:\n          name: Step %d\n          command: go test ./pkg/mod%d/... -run Test%d\n", s, command%uniqueCommands, command%uniqueCommands))
+			command++
+		}
+	}
+
+	b.WriteString("\nworkflows:\n  main:\n    jobs:\n")
+	for j := 0; j < jobs; j++ {
+		if j%5 == 0 {
+			b.WriteString(fmt.Sprintf("      - job-%d\n", j))
+		} else {
+			b.WriteString(fmt.Sprintf("      - job-%d:\n          requires: [job-%d]\n", j, j-j%5))
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// measurePhase runs fn iterations times and returns its average time and allocations
+func measurePhase(name string, iterations int, fn func() error) (BenchPhase, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for i := 0; i < iterations; i++ {
+		if err := fn(); err != nil {
+			return BenchPhase{}, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	n := uint64(iterations)
+	return BenchPhase{
+		Name:   name,
+		Time:   elapsed / time.Duration(iterations),
+		Bytes:  (after.TotalAlloc - before.TotalAlloc) / n,
+		Allocs: (after.Mallocs - before.Mallocs) / n,
+	}, nil
+}
+
+// runBenchCommand implements the `bench` subcommand
+func runBenchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	jobs := fs.Int("jobs", 100, "Number of jobs in the synthetic config")
+	steps := fs.Int("steps", 20, "Steps per job")
+	uniqueCommands := fs.Int("commands", 50, "Number of unique shell commands across all steps")
+	iterations := fs.Int("iterations", 5, "Iterations per phase; results are averaged")
+	save := fs.String("save", "", "Also write the synthetic config to this file, to attach to bug reports")
+	fs.Parse(args)
+
+	if *jobs < 1 || *steps < 1 || *uniqueCommands < 1 || *iterations < 1 {
+		return fmt.Errorf("-jobs, -steps, -commands and -iterations must be at least 1")
+	}
+
+	data := generateSyntheticConfig(*jobs, *steps, *uniqueCommands)
+	if *save != "" {
+		if err := os.WriteFile(*save, data, 0644); err != nil {
+			return fmt.Errorf("error writing synthetic config: %w", err)
+		}
+	}
+
+	outputDir, err := os.MkdirTemp("", "circle-to-task-bench-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	// Each phase works from the previous phase's result, computed once up front
+	config, err := parseConfig("synthetic.yml", data)
+	if err != nil {
+		return fmt.Errorf("synthetic config does not parse: %w", err)
+	}
+	opts := ConvertOptions{Orbs: NewOrbResolver("", true, "", "")}
+	newConfig, taskfile := convertConfig(config, opts, &ConversionReport{})
+
+	phases := []struct {
+		name string
+		fn   func() error
+	}{
+		{"parse", func() error {
+			_, err := parseConfig("synthetic.yml", data)
+			return err
+		}},
+		{"audit secrets", func() error {
+			auditSecrets(config, &ConversionReport{})
+			return nil
+		}},
+		{"analyze patterns", func() error {
+			analyzePatterns(config)
+			return nil
+		}},
+		{"convert (total)", func() error {
+			convertConfig(config, opts, &ConversionReport{})
+			return nil
+		}},
+		{"render Taskfile", func() error {
+			_, err := yaml.Marshal(taskfile)
+			return err
+		}},
+		{"render config", func() error {
+			return writeConfigFile(filepath.Join(outputDir, "config.yml"), newConfig, config.source)
+		}},
+		{"technology analysis", func() error {
+			return generateTechnologyAnalysis(config, outputDir)
+		}},
+		{"tool inventory", func() error {
+			buildToolInventory(config, taskfile)
+			return nil
+		}},
+	}
+
+	fmt.Printf("circle-to-task %s bench (%s/%s, %d CPUs)\n", Version, runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	fmt.Printf("Synthetic config: %d jobs × %d steps, %d unique commands, %d KB; %d iterations per phase\n\n",
+		*jobs, *steps, *uniqueCommands, len(data)/1024, *iterations)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "phase\ttime/op\tjobs/s\tMB/op\tallocs/op\t")
+	for _, phase := range phases {
+		result, err := measurePhase(phase.name, *iterations, phase.fn)
+		if err != nil {
+			return err
+		}
+		jobsPerSecond := float64(*jobs) / result.Time.Seconds()
+		fmt.Fprintf(w, "%s\t%s\t%.0f\t%.2f\t%d\t\n", result.Name, result.Time.Round(time.Microsecond), jobsPerSecond, float64(result.Bytes)/(1024*1024), result.Allocs)
+	}
+	w.Flush()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	fmt.Printf("\nHeap obtained from the OS: %.1f MB\n", float64(mem.HeapSys)/(1024*1024))
+	return nil
+}
@@ -0,0 +1,643 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromBitbucket reads Bitbucket Pipelines configs (bitbucket-pipelines.yml)
+const fromBitbucket = "bitbucket"
+
+// bitbucketDefaultImage is the image of steps when the config names none
+const bitbucketDefaultImage = "atlassian/default-image:4"
+
+// bitbucketPipeDir is where pipes see the clone
+const bitbucketPipeDir = "/opt/atlassian/pipelines/agent/build"
+
+// bitbucketCaches are the paths of the predefined caches; the docker cache has none
+var bitbucketCaches = map[string]string{
+	"node":       "node_modules",
+	"pip":        "~/.cache/pip",
+	"composer":   "~/.composer/cache",
+	"maven":      "~/.m2/repository",
+	"gradle":     "~/.gradle/caches",
+	"dotnetcore": "~/.nuget/packages",
+	"sbt":        "~/.sbt",
+	"ivy2":       "~/.ivy2/cache",
+}
+
+// bitbucketPipelineValues are the Bitbucket variables with a pipeline value
+// equivalent, which the Taskfile computes from git
+var bitbucketPipelineValues = map[string]string{
+	"BITBUCKET_COMMIT":        "pipeline.git.revision",
+	"BITBUCKET_BRANCH":        "pipeline.git.branch",
+	"BITBUCKET_TAG":           "pipeline.git.tag",
+	"BITBUCKET_BUILD_NUMBER":  "pipeline.number",
+	"BITBUCKET_PIPELINE_UUID": "pipeline.id",
+}
+
+// bitbucketVarRegex matches the references to BITBUCKET_ variables
+var bitbucketVarRegex = regexp.MustCompile(`\$(?:\{(BITBUCKET_[A-Za-z0-9_]+)\}|(BITBUCKET_[A-Za-z0-9_]+))`)
+
+// bitbucketConfig is the part of a bitbucket-pipelines.yml the steps share
+type bitbucketConfig struct {
+	Image       interface{} `yaml:"image"`
+	Definitions struct {
+		Caches   map[string]interface{} `yaml:"caches"`
+		Services map[string]struct {
+			Image     interface{}            `yaml:"image"`
+			Variables map[string]interface{} `yaml:"variables"`
+		} `yaml:"services"`
+	} `yaml:"definitions"`
+	Options struct {
+		Docker bool `yaml:"docker"`
+	} `yaml:"options"`
+	Clone struct {
+		Enabled *bool `yaml:"enabled"`
+	} `yaml:"clone"`
+}
+
+// bitbucketStep is a step of a pipeline
+type bitbucketStep struct {
+	Name        string        `yaml:"name"`
+	Image       interface{}   `yaml:"image"`
+	Script      []interface{} `yaml:"script"`
+	AfterScript []interface{} `yaml:"after-script"`
+	Caches      []string      `yaml:"caches"`
+	Artifacts   interface{}   `yaml:"artifacts"`
+	Services    []string      `yaml:"services"`
+	Deployment  string        `yaml:"deployment"`
+	Trigger     string        `yaml:"trigger"`
+	Condition   interface{}   `yaml:"condition"`
+	Clone       struct {
+		Enabled *bool `yaml:"enabled"`
+	} `yaml:"clone"`
+}
+
+// bitbucketGroup is what a pipeline runs at once: a step, or the steps of a parallel
+// group; manual groups wait to be started
+type bitbucketGroup struct {
+	steps  []*yaml.Node
+	manual bool
+}
+
+// bitbucketPipeline is a pipeline of a config, its steps grouped as they run
+type bitbucketPipeline struct {
+	name      string // the workflow's name
+	kind      string // default, branches, tags, pull-requests or custom
+	pattern   string // the branch, tag or pull request glob
+	groups    []bitbucketGroup
+	variables *yaml.Node // the variables a custom pipeline asks for
+	notes     []string
+}
+
+// bitbucketPipelines walks the `pipelines:` of a config in order and names the steps:
+// after their `name:`, else the pipeline, each step node (shared through anchors)
+// once
+func bitbucketPipelines(root *yaml.Node) ([]bitbucketPipeline, map[*yaml.Node]string) {
+	names := make(map[*yaml.Node]string)
+	used := make(map[string]int)
+	name := func(step *yaml.Node, pipeline string) {
+		if _, ok := names[step]; ok {
+			return
+		}
+		base := ""
+		if value := mappingValue(step, "name"); value != nil {
+			base = travisSlug(value.Value)
+		}
+		if base == "" {
+			base = pipeline + "-step"
+		}
+		used[base]++
+		if used[base] > 1 {
+			base = fmt.Sprintf("%s-%d", base, used[base])
+		}
+		names[step] = base
+	}
+
+	var pipelines []bitbucketPipeline
+	walk := func(pipeline *bitbucketPipeline, items *yaml.Node) {
+		var add func(items *yaml.Node, manual bool)
+		add = func(items *yaml.Node, manual bool) {
+			items = resolveAlias(items)
+			if items == nil || items.Kind != yaml.SequenceNode {
+				return
+			}
+			for _, item := range items.Content {
+				item = resolveAlias(item)
+				if step := resolveAlias(mappingValue(item, "step")); step != nil {
+					trigger := mappingValue(step, "trigger")
+					name(step, pipeline.name)
+					pipeline.groups = append(pipeline.groups, bitbucketGroup{steps: []*yaml.Node{step}, manual: manual || (trigger != nil && trigger.Value == "manual")})
+					manual = false
+				} else if parallel := resolveAlias(mappingValue(item, "parallel")); parallel != nil {
+					if steps := mappingValue(parallel, "steps"); steps != nil {
+						parallel = resolveAlias(steps)
+					}
+					group := bitbucketGroup{manual: manual}
+					for _, entry := range parallel.Content {
+						if step := resolveAlias(mappingValue(resolveAlias(entry), "step")); step != nil {
+							name(step, pipeline.name)
+							group.steps = append(group.steps, step)
+						}
+					}
+					pipeline.groups = append(pipeline.groups, group)
+					manual = false
+				} else if stage := resolveAlias(mappingValue(item, "stage")); stage != nil {
+					trigger := mappingValue(stage, "trigger")
+					for _, key := range []string{"deployment", "condition"} {
+						if mappingValue(stage, key) != nil {
+							pipeline.notes = append(pipeline.notes, fmt.Sprintf("a stage sets `%s:`, which is not converted", key))
+						}
+					}
+					add(mappingValue(stage, "steps"), trigger != nil && trigger.Value == "manual")
+				} else if variables := mappingValue(item, "variables"); variables != nil {
+					pipeline.variables = resolveAlias(variables)
+				}
+			}
+		}
+		add(items, false)
+	}
+
+	section := resolveAlias(mappingValue(root, "pipelines"))
+	for i := 0; section != nil && i+1 < len(section.Content); i += 2 {
+		kind, body := section.Content[i].Value, resolveAlias(section.Content[i+1])
+		if kind == "default" {
+			pipeline := bitbucketPipeline{name: "default", kind: kind}
+			walk(&pipeline, body)
+			pipelines = append(pipelines, pipeline)
+			continue
+		}
+		prefix := map[string]string{"branches": "branch", "tags": "tag", "pull-requests": "pull-request", "custom": "custom"}[kind]
+		if prefix == "" || body == nil || body.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(body.Content); j += 2 {
+			pattern := body.Content[j].Value
+			slug := travisSlug(pattern)
+			if slug == "" {
+				slug = "all"
+			}
+			pipeline := bitbucketPipeline{name: prefix + "-" + slug, kind: kind, pattern: pattern}
+			walk(&pipeline, body.Content[j+1])
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+	return pipelines, names
+}
+
+// bitbucketGlobRegex turns a branch or tag glob into a CircleCI filter regex
+func bitbucketGlobRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("/^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString(".")
+		case c == '{':
+			b.WriteString("(")
+		case c == '}':
+			b.WriteString(")")
+		case c == ',':
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$/")
+	return b.String()
+}
+
+// bitbucketTranslation translates the variable references of a step and collects
+// notes for the report
+type bitbucketTranslation struct {
+	job   string
+	notes *[]ReportEntry
+}
+
+// note records a translation note, once
+func (t *bitbucketTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "Bitbucket Pipelines", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// translate replaces the Bitbucket variables with a pipeline value by it; the others
+// are kept, and reported as needing a value outside Bitbucket
+func (t *bitbucketTranslation) translate(text string) string {
+	return bitbucketVarRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := bitbucketVarRegex.FindStringSubmatch(ref)
+		name := match[1] + match[2]
+		if value, ok := bitbucketPipelineValues[name]; ok {
+			return "<< " + value + " >>"
+		}
+		if name == "BITBUCKET_CLONE_DIR" {
+			return "${BITBUCKET_CLONE_DIR:-$PWD}"
+		}
+		t.note("`$%s` is set by Bitbucket; set it in the environment (or .env) to run the task locally", name)
+		return ref
+	})
+}
+
+// pipe translates a pipe into the docker run of its image, with its variables and the
+// clone mounted where pipes expect it
+func (t *bitbucketTranslation) pipe(entry map[string]interface{}) string {
+	pipe := gitlabString(entry["pipe"])
+	image := strings.TrimPrefix(pipe, "docker://")
+	if image == pipe && strings.HasPrefix(pipe, "atlassian/") {
+		image = "bitbucketpipelines/" + strings.TrimPrefix(pipe, "atlassian/")
+	}
+	args := []string{"docker run --rm", fmt.Sprintf(`-v "$PWD":%s -w %s`, bitbucketPipeDir, bitbucketPipeDir), "-e BITBUCKET_CLONE_DIR=" + bitbucketPipeDir}
+	variables, _ := entry["variables"].(map[string]interface{})
+	for _, key := range sortedKeys(variables) {
+		switch value := variables[key].(type) {
+		case map[string]interface{}, []interface{}:
+			t.note("pipe %s variable %s is not a single value, and is not passed", pipe, key)
+		default:
+			args = append(args, fmt.Sprintf(`-e %s="%s"`, key, githubShellEscaper.Replace(gitlabString(value))))
+		}
+	}
+	t.note("pipe %s runs as the docker image %s", pipe, image)
+	return strings.Join(append(args, image), " ")
+}
+
+// script joins the commands of a script, pipes run with docker
+func (t *bitbucketTranslation) script(entries []interface{}) string {
+	var lines []string
+	for _, entry := range entries {
+		if body, ok := entry.(map[string]interface{}); ok && body["pipe"] != nil {
+			lines = append(lines, t.pipe(body))
+			continue
+		}
+		lines = append(lines, gitlabString(entry))
+	}
+	return t.translate(strings.Join(lines, "\n"))
+}
+
+// image translates an image, written as a name or as a mapping with `name:`
+func bitbucketImage(value interface{}) string {
+	if body, ok := value.(map[string]interface{}); ok {
+		return gitlabString(body["name"])
+	}
+	return gitlabString(value)
+}
+
+// caches translates a step's caches into the steps restoring them before its script
+// and saving them after
+func (t *bitbucketTranslation) caches(names []string, config bitbucketConfig) ([]interface{}, []interface{}) {
+	var restore, save []interface{}
+	for _, name := range names {
+		path, key := bitbucketCaches[name], "bitbucket-"+name
+		if custom, ok := config.Definitions.Caches[name]; ok {
+			path = gitlabString(custom)
+			if body, ok := custom.(map[string]interface{}); ok {
+				path = gitlabString(body["path"])
+				keyBody, _ := body["key"].(map[string]interface{})
+				for _, file := range toStringList(keyBody["files"]) {
+					key += fmt.Sprintf(`-{{ checksum "%s" }}`, file)
+				}
+			}
+		}
+		if path == "" {
+			if name != "docker" {
+				t.note("cache %s is not defined", name)
+			}
+			continue
+		}
+		restore = append(restore, map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{key}}})
+		save = append(save, map[string]interface{}{"save_cache": map[string]interface{}{"key": key, "paths": []interface{}{path}}})
+	}
+	return restore, save
+}
+
+// bitbucketArtifacts returns the artifact paths of a step, and whether it downloads
+// the artifacts of the steps before it
+func bitbucketArtifacts(step bitbucketStep) ([]string, bool) {
+	if body, ok := step.Artifacts.(map[string]interface{}); ok {
+		return toStringList(body["paths"]), body["download"] != false
+	}
+	return toStringList(step.Artifacts), true
+}
+
+// step translates a step into a CircleCI job: its image and services, and steps for the
+// clone, caches, artifacts, script and after-script
+func (t *bitbucketTranslation) step(step bitbucketStep, config bitbucketConfig, attach bool, env map[string]interface{}) map[string]interface{} {
+	image := bitbucketImage(step.Image)
+	if image == "" {
+		image = bitbucketImage(config.Image)
+	}
+	if image == "" {
+		image = bitbucketDefaultImage
+	}
+	images := []interface{}{map[string]interface{}{"image": t.translate(image)}}
+	docker := config.Options.Docker
+	for _, name := range step.Services {
+		if name == "docker" {
+			docker = true
+			continue
+		}
+		service, ok := config.Definitions.Services[name]
+		if !ok {
+			t.note("service %s is not defined", name)
+			continue
+		}
+		def := map[string]interface{}{"image": t.translate(bitbucketImage(service.Image)), "name": name}
+		if len(service.Variables) > 0 {
+			variables := make(map[string]interface{})
+			for key, value := range service.Variables {
+				variables[key] = t.translate(gitlabString(value))
+			}
+			def["environment"] = variables
+		}
+		images = append(images, def)
+	}
+
+	var steps []interface{}
+	if clone := step.Clone.Enabled; (clone == nil && config.Clone.Enabled == nil) || (clone == nil && *config.Clone.Enabled) || (clone != nil && *clone) {
+		steps = append(steps, "checkout")
+	}
+	restore, save := t.caches(step.Caches, config)
+	steps = append(steps, restore...)
+	if attach {
+		steps = append(steps, map[string]interface{}{"attach_workspace": map[string]interface{}{"at": "."}})
+	}
+	if docker {
+		steps = append(steps, "setup_remote_docker")
+	}
+	steps = append(steps, map[string]interface{}{"run": map[string]interface{}{"name": "script", "command": t.script(step.Script)}})
+	steps = append(steps, save...)
+	if paths, _ := bitbucketArtifacts(step); len(paths) > 0 {
+		steps = append(steps, map[string]interface{}{"persist_to_workspace": map[string]interface{}{"root": ".", "paths": paths}})
+	}
+	if len(step.AfterScript) > 0 {
+		steps = append(steps, map[string]interface{}{"run": map[string]interface{}{"name": "after-script", "command": t.script(step.AfterScript), "when": "always"}})
+	}
+
+	if step.Deployment != "" {
+		t.note("the step deploys to the Bitbucket environment %s; set its deployment variables to run the task locally", step.Deployment)
+	}
+	if step.Condition != nil {
+		t.note("the step runs only when its `condition:` holds; the task runs unconditionally")
+	}
+	def := map[string]interface{}{"docker": images, "steps": steps}
+	if len(env) > 0 {
+		def["environment"] = env
+	}
+	return def
+}
+
+// bitbucketParameters translates the variables of a custom pipeline into pipeline
+// parameters
+func bitbucketParameters(variables *yaml.Node) map[string]interface{} {
+	var list []map[string]interface{}
+	if variables == nil || variables.Decode(&list) != nil {
+		return nil
+	}
+	parameters := make(map[string]interface{})
+	for _, variable := range list {
+		name := gitlabString(variable["name"])
+		if name == "" {
+			continue
+		}
+		parameter := map[string]interface{}{"type": "string", "default": gitlabString(variable["default"])}
+		if allowed := toStringList(variable["allowed-values"]); len(allowed) > 0 {
+			parameter["type"] = "enum"
+			parameter["enum"] = allowed
+		}
+		if description := gitlabString(variable["description"]); description != "" {
+			parameter["description"] = description
+		}
+		parameters[name] = parameter
+	}
+	return parameters
+}
+
+// parseBitbucketPipelines translates a Bitbucket Pipelines config into a CircleCI
+// config: a workflow per pipeline (default, branches, tags, pull requests and custom
+// pipelines) running its steps in order, parallel groups side by side and manual
+// steps after an approval. Each step is a job, once however many pipelines share it
+// through anchors; custom pipeline variables become pipeline parameters. What has no
+// equivalent is recorded in the config's notes.
+func parseBitbucketPipelines(file string, data []byte) (CircleCIConfig, error) {
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	var config bitbucketConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	var root *yaml.Node
+	if len(original.Content) > 0 {
+		root = original.Content[0]
+	}
+	pipelines, names := bitbucketPipelines(root)
+	if len(names) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the config has no pipeline steps", Hint: "is this a Bitbucket Pipelines config? Pass -from circleci for CircleCI configs"}
+	}
+
+	var notes []ReportEntry
+	var branchPatterns []interface{}
+	for _, pipeline := range pipelines {
+		if pipeline.kind == "branches" {
+			branchPatterns = append(branchPatterns, bitbucketGlobRegex(pipeline.pattern))
+		}
+	}
+
+	parameters := make(map[string]interface{})
+	env := make(map[*yaml.Node]map[string]interface{})
+	attach := make(map[*yaml.Node]bool)
+	var order []*yaml.Node
+	invoked := make(map[*yaml.Node][]map[string]interface{})
+	workflows := make(map[string]interface{})
+	for _, pipeline := range pipelines {
+		wt := &bitbucketTranslation{job: pipeline.name, notes: &notes}
+		for _, note := range pipeline.notes {
+			wt.note("%s", note)
+		}
+		filters := make(map[string]interface{})
+		switch pipeline.kind {
+		case "default":
+			if len(branchPatterns) > 0 {
+				filters["branches"] = map[string]interface{}{"ignore": branchPatterns}
+			}
+		case "branches":
+			filters["branches"] = map[string]interface{}{"only": bitbucketGlobRegex(pipeline.pattern)}
+		case "tags":
+			filters["tags"] = map[string]interface{}{"only": bitbucketGlobRegex(pipeline.pattern)}
+			filters["branches"] = map[string]interface{}{"ignore": "/.*/"}
+		case "pull-requests":
+			wt.note("the pipeline runs for pull requests from %s; the converted workflow has no filter", pipeline.pattern)
+		case "custom":
+			wt.note("the custom pipeline runs when started by hand or on a schedule; the converted workflow has no filter")
+		}
+		custom := bitbucketParameters(pipeline.variables)
+		for name, parameter := range custom {
+			parameters[name] = parameter
+		}
+
+		var invocations []interface{}
+		seen := make(map[*yaml.Node]bool)
+		var previous []string
+		artifacts := false
+		for _, group := range pipeline.groups {
+			var current []string
+			hold := ""
+			if group.manual {
+				hold = "approve-" + names[group.steps[0]]
+				approval := map[string]interface{}{"type": "approval"}
+				if len(previous) > 0 {
+					approval["requires"] = previous
+				}
+				if len(filters) > 0 {
+					approval["filters"] = filters
+				}
+				invocations = append(invocations, map[string]interface{}{hold: approval})
+			}
+			produced := false
+			for _, node := range group.steps {
+				name := names[node]
+				if seen[node] {
+					wt.note("step %s runs twice in the pipeline; the workflow runs it once", name)
+					continue
+				}
+				seen[node] = true
+				if _, ok := env[node]; !ok {
+					env[node] = make(map[string]interface{})
+					order = append(order, node)
+				}
+				for variable := range custom {
+					env[node][variable] = "<< pipeline.parameters." + variable + " >>"
+				}
+				var step bitbucketStep
+				node.Decode(&step)
+				paths, download := bitbucketArtifacts(step)
+				if artifacts && download {
+					attach[node] = true
+				}
+				produced = produced || len(paths) > 0
+
+				invocation := make(map[string]interface{})
+				if hold != "" {
+					invocation["requires"] = []string{hold}
+				} else if len(previous) > 0 {
+					invocation["requires"] = previous
+				}
+				if len(filters) > 0 {
+					invocation["filters"] = filters
+				}
+				invoked[node] = append(invoked[node], invocation)
+				invocations = append(invocations, map[string]interface{}{name: invocation})
+				current = append(current, name)
+			}
+			artifacts = artifacts || produced
+			if len(current) > 0 {
+				previous = current
+			}
+		}
+		if len(invocations) > 0 {
+			workflows[pipeline.name] = map[string]interface{}{"jobs": invocations}
+		}
+	}
+
+	// A step the pipelines of several branches share runs on all of them
+	for _, invocations := range invoked {
+		for _, invocation := range invocations {
+			if fmt.Sprint(invocation["filters"]) != fmt.Sprint(invocations[0]["filters"]) {
+				for _, invocation := range invocations {
+					delete(invocation, "filters")
+				}
+				break
+			}
+		}
+	}
+
+	jobs := make(map[string]interface{})
+	for _, node := range order {
+		var step bitbucketStep
+		if err := node.Decode(&step); err != nil {
+			return CircleCIConfig{}, &ParseError{File: file, Line: node.Line, Column: node.Column, Message: err.Error()}
+		}
+		t := &bitbucketTranslation{job: names[node], notes: &notes}
+		jobs[names[node]] = t.step(step, config, attach[node], env[node])
+	}
+
+	circle := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      jobs,
+		"workflows": workflows,
+	}
+	if len(parameters) > 0 {
+		circle["parameters"] = parameters
+	}
+	translated, err := yaml.Marshal(circle)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	converted, err := parseConfig(file, translated)
+	if err != nil {
+		return converted, err
+	}
+	converted.format = fromBitbucket
+	converted.original = &original
+	converted.notes = notes
+	converted.source = nil
+	return converted, nil
+}
+
+// writeBitbucketPipelines writes the slim config: the input config with the script of
+// each step replaced by a call to the step's task (or script, for the script targets),
+// passing the custom pipeline variables it uses, and without its after-script. Steps
+// shared through anchors are replaced once.
+func writeBitbucketPipelines(path string, config CircleCIConfig, target string) error {
+	_, names := bitbucketPipelines(config.original.Content[0])
+	for node, name := range names {
+		call := "task " + name
+		if scriptTarget(target) {
+			call = "./" + scriptFile(name)
+		}
+		var args []string
+		for _, ref := range pipelineRefs(config.Jobs[name]) {
+			if variable := strings.TrimPrefix(ref, "pipeline.parameters."); variable != ref {
+				args = append(args, fmt.Sprintf(`%s="$%s"`, taskVarName(variable), variable))
+			}
+		}
+		if len(args) > 0 {
+			call += " " + strings.Join(args, " ")
+		}
+
+		var script yaml.Node
+		if err := script.Encode([]string{call}); err != nil {
+			return err
+		}
+		gitlabDeleteKeys(node, "after-script")
+		if existing := mappingValue(node, "script"); existing != nil {
+			*existing = script
+		} else {
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "script"}, &script)
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.original); err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
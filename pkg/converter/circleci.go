@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CircleCIClient calls the API v2 of CircleCI cloud or of a CircleCI Server installation
+type CircleCIClient struct {
+	BaseURL string // API v2 root, like https://circleci.com/api/v2
+	Token   string // personal API token
+	Client  *http.Client
+}
+
+// NewCircleCIClient creates a client for the given CircleCI host (empty for circleci.com)
+func NewCircleCIClient(host, token string) *CircleCIClient {
+	if host == "" {
+		host = defaultCircleCIHost
+	}
+	if token == "" {
+		token = os.Getenv("CIRCLECI_CLI_TOKEN")
+	}
+	return &CircleCIClient{
+		BaseURL: strings.TrimRight(host, "/") + "/api/v2",
+		Token:   token,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// get decodes the JSON answer of an API v2 GET request into out
+func (c *CircleCIClient) get(path string, query url.Values, out interface{}) error {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "circle-to-task/"+Version)
+	if c.Token != "" {
+		req.Header.Set("Circle-Token", c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// pages calls get for each page of a paginated API v2 list, passing the items of each
+// page to add
+func (c *CircleCIClient) pages(path string, query url.Values, add func(items json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	for {
+		var page struct {
+			Items         json.RawMessage `json:"items"`
+			NextPageToken string          `json:"next_page_token"`
+		}
+		if err := c.get(path, query, &page); err != nil {
+			return err
+		}
+		if err := add(page.Items); err != nil {
+			return fmt.Errorf("error decoding %s: %w", path, err)
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.Set("page-token", page.NextPageToken)
+	}
+}
+
+// ProjectConfig returns the config source of the latest pipeline of a project, given
+// by its slug (gh/org/repo), on branch or on any branch when branch is empty
+func (c *CircleCIClient) ProjectConfig(slug, branch string) ([]byte, error) {
+	query := url.Values{}
+	if branch != "" {
+		query.Set("branch", branch)
+	}
+	var pipelines struct {
+		Items []struct {
+			ID     string `json:"id"`
+			Number int    `json:"number"`
+		} `json:"items"`
+	}
+	if err := c.get("/project/"+slug+"/pipeline", query, &pipelines); err != nil {
+		return nil, fmt.Errorf("error listing the pipelines of %s: %w", slug, err)
+	}
+	if len(pipelines.Items) == 0 {
+		return nil, fmt.Errorf("project %s has no pipelines to read the config from", projectSource(slug, branch))
+	}
+
+	latest := pipelines.Items[0]
+	var config struct {
+		Source string `json:"source"`
+	}
+	if err := c.get("/pipeline/"+latest.ID+"/config", nil, &config); err != nil {
+		return nil, fmt.Errorf("error reading the config of pipeline %d of %s: %w", latest.Number, slug, err)
+	}
+	logger.Info("config read from CircleCI", "project", slug, "pipeline", latest.Number)
+	return []byte(config.Source), nil
+}
+
+// ContextVariables returns the names of the variables of the given contexts of an
+// organization (gh/org), as set on CircleCI; the API never returns their values
+func (c *CircleCIClient) ContextVariables(ownerSlug string, contexts []string) (map[string][]string, error) {
+	wanted := make(map[string]bool, len(contexts))
+	for _, context := range contexts {
+		wanted[context] = true
+	}
+	ids := make(map[string]string)
+	err := c.pages("/context", url.Values{"owner-slug": {ownerSlug}}, func(items json.RawMessage) error {
+		var page []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(items, &page); err != nil {
+			return err
+		}
+		for _, context := range page {
+			if wanted[context.Name] {
+				ids[context.Name] = context.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the contexts of %s: %w", ownerSlug, err)
+	}
+
+	variables := make(map[string][]string)
+	for _, context := range sortedStringKeys(ids) {
+		err := c.pages("/context/"+ids[context]+"/environment-variable", nil, func(items json.RawMessage) error {
+			var page []struct {
+				Variable string `json:"variable"`
+			}
+			if err := json.Unmarshal(items, &page); err != nil {
+				return err
+			}
+			for _, v := range page {
+				variables[context] = append(variables[context], v.Variable)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing the variables of context %s: %w", context, err)
+		}
+		sort.Strings(variables[context])
+	}
+	return variables, nil
+}
+
+// projectOwner returns the organization slug (gh/org) of a project slug (gh/org/repo)
+func projectOwner(slug string) (string, error) {
+	parts := strings.Split(slug, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("project slug %q is not <vcs>/<org>/<repo>, like gh/acme/api", slug)
+	}
+	return parts[0] + "/" + parts[1], nil
+}
+
+// projectSource describes a config read from CircleCI for messages, as slug@branch
+func projectSource(slug, branch string) string {
+	if branch == "" {
+		return slug
+	}
+	return slug + "@" + branch
+}
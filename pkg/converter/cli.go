@@ -0,0 +1,627 @@
+package converter
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const Version = "v0.3.1"
+
+// stdinPath and stdoutPath are the -input and -output values reading stdin and
+// writing to stdout
+const (
+	stdinPath  = "-"
+	stdoutPath = "-"
+)
+
+// subcommands are dispatched on the first argument; anything else is a conversion
+var subcommands = map[string]func(args []string) error{
+	"orbs":     runOrbsCommand,
+	"hooks":    runHooksCommand,
+	"run":      runRunCommand,
+	"selftest": runSelftestCommand,
+	"bench":    runBenchCommand,
+	"annotate": runAnnotateCommand,
+	"graph":    runGraphCommand,
+}
+
+// Main runs the circle-to-task command line: a subcommand, or a conversion
+func Main() {
+	var inputFiles stringList
+	flag.Var(&inputFiles, "input", "Input CircleCI config file (required; with -repo, the path inside the repository; - reads stdin). Repeat it to convert several configs")
+	var manifest = flag.String("manifest", "", "File listing CircleCI configs to convert in one batch, one path per line")
+	var repo = flag.String("repo", "", "Convert the config of a remote git repository, fetched without a local clone")
+	var ref = flag.String("ref", "", "Branch or tag to convert with -repo, or branch of the pipeline with -circleci-project (default: the remote's default branch, or the latest pipeline)")
+	var outputDir = flag.String("output", ".", "Output directory for generated files (in batch mode, one subdirectory per config; - writes only the Taskfile, to stdout)")
+	var watch = flag.Bool("watch", false, "Keep running, converting the config again whenever it or a file it includes changes")
+	var dryRunFlag = flag.Bool("dry-run", false, "Convert without writing files, printing unified diffs of the new config and Taskfile against the output directory")
+	var configOutput = flag.String("config-output", "", "With -output -, where to write the new config (default: not written)")
+	var help = flag.Bool("help", false, "Show help message")
+	var version = flag.Bool("version", false, "Show version information")
+	var offline = flag.Bool("offline", false, "Forbid network access; resolve orbs only from the vendor directory")
+	var orbsDir = flag.String("orbs-dir", "orbs", "Directory of vendored orb sources (see 'orbs vendor')")
+	var orbsCache = flag.String("orbs-cache", defaultOrbCacheDir(), "Directory caching pinned orbs fetched from the registry (empty disables the cache)")
+	var circleciHost = flag.String("circleci-host", os.Getenv("CIRCLECI_CLI_HOST"), "CircleCI host for orb resolution and -circleci-project, for CircleCI Server installations (default https://circleci.com)")
+	var circleciToken = flag.String("circleci-token", "", "CircleCI API token for orb resolution and -circleci-project (default $CIRCLECI_CLI_TOKEN)")
+	var circleciProject = flag.String("circleci-project", "", "CircleCI project slug (gh/org/repo): looks up the variables of its organization's contexts, and without -input or -repo converts the config of its latest pipeline (on the -ref branch)")
+	var allowRisky = flag.Bool("allow-risky", false, "Emit risky commands (curl | bash, chmod 777, plaintext passwords) instead of blocking them")
+	var secretsManager = flag.String("secrets-manager", "", "Write secrets manager templates to <output>/secrets: vault, doppler, 1password (comma-separated)")
+	var retry = flag.Int("retry", 0, "Retry every generated command up to this many attempts (0 disables the global retry policy)")
+	var retryDelay = flag.Int("retry-delay", 2, "Seconds before the first retry; doubled after each further failure")
+	var emitJSON = flag.Bool("emit-json", false, "Also write Taskfile.json and CONVERSION_MODEL.json for programmatic consumers")
+	var vscode = flag.Bool("vscode", false, "Also write .vscode/tasks.json so the tasks can be run from VS Code")
+	var toolchain = flag.Bool("toolchain-dockerfile", false, "Also write Dockerfile.toolchain bundling every tool the tasks use, and a ci-shell task")
+	var shellLib = flag.Bool("shell-lib", false, "Put commands repeated across jobs in scripts/ci-lib.sh shell functions instead of shared tasks")
+	var logFormat = flag.String("log-format", "text", "Log format on stderr: text or json")
+	var logLevel = flag.String("log-level", "", "Log level: debug, info, warn or error (default warn for text, info for json)")
+	var pluginNames stringList
+	flag.Var(&pluginNames, "plugin", "Step-converter plugin: runs circle-to-task-plugin-<name> from PATH on each step, passing it as JSON. Repeat it for several, asked in order")
+	var stepMapFile = flag.String("step-map", "", "YAML or JSON file mapping step names (private orbs, internal commands) to commands or tasks")
+	var jetbrains = flag.Bool("jetbrains", false, "Also write .run/*.run.xml run configurations for IntelliJ/GoLand")
+	var docker = flag.Bool("docker", false, "Run the commands of jobs on docker executors in the job's image (docker run) instead of on the host")
+	var hostDocker = flag.Bool("host-docker", false, "Convert setup_remote_docker to a check that the host's Docker daemon is reachable instead of skipping it")
+	var target = flag.String("target", targetTaskfile, "What the jobs convert to, comma-separated to write several: taskfile (go-task), scripts (standalone bash scripts/<job>.sh, no task runner), mise (the scripts as mise.toml tasks), npm-scripts (the scripts as package.json scripts), makefile (the scripts as make targets), jenkinsfile (the Taskfile and a Jenkinsfile running its tasks) or github-actions (the Taskfile and GitHub Actions workflows running its tasks)")
+	var from = flag.String("from", fromAuto, "Format of the input: circleci, github (GitHub Actions workflow), gitlab (.gitlab-ci.yml), travis (.travis.yml), bitbucket (bitbucket-pipelines.yml), jenkins (declarative Jenkinsfile), azure (azure-pipelines.yml) or auto (detect from the path and contents)")
+	var npmConflict = flag.String("npm-conflict", npmConflictPrefix, "With -target npm-scripts, what to do with jobs named like an existing package.json script: prefix (add ci:<job>), skip or overwrite")
+	
+	flag.Parse()
+	if err := setupLogging(*logFormat, *logLevel); err != nil {
+		fatal("invalid logging flags", err)
+	}
+
+	// Subcommands follow the logging flags, which apply to them too; `convert` is an
+	// explicit name for the default conversion
+	if name := flag.Arg(0); name == "convert" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+		if err := setupLogging(*logFormat, *logLevel); err != nil {
+			fatal("invalid logging flags", err)
+		}
+	} else if run, ok := subcommands[name]; ok {
+		var misplaced []string
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name != "log-format" && f.Name != "log-level" {
+				misplaced = append(misplaced, "-"+f.Name)
+			}
+		})
+		if len(misplaced) > 0 {
+			fatal("invalid flags", fmt.Errorf("%s must follow the %s subcommand", strings.Join(misplaced, ", "), name))
+		}
+		if err := run(flag.Args()[1:]); err != nil {
+			fatal(name+" failed", err)
+		}
+		return
+	}
+
+	if *version {
+		fmt.Printf("circle-to-task %s\n", Version)
+		return
+	}
+
+	if *help || (len(inputFiles) == 0 && *manifest == "" && *repo == "" && *circleciProject == "") {
+		showHelp()
+		return
+	}
+
+	orbs := NewOrbResolver(*orbsDir, *offline, *circleciHost, *circleciToken)
+	orbs.CacheDir = *orbsCache
+
+	var stepMap map[string]StepMapping
+	if *stepMapFile != "" {
+		loaded, err := loadStepMap(*stepMapFile)
+		if err != nil {
+			fatal("invalid -step-map", err)
+		}
+		stepMap = loaded
+	}
+	plugins, err := lookupPlugins(pluginNames)
+	if err != nil {
+		fatal("invalid -plugin", err)
+	}
+
+	targets, err := parseTargets(*target)
+	if err != nil {
+		fatal("invalid -target", err)
+	}
+	switch *from {
+	case fromAuto, fromCircleCI, fromGitHub, fromGitLab, fromTravis, fromBitbucket, fromJenkins, fromAzure:
+	default:
+		fatal("invalid -from", fmt.Errorf("unknown input format %q (want %s, %s, %s, %s, %s, %s, %s or %s)", *from, fromAuto, fromCircleCI, fromGitHub, fromGitLab, fromTravis, fromBitbucket, fromJenkins, fromAzure))
+	}
+	switch *npmConflict {
+	case npmConflictPrefix, npmConflictSkip, npmConflictOverwrite:
+	default:
+		fatal("invalid -npm-conflict", fmt.Errorf("unknown strategy %q (want %s, %s or %s)", *npmConflict, npmConflictPrefix, npmConflictSkip, npmConflictOverwrite))
+	}
+	if !containsString(emittedTargets(targets), targetTaskfile) && (*vscode || *jetbrains) {
+		fatal("invalid flags", fmt.Errorf("-vscode and -jetbrains run the tasks with go-task and cannot be combined with -target %s", *target))
+	}
+
+	managers, err := parseSecretsManagers(*secretsManager)
+	if err != nil {
+		fatal("invalid -secrets-manager", err)
+	}
+	settings := conversionSettings{
+		Options: ConvertOptions{
+			AllowRisky: *allowRisky,
+			Docker:     *docker,
+			HostDocker: *hostDocker,
+			Orbs:       orbs,
+			Retry:      RetryPolicy{Attempts: *retry, Delay: *retryDelay},
+			ShellLib:   *shellLib,
+			StepMap:    stepMap,
+			Plugins:    plugins,
+		},
+		SecretsManagers: managers,
+		LockOptions: map[string]string{
+			"allow-risky":     fmt.Sprintf("%t", *allowRisky),
+			"retry":           fmt.Sprintf("%d", *retry),
+			"retry-delay":     fmt.Sprintf("%d", *retryDelay),
+			"secrets-manager": *secretsManager,
+		},
+		From:        *from,
+		Targets:     targets,
+		NpmConflict: *npmConflict,
+		EmitJSON:    *emitJSON,
+		Toolchain:   *toolchain,
+		VSCode:      *vscode,
+		JetBrains:   *jetbrains,
+	}
+	// Recorded only when set, so locks written before the flag existed still match
+	if *shellLib {
+		settings.LockOptions["shell-lib"] = "true"
+	}
+	if *docker {
+		settings.LockOptions["docker"] = "true"
+	}
+	if *hostDocker {
+		settings.LockOptions["host-docker"] = "true"
+	}
+	if strings.Join(targets, ",") != targetTaskfile {
+		settings.LockOptions["target"] = strings.Join(targets, ",")
+	}
+	if stepMap != nil {
+		settings.LockOptions["step-map"] = hashValue(stepMap)
+	}
+	if len(pluginNames) > 0 {
+		settings.LockOptions["plugins"] = strings.Join(pluginNames, ",")
+	}
+
+	// Several configs (repeated -input or a manifest) are converted as a batch
+	if *manifest != "" {
+		listed, err := readManifest(*manifest)
+		if err != nil {
+			fatal("error reading manifest", err)
+		}
+		inputFiles = append(inputFiles, listed...)
+	}
+	if len(inputFiles) > 1 || *manifest != "" {
+		if *repo != "" || *circleciProject != "" {
+			fatal("invalid flags", fmt.Errorf("-repo and -circleci-project convert a single config and cannot be combined with a batch"))
+		}
+		if containsString(inputFiles, stdinPath) || *outputDir == stdoutPath {
+			fatal("invalid flags", fmt.Errorf("a batch cannot read stdin or write to stdout"))
+		}
+		if *dryRunFlag || *watch {
+			fatal("invalid flags", fmt.Errorf("-dry-run and -watch convert a single config and cannot be combined with a batch"))
+		}
+		if err := runBatch(inputFiles, *outputDir, settings); err != nil {
+			fatal("batch conversion failed", err)
+		}
+		return
+	}
+
+	// With -output -, the files are written to a temporary directory and the Taskfile
+	// printed from it
+	outputToStdout := *outputDir == stdoutPath
+	if outputToStdout && *dryRunFlag {
+		fatal("invalid flags", fmt.Errorf("-dry-run prints diffs and cannot be combined with -output -"))
+	}
+	if *watch && (*repo != "" || len(inputFiles) == 0 || containsString(inputFiles, stdinPath) || outputToStdout || *dryRunFlag) {
+		fatal("invalid flags", fmt.Errorf("-watch needs a local -input file and an -output directory, and cannot be combined with -dry-run"))
+	}
+	if outputToStdout {
+		if !containsString(emittedTargets(targets), targetTaskfile) {
+			fatal("invalid flags", fmt.Errorf("-output - prints the Taskfile, which -target %s does not write", *target))
+		}
+		dir, err := os.MkdirTemp("", "circle-to-task-")
+		if err != nil {
+			fatal("error creating temporary directory", err)
+		}
+		defer os.RemoveAll(dir)
+		*outputDir = dir
+	} else if *configOutput != "" {
+		fatal("invalid flags", fmt.Errorf("-config-output is only used with -output -"))
+	}
+
+	// Create output directory
+	if !*dryRunFlag {
+		if err := os.MkdirAll(*outputDir, 0755); err != nil {
+			fatal("error creating output directory", err)
+		}
+	}
+	
+	// Read CircleCI config, locally or from a remote repository
+	inputFile := ""
+	if len(inputFiles) > 0 {
+		inputFile = inputFiles[0]
+	}
+	source := inputFile
+	project := projectName(inputFile)
+	var data []byte
+	var circleci *CircleCIClient
+	if *circleciProject != "" {
+		if *offline {
+			fatal("invalid flags", fmt.Errorf("-circleci-project reads from the CircleCI API and cannot be combined with -offline"))
+		}
+		circleci = NewCircleCIClient(*circleciHost, *circleciToken)
+	}
+	if *repo == "" && inputFile == "" && circleci != nil {
+		source = projectSource(*circleciProject, *ref)
+		project = repoName(*circleciProject)
+		data, err = circleci.ProjectConfig(*circleciProject, *ref)
+	} else if *repo != "" {
+		if inputFile == "" {
+			inputFile = defaultRemoteInput
+		}
+		source = remoteSource(*repo, *ref, inputFile)
+		project = repoName(*repo)
+		data, err = fetchRemoteConfig(*repo, *ref, inputFile)
+	} else if inputFile == stdinPath {
+		source = stdinSource
+		project = projectName(".")
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(inputFile)
+	}
+	if err != nil {
+		fatal("error reading input file", err)
+	}
+
+	config, err := parseInput(source, data, *from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if source == stdinSource {
+		// The slim config of a translated input is named like the input
+		source = inputFileNames[config.format]
+	}
+
+	// Contexts are looked up once; without them the conversion still infers their variables
+	if circleci != nil {
+		settings.ContextVars, err = lookupContextVariables(circleci, *circleciProject, config)
+		if err != nil {
+			logger.Warn("context variables could not be read from CircleCI", "project", *circleciProject, "error", err)
+		}
+	}
+
+	if *dryRunFlag {
+		result, err := dryRun(os.Stdout, source, project, data, config, *outputDir, settings)
+		if err != nil {
+			fatal("conversion failed", err)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+		}
+		return
+	}
+
+	result, err := convertInput(source, project, data, config, *outputDir, settings)
+	if err != nil {
+		fatal("conversion failed", err)
+	}
+	if outputToStdout {
+		if err := printTaskfile(result, *configOutput); err != nil {
+			fatal("error writing output", err)
+		}
+		return
+	}
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	showSuccess(result.System, result.Jobs, result.ConfigPath, result.TaskfilePath, *outputDir, result.Optional)
+	if *watch {
+		if err := watchConfig(inputFile, project, *from, *outputDir, config, settings); err != nil {
+			fatal("watch failed", err)
+		}
+	}
+}
+
+// printTaskfile writes the Taskfile of a conversion to stdout, and its new config to
+// configOutput unless it is empty; warnings go to stderr, keeping stdout for the
+// Taskfile
+func printTaskfile(result conversionResult, configOutput string) error {
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "⚠️  %s\n", warning)
+	}
+	if configOutput != "" {
+		data, err := os.ReadFile(result.ConfigPath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(configOutput, data, 0644); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(result.TaskfilePath)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// conversionSettings are the command-line options shared by every converted config
+type conversionSettings struct {
+	Options         ConvertOptions
+	SecretsManagers []string
+	LockOptions     map[string]string // options recorded in the lock file
+	From            string            // input format, fromAuto to detect it
+	Targets         []string          // names of the emitters -target selects, in registry order
+	NpmConflict     string            // strategy for package.json scripts clashing with jobs
+	ContextVars     map[string][]string // variables of the contexts on CircleCI (-circleci-project)
+	EmitJSON        bool
+	Toolchain       bool
+	VSCode          bool
+	JetBrains       bool
+}
+
+// conversionResult summarizes one converted config
+type conversionResult struct {
+	System       string // CI system of the input and the new config
+	Jobs         int
+	Tasks        int
+	ConfigPath   string
+	TaskfilePath string // empty when the target replaces the Taskfile with scripts
+	Optional     []string // files of optional outputs, as "path (description)"
+	Warnings     []string // hand-edited tasks kept from the lock file, tasks this host skips, target hints
+	Report       *ConversionReport
+}
+
+// convertInput converts a parsed config and writes every output file to outputDir
+func convertInput(source, project string, data []byte, config CircleCIConfig, outputDir string, settings conversionSettings) (conversionResult, error) {
+	// Convert
+	logger.Info("converting config", "input", source, "jobs", len(config.Jobs))
+	report := &ConversionReport{}
+	opts := settings.Options
+	config.contextVars = settings.ContextVars
+	newConfig, taskfile := convertConfig(config, opts, report)
+	result := conversionResult{System: inputFormatName(config), Jobs: len(config.Jobs), Report: report}
+
+	// Scaffold the secrets manager before writing the Taskfile, which gains wrapper tasks
+	if len(settings.SecretsManagers) > 0 {
+		if err := generateSecretsTemplates(config, &taskfile, settings.SecretsManagers, project, outputDir, report); err != nil {
+			return result, fmt.Errorf("error writing secrets templates: %w", err)
+		}
+		result.Optional = append(result.Optional, "secrets/ (secrets manager templates)")
+	}
+
+	// The toolchain image adds the ci-shell task, so it is also written before the Taskfile
+	if settings.Toolchain {
+		if err := generateToolchainDockerfile(config, &taskfile, project, outputDir, report); err != nil {
+			return result, fmt.Errorf("error writing toolchain Dockerfile: %w", err)
+		}
+	}
+
+	// Standalone scripts replace the go-task calls of the new config
+	runner := jobRunner(settings.Targets)
+	if scriptTarget(runner) {
+		scriptConfigSteps(&newConfig, taskfile)
+	}
+
+	// Write the new config: a slim CircleCI config, or a slim config of the CI the input
+	// was translated from
+	switch config.format {
+	case fromGitHub:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeGitHubWorkflow(result.ConfigPath, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new workflow: %w", err)
+		}
+	case fromGitLab:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeGitLabCI(result.ConfigPath, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new config: %w", err)
+		}
+	case fromTravis:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeTravisCI(result.ConfigPath, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new config: %w", err)
+		}
+	case fromBitbucket:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeBitbucketPipelines(result.ConfigPath, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new config: %w", err)
+		}
+	case fromJenkins:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeJenkinsfile(result.ConfigPath, data, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new Jenkinsfile: %w", err)
+		}
+	case fromAzure:
+		result.ConfigPath = filepath.Join(outputDir, filepath.Base(source))
+		if err := writeAzurePipelines(result.ConfigPath, config, runner); err != nil {
+			return result, fmt.Errorf("error writing new config: %w", err)
+		}
+	default:
+		result.ConfigPath = filepath.Join(outputDir, "config.yml")
+		if err := writeConfigFile(result.ConfigPath, newConfig, config.source); err != nil {
+			return result, fmt.Errorf("error writing new config: %w", err)
+		}
+	}
+
+	// Compare with the previous conversion: keep hand-edited tasks whose source is unchanged
+	lock := buildLockFile(data, config, taskfile, settings.LockOptions, opts.Orbs)
+	warnings, err := reconcileWithLock(outputDir, lock, &taskfile, report)
+	if err != nil {
+		return result, err
+	}
+	result.Warnings = warnings
+	result.Warnings = append(result.Warnings, hostPlatformWarnings(taskfile)...)
+	if hint := npmScriptsHint(config, taskfile); hint != "" && len(settings.Targets) == 1 && settings.Targets[0] == targetTaskfile {
+		result.Warnings = append(result.Warnings, hint)
+	}
+
+	// Write the Taskfile, the scripts in its place, and the other targets
+	out := emission{Pipeline: buildPipeline(config), Config: config, NewConfig: newConfig, Taskfile: taskfile, Report: report, Project: project, OutputDir: outputDir, Settings: settings}
+	for _, name := range emittedTargets(settings.Targets) {
+		emitter, _ := lookupEmitter(name)
+		written, err := emitter.Emit(out)
+		if err != nil {
+			return result, err
+		}
+		result.Optional = append(result.Optional, written...)
+		if name == targetTaskfile {
+			result.TaskfilePath = filepath.Join(outputDir, taskfileName)
+		}
+	}
+	result.Tasks = len(taskfile.Tasks)
+	if err := writeLockFile(outputDir, lock); err != nil {
+		logger.Warn("error writing "+lockFileName, "error", err)
+	}
+
+	// Write the shell function library the tasks source
+	if taskfile.shellLib != "" {
+		if err := writeShellLib(taskfile, outputDir); err != nil {
+			return result, fmt.Errorf("error writing shell library: %w", err)
+		}
+		result.Optional = append(result.Optional, shellLibPath+" (shell functions for repeated commands)")
+	}
+	if settings.Toolchain {
+		result.Optional = append(result.Optional, toolchainDockerfileName+" (toolchain image; `task ci-shell` opens a shell in it)")
+	}
+
+	// Service containers of jobs with several docker images
+	if written, err := generateComposeFile(config, outputDir); err != nil {
+		logger.Warn("error writing "+composeFileName, "error", err)
+	} else if written {
+		result.Optional = append(result.Optional, composeFileName+" (service containers of jobs with several docker images)")
+	}
+
+	// List the scheduled workflows with a crontab snippet
+	if written, err := generateSchedules(config, outputDir); err != nil {
+		logger.Warn("error writing "+schedulesFileName, "error", err)
+	} else if written {
+		result.Optional = append(result.Optional, schedulesFileName+" (scheduled workflows and a crontab snippet)")
+	}
+
+	// Template of the dotenv file holding the project's secrets
+	if written, err := generateEnvExample(taskfile, outputDir); err != nil {
+		logger.Warn("error writing "+projectEnvExample, "error", err)
+	} else if written {
+		result.Optional = append(result.Optional, projectEnvExample+" (secrets the config uses without setting them)")
+	}
+
+	// Templates of the dotenv files holding the contexts' variables
+	if written, err := generateContextEnvExamples(config, outputDir); err != nil {
+		logger.Warn("error writing context env templates", "error", err)
+	} else {
+		for _, name := range written {
+			result.Optional = append(result.Optional, name+" (variables of a CircleCI context)")
+		}
+	}
+
+	// Generate technology analysis
+	if err := generateTechnologyAnalysis(config, outputDir); err != nil {
+		logger.Warn("error generating technology analysis", "error", err)
+	}
+
+	// Write tool inventory
+	if err := generateToolInventory(config, taskfile, outputDir); err != nil {
+		logger.Warn("error generating tool inventory", "error", err)
+	}
+
+	// Write conversion report
+	if err := generateConversionReport(report, outputDir); err != nil {
+		logger.Warn("error generating conversion report", "error", err)
+	}
+
+	// Write JSON output for programmatic consumers
+	if settings.EmitJSON {
+		if err := generateJSONOutput(source, config, newConfig, taskfile, report, outputDir); err != nil {
+			return result, fmt.Errorf("error writing JSON output: %w", err)
+		}
+		result.Optional = append(result.Optional, "Taskfile.json (go-task configuration as JSON)", "CONVERSION_MODEL.json (parsed input, outputs and report as JSON)")
+	}
+
+	// Write VS Code tasks
+	if settings.VSCode {
+		if err := generateVSCodeTasks(taskfile, outputDir); err != nil {
+			return result, fmt.Errorf("error writing VS Code tasks: %w", err)
+		}
+		result.Optional = append(result.Optional, ".vscode/tasks.json (VS Code tasks)")
+	}
+
+	// Write JetBrains run configurations
+	if settings.JetBrains {
+		if err := generateJetBrainsRunConfigs(taskfile, outputDir); err != nil {
+			return result, fmt.Errorf("error writing JetBrains run configurations: %w", err)
+		}
+		result.Optional = append(result.Optional, ".run/ (IntelliJ/GoLand run configurations)")
+	}
+
+	logger.Info("conversion finished", "input", source, "output", outputDir, "tasks", len(taskfile.Tasks), "report_entries", len(report.Entries))
+	return result, nil
+}
+
+func showHelp() {
+	fmt.Printf("Circle-to-Task Converter %s\n", Version)
+	fmt.Println("================================")
+	fmt.Println()
+	fmt.Println("Converts CircleCI config to orchestration-only config + Taskfile")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Printf("  %s -input <circleci-config.yml> -output <output-dir>\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("Flags:")
+	flag.PrintDefaults()
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Printf("  %s -input .circleci/config.yml -output ./converted\n", os.Args[0])
+	fmt.Printf("  %s -input config.yml\n", os.Args[0])
+	fmt.Printf("  %s convert -repo https://github.com/org/repo -ref main -output ./converted\n", os.Args[0])
+	fmt.Printf("  %s -circleci-project gh/org/repo -output ./converted\n", os.Args[0])
+	fmt.Printf("  %s -input svc-a/.circleci/config.yml -input svc-b/.circleci/config.yml -output ./fleet\n", os.Args[0])
+	fmt.Println()
+	fmt.Println("Subcommands:")
+	fmt.Printf("  %s orbs vendor -input config.yml [-dir orbs]   Download orb sources for -offline use\n", os.Args[0])
+	fmt.Printf("  %s hooks install -input config.yml             Install a git pre-push hook running affected tasks\n", os.Args[0])
+	fmt.Printf("  %s run -input config.yml [-from job]           Run a workflow locally through the generated Taskfile\n", os.Args[0])
+	fmt.Printf("  %s selftest                                    Convert the built-in corpus and check the results\n", os.Args[0])
+	fmt.Printf("  %s bench [-jobs 100 -steps 20 -commands 50]    Measure conversion phases on a synthetic config\n", os.Args[0])
+	fmt.Printf("  %s annotate -input config.yml [-output -]      Copy the config with notes on how each step converts\n", os.Args[0])
+	fmt.Printf("  %s graph dot|serve -input config.yml           Print the workflow DAG as DOT, or explore it in the browser\n", os.Args[0])
+	fmt.Printf("  -log-format and -log-level go before the subcommand: %s -log-level debug orbs vendor ...\n", os.Args[0])
+}
+
+// showSuccess prints the conversion summary; optional lists the files written by
+// optional outputs, as "path (description)"
+func showSuccess(system string, jobCount int, configPath, taskfilePath, outputDir string, optional []string) {
+	fmt.Printf("✅ Successfully converted %s config!\n", system)
+	fmt.Printf("📋 Converted %d jobs into tasks\n", jobCount)
+	fmt.Printf("📁 Output files:\n")
+	fmt.Printf("   - %s (new %s config)\n", configPath, system)
+	if taskfilePath != "" {
+		fmt.Printf("   - %s (go-task configuration)\n", taskfilePath)
+	}
+	fmt.Printf("   - %s/TECHNOLOGY_ANALYSIS.md (commands for AI categorization)\n", outputDir)
+	fmt.Printf("   - %s/CONVERSION_REPORT.md (conversion notes to review)\n", outputDir)
+	fmt.Printf("   - %s/TOOL_INVENTORY.json (external tools the tasks need)\n", outputDir)
+	fmt.Printf("   - %s/%s (conversion lock for re-runs)\n", outputDir, lockFileName)
+	for _, file := range optional {
+		fmt.Printf("   - %s/%s\n", outputDir, file)
+	}
+	fmt.Printf("\n🚀 Next steps:\n")
+	fmt.Printf("   1. Review generated files\n")
+	fmt.Printf("   2. Use TECHNOLOGY_ANALYSIS.md to categorize commands by technology\n")
+	if taskfilePath == "" {
+		fmt.Printf("   3. Test locally: cd %s && ./%s/<job-name>.sh\n", outputDir, scriptsDir)
+		return
+	}
+	fmt.Printf("   3. Test locally: cd %s && task <job-name>\n", outputDir)
+	fmt.Printf("   4. Install go-task if needed: go install github.com/go-task/task/v3/cmd/task@latest\n")
+}
+
@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// conditionRefRegex matches a condition value that is entirely one << reference >>
+var conditionRefRegex = regexp.MustCompile(`^<<\s*([A-Za-z0-9_.-]+)\s*>>$`)
+
+// templateCondition converts a CircleCI logic statement (a value, and, or, not,
+// equal, matches) into a go-task template expression over the task variables.
+// Boolean parameters (per types, see parameterTypes) are compared with "true"; other
+// values evaluate like CircleCI's: empty, false and 0 are false.
+func templateCondition(condition interface{}, types map[string]string) (string, error) {
+	switch v := condition.(type) {
+	case nil:
+		return "false", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, float64:
+		return strconv.FormatBool(fmt.Sprintf("%v", v) != "0"), nil
+	case string:
+		ref, ok, err := conditionRef(v)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return strconv.FormatBool(v != ""), nil
+		}
+		if match := conditionRefRegex.FindStringSubmatch(strings.TrimSpace(v)); types[match[1]] == "boolean" {
+			return fmt.Sprintf(`(eq %s "true")`, ref), nil
+		}
+		return fmt.Sprintf(`(and %s (ne %s "false") (ne %s "0"))`, ref, ref, ref), nil
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return "", fmt.Errorf("a logic statement has exactly one key, got %d", len(v))
+		}
+		for key, value := range v {
+			return templateStatement(key, value, types)
+		}
+	}
+	return "", fmt.Errorf("unsupported condition %v", condition)
+}
+
+// templateStatement converts one logic statement: and, or, not, equal or matches
+func templateStatement(key string, value interface{}, types map[string]string) (string, error) {
+	switch key {
+	case "and", "or":
+		items, ok := value.([]interface{})
+		if !ok || len(items) == 0 {
+			return "", fmt.Errorf("%s takes a list of conditions", key)
+		}
+		if len(items) == 1 {
+			return templateCondition(items[0], types)
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			part, err := templateCondition(item, types)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = part
+		}
+		return fmt.Sprintf("(%s %s)", key, strings.Join(parts, " ")), nil
+	case "not":
+		inner, err := templateCondition(value, types)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(not %s)", inner), nil
+	case "equal":
+		items, ok := value.([]interface{})
+		if !ok || len(items) < 2 {
+			return "", fmt.Errorf("equal takes a list of at least two values")
+		}
+		operands := make([]string, len(items))
+		for i, item := range items {
+			operand, err := templateOperand(item)
+			if err != nil {
+				return "", err
+			}
+			operands[i] = operand
+		}
+		// eq compares its first argument with each of the others; equal needs all of them to match
+		var checks []string
+		for _, operand := range operands[1:] {
+			checks = append(checks, fmt.Sprintf("(eq %s %s)", operands[0], operand))
+		}
+		if len(checks) == 1 {
+			return checks[0], nil
+		}
+		return fmt.Sprintf("(and %s)", strings.Join(checks, " ")), nil
+	case "matches":
+		args, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("matches takes a pattern and a value")
+		}
+		pattern, ok := args["pattern"].(string)
+		if !ok {
+			return "", fmt.Errorf("matches needs a pattern")
+		}
+		operand, err := templateOperand(args["value"])
+		if err != nil {
+			return "", err
+		}
+		// CircleCI patterns must match the whole value
+		return fmt.Sprintf("(regexMatch %s %s)", strconv.Quote("^(?:"+strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")+")$"), operand), nil
+	}
+	return "", fmt.Errorf("unsupported logic statement %s", key)
+}
+
+// templateOperand converts a value compared by equal or matches: a reference becomes
+// its variable, anything else a quoted string (task variables are strings)
+func templateOperand(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		ref, isRef, err := conditionRef(s)
+		if err != nil || isRef {
+			return ref, err
+		}
+	}
+	if value == nil {
+		return `""`, nil
+	}
+	return strconv.Quote(formatParamValue(nil, value)), nil
+}
+
+// inlineStepCommands converts steps into commands in place: command invocations
+// become `task` calls where they appear instead of dependencies
+func inlineStepCommands(jobName string, steps []Step, commands map[string]Command, orbs map[string]interface{}, params map[string]interface{}) TaskCmds {
+	var cmds TaskCmds
+	for _, step := range steps {
+		// Steps are converted one at a time so command calls keep their place
+		stepCmds, deps := convertSteps(jobName, []Step{step}, nil, commands, orbs, params)
+		for _, dep := range deps {
+			cmds = append(cmds, TaskCmd{Cmd: "task " + dep})
+		}
+		cmds = append(cmds, stepCmds...)
+	}
+	return cmds
+}
+
+// conditionRef returns the template variable of a << reference >>, and false for
+// values that are not a reference
+func conditionRef(value string) (string, bool, error) {
+	match := conditionRefRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", false, nil
+	}
+	ref := match[1]
+	if name, ok := pipelineRefVar(ref); ok {
+		return "." + name, true, nil
+	}
+	for _, prefix := range []string{"parameters.", "matrix."} {
+		if strings.HasPrefix(ref, prefix) {
+			return "." + taskVarName(strings.TrimPrefix(ref, prefix)), true, nil
+		}
+	}
+	return "", false, fmt.Errorf("<< %s >> has no local equivalent", ref)
+}
+
+// conditionalCommands converts a when/unless step of a job or command with the given
+// parameters: the nested steps' commands are each guarded by {{if}} on the condition,
+// so they render empty when it is false. Conditions that cannot be converted leave a stub.
+func conditionalCommands(jobName, kind string, block interface{}, commands map[string]Command, orbs map[string]interface{}, params map[string]interface{}) TaskCmds {
+	body, ok := block.(map[string]interface{})
+	if !ok {
+		return shellCommands(fmt.Sprintf("echo 'Conditional step not converted: %s'", kind))
+	}
+	condition, err := templateCondition(body["condition"], parameterTypes("parameters.", params))
+	if err != nil {
+		logger.Warn("conditional step not converted", "job", jobName, "error", err)
+		return shellCommands(fmt.Sprintf("echo 'Conditional step not converted: %s'", strings.ReplaceAll(err.Error(), "'", "")))
+	}
+	if kind == "unless" {
+		condition = fmt.Sprintf("(not %s)", condition)
+	}
+	nested, _ := body["steps"].([]interface{})
+	steps := make([]Step, len(nested))
+	for i, step := range nested {
+		steps[i] = step
+	}
+
+	cmds := inlineStepCommands(jobName, steps, commands, orbs, params)
+	// Literal conditions need no template
+	switch condition {
+	case "true", "(not false)":
+		return cmds
+	case "false", "(not true)":
+		return shellCommands(fmt.Sprintf("# Skipping %s block (its condition is always false)", kind))
+	}
+	for i, cmd := range cmds {
+		// Deferred commands are guarded inside the defer
+		cmds[i] = cmd.withCmd(fmt.Sprintf("{{if %s}}%s{{end}}", condition, cmd.Cmd))
+	}
+	return cmds
+}
@@ -0,0 +1,481 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yaml11Bools are the plain scalars YAML 1.1 parsers (like CircleCI's) read as booleans
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"true": true, "True": true, "TRUE": true,
+	"false": false, "False": false, "FALSE": false,
+	"on": true, "On": true, "ON": true,
+	"off": false, "Off": false, "OFF": false,
+}
+
+// yaml11Bool interprets a decoded value the way a YAML 1.1 parser would
+func yaml11Bool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, ok := yaml11Bools[v]
+		return b, ok
+	}
+	return false, false
+}
+
+// writeConfigFile writes the slimmed CircleCI config, keeping scalars exactly as they
+// were written in the source document where the same path still exists
+func writeConfigFile(path string, config CircleCIConfig, source *yaml.Node) error {
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return err
+	}
+
+	if source != nil && source.Kind == yaml.DocumentNode && len(source.Content) > 0 {
+		restorePlainScalars(&root, source.Content[0])
+		restoreComments(&root, source.Content[0])
+		// The file's leading comment belongs to the document
+		if root.HeadComment == "" {
+			root.HeadComment = source.HeadComment
+		}
+		restoreAnchors(&root, source.Content[0])
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(4)
+	if err := encoder.Encode(&root); err != nil {
+		return err
+	}
+	encoder.Close()
+
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// restorePlainScalars walks the output and source trees in parallel and drops the
+// quoting yaml.v3 adds to YAML 1.1 booleans (yes/no/on/off) that were written plain
+func restorePlainScalars(out, src *yaml.Node) {
+	src = resolveAlias(src)
+	if out == nil || src == nil {
+		return
+	}
+
+	switch out.Kind {
+	case yaml.ScalarNode:
+		if src.Kind == yaml.ScalarNode && src.Style == 0 && src.Value == out.Value && src.Tag == "!!str" {
+			if _, isBool := yaml11Bools[src.Value]; isBool {
+				out.Style = 0
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(out.Content); i += 2 {
+			restorePlainScalars(out.Content[i+1], mappingValue(src, out.Content[i].Value))
+		}
+	case yaml.SequenceNode:
+		if src.Kind != yaml.SequenceNode {
+			return
+		}
+		for i := 0; i < len(out.Content) && i < len(src.Content); i++ {
+			restorePlainScalars(out.Content[i], src.Content[i])
+		}
+	}
+}
+
+// restoreComments copies the comments of the source onto the output wherever the same
+// key is still there, so explanations stay next to the sections they describe. Scalars
+// and sequence items keep theirs only when unchanged: the steps of converted jobs, for
+// one, are replaced and their comments would describe steps that are gone.
+func restoreComments(out, src *yaml.Node) {
+	if out == nil || src == nil {
+		return
+	}
+	copyComments(out, src)
+	src = resolveAlias(src)
+
+	switch out.Kind {
+	case yaml.ScalarNode:
+		if src.Kind != yaml.ScalarNode || src.Value != out.Value {
+			out.HeadComment, out.LineComment, out.FootComment = "", "", ""
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(out.Content); i += 2 {
+			key, value := mappingEntry(src, out.Content[i].Value)
+			copyComments(out.Content[i], key)
+			restoreComments(out.Content[i+1], value)
+		}
+	case yaml.SequenceNode:
+		if src.Kind != yaml.SequenceNode {
+			return
+		}
+		for i := 0; i < len(out.Content) && i < len(src.Content); i++ {
+			if nodesEqual(out.Content[i], src.Content[i]) {
+				restoreComments(out.Content[i], src.Content[i])
+			}
+		}
+	}
+}
+
+// copyComments copies the comments of a source node the output node has none of
+func copyComments(out, src *yaml.Node) {
+	if src == nil || out.HeadComment != "" || out.LineComment != "" || out.FootComment != "" {
+		return
+	}
+	out.HeadComment, out.LineComment, out.FootComment = src.HeadComment, src.LineComment, src.FootComment
+}
+
+// mappingValue looks up a key in a mapping node, following `<<` merge keys
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	_, value := mappingEntry(node, key)
+	return value
+}
+
+// mappingEntry looks up the key and value nodes of a key in a mapping node, following
+// `<<` merge keys
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "<<" {
+			continue
+		}
+		merge := resolveAlias(node.Content[i+1])
+		if merge.Kind == yaml.SequenceNode {
+			for _, item := range merge.Content {
+				if k, value := mappingEntry(item, key); value != nil {
+					return k, value
+				}
+			}
+		} else if k, value := mappingEntry(merge, key); value != nil {
+			return k, value
+		}
+	}
+
+	return nil, nil
+}
+
+// resolveAlias returns the node an alias points to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// formatParamValue renders a parameter value for go-task, normalizing YAML 1.1
+// booleans (yes/no/on/off) to true/false for boolean-typed parameters
+func formatParamValue(paramDef interface{}, value interface{}) string {
+	if def, ok := paramDef.(map[string]interface{}); ok && def["type"] == "boolean" {
+		if b, ok := yaml11Bool(value); ok {
+			if b {
+				return "true"
+			}
+			return "false"
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// mergeUse is an output mapping holding the keys a `<<` merge key gave its source
+type mergeUse struct {
+	mapping *yaml.Node
+	keys    []string
+}
+
+// anchorTracker collects, per source node, where the output repeats it
+type anchorTracker struct {
+	merges     map[*yaml.Node][]mergeUse
+	mergeOrder []*yaml.Node
+	owner      map[*yaml.Node]*yaml.Node // output node to the source node it was written from
+	paths      map[*yaml.Node][]string   // output node to its path, for anchor names
+	parents    map[*yaml.Node]sourceParent
+	names      map[string]bool // anchor names in use
+}
+
+// sourceParent is the mapping holding a source node, and its key there
+type sourceParent struct {
+	mapping *yaml.Node
+	key     string
+}
+
+// anchorNameRegex matches the characters anchor names are made of
+var anchorNameRegex = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// restoreAnchors writes anchors and aliases back into the output where the source
+// reached the same node more than once and the output still holds it unchanged:
+// `<<: *defaults` merges whose keys all survive stay merges, and repeated mappings
+// and sequences become aliases of their first occurrence. Without this, every alias
+// of the source is written out in full.
+func restoreAnchors(out, src *yaml.Node) {
+	tracker := &anchorTracker{
+		merges:  make(map[*yaml.Node][]mergeUse),
+		owner:   make(map[*yaml.Node]*yaml.Node),
+		paths:   make(map[*yaml.Node][]string),
+		parents: make(map[*yaml.Node]sourceParent),
+		names:   make(map[string]bool),
+	}
+	tracker.indexSource(src, make(map[*yaml.Node]bool))
+	tracker.collect(out, src, nil)
+
+	for _, target := range tracker.mergeOrder {
+		tracker.restoreMerge(target)
+	}
+
+	tracker.restoreAliases(out)
+}
+
+// indexSource records the parent of each source node and the anchor names in use
+func (t *anchorTracker) indexSource(node *yaml.Node, seen map[*yaml.Node]bool) {
+	if node == nil || seen[node] {
+		return
+	}
+	seen[node] = true
+	if node.Anchor != "" {
+		t.names[node.Anchor] = true
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if _, exists := t.parents[node.Content[i+1]]; !exists {
+				t.parents[node.Content[i+1]] = sourceParent{mapping: node, key: node.Content[i].Value}
+			}
+		}
+	}
+	for _, child := range node.Content {
+		t.indexSource(child, seen)
+	}
+}
+
+// collect walks the output and source trees in parallel, in document order
+func (t *anchorTracker) collect(out, src *yaml.Node, path []string) {
+	target := resolveAlias(src)
+	if out == nil || target == nil || out.Kind != target.Kind {
+		return
+	}
+
+	switch out.Kind {
+	case yaml.MappingNode:
+		t.record(out, target, path)
+		covered := t.collectMerges(out, target)
+		for i := 0; i+1 < len(out.Content); i += 2 {
+			key := out.Content[i].Value
+			if !covered[key] {
+				t.collect(out.Content[i+1], mappingValue(target, key), append(path[:len(path):len(path)], key))
+			}
+		}
+	case yaml.SequenceNode:
+		t.record(out, target, path)
+		for i := 0; i < len(out.Content) && i < len(target.Content); i++ {
+			t.collect(out.Content[i], target.Content[i], append(path[:len(path):len(path)], fmt.Sprint(i)))
+		}
+	}
+}
+
+// record notes that out was written from the source node target
+func (t *anchorTracker) record(out, target *yaml.Node, path []string) {
+	t.owner[out] = target
+	t.paths[out] = path
+}
+
+// collectMerges records the `<<` merges of a source mapping whose keys the output
+// mapping still holds unchanged, and returns those keys
+func (t *anchorTracker) collectMerges(out, target *yaml.Node) map[string]bool {
+	covered := make(map[string]bool)
+	own := make(map[string]bool)
+	var merged []*yaml.Node
+	for i := 0; i+1 < len(target.Content); i += 2 {
+		if target.Content[i].Value != "<<" {
+			own[target.Content[i].Value] = true
+			continue
+		}
+		value := target.Content[i+1]
+		if value.Kind == yaml.SequenceNode {
+			merged = append(merged, value.Content...)
+		} else {
+			merged = append(merged, value)
+		}
+	}
+
+	for _, alias := range merged {
+		// Only merges of an anchored mapping can be written as an alias again
+		if alias.Kind != yaml.AliasNode {
+			continue
+		}
+		mapping := resolveAlias(alias)
+		if mapping == nil || mapping.Kind != yaml.MappingNode {
+			continue
+		}
+		var keys []string
+		applies := true
+		for i := 0; i+1 < len(mapping.Content) && applies; i += 2 {
+			key := mapping.Content[i].Value
+			if own[key] || covered[key] {
+				continue
+			}
+			value := outMappingValue(out, key)
+			applies = key != "<<" && value != nil && mappingValue(target, key) == mapping.Content[i+1] && nodesEqual(value, mapping.Content[i+1])
+			keys = append(keys, key)
+		}
+		if !applies || len(keys) == 0 {
+			continue
+		}
+		if len(t.merges[mapping]) == 0 {
+			t.mergeOrder = append(t.mergeOrder, mapping)
+		}
+		t.merges[mapping] = append(t.merges[mapping], mergeUse{mapping: out, keys: keys})
+		for _, key := range keys {
+			covered[key] = true
+		}
+	}
+	return covered
+}
+
+// restoreMerge writes the keys a merged source mapping gave the output mappings back
+// as a merge: `<<: &name` with the keys at the first use, `<<: *name` at the others
+func (t *anchorTracker) restoreMerge(target *yaml.Node) {
+	uses := t.merges[target]
+	if len(uses) < 2 {
+		return
+	}
+	name := t.anchorName(target, nil)
+	var anchored *yaml.Node
+	for _, use := range uses {
+		var pairs []*yaml.Node
+		var rest []*yaml.Node
+		position := -1
+		for i := 0; i+1 < len(use.mapping.Content); i += 2 {
+			if containsString(use.keys, use.mapping.Content[i].Value) {
+				if position == -1 {
+					position = len(rest)
+				}
+				pairs = append(pairs, use.mapping.Content[i], use.mapping.Content[i+1])
+				continue
+			}
+			rest = append(rest, use.mapping.Content[i], use.mapping.Content[i+1])
+		}
+
+		var value *yaml.Node
+		if anchored == nil {
+			anchored = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Anchor: name, Content: pairs}
+			value = anchored
+		} else {
+			value = &yaml.Node{Kind: yaml.AliasNode, Alias: anchored, Value: name}
+		}
+		merge := []*yaml.Node{{Kind: yaml.ScalarNode, Value: "<<"}, value}
+		use.mapping.Content = append(rest[:position:position], append(merge, rest[position:]...)...)
+	}
+}
+
+// restoreAliases replaces output nodes written from a repeated source node with
+// aliases of the first one holding the same content, which gets the anchor. It walks
+// the output in document order, so anchors come before their aliases, and skips the
+// nodes inside replaced ones.
+func (t *anchorTracker) restoreAliases(root *yaml.Node) {
+	first := make(map[*yaml.Node]*yaml.Node) // source node to its first output node
+	var anchored []*yaml.Node
+	replaced := make(map[*yaml.Node]*yaml.Node) // output node to the node it aliases
+	var replacedOrder []*yaml.Node
+
+	var walk func(out *yaml.Node)
+	walk = func(out *yaml.Node) {
+		if target, ok := t.owner[out]; ok {
+			if f, seen := first[target]; !seen {
+				first[target] = out
+			} else if nodesEqual(f, out) {
+				if !containsNode(anchored, f) {
+					anchored = append(anchored, f)
+				}
+				replaced[out] = f
+				replacedOrder = append(replacedOrder, out)
+				return
+			}
+		}
+		for _, child := range out.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	for _, out := range anchored {
+		if out.Anchor == "" {
+			out.Anchor = t.anchorName(t.owner[out], t.paths[out])
+		}
+	}
+	for _, out := range replacedOrder {
+		f := replaced[out]
+		*out = yaml.Node{Kind: yaml.AliasNode, Alias: f, Value: f.Anchor, HeadComment: out.HeadComment, LineComment: out.LineComment, FootComment: out.FootComment}
+	}
+}
+
+// containsNode reports whether nodes holds node
+func containsNode(nodes []*yaml.Node, node *yaml.Node) bool {
+	for _, n := range nodes {
+		if n == node {
+			return true
+		}
+	}
+	return false
+}
+
+// anchorName names the anchor of a source node: its own anchor, its parent's followed
+// by its key, or the end of its output path
+func (t *anchorTracker) anchorName(target *yaml.Node, path []string) string {
+	if target.Anchor != "" {
+		return target.Anchor
+	}
+	var name string
+	if parent, ok := t.parents[target]; ok && parent.mapping.Anchor != "" {
+		name = parent.mapping.Anchor + "_" + parent.key
+	} else {
+		if len(path) > 2 {
+			path = path[len(path)-2:]
+		}
+		name = strings.Join(path, "_")
+	}
+	name = strings.Trim(anchorNameRegex.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "anchor"
+	}
+	unique := name
+	for i := 2; t.names[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	t.names[unique] = true
+	return unique
+}
+
+// outMappingValue looks up a key in an output mapping node
+func outMappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodesEqual reports whether two nodes hold the same data
+func nodesEqual(a, b *yaml.Node) bool {
+	var x, y interface{}
+	if a.Decode(&x) != nil || b.Decode(&y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,181 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// contextEnvFile is the dotenv file holding a context's variables locally
+func contextEnvFile(context string) string {
+	return ".env." + context
+}
+
+// contextEnvExample is the template of a context's dotenv file written by the converter
+func contextEnvExample(context string) string {
+	return contextEnvFile(context) + ".example"
+}
+
+// contextVariables maps each context to the variables that the jobs attached to it use
+// and the config does not define, as found by collectSecretVars. When the contexts were
+// looked up on CircleCI, a variable of jobs with several contexts goes to those holding
+// it, and each context also lists its other variables.
+func contextVariables(config CircleCIConfig) map[string][]SecretVar {
+	held := make(map[string]map[string]bool)
+	for context, names := range config.contextVars {
+		held[context] = make(map[string]bool, len(names))
+		for _, name := range names {
+			held[context][name] = true
+		}
+	}
+
+	byContext := make(map[string][]SecretVar)
+	for _, v := range collectSecretVars(config) {
+		if v.Hardcoded {
+			continue
+		}
+		var holders []string
+		for _, context := range v.Contexts {
+			if held[context][v.Name] {
+				holders = append(holders, context)
+			}
+		}
+		if len(holders) > 0 {
+			v.Contexts = holders
+		}
+		for _, context := range v.Contexts {
+			if context != defaultContext {
+				byContext[context] = append(byContext[context], v)
+			}
+		}
+	}
+	if len(config.contextVars) == 0 {
+		return byContext
+	}
+
+	contextJobs := make(map[string][]string)
+	jobs := jobContexts(config.Workflows)
+	for _, job := range sortedJobNames(config.Jobs) {
+		for _, context := range jobs[job] {
+			contextJobs[context] = append(contextJobs[context], job)
+		}
+	}
+	for context, names := range config.contextVars {
+		listed := make(map[string]bool)
+		for _, vars := range byContext {
+			for _, v := range vars {
+				listed[v.Name] = true
+			}
+		}
+		for _, name := range names {
+			if !listed[name] {
+				byContext[context] = append(byContext[context], SecretVar{Name: name, Contexts: []string{context}, Jobs: contextJobs[context], OnCircleCI: true})
+			}
+		}
+	}
+	return byContext
+}
+
+// lookupContextVariables reads the variables of the contexts the config uses from the
+// organization of a CircleCI project
+func lookupContextVariables(client *CircleCIClient, projectSlug string, config CircleCIConfig) (map[string][]string, error) {
+	owner, err := projectOwner(projectSlug)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, contexts := range jobContexts(config.Workflows) {
+		for _, context := range contexts {
+			used[context] = true
+		}
+	}
+	if len(used) == 0 {
+		return nil, nil
+	}
+	var contexts []string
+	for context := range used {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+	return client.ContextVariables(owner, contexts)
+}
+
+// addContextDotenv loads the dotenv file of each context a job is attached to into its
+// task. go-task skips files that do not exist, so tasks still run without them.
+func addContextDotenv(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	contexts := jobContexts(config.Workflows)
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		task, ok := taskfile.Tasks[jobName]
+		if !ok || len(contexts[jobName]) == 0 {
+			continue
+		}
+		var files []string
+		for _, context := range contexts[jobName] {
+			task.Dotenv = append(task.Dotenv, "{{.ROOT_DIR}}/"+contextEnvFile(context))
+			files = append(files, contextEnvFile(context))
+		}
+		taskfile.Tasks[jobName] = task
+		report.Add("Contexts", jobName, "uses context %s; its variables are loaded from %s (see the .example files)", strings.Join(contexts[jobName], ", "), strings.Join(files, ", "))
+	}
+}
+
+// generateContextEnvExamples writes a .env.<context>.example file per context listing
+// the variables the jobs attached to it need, and returns the files written
+func generateContextEnvExamples(config CircleCIConfig, outputDir string) ([]string, error) {
+	contextJobs := make(map[string][]string)
+	for job, contexts := range jobContexts(config.Workflows) {
+		for _, context := range contexts {
+			contextJobs[context] = append(contextJobs[context], job)
+		}
+	}
+	vars := contextVariables(config)
+
+	var contexts []string
+	for context := range contextJobs {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+
+	var written []string
+	for _, context := range contexts {
+		jobs := contextJobs[context]
+		sort.Strings(jobs)
+
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("# Variables of the CircleCI context %q, used by: %s\n", context, strings.Join(jobs, ", ")))
+		b.WriteString(fmt.Sprintf("# Copy to %s and fill in the values; the job tasks load it. Keep it out of git.\n", contextEnvFile(context)))
+		if len(vars[context]) == 0 {
+			b.WriteString("# The jobs reference no variables the config does not define.\n")
+		}
+		for _, v := range vars[context] {
+			b.WriteString("\n")
+			if v.OnCircleCI {
+				b.WriteString("# Set in the context on CircleCI; the config does not reference it directly\n")
+			} else if len(v.Contexts) > 1 {
+				b.WriteString(fmt.Sprintf("# Used by %s; also listed under %s, set it where the value lives\n", strings.Join(v.Jobs, ", "), strings.Join(otherContexts(v.Contexts, context), ", ")))
+			} else {
+				b.WriteString(fmt.Sprintf("# Used by %s\n", strings.Join(v.Jobs, ", ")))
+			}
+			b.WriteString(v.Name + "=\n")
+		}
+
+		name := contextEnvExample(context)
+		if err := writeTextFile(filepath.Join(outputDir, name), b.String()); err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+// otherContexts returns contexts without the given one
+func otherContexts(contexts []string, context string) []string {
+	var others []string
+	for _, c := range contexts {
+		if c != context {
+			others = append(others, c)
+		}
+	}
+	return others
+}
@@ -0,0 +1,922 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// convertConfig converts CircleCI config to orchestration-only config + Taskfile
+func convertConfig(config CircleCIConfig, opts ConvertOptions, report *ConversionReport) (CircleCIConfig, Taskfile) {
+	newConfig := CircleCIConfig{
+		Version:   config.Version,
+		Orbs:      config.Orbs,
+		Jobs:      make(map[string]Job),
+		Commands:  nil, // Remove commands from new config - they become tasks
+		Workflows: config.Workflows,
+		Executors: userDefinedOnly(config.Executors),
+		// Workflows, triggers and the job task calls still refer to pipeline parameters
+		Parameters: config.Parameters,
+	}
+
+	// Notes from translating another CI system's config come first
+	for _, note := range config.notes {
+		report.Add(note.Category, note.Job, "%s", note.Message)
+	}
+
+	// Inline orb commands and executors so orb steps convert like native commands.
+	// The new config keeps the orbs stanza, so inlined entries are left out of it.
+	resolveOrbs(&config, opts.Orbs, report)
+	// User step mappings win over orbs and commands, inlined or not
+	applyStepMap(&config, opts.StepMap, report)
+	// Plugins convert the steps left, before the built-in conversions
+	applyPlugins(&config, opts.Plugins, report)
+
+	// Pre-steps and post-steps shared by every invocation of a job run in its task
+	sharedSteps := sharedInvocationSteps(config.Workflows)
+	newConfig.Workflows = withoutInvocationSteps(config.Workflows, expandInvocationSteps(&config, sharedSteps, report))
+
+	taskfile := Taskfile{
+		Version: "3",
+		Tasks:   make(map[string]Task),
+	}
+
+	// Flag hardcoded credentials before they are copied into the Taskfile
+	auditSecrets(config, report)
+
+	// Extract common patterns and deduplicate
+	patterns := analyzePatterns(config)
+	// With -shell-lib, jobs keep repeated commands inline until applyShellLib; with
+	// -docker they keep them inline to run them in their image
+	jobPatterns := patterns
+	if opts.ShellLib || opts.Docker {
+		jobPatterns = nil
+	}
+	
+	// Convert CircleCI commands to tasks
+	commandTasks := convertCommandsToTasks(config.Commands, config.Orbs)
+	for name, task := range commandTasks {
+		taskfile.Tasks[name] = task
+	}
+	
+	// Branch and tag filters from workflows become preconditions on the job tasks
+	jobFilters := collectJobFilters(config.Workflows, report)
+
+	// Convert each job, in order so report entries are stable
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		job := config.Jobs[jobName]
+		// Create task from job steps
+		task := buildJobTask(jobName, job, config, jobPatterns, jobFilters, report)
+		taskfile.Tasks[jobName] = task
+
+		// Create minimal CircleCI job that just calls the task
+		// If the job has parameters, we need to handle them in the workflow invocations
+		taskCall := fmt.Sprintf("task %s", jobName) + pipelineArgs(job, config.Commands)
+		
+		newJob := Job{
+			Executor:   job.Executor,
+			Docker:     job.Docker,
+			Machine:    job.Machine,
+			Macos:      job.Macos,
+			Windows:    job.Windows,
+			Parameters: job.Parameters, // Keep parameters for workflow invocations
+			// Keep parallelism so CircleCI still runs the split test nodes
+			Parallelism: job.Parallelism,
+			// CircleCI still runs the task on the same machine size
+			ResourceClass: job.ResourceClass,
+			Steps: []Step{
+				map[string]interface{}{"run": taskCall},
+			},
+		}
+		newConfig.Jobs[jobName] = newJob
+	}
+
+	// Secondary docker images run as docker compose services for local runs
+	addServiceContainers(&taskfile, config, report)
+	// add_ssh_keys steps need the keys locally
+	addSSHKeyPreconditions(&taskfile, config, report)
+	// GPU, arm and large resource classes may not be available locally
+	addResourceClassChecks(&taskfile, config, report)
+
+	// Workflows may invoke jobs straight from orbs (node/test); give them local tasks too
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		if _, isLocal := config.Jobs[invocation.Job]; isLocal {
+			continue
+		}
+		// Orb commands and jobs live in separate namespaces and may share a name
+		taskName := invocation.Job
+		if _, isCommand := config.Commands[taskName]; isCommand {
+			taskName += "-job"
+		}
+		if _, done := taskfile.Tasks[taskName]; done {
+			continue
+		}
+		orbJob, ok := config.orbJobs[invocation.Job]
+		if !ok {
+			// Popular orb jobs run the local equivalents of their usual steps
+			if mapped, isMapped := orbJobFromMapping(invocation.Job, invocation.Arguments, config.Orbs); isMapped {
+				var ran []string
+				for _, step := range mapped.Steps {
+					ran = append(ran, stepType(step))
+				}
+				if added, ok := sharedSteps[invocation.Job]; ok {
+					mapped.Steps = withInvocationSteps(mapped.Steps, added, config.Commands)
+				}
+				task := buildJobTask(taskName, mapped, config, jobPatterns, jobFilters, report)
+				task.Desc = fmt.Sprintf("Task converted from orb job: %s (mapped, orb not resolved)", invocation.Job)
+				taskfile.Tasks[taskName] = task
+				report.Add("Orbs", invocation.Job, "orb could not be resolved; the job was mapped to local task %s running %s", taskName, strings.Join(ran, ", "))
+				continue
+			}
+			if strings.Contains(invocation.Job, "/") {
+				report.Add("Orbs", invocation.Job, "orb job could not be resolved; no local task was generated")
+			}
+			continue
+		}
+
+		// Resolve job parameters used in the executor reference (image tags, mostly)
+		args := invocation.Arguments
+		orbJob.Executor = substituteParameters(orbJob.Executor, executorParameterValues(map[string]interface{}{"parameters": orbJob.Parameters}, args))
+
+		if added, ok := sharedSteps[invocation.Job]; ok {
+			orbJob.Steps = withInvocationSteps(orbJob.Steps, added, config.Commands)
+			report.Add("Pre/post steps", invocation.Job, "the %d pre-steps and %d post-steps of its workflow invocations run in task %s", len(added.Pre), len(added.Post), taskName)
+		}
+		task := buildJobTask(taskName, orbJob, config, jobPatterns, jobFilters, report)
+		task.Desc = fmt.Sprintf("Task converted from orb job: %s", invocation.Job)
+		for argName, argValue := range args {
+			if task.Vars == nil {
+				task.Vars = make(map[string]string)
+			}
+			value := formatParamValue(orbJob.Parameters[argName], argValue)
+			task.Vars[taskVarName(argName)] = fmt.Sprintf("{{.%s | default \"%s\"}}", taskVarName(argName), value)
+		}
+		taskfile.Tasks[taskName] = task
+		report.Add("Orbs", invocation.Job, "orb job converted to local task %s; CircleCI still runs the orb job itself", taskName)
+	}
+
+	// Invocations passing their own executor get tasks running in that executor
+	addExecutorInvocationTasks(&taskfile, config, report)
+
+	// Invocations adding pre-steps or post-steps of their own get tasks running them
+	addInvocationStepTasks(&taskfile, config, report)
+
+	// Invocations with a `name:` alias get a task calling the job with their arguments
+	addAliasTasks(&taskfile, config, report)
+
+	// Expand matrix invocations into per-variant tasks
+	addMatrixTasks(&taskfile, config, report)
+
+	// Add common pattern tasks, or the shell functions replacing them
+	if opts.ShellLib {
+		applyShellLib(&taskfile, patterns, report)
+	} else {
+		for name, task := range patterns {
+			taskfile.Tasks[name] = task
+		}
+	}
+
+	// Emulate `circleci tests glob | circleci tests split` outside CircleCI
+	applyTestsSplit(&taskfile, config, report)
+
+	// Report risky commands and block them unless explicitly allowed, before the halt
+	// guards and retry wrappers below hide them
+	guardRiskyCommands(&taskfile, opts.AllowRisky, report)
+
+	// Halt tasks on `circleci-agent step halt` and skip other agent calls outside CircleCI
+	applyAgentCommands(&taskfile, config, report)
+
+	// Wrap retry loops (and, if requested, every command) in retry wrappers with backoff
+	applyRetryPolicies(&taskfile, opts.Retry, report)
+
+	// Variables exported to $BASH_ENV reach the task's later commands
+	applyBashEnv(&taskfile, config, report)
+
+	// Workflows guarded by when/unless get a task running them if the condition holds
+	addWorkflowConditionTasks(&taskfile, config, report)
+
+	// Jobs attached to contexts load the contexts' dotenv files
+	addContextDotenv(&taskfile, config, report)
+
+	// Scheduled workflows get a task to run from cron
+	addScheduleTasks(&taskfile, config, report)
+
+	// Browse stored artifacts locally
+	addArtifactTasks(&taskfile)
+	addTestReportTask(&taskfile)
+
+	// Add local development helpers
+	addLocalDevTasks(&taskfile)
+
+	// Pipeline parameters and values become global vars with their local defaults
+	addPipelineParameterVars(&taskfile, config, report)
+	addPipelineValueVars(&taskfile, config, report)
+
+	// Add environment variable defaults for local development
+	addLocalEnvDefaults(&taskfile, config, report)
+
+	// setup_remote_docker steps use the host's daemon with -host-docker
+	if opts.HostDocker {
+		applyHostDocker(&taskfile, report)
+	}
+
+	// With -docker, the commands run in the executors' images, after every rewrite of them
+	if opts.Docker {
+		applyDockerWrap(&taskfile, config, report)
+	}
+
+	// Tasks of configs translated from other CI systems name the system they came from
+	if config.format != "" {
+		relabelTaskSources(&taskfile, inputFormatName(config))
+	}
+
+	return newConfig, taskfile
+}
+
+// buildJobTask converts a job into a task with branch filter preconditions and
+// the environment of its resolved executor
+func buildJobTask(jobName string, job Job, config CircleCIConfig, patterns map[string]Task, jobFilters map[string]WorkflowFilters, report *ConversionReport) Task {
+	// Resolve the job's executor (including parameterized executors) for image and env info
+	resolved, err := resolveJobExecutor(job, config.Executors)
+
+	// The job's working directory, or its executor's, relative to the project root.
+	// Pattern tasks run from the root, so jobs in a subdirectory keep their commands.
+	workingDir := job.WorkingDirectory
+	if workingDir == "" {
+		workingDir = resolved.WorkingDirectory
+	}
+	dir := monorepoDir(workingDir)
+	if dir != "" {
+		patterns = nil
+	}
+
+	task := convertJobToTask(jobName, job, patterns, config.Commands, config.Orbs)
+	// Background steps of the invoked commands run until the job ends
+	if stops := commandBackgroundStops(job, config.Commands); len(stops) > 0 {
+		task.Cmds = append(stops, task.Cmds...)
+	}
+	for _, step := range job.Steps {
+		switch runStepWhen(step) {
+		case "always":
+			report.Add("Final steps", jobName, "`%s` (when: always) is deferred: it runs after the other commands, even when one fails", firstLine(extractCommand(step)))
+		case "on_fail":
+			report.Add("Final steps", jobName, "`%s` (when: on_fail) is deferred and only runs when the task fails", firstLine(extractCommand(step)))
+		}
+	}
+	for _, step := range backgroundSteps(job.Steps) {
+		output, _ := backgroundFiles(jobName, step)
+		report.Add("Background steps", jobName, "`%s` runs in the background until the task ends, with its output in %s", firstLine(extractCommand(step)), strings.TrimPrefix(output, "{{.ROOT_DIR}}/"))
+	}
+	if dir != "" {
+		task.Dir = dir
+		report.Add("Working directories", jobName, "runs in %s (working_directory %s)", dir, workingDir)
+	} else if _, inProject := projectSubdir(workingDir); !inProject && (strings.HasPrefix(workingDir, "/") || strings.HasPrefix(workingDir, "~")) {
+		report.Add("Working directories", jobName, "working_directory %s is outside the CircleCI project directory; the task runs in the project root", workingDir)
+	}
+	if filters, ok := jobFilters[jobName]; ok {
+		task.Preconditions = append(task.Preconditions, filterPreconditions(jobName, filters)...)
+		if len(filters.Branches.Only) > 0 {
+			report.Add("Branch filters", jobName, "only runs on branches %s; converted to a precondition", strings.Join(filters.Branches.Only, ", "))
+		}
+		if len(filters.Branches.Ignore) > 0 {
+			report.Add("Branch filters", jobName, "skipped on branches %s; converted to a negated precondition", strings.Join(filters.Branches.Ignore, ", "))
+		}
+		if len(filters.Tags.Only) > 0 {
+			report.Add("Tag filters", jobName, "runs on tags %s; converted to a precondition on CIRCLE_TAG, which also skips the branch filters when set", strings.Join(filters.Tags.Only, ", "))
+		}
+		if len(filters.Tags.Ignore) > 0 {
+			report.Add("Tag filters", jobName, "skipped on tags %s; converted to a negated precondition on CIRCLE_TAG", strings.Join(filters.Tags.Ignore, ", "))
+		}
+	}
+
+	for _, step := range job.Steps {
+		if retried, policy, ok := retryOrbStep(step); ok {
+			report.Add("Retries", jobName, "retry step `%s` converted to %d attempts with %ds exponential backoff", firstLine(retried), policy.Attempts, policy.Delay)
+		}
+		if name, ok := isCommandInvocation(step); ok {
+			args, _ := step.(map[string]interface{})[name].(map[string]interface{})
+			for _, arg := range sortedKeys(args) {
+				if paramType(config.Commands[name].Parameters[arg]) == "steps" {
+					report.Add("Parameters", jobName, "passes its own steps as `%s` to %s; the task runs the command's default steps instead", arg, name)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		report.Add("Executors", jobName, "could not resolve executor: %v", err)
+	} else if resolved.Name != "" {
+		report.Add("Executors", jobName, "runs on %s", describeExecutor(resolved))
+	}
+
+	// Jobs on macOS and Windows executors only run on those hosts
+	if err == nil && resolved.Platform != "linux" {
+		task.Platforms = []string{resolved.Platform}
+		report.Add("Platforms", jobName, "runs on a %s executor; the task declares platforms [%s], so go-task skips it on other hosts", platformNames[resolved.Platform], resolved.Platform)
+		if resolved.Platform == "windows" && job.Shell == "" && resolved.Shell == "" {
+			for _, step := range job.Steps {
+				if stepType(step) == "run" && runStepShell(step) == "" {
+					report.Add("Platforms", jobName, "CircleCI runs its steps in PowerShell on Windows; the task runs them in go-task's shell, set `shell:` on the job to keep PowerShell")
+					break
+				}
+			}
+		}
+	}
+
+	// The executor's primary image, for running the task in the job's container
+	if len(resolved.Images) > 0 {
+		if task.Vars == nil {
+			task.Vars = make(map[string]string)
+		}
+		task.Vars["EXECUTOR_IMAGE"] = resolved.Images[0]
+	}
+
+	// Executor environment applies to every step; job-level values take precedence
+	env := make(map[string]string)
+	for key, value := range resolved.Environment {
+		env[key] = value
+	}
+	mergeEnvironment(env, job.Environment)
+	for key, value := range env {
+		env[key] = convertParameterSyntax(escapeTemplateBraces(value))
+	}
+	if len(env) > 0 {
+		task.Env = env
+	}
+
+	return task
+}
+
+// CommandInfo holds information about a command including usage count
+type CommandInfo struct {
+	Command string
+	Count   int
+}
+
+// extractAllCommands extracts all commands from the CircleCI config with usage counts
+func extractAllCommands(config CircleCIConfig) []CommandInfo {
+	commandCounts := make(map[string]int)
+	
+	// Extract from jobs
+	for _, job := range config.Jobs {
+		for _, step := range job.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				subCommands := extractIndividualCommands(cmd)
+				for _, subCmd := range subCommands {
+					cleanCmd := cleanCommandForAnalysis(subCmd)
+					if cleanCmd != "" {
+						commandCounts[cleanCmd]++
+					}
+				}
+			}
+		}
+	}
+	
+	// Extract from commands
+	for _, command := range config.Commands {
+		for _, step := range command.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				subCommands := extractIndividualCommands(cmd)
+				for _, subCmd := range subCommands {
+					cleanCmd := cleanCommandForAnalysis(subCmd)
+					if cleanCmd != "" {
+						commandCounts[cleanCmd]++
+					}
+				}
+			}
+		}
+	}
+	
+	// Convert map to sorted slice
+	var commands []CommandInfo
+	for cmd, count := range commandCounts {
+		commands = append(commands, CommandInfo{Command: cmd, Count: count})
+	}
+	
+	// Sort by count (descending) then by command name
+	for i := 0; i < len(commands); i++ {
+		for j := i + 1; j < len(commands); j++ {
+			if commands[i].Count < commands[j].Count || 
+			   (commands[i].Count == commands[j].Count && commands[i].Command > commands[j].Command) {
+				commands[i], commands[j] = commands[j], commands[i]
+			}
+		}
+	}
+	
+	return commands
+}
+
+// extractIndividualCommands splits multi-line commands into individual command lines
+func extractIndividualCommands(cmd string) []string {
+	var commands []string
+	
+	// Split by newlines and also by && operators
+	lines := strings.Split(cmd, "\n")
+	
+	for _, line := range lines {
+		// Split by && to get individual commands on same line
+		parts := strings.Split(line, "&&")
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+			if part != "" && !strings.HasPrefix(part, "#") { // Skip empty lines and comments
+				commands = append(commands, part)
+			}
+		}
+	}
+	
+	return commands
+}
+
+// cleanCommandForAnalysis cleans up commands for technology analysis
+func cleanCommandForAnalysis(cmd string) string {
+	// Remove parameter syntax and variables for cleaner analysis
+	cleaned := convertParameterSyntax(cmd)
+	
+	// Remove environment variables for cleaner output
+	envRegex := regexp.MustCompile(`\$[A-Z_][A-Z0-9_]*|\$\{[A-Z_][A-Z0-9_]*\}`)
+	cleaned = envRegex.ReplaceAllString(cleaned, "${VAR}")
+	
+	// Never copy hardcoded credentials into the analysis
+	cleaned = redactSecrets(cleaned)
+
+	// Normalize whitespace but preserve line breaks for multi-line commands
+	cleaned = strings.TrimSpace(cleaned)
+	
+	// Skip empty or very short commands
+	if len(cleaned) < 3 {
+		return ""
+	}
+	
+	return cleaned
+}
+
+// generateTechnologyAnalysis creates a markdown file with all commands for AI analysis
+func generateTechnologyAnalysis(config CircleCIConfig, outputDir string) error {
+	commands := extractAllCommands(config)
+	
+	if len(commands) == 0 {
+		return nil // No commands to analyze
+	}
+	
+	var content strings.Builder
+	
+	content.WriteString("# Technology Analysis Report\n\n")
+	content.WriteString("This file contains all commands extracted from the CircleCI configuration for technology categorization.\n\n")
+	content.WriteString("## Instructions for AI Analysis\n\n")
+	content.WriteString("Please categorize these commands by technology/tool type. Commands are sorted by usage frequency (most used first).\n\n")
+	content.WriteString("Suggested categories:\n")
+	content.WriteString("- **Package Managers**: npm, yarn, pip, composer, etc.\n")
+	content.WriteString("- **Build Tools**: webpack, gulp, maven, gradle, etc.\n")
+	content.WriteString("- **Testing**: jest, pytest, phpunit, go test, etc.\n")
+	content.WriteString("- **Cloud/Infrastructure**: aws, gcloud, kubectl, terraform, etc.\n")
+	content.WriteString("- **Containers**: docker, podman, etc.\n")
+	content.WriteString("- **Languages**: node, python, php, go, java, etc.\n")
+	content.WriteString("- **Databases**: mysql, postgres, redis, etc.\n")
+	content.WriteString("- **Other Tools**: git, curl, ssh, etc.\n\n")
+	
+	// Calculate total usage
+	totalUsage := 0
+	for _, cmd := range commands {
+		totalUsage += cmd.Count
+	}
+	
+	content.WriteString(fmt.Sprintf("## All Commands (%d unique commands, %d total usages)\n\n", len(commands), totalUsage))
+	
+	for i, cmd := range commands {
+		percentage := float64(cmd.Count) / float64(totalUsage) * 100
+		content.WriteString(fmt.Sprintf("%d. `%s` **(used %d times, %.1f%%)**\n", i+1, cmd.Command, cmd.Count, percentage))
+	}
+	
+	content.WriteString("\n")
+	content.WriteString("## Usage Summary\n\n")
+	content.WriteString("Commands ordered by frequency can help prioritize which technologies are most important in this configuration.\n\n")
+	
+	content.WriteString("## Technology Categories\n\n")
+	content.WriteString("*Please fill in this section after AI analysis*\n\n")
+	content.WriteString("### Package Managers\n- \n\n")
+	content.WriteString("### Build Tools\n- \n\n") 
+	content.WriteString("### Testing Frameworks\n- \n\n")
+	content.WriteString("### Cloud/Infrastructure\n- \n\n")
+	content.WriteString("### Container Tools\n- \n\n")
+	content.WriteString("### Programming Languages\n- \n\n")
+	content.WriteString("### Databases\n- \n\n")
+	content.WriteString("### Other Tools\n- \n\n")
+	
+	analysisPath := fmt.Sprintf("%s/TECHNOLOGY_ANALYSIS.md", outputDir)
+	return writeTextFile(analysisPath, content.String())
+}
+
+
+// convertParameterSyntax converts CircleCI parameter syntax to go-task variable syntax
+func convertParameterSyntax(cmd string) string {
+	// Convert << parameters.name >> (and << matrix.name >>) to {{.NAME}}
+	result := matrixRefRegex.ReplaceAllStringFunc(cmd, func(ref string) string {
+		return "{{." + taskVarName(matrixRefRegex.FindStringSubmatch(ref)[1]) + "}}"
+	})
+	// Pipeline parameters and values refer to their global vars
+	result = convertPipelineRefs(result)
+	// Find all << parameters.xxx >> patterns and convert them
+	for {
+		start := strings.Index(result, "<< parameters.")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(result[start:], " >>")
+		if end == -1 {
+			break
+		}
+		end += start
+		
+		// Extract parameter name
+		paramPart := result[start+14:end] // Skip "<< parameters."
+		paramName := taskVarName(paramPart)
+		
+		// Replace with go-task syntax
+		result = result[:start] + "{{." + paramName + "}}" + result[end+3:]
+	}
+	
+	return result
+}
+
+// taskVarName converts a CircleCI parameter name into a go-task variable name
+func taskVarName(paramName string) string {
+	return strings.ToUpper(strings.ReplaceAll(paramName, "-", "_"))
+}
+
+// convertJobToTask converts a CircleCI job to a go-task Task  
+func convertJobToTask(jobName string, job Job, patterns map[string]Task, commands map[string]Command, orbs map[string]interface{}) Task {
+	// Convert job parameters to go-task variables
+	vars := paramVars(job.Parameters)
+
+	// The job's environment is set by buildJobTask, merged with its executor's
+
+	cmds, deps := convertSteps(jobName, withJobShell(job.Steps, job.Shell), patterns, commands, orbs, job.Parameters)
+
+	task := Task{
+		Desc:          fmt.Sprintf("Task converted from CircleCI job: %s", jobName),
+		Cmds:          cmds,
+		Deps:          deps,
+		Silent:        false,
+		Preconditions: enumPreconditions(job.Parameters),
+	}
+
+	if len(vars) > 0 {
+		task.Vars = vars
+	}
+
+	return task
+}
+
+// convertSteps converts the steps of a job (or command) with the given parameters into
+// task commands, and the dependencies on command and pattern tasks the steps call
+func convertSteps(jobName string, steps []Step, patterns map[string]Task, commands map[string]Command, orbs map[string]interface{}, params map[string]interface{}) (TaskCmds, []string) {
+	var cmds TaskCmds
+	var deps []string
+	var finals TaskCmds
+	for i, step := range steps {
+		var handler string
+		if inlined, ok := stepsParameter(step, params); ok {
+			// Steps parameters are replaced by their default steps
+			handler = "steps"
+			inlinedCmds, inlinedDeps := convertSteps(jobName, inlined, patterns, commands, orbs, params)
+			cmds = append(cmds, inlinedCmds...)
+			deps = append(deps, inlinedDeps...)
+		} else if cmd := runStepCommand(step); cmd != "" {
+			// Convert parameter syntax in commands
+			convertedCmd := convertParameterSyntax(cmd)
+			// Check if this command matches a common pattern
+			normalized := normalizeCommand(convertedCmd)
+			if when := runStepWhen(step); when == "always" || when == "on_fail" {
+				handler = when
+				finals = append(finals, finalCommand(when, convertedCmd))
+			} else if isBackgroundStep(step) {
+				handler = "background"
+				cmds = append(cmds, backgroundCommands(jobName, step, convertedCmd, commands)...)
+			} else if taskName := findPatternTask(normalized, patterns); taskName != "" {
+				handler = "pattern"
+				deps = append(deps, taskName)
+			} else {
+				handler = "run"
+				cmds = append(cmds, TaskCmd{Cmd: convertedCmd})
+			}
+		} else if stepStr, ok := step.(string); ok {
+			// Check if this string step is a command invocation
+			if _, isCommandDefined := commands[stepStr]; isCommandDefined {
+				handler = "command"
+				deps = append(deps, stepStr)
+			} else {
+				// Handle built-in steps like "checkout"
+				handler = stepStr
+				converted := convertStepToCommand(step, orbs)
+				if !strings.Contains(converted, "Skipping") && !strings.Contains(converted, "task ") {
+					cmds = append(cmds, TaskCmd{Cmd: converted})
+				} else {
+					cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf("# %s", converted)})
+				}
+			}
+		} else if retried, policy, isRetry := retryOrbStep(step); isRetry {
+			// Retry orb steps become a retry wrapper around the command
+			handler = "retry"
+			cmds = append(cmds, TaskCmd{Cmd: retryWrap(convertParameterSyntax(retried), policy)})
+		} else if kind := stepType(step); kind == "when" || kind == "unless" {
+			// Conditional steps are guarded by the condition on the task variables
+			handler = kind
+			cmds = append(cmds, conditionalCommands(jobName, kind, step.(map[string]interface{})[kind], commands, orbs, params)...)
+		} else if mapped, isMapped := orbStepCommand(step, orbs); isMapped && !isDefinedCommand(stepType(step), commands) {
+			// A popular orb step whose orb was not resolved
+			handler = "orb-mapping"
+			cmds = append(cmds, TaskCmd{Cmd: mapped})
+		} else if commandName, isCommand := isCommandInvocation(step); isCommand {
+			// This step invokes a CircleCI command with parameters
+			handler = "command"
+			taskCall := generateTaskCallWithParams(commandName, step, commands)
+			cmds = append(cmds, TaskCmd{Cmd: taskCall})
+		} else {
+			// Handle other step types (checkout, etc.)
+			handler = stepType(step)
+			converted := convertStepToCommand(step, orbs)
+			if !strings.Contains(converted, "Skipping") {
+				cmds = append(cmds, TaskCmd{Cmd: converted})
+			} else {
+				// Add as comment for visibility
+				cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf("# %s", converted)})
+			}
+		}
+		logger.Debug("converted step", "job", jobName, "step", i, "handler", handler)
+	}
+	return withFinalCommands(cmds, finals), deps
+}
+
+// addLocalDevTasks adds helpful local development tasks
+func addLocalDevTasks(taskfile *Taskfile) {
+	// Clean up local artifacts
+	taskfile.Tasks["clean"] = Task{
+		Desc: "Clean local build artifacts",
+		Cmds: shellCommands(
+			"rm -rf ./workspace ./artifacts ./test-results",
+			"echo 'Cleaned local CircleCI simulation directories'",
+		),
+	}
+
+	// Setup local environment to mimic CircleCI
+	taskfile.Tasks["setup-local"] = Task{
+		Desc: "Setup local environment for CircleCI simulation",
+		Cmds: shellCommands(
+			"mkdir -p ./workspace ./artifacts ./test-results",
+			"echo 'Local CircleCI directories created'",
+			"echo 'Note: Some steps are CircleCI-server only and will be skipped'",
+		),
+	}
+
+	// Run all jobs in dependency order (simulate full CI)
+	taskfile.Tasks["ci-local"] = Task{
+		Desc: "Run full CI pipeline locally (where possible)",
+		Deps: []string{"setup-local"},
+		Cmds: shellCommands(
+			"echo 'Running local CI simulation...'",
+			"echo 'Note: This runs the build logic, but skips server-only features'",
+		),
+	}
+}
+
+// convertCommandsToTasks converts CircleCI commands to go-task tasks
+func convertCommandsToTasks(commands map[string]Command, orbs map[string]interface{}) map[string]Task {
+	tasks := make(map[string]Task)
+	
+	for commandName, command := range commands {
+		var cmds TaskCmds
+		var finals TaskCmds
+		// Convert CircleCI parameters to go-task variables with defaults
+		vars := paramVars(command.Parameters)
+		
+		var steps []Step
+		for _, step := range command.Steps {
+			if inlined, ok := stepsParameter(step, command.Parameters); ok {
+				steps = append(steps, inlined...)
+			} else {
+				steps = append(steps, step)
+			}
+		}
+		for _, step := range steps {
+			if cmd := runStepCommand(step); cmd != "" {
+				// Replace CircleCI parameter syntax with go-task variable syntax
+				convertedCmd := convertParameterSyntax(cmd)
+				if when := runStepWhen(step); when == "always" || when == "on_fail" {
+					finals = append(finals, finalCommand(when, convertedCmd))
+				} else if isBackgroundStep(step) {
+					cmds = append(cmds, backgroundCommands(commandName, step, convertedCmd, commands)...)
+				} else {
+					cmds = append(cmds, TaskCmd{Cmd: convertedCmd})
+				}
+			} else if kind := stepType(step); kind == "when" || kind == "unless" {
+				cmds = append(cmds, conditionalCommands(commandName, kind, step.(map[string]interface{})[kind], commands, orbs, command.Parameters)...)
+			} else if mapped, isMapped := orbStepCommand(step, orbs); isMapped && !isDefinedCommand(stepType(step), commands) {
+				cmds = append(cmds, TaskCmd{Cmd: convertParameterSyntax(mapped)})
+			} else if nested, isCommand := isCommandInvocation(step); isCommand && isDefinedCommand(nested, commands) {
+				// Commands calling other commands run their tasks in place, with the arguments
+				cmds = append(cmds, TaskCmd{Cmd: convertParameterSyntax(generateTaskCallWithParams(nested, step, commands))})
+			} else {
+				// Handle other step types
+				converted := convertStepToCommand(step, orbs)
+				if !strings.Contains(converted, "Skipping") {
+					convertedCmd := convertParameterSyntax(converted)
+					cmds = append(cmds, TaskCmd{Cmd: convertedCmd})
+				} else {
+					cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf("# %s", converted)})
+				}
+			}
+		}
+		
+		desc := command.Description
+		if desc == "" {
+			desc = fmt.Sprintf("Task converted from CircleCI command: %s", commandName)
+		}
+		
+		task := Task{
+			Desc:          desc,
+			Cmds:          withFinalCommands(cmds, finals),
+			Silent:        false,
+			Preconditions: enumPreconditions(command.Parameters),
+		}
+		
+		if len(vars) > 0 {
+			task.Vars = vars
+		}
+		
+		tasks[commandName] = task
+	}
+	
+	return tasks
+}
+
+// isDefinedCommand reports whether a command is defined by the config or a resolved orb
+func isDefinedCommand(name string, commands map[string]Command) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// generateTaskCallWithParams generates a go-task call with parameters
+func generateTaskCallWithParams(commandName string, step Step, commands map[string]Command) string {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("task %s", commandName)
+	}
+	
+	commandParams, ok := stepMap[commandName]
+	if !ok {
+		return fmt.Sprintf("task %s", commandName)
+	}
+	
+	paramMap, ok := commandParams.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("task %s", commandName)
+	}
+	
+	call := fmt.Sprintf("task %s", commandName)
+	for _, paramName := range sortedKeys(paramMap) {
+		paramDef := commands[commandName].Parameters[paramName]
+		// Steps cannot be passed on the command line; the command runs its default steps
+		if paramType(paramDef) == "steps" {
+			continue
+		}
+		call += fmt.Sprintf(" %s=%s", taskVarName(paramName), shellQuote(formatParamValue(paramDef, paramMap[paramName])))
+	}
+	return call
+}
+
+// circleCIGitDefaults derive CircleCI's build variables from the local git repo, by
+// variable. A value set in the environment is kept; outside a repo (or without a
+// remote) the placeholders of older conversions are used.
+var circleCIGitDefaults = map[string]string{
+	"CIRCLE_SHA1":             `echo "${CIRCLE_SHA1:-$(git rev-parse HEAD 2>/dev/null || echo local-sha)}"`,
+	"CIRCLE_BRANCH":           `echo "${CIRCLE_BRANCH:-$(git rev-parse --abbrev-ref HEAD 2>/dev/null || echo main)}"`,
+	"CIRCLE_BUILD_NUM":        `echo "${CIRCLE_BUILD_NUM:-$(git rev-list --count HEAD 2>/dev/null || echo 1)}"`,
+	"CIRCLE_REPOSITORY_URL":   `echo "${CIRCLE_REPOSITORY_URL:-$(git config --get remote.origin.url)}"`,
+	"CIRCLE_PROJECT_REPONAME": `echo "${CIRCLE_PROJECT_REPONAME:-$(git config --get remote.origin.url | sed -E 's#/*$##; s#\.git$##; s#.*[:/]##' | grep . || echo local-repo)}"`,
+	"CIRCLE_PROJECT_USERNAME": `echo "${CIRCLE_PROJECT_USERNAME:-$(git config --get remote.origin.url | sed -E 's#/*$##; s#/[^/]*$##; s#.*[:/]##' | grep . || echo local-user)}"`,
+}
+
+// addLocalEnvDefaults adds environment variable defaults for local development.
+// Secrets get no placeholder: they are loaded from dotenv files.
+func addLocalEnvDefaults(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	envVars := make(map[string]string)
+	var secrets []string
+	
+	// Collect all environment variables used in the config
+	envVarsUsed := extractEnvironmentVariables(config)
+	
+	// Add defaults for common CircleCI environment variables
+	circleCIDefaults := map[string]string{
+		"CIRCLE_WORKING_DIRECTORY":    ".",
+		"CIRCLE_TEST_REPORTS":         "./test-results",
+		"HOME":                        "$HOME",
+		"PWD":                         "$PWD",
+		"NODE_ENV":                    "development",
+		"AWS_DEFAULT_REGION":          "us-east-1",
+	}
+	
+	// Variables set by a job's (or its executor's) environment come from the config,
+	// not the developer; the task env holds them
+	for _, task := range taskfile.Tasks {
+		for envVar := range task.Env {
+			delete(envVarsUsed, envVar)
+		}
+	}
+
+	// BASH_ENV and the variables exported to it are set by the tasks' commands
+	delete(envVarsUsed, "BASH_ENV")
+	for _, envVar := range bashEnvExports(config) {
+		delete(envVarsUsed, envVar)
+	}
+
+	// Only add defaults for env vars that are actually used
+	for envVar := range envVarsUsed {
+		if sh, fromGit := circleCIGitDefaults[envVar]; fromGit {
+			// A global var runs the command once; the env passes its value on
+			if taskfile.Vars == nil {
+				taskfile.Vars = make(map[string]interface{})
+			}
+			if _, declared := taskfile.Vars[envVar]; !declared {
+				taskfile.Vars[envVar] = DynamicVar{Sh: sh}
+			}
+			envVars[envVar] = "{{." + envVar + "}}"
+		} else if defaultValue, hasDefault := circleCIDefaults[envVar]; hasDefault {
+			envVars[envVar] = defaultValue
+		} else if looksLikeSecretEnv(envVar) {
+			secrets = append(secrets, envVar)
+		} else {
+			// Add a placeholder for unknown env vars
+			envVars[envVar] = fmt.Sprintf("# TODO: Set %s for local development", envVar)
+		}
+	}
+	
+	if len(envVars) > 0 {
+		taskfile.Env = envVars
+	}
+	sort.Strings(secrets)
+	addProjectDotenv(taskfile, config, secrets, report)
+}
+
+// extractEnvironmentVariables finds all environment variables used in the config
+func extractEnvironmentVariables(config CircleCIConfig) map[string]bool {
+	envVars := make(map[string]bool)
+	envRegex := regexp.MustCompile(`\$([A-Z_][A-Z0-9_]*)\b|\$\{([A-Z_][A-Z0-9_]*)\}`)
+	
+	// Check all jobs
+	for _, job := range config.Jobs {
+		for _, step := range job.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				// Variables set by the step's own environment need no default
+				stepEnv := runStepEnvironment(step)
+				matches := envRegex.FindAllStringSubmatch(cmd, -1)
+				for _, match := range matches {
+					if _, set := stepEnv[match[1]]; match[1] != "" && !set {
+						envVars[match[1]] = true
+					}
+					if _, set := stepEnv[match[2]]; match[2] != "" && !set {
+						envVars[match[2]] = true
+					}
+				}
+			}
+		}
+	}
+	
+	// Check all commands
+	for _, command := range config.Commands {
+		for _, step := range command.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				// Variables set by the step's own environment need no default
+				stepEnv := runStepEnvironment(step)
+				matches := envRegex.FindAllStringSubmatch(cmd, -1)
+				for _, match := range matches {
+					if _, set := stepEnv[match[1]]; match[1] != "" && !set {
+						envVars[match[1]] = true
+					}
+					if _, set := stepEnv[match[2]]; match[2] != "" && !set {
+						envVars[match[2]] = true
+					}
+				}
+			}
+		}
+	}
+	
+	return envVars
+}
+
+// Helper to get job dependencies from workflow: the jobs the invocations of jobName
+// (by job or alias name) require
+func getJobDependencies(jobName string, workflow Workflow) []string {
+	var deps []string
+	for _, job := range workflow.Jobs {
+		if job.Job != jobName && job.DisplayName() != jobName {
+			continue
+		}
+		for _, req := range job.Requires {
+			if !containsString(deps, req) {
+				deps = append(deps, req)
+			}
+		}
+	}
+	return deps
+}
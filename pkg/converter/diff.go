@@ -0,0 +1,197 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// dryRunInputs are the files of the output directory a conversion reads back: the
+// Taskfile and lock to keep hand-edited tasks, and package.json to merge scripts into
+var dryRunInputs = []string{taskfileName, lockFileName, "package.json"}
+
+// dryRun converts a config into a temporary copy of outputDir and prints unified diffs
+// of the new config and Taskfile against the files in outputDir, which is not written
+func dryRun(w io.Writer, source, project string, data []byte, config CircleCIConfig, outputDir string, settings conversionSettings) (conversionResult, error) {
+	dir, err := os.MkdirTemp("", "circle-to-task-")
+	if err != nil {
+		return conversionResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range dryRunInputs {
+		content, err := os.ReadFile(filepath.Join(outputDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return conversionResult{}, err
+		}
+		if err := writeFileContent(filepath.Join(dir, name), content); err != nil {
+			return conversionResult{}, err
+		}
+	}
+
+	result, err := convertInput(source, project, data, config, dir, settings)
+	if err != nil {
+		return result, err
+	}
+	changed := false
+	for _, path := range []string{result.ConfigPath, result.TaskfilePath} {
+		if path == "" {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return result, err
+		}
+		generated, err := os.ReadFile(path)
+		if err != nil {
+			return result, err
+		}
+		existingPath := filepath.Join(outputDir, rel)
+		oldName := existingPath
+		existing, err := os.ReadFile(existingPath)
+		if os.IsNotExist(err) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return result, err
+		}
+		diff := unifiedDiff(oldName, existingPath, string(existing), string(generated))
+		if diff != "" {
+			changed = true
+			fmt.Fprint(w, diff)
+		}
+	}
+	if !changed {
+		fmt.Fprintf(w, "No changes to the config or Taskfile in %s\n", outputDir)
+	}
+	return result, nil
+}
+
+// unifiedDiff returns the changes from a to b in unified format, empty when they are
+// the same
+func unifiedDiff(oldName, newName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	oldLines, newLines := diffLines(a), diffLines(b)
+
+	// Lines kept before and after the changes are matched without the quadratic LCS
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix && oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+	match := make([]int, len(oldLines))
+	for i := 0; i < prefix; i++ {
+		match[i] = i
+	}
+	middle := lcsMatch(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])
+	for i, j := range middle {
+		if j >= 0 {
+			j += prefix
+		}
+		match[prefix+i] = j
+	}
+	for i := 0; i < suffix; i++ {
+		match[len(oldLines)-suffix+i] = len(newLines) - suffix + i
+	}
+
+	// Edit script: ' ' kept, '-' removed, '+' added lines, with their line indexes
+	type edit struct {
+		op       byte
+		old, new int
+	}
+	var edits []edit
+	j := 0
+	for i, m := range match {
+		if m < 0 {
+			edits = append(edits, edit{'-', i, j})
+			continue
+		}
+		for ; j < m; j++ {
+			edits = append(edits, edit{'+', i, j})
+		}
+		edits = append(edits, edit{' ', i, j})
+		j++
+	}
+	for ; j < len(newLines); j++ {
+		edits = append(edits, edit{'+', len(oldLines), j})
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// A hunk spans changes less than two contexts apart
+		end := start
+		for next := start; next < len(edits); next++ {
+			if edits[next].op != ' ' {
+				if next-end > 2*diffContext {
+					break
+				}
+				end = next + 1
+			}
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(edits) {
+			to = len(edits)
+		}
+		oldCount, newCount := 0, 0
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[from].old, oldCount), hunkRange(edits[from].new, newCount))
+		for _, e := range edits[from:to] {
+			line := ""
+			if e.op == '+' {
+				line = newLines[e.new]
+			} else {
+				line = oldLines[e.old]
+			}
+			fmt.Fprintf(&out, "%c%s\n", e.op, line)
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// diffLines splits text into lines without their line breaks
+func diffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// hunkRange formats the start and length of a hunk: lines are counted from 1, and an
+// empty range starts at the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
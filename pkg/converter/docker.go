@@ -0,0 +1,240 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// conditionalCmdRegex matches a command guarded by a go-task {{if}} as a whole
+var conditionalCmdRegex = regexp.MustCompile(`(?s)^(\{\{if [^}]*\}\})(.*)(\{\{end\}\})$`)
+
+// containerShell runs the command passed after it with bash when the image has it, as
+// CircleCI does, and sh otherwise
+const containerShell = `'if command -v bash >/dev/null; then exec bash -c "$0"; fi; exec sh -c "$0"'`
+
+// dockerDir holds the id files of the containers tasks run their commands in
+const dockerDir = "{{.ROOT_DIR}}/.task/docker"
+
+// containerIDFile is the task var naming the file holding the id of the task's container
+const containerIDFile = "CONTAINER_ID_FILE"
+
+// dockerContainer returns the commands starting and removing the container a task runs
+// its commands in, and the prefix running a command there. The container runs the
+// executor's image with the project mounted at the same path, so {{.ROOT_DIR}} paths
+// work on both sides, and the host network puts the service containers on localhost,
+// as on CircleCI. Commands run from the task's directory, with the given variables.
+func dockerContainer(envKeys []string, hostDocker bool) (string, string, string) {
+	cid := `"{{.` + containerIDFile + `}}"`
+
+	var start strings.Builder
+	// A container left by an interrupted run is removed first
+	start.WriteString(fmt.Sprintf(`mkdir -p "%s" && { [ ! -f %s ] || docker rm -f "$(cat %s)" >/dev/null 2>&1; rm -f %s; } && `, dockerDir, cid, cid, cid))
+	start.WriteString(fmt.Sprintf(`docker run -d --cidfile %s --network host -v "{{.ROOT_DIR}}:{{.ROOT_DIR}}"`, cid))
+	// Jobs using the host's daemon for setup_remote_docker reach it through its socket
+	if hostDocker {
+		start.WriteString(" -v /var/run/docker.sock:/var/run/docker.sock")
+	}
+	start.WriteString(" --entrypoint sh {{.EXECUTOR_IMAGE}} -c 'while :; do sleep 3600; done' >/dev/null")
+
+	stop := fmt.Sprintf(`docker rm -f "$(cat %s)" >/dev/null; rm -f %s`, cid, cid)
+
+	var exec strings.Builder
+	exec.WriteString(`docker exec -i -w "$PWD"`)
+	for _, key := range envKeys {
+		exec.WriteString(" -e " + key)
+	}
+	exec.WriteString(fmt.Sprintf(` "$(cat %s)" sh -c %s `, cid, containerShell))
+	return start.String(), stop, exec.String()
+}
+
+// dockerWrapCommand runs a task command in the task's container. Task calls, comments,
+// and the commands managing service containers and background steps stay on the host.
+func dockerWrapCommand(cmd, exec string) string {
+	if match := conditionalCmdRegex.FindStringSubmatch(cmd); match != nil {
+		return match[1] + dockerWrapCommand(match[2], exec) + match[3]
+	}
+	if strings.HasPrefix(cmd, "task ") || strings.HasPrefix(cmd, "#") ||
+		strings.Contains(cmd, composeFileName) || strings.Contains(cmd, backgroundDir) {
+		return cmd
+	}
+	return exec + shellQuote(cmd)
+}
+
+// dockerWrapTask runs the commands of a task in one container of the image of its
+// EXECUTOR_IMAGE var, passing the task's environment and the other given variables
+// through. The container is started by the task's first command and removed by a
+// deferred command, which go-task runs after the task's other deferred commands.
+func dockerWrapTask(name string, task Task, passed map[string]bool) Task {
+	vars := make(map[string]string, len(task.Vars)+1)
+	for key, value := range task.Vars {
+		vars[key] = value
+	}
+	vars[containerIDFile] = fmt.Sprintf("%s/%s.cid", dockerDir, strings.NewReplacer("/", "-", ":", "-").Replace(name))
+
+	keys := make(map[string]bool)
+	for key := range task.Env {
+		keys[key] = true
+	}
+	for key := range passed {
+		keys[key] = true
+	}
+	var envKeys []string
+	for key := range keys {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+
+	start, stop, exec := dockerContainer(envKeys, usesHostDocker(task))
+	cmds := TaskCmds{{Cmd: start}, deferredCommand(stop)}
+	wrapped := false
+	for _, cmd := range task.Cmds {
+		converted := dockerWrapCommand(cmd.Cmd, exec)
+		wrapped = wrapped || converted != cmd.Cmd
+		cmds = append(cmds, cmd.withCmd(converted))
+	}
+	// Tasks with only host commands need no container
+	if !wrapped {
+		return task
+	}
+	task.Cmds = cmds
+	task.Vars = vars
+	return task
+}
+
+// applyDockerWrap runs the commands of job tasks on docker executors in the job's
+// primary image, and those of command tasks when every job invoking them uses the same
+// image, so tasks run without the toolchains installed on the host (-docker)
+func applyDockerWrap(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	contexts := jobContexts(config.Workflows)
+	contextVars := contextVariables(config)
+
+	// The images of the jobs invoking each command ("" for other executors), and the
+	// variables those jobs pass on
+	commandImages := make(map[string]map[string]bool)
+	commandEnv := make(map[string]map[string]bool)
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		job := config.Jobs[jobName]
+		task, ok := taskfile.Tasks[jobName]
+		if !ok {
+			continue
+		}
+		resolved, err := resolveJobExecutor(job, config.Executors)
+		image := ""
+		if err == nil && resolved.Kind == "docker" && len(resolved.Images) > 0 {
+			image = resolved.Images[0]
+		}
+
+		// Variables of the Taskfile, the job's contexts and the job's task
+		passed := make(map[string]bool)
+		for key := range taskfile.Env {
+			passed[key] = true
+		}
+		for _, context := range contexts[jobName] {
+			for _, v := range contextVars[context] {
+				passed[v.Name] = true
+			}
+		}
+
+		found := make(map[string]Command)
+		referencedCommands(job.Steps, config.Commands, found)
+		for name := range found {
+			if commandImages[name] == nil {
+				commandImages[name] = make(map[string]bool)
+				commandEnv[name] = make(map[string]bool)
+			}
+			commandImages[name][image] = true
+			for key := range passed {
+				commandEnv[name][key] = true
+			}
+			for key := range task.Env {
+				commandEnv[name][key] = true
+			}
+		}
+
+		if image == "" {
+			if err == nil {
+				report.Add("Docker", jobName, "runs on %s, not a docker executor; its task runs on the host", describeExecutor(resolved))
+			}
+			continue
+		}
+		taskfile.Tasks[jobName] = dockerWrapTask(jobName, task, passed)
+		report.Add("Docker", jobName, "commands run in %s (override with EXECUTOR_IMAGE=...)", image)
+	}
+
+	var names []string
+	for name := range commandImages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		task, ok := taskfile.Tasks[name]
+		if !ok {
+			continue
+		}
+		if len(commandImages[name]) > 1 {
+			report.Add("Docker", name, "command invoked by jobs on different executors; its task runs on the host")
+			continue
+		}
+		if commandImages[name][""] {
+			continue
+		}
+		var image string
+		for candidate := range commandImages[name] {
+			image = candidate
+		}
+		if task.Vars == nil {
+			task.Vars = make(map[string]string)
+		}
+		task.Vars["EXECUTOR_IMAGE"] = fmt.Sprintf(`{{.EXECUTOR_IMAGE | default "%s"}}`, image)
+		taskfile.Tasks[name] = dockerWrapTask(name, task, commandEnv[name])
+	}
+}
+
+// hostDockerCommand replaces the setup_remote_docker step with -host-docker
+const hostDockerCommand = "echo 'setup_remote_docker: using the host Docker daemon'"
+
+// hostDockerPrecondition checks that the host's Docker daemon is reachable
+var hostDockerPrecondition = Precondition{
+	Sh:  "docker info >/dev/null 2>&1",
+	Msg: "setup_remote_docker: no Docker daemon is reachable; start Docker or set DOCKER_HOST",
+}
+
+// usesHostDocker reports whether a task runs setup_remote_docker against the host daemon
+func usesHostDocker(task Task) bool {
+	for _, precondition := range task.Preconditions {
+		if precondition == hostDockerPrecondition {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHostDocker turns the setup_remote_docker steps of tasks into a no-op using the
+// host's Docker daemon, which is generally reachable locally, with a precondition
+// checking that it is (-host-docker)
+func applyHostDocker(taskfile *Taskfile, report *ConversionReport) {
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		task := taskfile.Tasks[name]
+		found := false
+		cmds := make(TaskCmds, len(task.Cmds))
+		for i, cmd := range task.Cmds {
+			found = found || strings.Contains(cmd.Cmd, remoteDockerSkip)
+			cmds[i] = cmd.withCmd(strings.ReplaceAll(cmd.Cmd, remoteDockerSkip, hostDockerCommand))
+		}
+		if !found {
+			continue
+		}
+		task.Cmds = cmds
+		task.Preconditions = append(task.Preconditions, hostDockerPrecondition)
+		taskfile.Tasks[name] = task
+		report.Add("Remote Docker", name, "setup_remote_docker uses the host Docker daemon; the task checks that it is reachable")
+	}
+}
@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// projectEnvFile is the dotenv file holding the project's secrets locally, loaded by
+// the Taskfile
+const projectEnvFile = ".env"
+
+// projectEnvExample is the template of the project's dotenv file written by the converter
+const projectEnvExample = projectEnvFile + ".example"
+
+// secretKeyNameRegex matches env var names holding a key (DEPLOY_KEY, AWS_ACCESS_KEY_ID)
+var secretKeyNameRegex = regexp.MustCompile(`(^|_)KEYS?(_|$)`)
+
+// looksLikeSecretEnv reports whether an env var name conventionally holds a credential:
+// tokens, keys, passwords, and AWS settings
+func looksLikeSecretEnv(name string) bool {
+	return secretEnvNameRegex.MatchString(name) || secretKeyNameRegex.MatchString(name) || strings.HasPrefix(name, "AWS_")
+}
+
+// addProjectDotenv loads .env into every task when the config uses secrets it does not
+// set, rather than giving them placeholders, and reports each of them. Secrets only
+// used by jobs attached to contexts come from the contexts' dotenv files instead.
+// go-task skips a missing .env, so the tasks still run without it.
+func addProjectDotenv(taskfile *Taskfile, config CircleCIConfig, secrets []string, report *ConversionReport) {
+	jobs := make(map[string]SecretVar)
+	for _, v := range collectSecretVars(config) {
+		jobs[v.Name] = v
+	}
+	for _, name := range secrets {
+		v, found := jobs[name]
+		if found && !containsString(v.Contexts, defaultContext) {
+			continue
+		}
+		taskfile.envSecrets = append(taskfile.envSecrets, name)
+		report.Add("Secrets", strings.Join(v.Jobs, ", "), "%s looks like a secret and the config does not set it; set it in %s (see %s)", name, projectEnvFile, projectEnvExample)
+	}
+	if len(taskfile.envSecrets) > 0 {
+		taskfile.Dotenv = []string{projectEnvFile}
+	}
+}
+
+// generateEnvExample writes .env.example listing the secrets the Taskfile loads from
+// .env, and reports whether there are any
+func generateEnvExample(taskfile Taskfile, outputDir string) (bool, error) {
+	if len(taskfile.envSecrets) == 0 {
+		return false, nil
+	}
+	var b strings.Builder
+	b.WriteString("# Secrets the CircleCI config uses without setting them (project environment variables on CircleCI).\n")
+	b.WriteString(fmt.Sprintf("# Copy to %s and fill in the values; the Taskfile loads it. Keep it out of git.\n\n", projectEnvFile))
+	for _, name := range taskfile.envSecrets {
+		b.WriteString(name + "=\n")
+	}
+	return true, os.WriteFile(filepath.Join(outputDir, projectEnvExample), []byte(b.String()), 0644)
+}
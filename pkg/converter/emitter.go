@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nichecode/circle-to-task/ir"
+)
+
+// taskfileName is the Taskfile written by the taskfile target
+const taskfileName = "Taskfile.yml"
+
+// Emitter writes one output target of a conversion; -target selects emitters by name
+type Emitter interface {
+	// Name is the -target value of the emitter
+	Name() string
+	// Emit writes the target's files to the output directory and returns the optional
+	// outputs it wrote, as "path (description)"
+	Emit(out emission) ([]string, error)
+}
+
+// emission is what emitters write from: the pipeline model, the parsed config, the
+// slim config and the Taskfile of one conversion, and the report they add to. New
+// emitters write from the model, which does not depend on the input's CI system.
+type emission struct {
+	Pipeline  *ir.Pipeline
+	Config    CircleCIConfig
+	NewConfig CircleCIConfig
+	Taskfile  Taskfile
+	Report    *ConversionReport
+	Project   string
+	OutputDir string
+	Settings  conversionSettings
+}
+
+// emitters are the registered output targets, in the order they are written. Adding
+// a target only takes an Emitter here.
+var emitters = []Emitter{
+	taskfileEmitter{},
+	scriptsEmitter{},
+	miseEmitter{},
+	npmScriptsEmitter{},
+	jenkinsfileEmitter{},
+	githubActionsEmitter{},
+	makefileEmitter{},
+}
+
+// emitterNames returns the names of the registered emitters
+func emitterNames() []string {
+	names := make([]string, len(emitters))
+	for i, emitter := range emitters {
+		names[i] = emitter.Name()
+	}
+	return names
+}
+
+// lookupEmitter returns the emitter registered under name
+func lookupEmitter(name string) (Emitter, bool) {
+	for _, emitter := range emitters {
+		if emitter.Name() == name {
+			return emitter, true
+		}
+	}
+	return nil, false
+}
+
+// parseTargets parses the comma-separated -target value into the targets it names,
+// in registry order
+func parseTargets(value string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := lookupEmitter(name); !ok {
+			return nil, fmt.Errorf("unknown target %q (supported: %s)", name, strings.Join(emitterNames(), ", "))
+		}
+		selected[name] = true
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no target given (supported: %s)", strings.Join(emitterNames(), ", "))
+	}
+	var targets []string
+	for _, name := range emitterNames() {
+		if selected[name] {
+			targets = append(targets, name)
+		}
+	}
+	return targets, nil
+}
+
+// emittedTargets returns the targets to write for the requested ones: the targets
+// running the jobs with the standalone scripts need the scripts, the others the
+// Taskfile
+func emittedTargets(targets []string) []string {
+	selected := make(map[string]bool)
+	for _, name := range targets {
+		selected[name] = true
+		if scriptTarget(name) {
+			selected[targetScripts] = true
+		} else {
+			selected[targetTaskfile] = true
+		}
+	}
+	var emitted []string
+	for _, name := range emitterNames() {
+		if selected[name] {
+			emitted = append(emitted, name)
+		}
+	}
+	return emitted
+}
+
+// jobRunner returns the target the jobs of the slim config call: the Taskfile when it
+// is written, else the standalone scripts
+func jobRunner(targets []string) string {
+	if containsString(emittedTargets(targets), targetTaskfile) {
+		return targetTaskfile
+	}
+	return targetScripts
+}
+
+// taskfileEmitter writes the Taskfile
+type taskfileEmitter struct{}
+
+func (taskfileEmitter) Name() string { return targetTaskfile }
+
+func (taskfileEmitter) Emit(out emission) ([]string, error) {
+	if err := writeYAMLFile(filepath.Join(out.OutputDir, taskfileName), out.Taskfile); err != nil {
+		return nil, fmt.Errorf("error writing taskfile: %w", err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,413 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ResolvedExecutor is the execution environment of a job after resolving executor references
+type ResolvedExecutor struct {
+	Name        string            // executor name, empty for inline docker/machine jobs
+	Kind        string            // docker, machine or macos
+	Images      []string          // docker images (primary first) or the machine image
+	Environment map[string]string // executor and primary image environment
+	Platform    string            // linux, darwin or windows
+	Arguments   map[string]string // executor parameters after applying defaults
+
+	WorkingDirectory string // the executor's working_directory, empty for the default
+	Shell            string // the executor's default shell, empty when unset
+
+	Services      []ServiceContainer // secondary docker images
+	ResourceClass string             // the job's or executor's resource_class, empty when unset
+}
+
+var parameterRefRegex = regexp.MustCompile(`<<\s*parameters\.([A-Za-z0-9_-]+)\s*>>`)
+
+// resolveJobExecutor resolves the execution environment of a job, including named and
+// parameterized executors declared in the top-level executors map
+func resolveJobExecutor(job Job, executors map[string]interface{}) (ResolvedExecutor, error) {
+	job = jobWithExecutor(job, nil)
+	if job.Executor == nil {
+		inline := map[string]interface{}{}
+		if len(job.Docker) > 0 {
+			var images []interface{}
+			for _, image := range job.Docker {
+				images = append(images, image.definition())
+			}
+			inline["docker"] = images
+		}
+		if job.Machine != nil {
+			inline["machine"] = job.Machine
+		}
+		if job.Macos != nil {
+			inline["macos"] = job.Macos
+		}
+		if job.Windows != nil {
+			inline["windows"] = job.Windows
+		}
+		if job.ResourceClass != "" {
+			inline["resource_class"] = job.ResourceClass
+		}
+		return parseExecutorDefinition("", inline), nil
+	}
+
+	name, args := executorReference(job.Executor)
+	if name == "" {
+		return ResolvedExecutor{}, fmt.Errorf("executor reference has no name")
+	}
+
+	rawDef, ok := executors[name]
+	if !ok {
+		return ResolvedExecutor{Name: name}, fmt.Errorf("executor %q is not defined (orb executor?)", name)
+	}
+	def, ok := rawDef.(map[string]interface{})
+	if !ok {
+		return ResolvedExecutor{Name: name}, fmt.Errorf("executor %q has an unexpected shape", name)
+	}
+
+	values := executorParameterValues(def, args)
+	resolved := parseExecutorDefinition(name, substituteParameters(def, values).(map[string]interface{}))
+	resolved.Arguments = values
+	// A job's resource_class overrides its executor's
+	if job.ResourceClass != "" {
+		resolved.ResourceClass = job.ResourceClass
+		if strings.HasPrefix(job.ResourceClass, "windows.") {
+			resolved.Platform = "windows"
+		}
+	}
+
+	return resolved, nil
+}
+
+// executorParameter returns the `type: executor` parameter a job takes its executor
+// from (`executor: << parameters.e >>`)
+func executorParameter(job Job) (string, bool) {
+	ref, ok := job.Executor.(string)
+	if !ok {
+		return "", false
+	}
+	ref = strings.TrimSpace(ref)
+	match := parameterRefRegex.FindStringSubmatch(ref)
+	if match == nil || match[0] != ref {
+		return "", false
+	}
+	def, _ := job.Parameters[match[1]].(map[string]interface{})
+	if def["type"] != "executor" {
+		return "", false
+	}
+	return match[1], true
+}
+
+// jobWithExecutor replaces an executor parameter with the executor an invocation
+// passes for it, or with the parameter's default
+func jobWithExecutor(job Job, args map[string]interface{}) Job {
+	param, ok := executorParameter(job)
+	if !ok {
+		return job
+	}
+	if value, ok := args[param]; ok {
+		job.Executor = value
+	} else if def, _ := job.Parameters[param].(map[string]interface{}); def["default"] != nil {
+		job.Executor = def["default"]
+	}
+	return job
+}
+
+// executorInvocationTask names the task of a workflow invocation that passes its own
+// executor to a job with an executor parameter: the invocation's `name:`, or the job
+// name followed by the executor's
+func executorInvocationTask(job Job, invocation WorkflowJob) (string, bool) {
+	param, ok := executorParameter(job)
+	if !ok {
+		return "", false
+	}
+	value, ok := invocation.Arguments[param]
+	if !ok {
+		return "", false
+	}
+	if invocation.Name != "" && invocation.Name != invocation.Job {
+		return invocation.Name, true
+	}
+	executorName, _ := executorReference(value)
+	return invocation.Job + "-" + strings.ReplaceAll(executorName, "/", "-"), true
+}
+
+// withInvocationExecutor adapts a job task to the executor chosen by an invocation:
+// the environment comes from that executor, EXECUTOR_IMAGE holds its primary image,
+// non-Linux executors restrict the task's platforms, and the other arguments
+// become var defaults
+func withInvocationExecutor(base Task, job Job, args map[string]interface{}, executors map[string]interface{}) (Task, ResolvedExecutor, error) {
+	task := base
+	param, _ := executorParameter(job)
+	resolved, err := resolveJobExecutor(jobWithExecutor(job, args), executors)
+	if err != nil {
+		return task, resolved, err
+	}
+
+	task.Vars = make(map[string]string)
+	for key, value := range base.Vars {
+		task.Vars[key] = value
+	}
+	for argName, argValue := range args {
+		if argName == param {
+			continue
+		}
+		value := formatParamValue(job.Parameters[argName], argValue)
+		task.Vars[taskVarName(argName)] = fmt.Sprintf("{{.%s | default \"%s\"}}", taskVarName(argName), value)
+	}
+	if len(resolved.Images) > 0 {
+		task.Vars["EXECUTOR_IMAGE"] = resolved.Images[0]
+	}
+
+	env := make(map[string]string)
+	for key, value := range resolved.Environment {
+		env[key] = value
+	}
+	mergeEnvironment(env, job.Environment)
+	for key, value := range env {
+		env[key] = convertParameterSyntax(escapeTemplateBraces(value))
+	}
+	task.Env = nil
+	if len(env) > 0 {
+		task.Env = env
+	}
+
+	task.Platforms = nil
+	if resolved.Platform != "linux" {
+		task.Platforms = []string{resolved.Platform}
+	}
+	return task, resolved, nil
+}
+
+// addExecutorInvocationTasks gives workflow invocations that pass an executor to a job
+// their own task, since the job task runs in the parameter's default executor
+func addExecutorInvocationTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		job, isLocal := config.Jobs[invocation.Job]
+		if !isLocal || invocation.Matrix != nil {
+			continue
+		}
+		name, ok := executorInvocationTask(job, invocation.WorkflowJob)
+		if !ok {
+			continue
+		}
+		if _, exists := taskfile.Tasks[name]; exists {
+			continue
+		}
+
+		task, resolved, err := withInvocationExecutor(taskfile.Tasks[invocation.Job], job, invocation.Arguments, config.Executors)
+		if err != nil {
+			report.Add("Executors", invocation.Job, "could not resolve the executor of invocation %s: %v", name, err)
+			continue
+		}
+		task.Desc = fmt.Sprintf("Task converted from CircleCI job: %s (on %s)", invocation.Job, describeExecutor(resolved))
+		taskfile.Tasks[name] = task
+		report.Add("Executors", invocation.Job, "invocation %s runs on %s; converted to task %s", name, describeExecutor(resolved), name)
+	}
+}
+
+// executorReference splits a job's executor field into a name and its arguments
+func executorReference(ref interface{}) (string, map[string]interface{}) {
+	switch v := ref.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		args := make(map[string]interface{})
+		for key, value := range v {
+			if key != "name" {
+				args[key] = value
+			}
+		}
+		return name, args
+	}
+	return "", nil
+}
+
+// executorParameterValues merges invocation arguments with the executor's parameter defaults
+func executorParameterValues(def map[string]interface{}, args map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+
+	if params, ok := def["parameters"].(map[string]interface{}); ok {
+		for paramName, paramDef := range params {
+			if paramMap, ok := paramDef.(map[string]interface{}); ok {
+				if defVal, hasDefault := paramMap["default"]; hasDefault {
+					values[paramName] = formatParamValue(paramDef, defVal)
+				}
+			}
+		}
+		for argName, argValue := range args {
+			values[argName] = formatParamValue(params[argName], argValue)
+		}
+	} else {
+		for argName, argValue := range args {
+			values[argName] = fmt.Sprintf("%v", argValue)
+		}
+	}
+
+	return values
+}
+
+// substituteParameters replaces << parameters.x >> references throughout a YAML value
+func substituteParameters(value interface{}, params map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return parameterRefRegex.ReplaceAllStringFunc(v, func(ref string) string {
+			name := parameterRefRegex.FindStringSubmatch(ref)[1]
+			if paramValue, ok := params[name]; ok {
+				return paramValue
+			}
+			return ref
+		})
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if key == "parameters" {
+				result[key] = item
+				continue
+			}
+			result[key] = substituteParameters(item, params)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = substituteParameters(item, params)
+		}
+		return result
+	}
+	return value
+}
+
+// parseExecutorDefinition reads images, environment and platform from an executor body
+func parseExecutorDefinition(name string, def map[string]interface{}) ResolvedExecutor {
+	resolved := ResolvedExecutor{
+		Name:        name,
+		Platform:    "linux",
+		Environment: make(map[string]string),
+	}
+
+	if docker, ok := def["docker"].([]interface{}); ok {
+		resolved.Kind = "docker"
+		for i, entry := range docker {
+			image, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if imageName, ok := image["image"].(string); ok {
+				resolved.Images = append(resolved.Images, imageName)
+			}
+			// Only the primary container's environment applies to the job's steps
+			if i == 0 {
+				mergeEnvironment(resolved.Environment, image["environment"])
+			} else if service, ok := parseServiceContainer(image); ok {
+				resolved.Services = append(resolved.Services, service)
+			}
+		}
+	}
+
+	if machine, ok := def["machine"]; ok && machine != nil {
+		resolved.Kind = "machine"
+		if machineMap, ok := machine.(map[string]interface{}); ok {
+			if image, ok := machineMap["image"].(string); ok {
+				resolved.Images = append(resolved.Images, image)
+				if strings.Contains(image, "windows") {
+					resolved.Platform = "windows"
+				}
+			}
+		}
+	}
+
+	if _, ok := def["macos"]; ok {
+		resolved.Kind = "macos"
+		resolved.Platform = "darwin"
+	}
+	if _, ok := def["windows"]; ok {
+		resolved.Platform = "windows"
+	}
+
+	resolved.ResourceClass, _ = def["resource_class"].(string)
+	if strings.HasPrefix(resolved.ResourceClass, "windows.") {
+		resolved.Platform = "windows"
+	}
+
+	mergeEnvironment(resolved.Environment, def["environment"])
+	resolved.WorkingDirectory, _ = def["working_directory"].(string)
+	resolved.Shell, _ = def["shell"].(string)
+
+	return resolved
+}
+
+// mergeEnvironment copies a CircleCI environment map (or KEY=VALUE list) into env
+func mergeEnvironment(env map[string]string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			env[key] = fmt.Sprintf("%v", item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if pair, ok := item.(string); ok {
+				if key, val, found := strings.Cut(pair, "="); found {
+					env[key] = val
+				}
+			}
+		}
+	}
+}
+
+// describeExecutor summarizes a resolved executor for the conversion report
+func describeExecutor(resolved ResolvedExecutor) string {
+	var parts []string
+	if len(resolved.Images) > 0 {
+		parts = append(parts, fmt.Sprintf("image %s", resolved.Images[0]))
+	} else if resolved.Kind != "" {
+		parts = append(parts, resolved.Kind)
+	}
+	if resolved.Platform != "linux" {
+		parts = append(parts, fmt.Sprintf("platform %s", resolved.Platform))
+	}
+
+	var args []string
+	for name, value := range resolved.Arguments {
+		args = append(args, fmt.Sprintf("%s=%s", name, value))
+	}
+	sort.Strings(args)
+	if len(args) > 0 {
+		parts = append(parts, fmt.Sprintf("executor %s(%s)", resolved.Name, strings.Join(args, ", ")))
+	} else if resolved.Name != "" {
+		parts = append(parts, fmt.Sprintf("executor %s", resolved.Name))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// platformNames are the operating systems of go-task's platforms, for messages
+var platformNames = map[string]string{"linux": "Linux", "darwin": "macOS", "windows": "Windows"}
+
+// hostPlatformWarnings lists the tasks whose platforms exclude the host running the
+// converter, which go-task skips here
+func hostPlatformWarnings(taskfile Taskfile) []string {
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	for _, name := range names {
+		platforms := taskfile.Tasks[name].Platforms
+		if len(platforms) == 0 || containsString(platforms, runtime.GOOS) {
+			continue
+		}
+		var hosts []string
+		for _, platform := range platforms {
+			hosts = append(hosts, platformNames[platform])
+		}
+		warnings = append(warnings, fmt.Sprintf("task %s only runs on %s; go-task skips it on this %s host", name, strings.Join(hosts, ", "), platformNames[runtime.GOOS]))
+	}
+	return warnings
+}
@@ -0,0 +1,30 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeTextFile writes a text file to the filesystem
+func writeTextFile(path string, content string) error {
+	return writeFileContent(path, []byte(content))
+}
+
+// writeFileContent writes content to a file
+func writeFileContent(path string, content []byte) error {
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing content: %w", err)
+	}
+	return nil
+}
+
+// writeYAMLFile writes data as a YAML file
+func writeYAMLFile(path string, data interface{}) error {
+	yamlData, err := yaml.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling YAML: %w", err)
+	}
+	return writeFileContent(path, yamlData)
+}
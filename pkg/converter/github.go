@@ -0,0 +1,873 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromGitHub reads GitHub Actions workflows (.github/workflows/*.yml)
+const fromGitHub = "github"
+
+// githubWorkflow is a GitHub Actions workflow file
+type githubWorkflow struct {
+	Name     string                 `yaml:"name"`
+	On       interface{}            `yaml:"on"`
+	Env      map[string]interface{} `yaml:"env"`
+	Defaults githubDefaults         `yaml:"defaults"`
+	Jobs     map[string]githubJob   `yaml:"jobs"`
+}
+
+// githubDefaults are the default shell and directory of a workflow's or job's run steps
+type githubDefaults struct {
+	Run struct {
+		Shell            string `yaml:"shell"`
+		WorkingDirectory string `yaml:"working-directory"`
+	} `yaml:"run"`
+}
+
+// githubJob is a job of a workflow: steps on a runner, or a reusable workflow it calls
+type githubJob struct {
+	RunsOn    interface{}            `yaml:"runs-on"`
+	Needs     interface{}            `yaml:"needs"`
+	If        interface{}            `yaml:"if"`
+	Env       map[string]interface{} `yaml:"env"`
+	Container interface{}            `yaml:"container"`
+	Services  map[string]interface{} `yaml:"services"`
+	Strategy  struct {
+		Matrix interface{} `yaml:"matrix"`
+	} `yaml:"strategy"`
+	Defaults githubDefaults `yaml:"defaults"`
+	Steps    []githubStep   `yaml:"steps"`
+	Uses     string         `yaml:"uses"`
+}
+
+// githubStep is a step of a job: a run step, or an action it uses
+type githubStep struct {
+	Name             string                 `yaml:"name"`
+	If               interface{}            `yaml:"if"`
+	Run              string                 `yaml:"run"`
+	Uses             string                 `yaml:"uses"`
+	With             map[string]interface{} `yaml:"with"`
+	Env              map[string]interface{} `yaml:"env"`
+	Shell            string                 `yaml:"shell"`
+	WorkingDirectory string                 `yaml:"working-directory"`
+}
+
+// githubExprRegex matches the ${{ }} expressions of a workflow
+var githubExprRegex = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+
+// githubContextRegex matches the expressions reading a github or runner context value
+var githubContextRegex = regexp.MustCompile(`^(github|runner)(\.[A-Za-z_][A-Za-z0-9_-]*)+$`)
+
+// githubNameRegex matches the characters not allowed in workflow and executor names
+var githubNameRegex = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// githubPipelineValues are the github context values with a pipeline value equivalent,
+// which the Taskfile computes from git
+var githubPipelineValues = map[string]string{
+	"github.sha":        "pipeline.git.revision",
+	"github.ref_name":   "pipeline.git.branch",
+	"github.head_ref":   "pipeline.git.branch",
+	"github.run_number": "pipeline.number",
+	"github.run_id":     "pipeline.id",
+}
+
+// githubShellEscaper escapes text for a double-quoted shell string, keeping its
+// variable references
+var githubShellEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+
+// githubShells are the `shell:` settings of run steps as CircleCI shells; GitHub runs
+// bash and sh with errexit
+var githubShells = map[string]string{
+	"bash": "/bin/bash -eo pipefail",
+	"sh":   "/bin/sh -e",
+}
+
+// githubActionMappings translate popular actions into CircleCI steps, given the
+// action's inputs with their expressions translated
+var githubActionMappings = map[string]func(with map[string]string) []interface{}{
+	"actions/checkout": func(with map[string]string) []interface{} {
+		if path := with["path"]; path != "" {
+			return []interface{}{map[string]interface{}{"checkout": map[string]interface{}{"path": path}}}
+		}
+		return []interface{}{"checkout"}
+	},
+	"actions/setup-node":   githubToolCheck("node --version"),
+	"actions/setup-python": githubToolCheck("python3 --version"),
+	"actions/setup-go":     githubToolCheck("go version"),
+	"actions/setup-java":   githubToolCheck("java -version"),
+	"actions/setup-dotnet": githubToolCheck("dotnet --version"),
+	"ruby/setup-ruby":      githubToolCheck("ruby --version"),
+	"actions/cache": func(with map[string]string) []interface{} {
+		return []interface{}{map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{with["key"]}}}}
+	},
+	"actions/cache/restore": func(with map[string]string) []interface{} {
+		return []interface{}{map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{with["key"]}}}}
+	},
+	"actions/cache/save": func(with map[string]string) []interface{} {
+		return []interface{}{map[string]interface{}{"save_cache": map[string]interface{}{"key": with["key"], "paths": githubList(with["path"])}}}
+	},
+	// Artifacts hand files from job to job, as the workspace does
+	"actions/upload-artifact": func(with map[string]string) []interface{} {
+		return []interface{}{map[string]interface{}{"persist_to_workspace": map[string]interface{}{"root": ".", "paths": githubList(with["path"])}}}
+	},
+	"actions/download-artifact": func(with map[string]string) []interface{} {
+		at := with["path"]
+		if at == "" {
+			at = "."
+		}
+		return []interface{}{map[string]interface{}{"attach_workspace": map[string]interface{}{"at": at}}}
+	},
+	"docker/login-action": func(with map[string]string) []interface{} {
+		cmd := fmt.Sprintf(`echo "%s" | docker login -u "%s" --password-stdin`, with["password"], with["username"])
+		if registry := with["registry"]; registry != "" {
+			cmd += " " + registry
+		}
+		return githubRun(cmd)
+	},
+	"docker/setup-buildx-action": githubToolCheck("docker buildx version"),
+	"docker/build-push-action": func(with map[string]string) []interface{} {
+		build := []string{"docker build"}
+		if file := with["file"]; file != "" {
+			build = append(build, "-f "+file)
+		}
+		for _, arg := range githubList(with["build-args"]) {
+			build = append(build, "--build-arg "+shellQuote(fmt.Sprint(arg)))
+		}
+		tags := githubList(with["tags"])
+		for _, tag := range tags {
+			build = append(build, fmt.Sprintf("-t %s", tag))
+		}
+		context := with["context"]
+		if context == "" {
+			context = "."
+		}
+		cmds := []string{strings.Join(append(build, context), " ")}
+		if with["push"] == "true" {
+			for _, tag := range tags {
+				cmds = append(cmds, fmt.Sprintf("docker push %s", tag))
+			}
+		}
+		return githubRun(strings.Join(cmds, "\n"))
+	},
+}
+
+// githubToolCheck maps a setup action to a check that the tool is installed
+func githubToolCheck(cmd string) func(with map[string]string) []interface{} {
+	return func(with map[string]string) []interface{} {
+		return githubRun(cmd)
+	}
+}
+
+// githubRun is a run step of a mapped action
+func githubRun(cmd string) []interface{} {
+	return []interface{}{map[string]interface{}{"run": map[string]interface{}{"command": cmd}}}
+}
+
+// githubList splits a multi-line or comma-separated action input
+func githubList(value string) []interface{} {
+	var items []interface{}
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// githubString renders a scalar of a workflow as a string
+func githubString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// githubContextVar returns the environment variable a github or runner context value
+// is read from: GH_ and its path, like GH_REPOSITORY for github.repository
+func githubContextVar(expr string) (string, bool) {
+	if !githubContextRegex.MatchString(expr) {
+		return "", false
+	}
+	path := strings.TrimPrefix(expr, "github.")
+	return "GH_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path)), true
+}
+
+// githubTranslation translates the expressions of a job, which may read its matrix
+// values and the workflow_dispatch inputs, and collects notes for the report
+type githubTranslation struct {
+	job    string
+	matrix map[string]bool
+	inputs map[string]bool
+	notes  *[]ReportEntry
+}
+
+// note records a translation note, once
+func (t *githubTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "GitHub Actions", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// expression returns the local equivalent of an expression: a parameter for matrix
+// values and inputs, a pipeline value, or an environment variable
+func (t *githubTranslation) expression(expr string) (string, bool) {
+	parts := strings.Split(expr, ".")
+	switch {
+	case len(parts) == 2 && parts[0] == "matrix" && t.matrix[parts[1]]:
+		return "<< parameters." + parts[1] + " >>", true
+	case len(parts) == 2 && parts[0] == "inputs" && t.inputs[parts[1]]:
+		return "<< pipeline.parameters." + parts[1] + " >>", true
+	case len(parts) == 4 && strings.HasPrefix(expr, "github.event.inputs.") && t.inputs[parts[3]]:
+		return "<< pipeline.parameters." + parts[3] + " >>", true
+	case len(parts) == 2 && (parts[0] == "secrets" || parts[0] == "env" || parts[0] == "vars"):
+		return "${" + parts[1] + "}", true
+	case expr == "github.workspace":
+		return "${GITHUB_WORKSPACE:-$PWD}", true
+	}
+	if ref, ok := githubPipelineValues[expr]; ok {
+		return "<< " + ref + " >>", true
+	}
+	if name, ok := githubContextVar(expr); ok {
+		t.note("`%s` is read from $%s, which must be set to run the task outside GitHub Actions", expr, name)
+		return fmt.Sprintf("${%s:?%s is not set}", name, name), true
+	}
+	return "", false
+}
+
+// translate replaces the expressions of a value with their local equivalents. Those
+// with none are kept, which makes the shell fail on them, and reported.
+func (t *githubTranslation) translate(value string) string {
+	return githubExprRegex.ReplaceAllStringFunc(value, func(match string) string {
+		if translated, ok := t.expression(githubExprRegex.FindStringSubmatch(match)[1]); ok {
+			return translated
+		}
+		t.note("expression `%s` has no local equivalent; the command keeps it and fails in the shell", match)
+		return match
+	})
+}
+
+// environment returns the variables of an env map that can be set as written: literal
+// values, and those reading only matrix values or inputs. The others are read from
+// the developer's environment instead, and reported.
+func (t *githubTranslation) environment(env map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, key := range sortedKeys(env) {
+		value := githubString(env[key])
+		translated := t.translate(value)
+		switch {
+		case !strings.Contains(translated, "$"):
+			result[key] = translated
+		case translated == "${"+key+"}":
+			t.note("%s is set from `%s`; set it in the environment (or .env) to run the task locally", key, value)
+		default:
+			t.note("%s is set from `%s`, which has no local value; set %s in the environment (or .env) to run the task locally", key, value, key)
+		}
+	}
+	return result
+}
+
+// githubStepWhen returns the CircleCI `when` of a step's `if:`, and whether it has one
+func githubStepWhen(condition interface{}) (string, bool) {
+	text := strings.TrimSpace(githubString(condition))
+	if match := githubExprRegex.FindStringSubmatch(text); match != nil && match[0] == text {
+		text = match[1]
+	}
+	switch text {
+	case "", "success()":
+		return "", true
+	case "always()", "!cancelled()":
+		return "always", true
+	case "failure()":
+		return "on_fail", true
+	}
+	return "", false
+}
+
+// runStep translates a run step. Its variables reading expressions are exported by the
+// command, as `environment:` values are set as written.
+func (t *githubTranslation) runStep(step githubStep, defaults ...githubDefaults) map[string]interface{} {
+	command := t.translate(step.Run)
+	run := make(map[string]interface{})
+	if step.Name != "" {
+		run["name"] = step.Name
+	}
+	env := make(map[string]interface{})
+	var exports []string
+	for _, key := range sortedKeys(step.Env) {
+		value := githubString(step.Env[key])
+		translated := t.translate(value)
+		switch {
+		case !strings.Contains(translated, "$"):
+			env[key] = translated
+		case translated != "${"+key+"}":
+			escaped := githubShellEscaper.Replace(translated)
+			exports = append(exports, fmt.Sprintf(`export %s="%s"`, key, escaped))
+		}
+	}
+	if len(exports) > 0 {
+		command = strings.Join(exports, "\n") + "\n" + command
+	}
+	run["command"] = command
+	if len(env) > 0 {
+		run["environment"] = env
+	}
+
+	shell, dir := step.Shell, step.WorkingDirectory
+	for _, d := range defaults {
+		if shell == "" {
+			shell = d.Run.Shell
+		}
+		if dir == "" {
+			dir = d.Run.WorkingDirectory
+		}
+	}
+	if shell != "" {
+		shell = strings.TrimSpace(strings.TrimSuffix(shell, "{0}"))
+		if mapped, ok := githubShells[shell]; ok {
+			shell = mapped
+		}
+		run["shell"] = shell
+	}
+	if dir != "" {
+		run["working_directory"] = t.translate(dir)
+	}
+	return run
+}
+
+// action translates a step using an action, with the mapping table; other actions
+// become a step echoing that they were not converted, and are reported
+func (t *githubTranslation) action(step githubStep) []interface{} {
+	name, _, _ := strings.Cut(step.Uses, "@")
+	with := make(map[string]string)
+	for key, value := range step.With {
+		if key == "key" || key == "restore-keys" {
+			// cache keys only name the cache, and are kept as written
+			with[key] = githubString(value)
+			continue
+		}
+		with[key] = t.translate(githubString(value))
+	}
+	mapping, ok := githubActionMappings[strings.ToLower(name)]
+	if !ok {
+		t.note("action %s is not converted; its task step echoes a placeholder", step.Uses)
+		label := step.Name
+		if label == "" {
+			label = step.Uses
+		}
+		return []interface{}{map[string]interface{}{"run": map[string]interface{}{
+			"name":    label,
+			"command": "echo " + shellQuote("GitHub action not converted: "+step.Uses),
+		}}}
+	}
+	for _, key := range sortedKeys(step.With) {
+		if strings.HasSuffix(key, "-version") {
+			t.note("%s sets up %s %s; the task checks the tool is installed, not its version", step.Uses, strings.TrimSuffix(key, "-version"), with[key])
+		}
+	}
+	steps := mapping(with)
+	for _, converted := range steps {
+		if body, ok := converted.(map[string]interface{}); ok && step.Name != "" {
+			if run, ok := body["run"].(map[string]interface{}); ok {
+				run["name"] = step.Name
+			}
+		}
+	}
+	return steps
+}
+
+// steps translates the steps of a job
+func (t *githubTranslation) steps(job githubJob, defaults githubDefaults) []interface{} {
+	var steps []interface{}
+	for _, step := range job.Steps {
+		when, ok := githubStepWhen(step.If)
+		if !ok {
+			label := step.Name
+			if label == "" {
+				label = step.Uses + firstLine(step.Run)
+			}
+			t.note("step %q runs only if `%s`; the task runs it unconditionally", label, githubString(step.If))
+		}
+		var converted []interface{}
+		if step.Uses != "" {
+			converted = t.action(step)
+		} else {
+			converted = []interface{}{map[string]interface{}{"run": t.runStep(step, job.Defaults, defaults)}}
+		}
+		for _, s := range converted {
+			if body, ok := s.(map[string]interface{}); ok && when != "" {
+				if run, ok := body["run"].(map[string]interface{}); ok {
+					run["when"] = when
+				}
+			}
+		}
+		steps = append(steps, converted...)
+	}
+	return steps
+}
+
+// matrixStanza translates a job's matrix into a CircleCI matrix: its parameters, with
+// the values as strings, and its exclusions. Include entries and computed matrices
+// have no equivalent and are reported.
+func (t *githubTranslation) matrixStanza(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		t.note("the matrix is computed (`%s`); the job runs once", v)
+		return nil
+	}
+	body, _ := value.(map[string]interface{})
+	parameters := make(map[string]interface{})
+	for _, key := range sortedKeys(body) {
+		if key == "include" || key == "exclude" {
+			continue
+		}
+		values, ok := body[key].([]interface{})
+		if !ok {
+			t.note("matrix value %s is computed; the job runs without it", key)
+			continue
+		}
+		strs, scalars := make([]interface{}, 0, len(values)), true
+		for _, item := range values {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				scalars = false
+			}
+			strs = append(strs, githubString(item))
+		}
+		if !scalars {
+			t.note("matrix value %s holds objects; the job runs without it", key)
+			continue
+		}
+		if len(strs) > 0 {
+			parameters[key] = strs
+			t.matrix[key] = true
+		}
+	}
+	if len(parameters) == 0 {
+		return nil
+	}
+	if body["include"] != nil {
+		t.note("the include entries of the matrix are not converted; only its combinations are")
+	}
+	stanza := map[string]interface{}{"parameters": parameters}
+	if excludes, ok := body["exclude"].([]interface{}); ok {
+		var converted []interface{}
+		for _, exclude := range excludes {
+			entry, _ := exclude.(map[string]interface{})
+			values := make(map[string]interface{})
+			for key, value := range entry {
+				if t.matrix[key] {
+					values[key] = githubString(value)
+				}
+			}
+			if len(values) == len(t.matrix) {
+				converted = append(converted, values)
+			} else {
+				t.note("a matrix exclusion naming only some values is not converted")
+			}
+		}
+		if len(converted) > 0 {
+			stanza["exclude"] = converted
+		}
+	}
+	return stanza
+}
+
+// runner returns the runner label of a job: the first of its labels, or for a matrix
+// of runners the first of the matrix values
+func (t *githubTranslation) runner(runsOn interface{}, matrix map[string]interface{}) string {
+	var labels []string
+	switch v := runsOn.(type) {
+	case map[string]interface{}:
+		labels = toStringList(v["labels"])
+		if group, ok := v["group"].(string); ok && len(labels) == 0 {
+			labels = []string{group}
+		}
+	default:
+		labels = toStringList(v)
+	}
+	if len(labels) == 0 {
+		return "ubuntu-latest"
+	}
+	label := labels[0]
+	if match := githubExprRegex.FindStringSubmatch(label); match != nil {
+		if key := strings.TrimPrefix(match[1], "matrix."); key != match[1] {
+			if values, _ := matrix["parameters"].(map[string]interface{})[key].([]interface{}); len(values) > 0 {
+				t.note("runs on every runner of the matrix (%s); the task runs on the executor of %s", key, values[0])
+				return githubString(values[0])
+			}
+		}
+		t.note("the runner is computed (`%s`); the task runs on the executor of ubuntu-latest", label)
+		return "ubuntu-latest"
+	}
+	return label
+}
+
+// githubExecutor is the CircleCI executor of a runner label: macOS and Windows runners
+// by their name, and Linux for the rest (ubuntu, self-hosted)
+func githubExecutor(label string) map[string]interface{} {
+	switch {
+	case strings.Contains(label, "macos"):
+		return map[string]interface{}{"macos": map[string]interface{}{"xcode": "15.4.0"}}
+	case strings.Contains(label, "windows"):
+		return map[string]interface{}{
+			"machine":        map[string]interface{}{"image": "windows-server-2022-gui:current"},
+			"resource_class": "windows.medium",
+		}
+	}
+	return map[string]interface{}{"machine": map[string]interface{}{"image": "ubuntu-2204:current"}}
+}
+
+// images translates a job's container and services into docker images: the container
+// first, then the services, named by their ids
+func (t *githubTranslation) images(job githubJob) []interface{} {
+	image := func(value interface{}) map[string]interface{} {
+		def := make(map[string]interface{})
+		switch v := value.(type) {
+		case string:
+			def["image"] = t.translate(v)
+		case map[string]interface{}:
+			def["image"] = t.translate(githubString(v["image"]))
+			if env, ok := v["env"].(map[string]interface{}); ok && len(env) > 0 {
+				def["environment"] = t.environment(env)
+			}
+			if v["credentials"] != nil {
+				t.note("image %s is pulled with credentials; log in to its registry to pull it locally", def["image"])
+			}
+		}
+		return def
+	}
+
+	if job.Container == nil {
+		if len(job.Services) > 0 {
+			t.note("the job's service containers run only for jobs in a container; start them locally before the task")
+		}
+		return nil
+	}
+	images := []interface{}{image(job.Container)}
+	for _, name := range sortedKeys(job.Services) {
+		service := image(job.Services[name])
+		service["name"] = name
+		images = append(images, service)
+	}
+	return images
+}
+
+// githubInputs translates the inputs of workflow_dispatch and workflow_call into
+// pipeline parameters
+func githubInputs(on interface{}) map[string]interface{} {
+	events, _ := on.(map[string]interface{})
+	parameters := make(map[string]interface{})
+	for _, event := range []string{"workflow_dispatch", "workflow_call"} {
+		body, _ := events[event].(map[string]interface{})
+		inputs, _ := body["inputs"].(map[string]interface{})
+		for name, value := range inputs {
+			input, _ := value.(map[string]interface{})
+			parameter := map[string]interface{}{"type": "string"}
+			switch input["type"] {
+			case "boolean":
+				parameter["type"] = "boolean"
+			case "choice":
+				parameter["type"] = "enum"
+				parameter["enum"] = input["options"]
+			}
+			if input["default"] != nil {
+				parameter["default"] = input["default"]
+			} else if parameter["type"] == "string" {
+				parameter["default"] = ""
+			}
+			if description, ok := input["description"].(string); ok {
+				parameter["description"] = description
+			}
+			parameters[name] = parameter
+		}
+	}
+	return parameters
+}
+
+// githubTriggers translates the schedules of a workflow into CircleCI triggers, and
+// returns the other events it runs on
+func githubTriggers(on interface{}) ([]interface{}, []string) {
+	var triggers []interface{}
+	var events []string
+	switch v := on.(type) {
+	case string:
+		events = []string{v}
+	case []interface{}:
+		events = toStringList(v)
+	case map[string]interface{}:
+		for _, event := range sortedKeys(v) {
+			if event != "schedule" {
+				events = append(events, event)
+				continue
+			}
+			schedules, _ := v[event].([]interface{})
+			for _, schedule := range schedules {
+				if cron, ok := schedule.(map[string]interface{})["cron"].(string); ok {
+					triggers = append(triggers, map[string]interface{}{"schedule": map[string]interface{}{"cron": cron}})
+				}
+			}
+		}
+	}
+	return triggers, events
+}
+
+// githubWorkflowName names the workflow after its file
+func githubWorkflowName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name = strings.Trim(githubNameRegex.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		return "workflow"
+	}
+	return name
+}
+
+// githubJobOrder returns the job ids of a workflow document in the order written
+func githubJobOrder(original *yaml.Node) []string {
+	var order []string
+	if len(original.Content) == 0 {
+		return nil
+	}
+	if jobs := mappingValue(original.Content[0], "jobs"); jobs != nil {
+		for i := 0; i+1 < len(jobs.Content); i += 2 {
+			order = append(order, jobs.Content[i].Value)
+		}
+	}
+	return order
+}
+
+// parseGitHubWorkflow translates a GitHub Actions workflow into a CircleCI config: a
+// workflow named after the file running the jobs in their `needs:` order, with their
+// runners as executors, containers as docker images, matrices as CircleCI matrices
+// and workflow_dispatch inputs as pipeline parameters. Run steps keep their commands,
+// with the expressions translated; actions become steps through the mapping table.
+// What has no equivalent is recorded in the config's notes.
+func parseGitHubWorkflow(file string, data []byte) (CircleCIConfig, error) {
+	var workflow githubWorkflow
+	if err := yaml.Unmarshal(data, &workflow); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	if len(workflow.Jobs) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the workflow has no jobs", Hint: "is this a GitHub Actions workflow? Pass -from circleci for CircleCI configs"}
+	}
+
+	var notes []ReportEntry
+	name := githubWorkflowName(file)
+	parameters := githubInputs(workflow.On)
+	inputs := make(map[string]bool)
+	for input := range parameters {
+		inputs[input] = true
+	}
+
+	workflowEnv := (&githubTranslation{job: name, inputs: inputs, notes: &notes}).environment(workflow.Env)
+
+	jobs := make(map[string]interface{})
+	executors := make(map[string]interface{})
+	var invocations []interface{}
+	for _, id := range githubJobOrder(&original) {
+		job := workflow.Jobs[id]
+		t := &githubTranslation{job: id, matrix: make(map[string]bool), inputs: inputs, notes: &notes}
+
+		invocation := make(map[string]interface{})
+		if needs := toStringList(job.Needs); len(needs) > 0 {
+			invocation["requires"] = needs
+		}
+		if job.If != nil {
+			t.note("the job runs only if `%s`; the task runs unconditionally", githubString(job.If))
+		}
+		if job.Uses != "" {
+			t.note("the job calls the reusable workflow %s, which is not converted", job.Uses)
+			jobs[id] = map[string]interface{}{
+				"machine": true,
+				"steps":   githubRun("echo " + shellQuote("GitHub reusable workflow not converted: "+job.Uses)),
+			}
+			invocations = append(invocations, map[string]interface{}{id: invocation})
+			continue
+		}
+
+		matrix := t.matrixStanza(job.Strategy.Matrix)
+		def := map[string]interface{}{}
+		if images := t.images(job); len(images) > 0 {
+			def["docker"] = images
+		} else {
+			label := t.runner(job.RunsOn, matrix)
+			executor := strings.Trim(githubNameRegex.ReplaceAllString(label, "-"), "-")
+			executors[executor] = githubExecutor(label)
+			def["executor"] = executor
+		}
+		environment := t.environment(job.Env)
+		for key, value := range workflowEnv {
+			if _, ok := job.Env[key]; !ok {
+				environment[key] = value
+			}
+		}
+		if len(environment) > 0 {
+			def["environment"] = environment
+		}
+		if matrix != nil {
+			jobParameters := make(map[string]interface{})
+			for key, values := range matrix["parameters"].(map[string]interface{}) {
+				jobParameters[key] = map[string]interface{}{"type": "string", "default": values.([]interface{})[0]}
+			}
+			def["parameters"] = jobParameters
+			invocation["matrix"] = matrix
+		}
+		def["steps"] = t.steps(job, workflow.Defaults)
+		jobs[id] = def
+
+		if len(invocation) == 0 {
+			invocations = append(invocations, id)
+		} else {
+			invocations = append(invocations, map[string]interface{}{id: invocation})
+		}
+	}
+
+	body := map[string]interface{}{"jobs": invocations}
+	triggers, events := githubTriggers(workflow.On)
+	if len(triggers) > 0 {
+		body["triggers"] = triggers
+		if len(events) > 0 {
+			notes = append(notes, ReportEntry{Category: "GitHub Actions", Job: name, Message: fmt.Sprintf("the workflow runs on a schedule and on %s; the converted workflow is a scheduled one", strings.Join(events, ", "))})
+		}
+	}
+	doc := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      jobs,
+		"workflows": map[string]interface{}{name: body},
+	}
+	if len(executors) > 0 {
+		doc["executors"] = executors
+	}
+	if len(parameters) > 0 {
+		doc["parameters"] = parameters
+	}
+
+	translated, err := yaml.Marshal(doc)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	config, err := parseConfig(file, translated)
+	if err != nil {
+		return config, err
+	}
+	config.format = fromGitHub
+	config.original = &original
+	config.notes = notes
+	config.source = nil
+	return config, nil
+}
+
+// githubSlimStep is a step of the slim workflow
+type githubSlimStep struct {
+	Uses string            `yaml:"uses,omitempty"`
+	Run  string            `yaml:"run,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+}
+
+// githubJobEnv returns the variables the task of a job reads that GitHub Actions sets:
+// the secrets and the github and runner context values its steps use
+func githubJobEnv(job *yaml.Node) map[string]string {
+	steps := mappingValue(job, "steps")
+	if steps == nil {
+		return nil
+	}
+	text, err := yaml.Marshal(steps)
+	if err != nil {
+		return nil
+	}
+	env := make(map[string]string)
+	for _, match := range githubExprRegex.FindAllStringSubmatch(string(text), -1) {
+		expr := match[1]
+		if name := strings.TrimPrefix(expr, "secrets."); name != expr && !strings.Contains(name, ".") {
+			env[name] = "${{ " + expr + " }}"
+		} else if name, ok := githubContextVar(expr); ok && githubPipelineValues[expr] == "" {
+			env[name] = "${{ " + expr + " }}"
+		}
+	}
+	return env
+}
+
+// writeGitHubWorkflow writes the slim workflow: the input workflow with the steps of
+// each job replaced by a checkout and a call to the job's task (or script, for the
+// script targets), passing the matrix values and inputs the job uses and setting the
+// secrets and context values its steps read
+func writeGitHubWorkflow(path string, config CircleCIConfig, target string) error {
+	root := config.original.Content[0]
+	jobs := mappingValue(root, "jobs")
+	for i := 0; jobs != nil && i+1 < len(jobs.Content); i += 2 {
+		name, node := jobs.Content[i].Value, jobs.Content[i+1]
+		steps := mappingValue(node, "steps")
+		if steps == nil {
+			continue
+		}
+
+		call := "task " + name
+		if scriptTarget(target) {
+			call = "./" + scriptFile(name)
+		}
+		job := config.Jobs[name]
+		env := githubJobEnv(node)
+		var args []string
+		for _, key := range sortedKeys(job.Parameters) {
+			args = append(args, fmt.Sprintf("%s='${{ matrix.%s }}'", taskVarName(key), key))
+		}
+		// inputs are empty on the other events, which run the task with the defaults
+		var inputArgs []string
+		for _, ref := range jobPipelineRefs(job, config.Commands) {
+			if input := strings.TrimPrefix(ref, "pipeline.parameters."); input != ref {
+				variable := taskVarName(input)
+				def, _ := config.Parameters[input].(map[string]interface{})
+				fallback := ""
+				if def["default"] != nil {
+					fallback = githubShellEscaper.Replace(formatParamValue(def, def["default"]))
+				}
+				if env == nil {
+					env = make(map[string]string)
+				}
+				env["INPUT_"+variable] = "${{ inputs." + input + " }}"
+				inputArgs = append(inputArgs, fmt.Sprintf(`%s="${INPUT_%s:-%s}"`, variable, variable, fallback))
+			}
+		}
+		sort.Strings(inputArgs)
+		if args = append(args, inputArgs...); len(args) > 0 {
+			call += " " + strings.Join(args, " ")
+		}
+
+		slim := []githubSlimStep{{Uses: "actions/checkout@v4"}}
+		if !scriptTarget(target) {
+			slim = append(slim, githubSlimStep{Uses: "arduino/setup-task@v2"})
+		}
+		slim = append(slim, githubSlimStep{Run: call, Env: env})
+		var encoded yaml.Node
+		if err := encoded.Encode(slim); err != nil {
+			return err
+		}
+		*steps = encoded
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.original); err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
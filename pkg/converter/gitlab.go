@@ -0,0 +1,1003 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromGitLab reads GitLab CI configs (.gitlab-ci.yml)
+const fromGitLab = "gitlab"
+
+// gitlabGlobalKeys are the top-level keys of a GitLab CI config that are not jobs
+var gitlabGlobalKeys = map[string]bool{
+	"stages": true, "variables": true, "default": true, "include": true, "workflow": true,
+	"image": true, "services": true, "before_script": true, "after_script": true, "cache": true,
+	"types": true, "spec": true,
+}
+
+// gitlabDefaultKeys are the job keywords a job takes from `default:` (or the deprecated
+// top-level keywords) when it does not set them
+var gitlabDefaultKeys = []string{"image", "services", "before_script", "after_script", "cache", "artifacts", "tags", "retry", "timeout", "interruptible"}
+
+// gitlabDefaultStages are the stages of a config without `stages:`
+var gitlabDefaultStages = []string{"build", "test", "deploy"}
+
+// gitlabPipelineValues are the predefined variables with a pipeline value equivalent,
+// which the Taskfile computes from git
+var gitlabPipelineValues = map[string]string{
+	"CI_COMMIT_SHA":      "pipeline.git.revision",
+	"CI_COMMIT_REF_NAME": "pipeline.git.branch",
+	"CI_COMMIT_BRANCH":   "pipeline.git.branch",
+	"CI_COMMIT_TAG":      "pipeline.git.tag",
+	"CI_PIPELINE_IID":    "pipeline.number",
+	"CI_PIPELINE_ID":     "pipeline.id",
+}
+
+// gitlabVarRegex matches the references to predefined CI_ variables
+var gitlabVarRegex = regexp.MustCompile(`\$(?:\{(CI_[A-Za-z0-9_]+)\}|(CI_[A-Za-z0-9_]+))`)
+
+// gitlabReference is the key standing for a !reference tag in a decoded config
+const gitlabReference = "!reference"
+
+// gitlabRefKeywords are the `only:`/`except:` refs naming kinds of pipelines rather
+// than branches or tags
+var gitlabRefKeywords = map[string]bool{
+	"api": true, "chat": true, "external": true, "external_pull_requests": true, "merge_requests": true,
+	"pipelines": true, "pushes": true, "schedules": true, "triggers": true, "web": true,
+}
+
+// gitlabUnconverted are the job keywords with no local equivalent, with what the task
+// does instead
+var gitlabUnconverted = map[string]string{
+	"environment":    "the job deploys to the GitLab environment %v, which the task does not track",
+	"resource_group": "GitLab runs one job of the resource group %v at a time; tasks do not queue",
+	"retry":          "GitLab retries the job when it fails (retry: %v); the task runs once",
+	"release":        "the job creates a GitLab release, which the task does not",
+	"timeout":        "GitLab stops the job after %v; the task runs until it finishes",
+}
+
+// gitlabTranslation translates the variable references of a job and collects notes
+// for the report
+type gitlabTranslation struct {
+	job   string
+	notes *[]ReportEntry
+}
+
+// note records a translation note, once
+func (t *gitlabTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "GitLab CI", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// translate replaces the predefined variables with a pipeline value by it; the others
+// are kept, and reported as needing a value outside GitLab
+func (t *gitlabTranslation) translate(text string) string {
+	return gitlabVarRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := gitlabVarRegex.FindStringSubmatch(ref)
+		name := match[1] + match[2]
+		if value, ok := gitlabPipelineValues[name]; ok {
+			return "<< " + value + " >>"
+		}
+		if name == "CI_PROJECT_DIR" {
+			return "${CI_PROJECT_DIR:-$PWD}"
+		}
+		t.note("`$%s` is set by GitLab; set it in the environment (or .env) to run the task locally", name)
+		return ref
+	})
+}
+
+// gitlabString renders a scalar of a config as a string
+func gitlabString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// gitlabLines flattens a script, whose entries may be lists (from !reference tags)
+func gitlabLines(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		var lines []string
+		for _, item := range v {
+			lines = append(lines, gitlabLines(item)...)
+		}
+		return lines
+	}
+	return []string{gitlabString(value)}
+}
+
+// gitlabMarkReferences turns the !reference tags of a document into mappings with the
+// gitlabReference key, which survive decoding
+func gitlabMarkReferences(node *yaml.Node) {
+	for _, child := range node.Content {
+		gitlabMarkReferences(child)
+	}
+	if node.Tag == "!reference" && node.Kind == yaml.SequenceNode {
+		path := *node
+		path.Tag = ""
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: gitlabReference},
+			&path,
+		}}
+	}
+}
+
+// gitlabDecode decodes a config, keeping its !reference tags
+func gitlabDecode(file string, data []byte) (map[string]interface{}, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, yamlParseError(file, data, err)
+	}
+	doc := make(map[string]interface{})
+	if len(root.Content) == 0 {
+		return doc, nil
+	}
+	gitlabMarkReferences(&root)
+	if err := root.Content[0].Decode(&doc); err != nil {
+		return nil, yamlParseError(file, data, err)
+	}
+	return doc, nil
+}
+
+// gitlabMerge deep-merges over into base, as GitLab merges includes and extends:
+// mappings key by key, other values replaced
+func gitlabMerge(base, over map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(over))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range over {
+		if overMap, ok := value.(map[string]interface{}); ok {
+			if baseMap, ok := merged[key].(map[string]interface{}); ok && overMap[gitlabReference] == nil {
+				merged[key] = gitlabMerge(baseMap, overMap)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// gitlabIncludes lists the includes of a config: a string, a mapping or a list of them
+func gitlabIncludes(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	if value == nil {
+		return nil
+	}
+	return []interface{}{value}
+}
+
+// gitlabLoad reads a config with its local includes merged in, its own keys over the
+// included ones'. Local paths are relative to the repository root; other includes
+// (remote, project, template, component) are reported.
+func gitlabLoad(file string, data []byte, root string, seen map[string]bool, t *gitlabTranslation) (map[string]interface{}, error) {
+	doc, err := gitlabDecode(file, data)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for _, include := range gitlabIncludes(doc["include"]) {
+		local := ""
+		switch v := include.(type) {
+		case string:
+			if !strings.HasPrefix(v, "http://") && !strings.HasPrefix(v, "https://") {
+				local = v
+			}
+		case map[string]interface{}:
+			local = gitlabString(v["local"])
+		}
+		if local == "" {
+			t.note("include `%s` is not converted; only local includes are read", gitlabDescribeInclude(include))
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(root, strings.TrimPrefix(local, "/")))
+		if len(matches) == 0 {
+			t.note("include `%s` matches no file", local)
+		}
+		for _, path := range matches {
+			abs, _ := filepath.Abs(path)
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			included, err := os.ReadFile(path)
+			if err != nil {
+				return nil, &ParseError{File: file, Message: fmt.Sprintf("include %s: %v", local, err)}
+			}
+			doc, err := gitlabLoad(path, included, root, seen, t)
+			if err != nil {
+				return nil, err
+			}
+			merged = gitlabMerge(merged, doc)
+		}
+	}
+	delete(doc, "include")
+	return gitlabMerge(merged, doc), nil
+}
+
+// gitlabDescribeInclude names an include in notes
+func gitlabDescribeInclude(include interface{}) string {
+	body, ok := include.(map[string]interface{})
+	if !ok {
+		return gitlabString(include)
+	}
+	for _, key := range []string{"remote", "project", "template", "component"} {
+		if value, ok := body[key]; ok {
+			if file := gitlabString(body["file"]); file != "" {
+				return fmt.Sprintf("%s: %v (%s)", key, value, file)
+			}
+			return fmt.Sprintf("%s: %v", key, value)
+		}
+	}
+	return fmt.Sprint(include)
+}
+
+// gitlabResolveReferences replaces the !reference values of a config with what they
+// refer to; references to nothing are left out, and reported
+func gitlabResolveReferences(value interface{}, doc map[string]interface{}, depth int, t *gitlabTranslation) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if path, ok := v[gitlabReference]; ok && len(v) == 1 {
+			var target interface{} = doc
+			for _, key := range toStringList(path) {
+				body, _ := target.(map[string]interface{})
+				target = body[key]
+			}
+			if target == nil || depth > 10 {
+				t.note("`!reference %v` refers to nothing; it is left out", toStringList(path))
+				return nil
+			}
+			return gitlabResolveReferences(target, doc, depth+1, t)
+		}
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved[key] = gitlabResolveReferences(item, doc, depth, t)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			resolved = append(resolved, gitlabResolveReferences(item, doc, depth, t))
+		}
+		return resolved
+	}
+	return value
+}
+
+// gitlabExtend returns a job with the jobs it extends merged in: each over the previous
+// one, and the job's own keys over them all
+func gitlabExtend(name string, jobs map[string]interface{}, depth int) (map[string]interface{}, error) {
+	job, _ := jobs[name].(map[string]interface{})
+	if depth > 11 {
+		return nil, fmt.Errorf("%s: extends nests more than 11 levels (a cycle?)", name)
+	}
+	merged := make(map[string]interface{})
+	for _, parent := range toStringList(job["extends"]) {
+		if _, ok := jobs[parent].(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s extends %s, which is not defined", name, parent)
+		}
+		base, err := gitlabExtend(parent, jobs, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		merged = gitlabMerge(merged, base)
+	}
+	merged = gitlabMerge(merged, job)
+	delete(merged, "extends")
+	return merged, nil
+}
+
+// gitlabApplyDefaults gives a job the keywords of `default:` and the top-level
+// keywords it does not set, and the global variables, as its `inherit:` allows
+func gitlabApplyDefaults(job, doc map[string]interface{}) map[string]interface{} {
+	inherit, _ := job["inherit"].(map[string]interface{})
+	inherits := func(kind, key string) bool {
+		switch v := inherit[kind].(type) {
+		case bool:
+			return v
+		case []interface{}:
+			return containsString(toStringList(v), key)
+		}
+		return true
+	}
+
+	defaults, _ := doc["default"].(map[string]interface{})
+	resolved := make(map[string]interface{}, len(job))
+	for key, value := range job {
+		resolved[key] = value
+	}
+	for _, key := range gitlabDefaultKeys {
+		if _, ok := resolved[key]; ok || !inherits("default", key) {
+			continue
+		}
+		if value, ok := defaults[key]; ok {
+			resolved[key] = value
+		} else if value, ok := doc[key]; ok {
+			resolved[key] = value
+		}
+	}
+
+	variables := make(map[string]interface{})
+	if globals, ok := doc["variables"].(map[string]interface{}); ok {
+		for key, value := range globals {
+			if inherits("variables", key) {
+				variables[key] = value
+			}
+		}
+	}
+	if own, ok := job["variables"].(map[string]interface{}); ok {
+		for key, value := range own {
+			variables[key] = value
+		}
+	}
+	resolved["variables"] = variables
+	return resolved
+}
+
+// gitlabVariableValue returns the value of a variable, written as a scalar or as a
+// mapping with `value:`
+func gitlabVariableValue(value interface{}) string {
+	if body, ok := value.(map[string]interface{}); ok {
+		return gitlabString(body["value"])
+	}
+	return gitlabString(value)
+}
+
+// gitlabParameters translates the global variables with a description, which GitLab
+// offers to fill in when running a pipeline by hand, into pipeline parameters
+func gitlabParameters(doc map[string]interface{}) map[string]interface{} {
+	globals, _ := doc["variables"].(map[string]interface{})
+	parameters := make(map[string]interface{})
+	for name, value := range globals {
+		body, ok := value.(map[string]interface{})
+		if !ok || body["description"] == nil {
+			continue
+		}
+		parameter := map[string]interface{}{
+			"type":        "string",
+			"default":     gitlabString(body["value"]),
+			"description": gitlabString(body["description"]),
+		}
+		if options := toStringList(body["options"]); len(options) > 0 {
+			parameter["type"] = "enum"
+			parameter["enum"] = options
+		}
+		parameters[name] = parameter
+	}
+	return parameters
+}
+
+// environment splits the variables of a job into its environment, for literal values,
+// and export lines for its scripts, for values reading other variables, which the
+// shell expands as GitLab does
+func (t *gitlabTranslation) environment(variables map[string]interface{}, parameters map[string]interface{}) (map[string]interface{}, []string) {
+	env := make(map[string]interface{})
+	var exports []string
+	for _, key := range sortedKeys(variables) {
+		if _, ok := parameters[key]; ok {
+			env[key] = "<< pipeline.parameters." + key + " >>"
+			continue
+		}
+		value := t.translate(gitlabVariableValue(variables[key]))
+		if strings.Contains(value, "$") {
+			exports = append(exports, fmt.Sprintf(`export %s="%s"`, key, githubShellEscaper.Replace(value)))
+		} else {
+			env[key] = value
+		}
+	}
+	return env, exports
+}
+
+// image translates an image, written as a name or as a mapping with `name:`
+func (t *gitlabTranslation) image(value interface{}) map[string]interface{} {
+	def := map[string]interface{}{"image": t.translate(gitlabString(value))}
+	if body, ok := value.(map[string]interface{}); ok {
+		def["image"] = t.translate(gitlabString(body["name"]))
+		if body["entrypoint"] != nil {
+			t.note("image %s overrides its entrypoint, which the task does not", def["image"])
+		}
+	}
+	return def
+}
+
+// images translates a job's image and services into docker images: the image first,
+// then the services, named by their aliases or, as GitLab names them, after their image
+func (t *gitlabTranslation) images(job map[string]interface{}) []interface{} {
+	services, _ := job["services"].([]interface{})
+	if job["image"] == nil {
+		if len(services) > 0 {
+			t.note("the job's services run only for jobs with an image; start them locally before the task")
+		}
+		return nil
+	}
+	images := []interface{}{t.image(job["image"])}
+	for _, service := range services {
+		def := t.image(service)
+		name := strings.SplitN(gitlabString(def["image"]), ":", 2)[0]
+		name = strings.ReplaceAll(name, "/", "__")
+		if body, ok := service.(map[string]interface{}); ok {
+			if alias := gitlabString(body["alias"]); alias != "" {
+				name = strings.Fields(strings.ReplaceAll(alias, ",", " "))[0]
+			}
+			if command := toStringList(body["command"]); len(command) > 0 {
+				def["command"] = command
+			}
+			if variables, ok := body["variables"].(map[string]interface{}); ok {
+				env := make(map[string]interface{})
+				for key, value := range variables {
+					env[key] = t.translate(gitlabVariableValue(value))
+				}
+				def["environment"] = env
+			}
+		}
+		def["name"] = name
+		images = append(images, def)
+	}
+	return images
+}
+
+// caches translates a job's caches into the steps restoring them before its scripts
+// and saving them after
+func (t *gitlabTranslation) caches(value interface{}) ([]interface{}, []interface{}) {
+	var caches []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		caches = []interface{}{v}
+	case []interface{}:
+		caches = v
+	}
+	var restore, save []interface{}
+	for _, item := range caches {
+		cache, _ := item.(map[string]interface{})
+		key := "default"
+		switch k := cache["key"].(type) {
+		case string:
+			key = k
+		case map[string]interface{}:
+			var parts []string
+			if prefix := gitlabString(k["prefix"]); prefix != "" {
+				parts = append(parts, prefix)
+			}
+			for _, file := range toStringList(k["files"]) {
+				parts = append(parts, fmt.Sprintf(`{{ checksum "%s" }}`, file))
+			}
+			key = strings.Join(parts, "-")
+		}
+		policy := gitlabString(cache["policy"])
+		if policy != "push" {
+			restore = append(restore, map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{key}}})
+		}
+		if paths := toStringList(cache["paths"]); len(paths) > 0 && policy != "pull" {
+			save = append(save, map[string]interface{}{"save_cache": map[string]interface{}{"key": key, "paths": paths}})
+		}
+	}
+	return restore, save
+}
+
+// gitlabArtifactPaths returns the paths of a job's artifacts
+func gitlabArtifactPaths(job map[string]interface{}) []string {
+	artifacts, _ := job["artifacts"].(map[string]interface{})
+	return toStringList(artifacts["paths"])
+}
+
+// filters translates `only:` and `except:` refs into CircleCI branch and tag filters:
+// branch names and /regexes/ filter branches, `tags` runs the job for tags too. The
+// other refs and conditions are reported.
+func (t *gitlabTranslation) filters(only, except interface{}) map[string]interface{} {
+	refs := func(value interface{}, keyword string) []string {
+		if body, ok := value.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(body) {
+				if key != "refs" {
+					t.note("the job runs only if its `%s: %s:` conditions hold; the task runs unconditionally", keyword, key)
+				}
+			}
+			value = body["refs"]
+		}
+		var names []string
+		for _, ref := range toStringList(value) {
+			if gitlabRefKeywords[ref] || strings.Contains(ref, "@") {
+				t.note("`%s: %s` is not converted; the task runs for any pipeline", keyword, ref)
+				continue
+			}
+			names = append(names, ref)
+		}
+		return names
+	}
+
+	filters := make(map[string]interface{})
+	var branches []interface{}
+	tags := false
+	for _, ref := range refs(only, "only") {
+		switch ref {
+		case "tags":
+			tags = true
+		case "branches":
+			branches = append(branches, "/.*/")
+		default:
+			branches = append(branches, ref)
+		}
+	}
+	if tags {
+		filters["tags"] = map[string]interface{}{"only": "/.*/"}
+		if len(branches) == 0 {
+			filters["branches"] = map[string]interface{}{"ignore": "/.*/"}
+		}
+	}
+	if len(branches) > 0 && !containsString(toStringList(branches), "/.*/") {
+		filters["branches"] = map[string]interface{}{"only": branches}
+	}
+	var ignore []interface{}
+	for _, ref := range refs(except, "except") {
+		switch ref {
+		case "tags":
+			delete(filters, "tags")
+		case "branches":
+			filters["branches"] = map[string]interface{}{"ignore": "/.*/"}
+		default:
+			ignore = append(ignore, ref)
+		}
+	}
+	if len(ignore) > 0 {
+		if _, ok := filters["branches"]; !ok {
+			filters["branches"] = map[string]interface{}{"ignore": ignore}
+		} else {
+			t.note("`except:` branches are not converted alongside `only:` branches")
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return filters
+}
+
+// steps translates a job's scripts, caches and artifacts into steps: the checkout,
+// caches and workspace, the before_script and script in one run step as GitLab runs
+// them in one shell, and the after_script, which runs even when they fail
+func (t *gitlabTranslation) steps(job map[string]interface{}, exports []string, attach bool) []interface{} {
+	var steps []interface{}
+	if gitlabVariableValue(job["variables"].(map[string]interface{})["GIT_STRATEGY"]) != "none" {
+		steps = append(steps, "checkout")
+	}
+	restore, save := t.caches(job["cache"])
+	steps = append(steps, restore...)
+	if attach {
+		steps = append(steps, map[string]interface{}{"attach_workspace": map[string]interface{}{"at": "."}})
+	}
+
+	prelude := append([]string{}, exports...)
+	script := append(prelude, gitlabLines(job["before_script"])...)
+	script = append(script, gitlabLines(job["script"])...)
+	steps = append(steps, map[string]interface{}{"run": map[string]interface{}{
+		"name":    "script",
+		"command": t.translate(strings.Join(script, "\n")),
+	}})
+
+	steps = append(steps, save...)
+	if paths := gitlabArtifactPaths(job); len(paths) > 0 {
+		steps = append(steps, map[string]interface{}{"persist_to_workspace": map[string]interface{}{"root": ".", "paths": paths}})
+	}
+	artifacts, _ := job["artifacts"].(map[string]interface{})
+	reports, _ := artifacts["reports"].(map[string]interface{})
+	for _, path := range toStringList(reports["junit"]) {
+		steps = append(steps, map[string]interface{}{"store_test_results": map[string]interface{}{"path": path}})
+	}
+	if after := gitlabLines(job["after_script"]); len(after) > 0 {
+		steps = append(steps, map[string]interface{}{"run": map[string]interface{}{
+			"name":    "after_script",
+			"command": t.translate(strings.Join(append(prelude, after...), "\n")),
+			"when":    "always",
+		}})
+	}
+	return steps
+}
+
+// matrix translates the first entry of `parallel: matrix:` into a CircleCI matrix,
+// whose parameters the job sets as variables
+func (t *gitlabTranslation) matrix(parallel interface{}) map[string]interface{} {
+	body, _ := parallel.(map[string]interface{})
+	entries, _ := body["matrix"].([]interface{})
+	if len(entries) == 0 {
+		return nil
+	}
+	if len(entries) > 1 {
+		t.note("only the first of the %d `parallel: matrix:` entries is converted", len(entries))
+	}
+	entry, _ := entries[0].(map[string]interface{})
+	parameters := make(map[string]interface{})
+	for key, value := range entry {
+		values := toStringList(value)
+		if len(values) == 0 {
+			values = []string{gitlabString(value)}
+		}
+		parameters[key] = values
+	}
+	return map[string]interface{}{"parameters": parameters}
+}
+
+// gitlabWorkflowName names the workflow of a config after its file
+func gitlabWorkflowName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name = strings.Trim(githubNameRegex.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return "pipeline"
+	}
+	return name
+}
+
+// gitlabJob is a job of a config, resolved and placed in its stage
+type gitlabJob struct {
+	name  string
+	body  map[string]interface{}
+	stage int
+	when  string
+}
+
+// blocking reports whether later stages wait for the job: manual jobs (which may fail
+// unless they say otherwise), jobs that never run and jobs that run on failure do not
+func (j gitlabJob) blocking() bool {
+	switch j.when {
+	case "never", "on_failure":
+		return false
+	case "manual":
+		return j.body["allow_failure"] == false
+	}
+	return true
+}
+
+// parseGitLabCI translates a GitLab CI config into a CircleCI config: a workflow named
+// after the file running the jobs stage by stage (or in their `needs:` order), with
+// local includes, `extends:`, `default:` and !reference tags resolved. Images become
+// docker executors, scripts run steps, caches and artifacts cache and workspace steps,
+// manual jobs approval jobs and `parallel: matrix:` CircleCI matrices. What has no
+// equivalent is recorded in the config's notes.
+func parseGitLabCI(file string, data []byte) (CircleCIConfig, error) {
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+
+	var notes []ReportEntry
+	name := gitlabWorkflowName(file)
+	wt := &gitlabTranslation{job: name, notes: &notes}
+	abs, _ := filepath.Abs(file)
+	seen := map[string]bool{abs: true}
+	loaded, err := gitlabLoad(file, data, filepath.Dir(file), seen, wt)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	doc := gitlabResolveReferences(loaded, loaded, 0, wt).(map[string]interface{})
+	if workflow, ok := doc["workflow"].(map[string]interface{}); ok && workflow["rules"] != nil {
+		wt.note("the pipeline runs only per its `workflow: rules:`; the tasks run unconditionally")
+	}
+
+	stages := toStringList(doc["stages"])
+	if len(stages) == 0 {
+		stages = gitlabDefaultStages
+	}
+	stages = append(append([]string{".pre"}, stages...), ".post")
+	stageIndex := make(map[string]int)
+	for i, stage := range stages {
+		stageIndex[stage] = i
+	}
+
+	templates := make(map[string]interface{})
+	for key, value := range doc {
+		if _, ok := value.(map[string]interface{}); ok && !gitlabGlobalKeys[key] {
+			templates[key] = value
+		}
+	}
+	var jobs []gitlabJob
+	byName := make(map[string]gitlabJob)
+	for _, jobName := range sortedKeys(templates) {
+		if strings.HasPrefix(jobName, ".") {
+			continue
+		}
+		body, err := gitlabExtend(jobName, templates, 0)
+		if err != nil {
+			return CircleCIConfig{}, &ParseError{File: file, Message: err.Error()}
+		}
+		body = gitlabApplyDefaults(body, doc)
+		t := &gitlabTranslation{job: jobName, notes: &notes}
+		stage := gitlabString(body["stage"])
+		if stage == "" {
+			stage = "test"
+		}
+		index, ok := stageIndex[stage]
+		if !ok {
+			t.note("stage %s is not in `stages:`; the job runs after the others", stage)
+			index = len(stages)
+		}
+		if body["trigger"] != nil {
+			t.note("the job triggers a downstream pipeline; it is not converted, and the slim config keeps it as written")
+			continue
+		}
+		when := gitlabString(body["when"])
+		if when == "never" {
+			t.note("the job has `when: never` and is not converted")
+			continue
+		}
+		job := gitlabJob{name: jobName, body: body, stage: index, when: when}
+		jobs = append(jobs, job)
+		byName[jobName] = job
+	}
+	if len(jobs) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the config has no jobs", Hint: "is this a GitLab CI config? Pass -from circleci for CircleCI configs"}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].stage < jobs[j].stage })
+
+	// A job needs the jobs it lists in `needs:`, else those of the latest earlier stage
+	// with jobs that later stages wait for
+	requires := make(map[string][]string)
+	for _, job := range jobs {
+		t := &gitlabTranslation{job: job.name, notes: &notes}
+		if needs, ok := job.body["needs"].([]interface{}); ok {
+			for _, need := range needs {
+				needed := gitlabString(need)
+				if body, ok := need.(map[string]interface{}); ok {
+					if body["pipeline"] != nil || body["project"] != nil {
+						t.note("the job needs a job of another pipeline, which is not converted")
+						continue
+					}
+					needed = gitlabString(body["job"])
+				}
+				if _, ok := byName[needed]; ok {
+					requires[job.name] = append(requires[job.name], needed)
+				}
+			}
+			continue
+		}
+		for stage := job.stage - 1; stage >= 0; stage-- {
+			for _, other := range jobs {
+				if other.stage == stage && other.blocking() {
+					requires[job.name] = append(requires[job.name], other.name)
+				}
+			}
+			if len(requires[job.name]) > 0 {
+				break
+			}
+		}
+	}
+
+	// Jobs take the artifacts of their `dependencies:`, else of the jobs before them
+	var before func(name string, seen map[string]bool) []string
+	before = func(name string, seen map[string]bool) []string {
+		var names []string
+		for _, required := range requires[name] {
+			if !seen[required] {
+				seen[required] = true
+				names = append(append(names, required), before(required, seen)...)
+			}
+		}
+		return names
+	}
+
+	parameters := gitlabParameters(doc)
+	circleJobs := make(map[string]interface{})
+	var invocations []interface{}
+	for _, job := range jobs {
+		t := &gitlabTranslation{job: job.name, notes: &notes}
+		body := job.body
+		invocation := make(map[string]interface{})
+		if len(requires[job.name]) > 0 {
+			invocation["requires"] = requires[job.name]
+		}
+		filters := t.filters(body["only"], body["except"])
+		if filters != nil {
+			invocation["filters"] = filters
+		}
+
+		switch job.when {
+		case "manual":
+			approval := "approve-" + job.name
+			hold := map[string]interface{}{"type": "approval"}
+			if requires := invocation["requires"]; requires != nil {
+				hold["requires"] = requires
+			}
+			if filters != nil {
+				hold["filters"] = filters
+			}
+			invocations = append(invocations, map[string]interface{}{approval: hold})
+			invocation["requires"] = []string{approval}
+		case "on_failure":
+			t.note("the job runs only when a job of an earlier stage fails; the task runs in the workflow's order")
+		case "always":
+			t.note("the job runs even when earlier stages fail; the task runs in the workflow's order")
+		case "delayed":
+			t.note("the job starts %v after its stage begins; the task starts right away", body["start_in"])
+		}
+		if body["rules"] != nil {
+			t.note("the job runs only per its `rules:`; the task runs unconditionally")
+		}
+		if failure, ok := body["allow_failure"]; ok && failure != false && job.when != "manual" {
+			t.note("the job may fail without failing the pipeline; the task fails like any other")
+		}
+		for _, key := range sortedKeys(body) {
+			if message, ok := gitlabUnconverted[key]; ok {
+				value := body[key]
+				if environment, ok := value.(map[string]interface{}); ok {
+					value = environment["name"]
+				}
+				if strings.Contains(message, "%v") {
+					t.note(message, value)
+				} else {
+					t.note(message)
+				}
+			}
+		}
+
+		def := make(map[string]interface{})
+		if images := t.images(body); len(images) > 0 {
+			def["docker"] = images
+		} else {
+			for key, value := range githubExecutor(strings.Join(toStringList(body["tags"]), " ")) {
+				def[key] = value
+			}
+		}
+		variables := body["variables"].(map[string]interface{})
+		matrix := t.matrix(body["parallel"])
+		if matrix != nil {
+			jobParameters := make(map[string]interface{})
+			for key, values := range matrix["parameters"].(map[string]interface{}) {
+				jobParameters[key] = map[string]interface{}{"type": "string", "default": values.([]string)[0]}
+				variables[key] = "<< parameters." + key + " >>"
+			}
+			def["parameters"] = jobParameters
+			invocation["matrix"] = matrix
+		} else if count, ok := body["parallel"].(int); ok && count > 1 {
+			def["parallelism"] = count
+		}
+		env, exports := t.environment(variables, parameters)
+		if len(env) > 0 {
+			def["environment"] = env
+		}
+
+		attach := false
+		sources := toStringList(body["dependencies"])
+		if _, ok := body["dependencies"]; !ok {
+			sources = before(job.name, make(map[string]bool))
+		}
+		for _, source := range sources {
+			if other, ok := byName[source]; ok && len(gitlabArtifactPaths(other.body)) > 0 {
+				attach = true
+			}
+		}
+		def["steps"] = t.steps(body, exports, attach)
+		circleJobs[job.name] = def
+
+		if len(invocation) == 0 {
+			invocations = append(invocations, job.name)
+		} else {
+			invocations = append(invocations, map[string]interface{}{job.name: invocation})
+		}
+	}
+
+	circle := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      circleJobs,
+		"workflows": map[string]interface{}{name: map[string]interface{}{"jobs": invocations}},
+	}
+	if len(parameters) > 0 {
+		circle["parameters"] = parameters
+	}
+	translated, err := yaml.Marshal(circle)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	config, err := parseConfig(file, translated)
+	if err != nil {
+		return config, err
+	}
+	config.format = fromGitLab
+	config.original = &original
+	config.notes = notes
+	for path := range seen {
+		if path != abs {
+			config.includes = append(config.includes, path)
+		}
+	}
+	sort.Strings(config.includes)
+	config.source = nil
+	return config, nil
+}
+
+// gitlabDeleteKeys removes keys from a mapping node
+func gitlabDeleteKeys(node *yaml.Node, keys ...string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !containsString(keys, node.Content[i].Value) {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+}
+
+// writeGitLabCI writes the slim config: the input config with the script of each job
+// replaced by a call to the job's task (or script, for the script targets), passing
+// the matrix and pipeline variables it uses. The scripts of `default:` and of hidden
+// jobs go too, as the tasks run them.
+func writeGitLabCI(path string, config CircleCIConfig, target string) error {
+	root := config.original.Content[0]
+	gitlabDeleteKeys(root, "before_script", "after_script")
+	gitlabDeleteKeys(mappingValue(root, "default"), "before_script", "after_script")
+
+	present := make(map[string]bool)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, node := root.Content[i].Value, resolveAlias(root.Content[i+1])
+		present[name] = true
+		if strings.HasPrefix(name, ".") {
+			gitlabDeleteKeys(node, "before_script", "script", "after_script")
+		}
+	}
+
+	for _, name := range sortedJobNames(config.Jobs) {
+		job := config.Jobs[name]
+		call := "task " + name
+		if scriptTarget(target) {
+			call = "./" + scriptFile(name)
+		}
+		var args []string
+		for _, key := range sortedKeys(job.Parameters) {
+			args = append(args, fmt.Sprintf(`%s="$%s"`, taskVarName(key), key))
+		}
+		for _, ref := range pipelineRefs(job) {
+			if variable := strings.TrimPrefix(ref, "pipeline.parameters."); variable != ref {
+				args = append(args, fmt.Sprintf(`%s="$%s"`, taskVarName(variable), variable))
+			}
+		}
+		if len(args) > 0 {
+			call += " " + strings.Join(args, " ")
+		}
+
+		var script yaml.Node
+		if err := script.Encode([]string{call}); err != nil {
+			return err
+		}
+		if node := resolveAlias(mappingValue(root, name)); present[name] && node != nil {
+			gitlabDeleteKeys(node, "before_script", "after_script")
+			if existing := mappingValue(node, "script"); existing != nil {
+				*existing = script
+			} else {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "script"}, &script)
+			}
+			continue
+		}
+		// Jobs of included files are overridden here, without their scripts
+		override := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range []string{"before_script", "after_script"} {
+			override.Content = append(override.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle})
+		}
+		override.Content = append(override.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "script"}, &script)
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, override)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.original); err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
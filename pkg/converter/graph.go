@@ -0,0 +1,215 @@
+package converter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// graphViewer is the page `graph serve` renders the model with
+//
+//go:embed graph.html
+var graphViewer []byte
+
+// GraphModel is the data behind `graph dot` and `graph serve`
+type GraphModel struct {
+	Input     string               `json:"input"`
+	Workflows []GraphWorkflow      `json:"workflows"`
+	Tasks     map[string]GraphTask `json:"tasks"`
+}
+
+// GraphWorkflow is the job DAG of one workflow; Error is set when it has none
+type GraphWorkflow struct {
+	Name  string      `json:"name"`
+	Nodes []GraphNode `json:"nodes"`
+	Error string      `json:"error,omitempty"`
+}
+
+// GraphNode is one workflow job; Layer is its depth in the DAG (0 = no requirements)
+type GraphNode struct {
+	RunNode
+	Layer int `json:"layer"`
+}
+
+// GraphTask holds what a node's detail view shows: the source steps, the converted
+// commands and the conversion report entries
+type GraphTask struct {
+	Desc     string   `json:"desc"`
+	Source   string   `json:"source,omitempty"`
+	Cmds     []string `json:"cmds"`
+	Deps     []string `json:"deps,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// buildGraphModel converts a config and collects its workflow DAGs and tasks
+func buildGraphModel(inputFile string, opts ConvertOptions) (GraphModel, error) {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return GraphModel{}, fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseConfig(inputFile, data)
+	if err != nil {
+		return GraphModel{}, err
+	}
+
+	report := &ConversionReport{}
+	_, taskfile := convertConfig(config, opts, report)
+
+	model := GraphModel{Input: inputFile, Tasks: make(map[string]GraphTask)}
+
+	var workflowNames []string
+	for name, workflow := range config.Workflows {
+		if workflow.IsWorkflow() {
+			workflowNames = append(workflowNames, name)
+		}
+	}
+	sort.Strings(workflowNames)
+	for _, name := range workflowNames {
+		workflow := GraphWorkflow{Name: name}
+		nodes, err := buildWorkflowGraph(config, name)
+		if err != nil {
+			workflow.Error = err.Error()
+		}
+		layers := make(map[string]int)
+		for _, node := range nodes {
+			layer := 0
+			for _, req := range node.Requires {
+				if layers[req]+1 > layer {
+					layer = layers[req] + 1
+				}
+			}
+			layers[node.Name] = layer
+			workflow.Nodes = append(workflow.Nodes, GraphNode{RunNode: node, Layer: layer})
+		}
+		model.Workflows = append(model.Workflows, workflow)
+	}
+
+	warnings := make(map[string][]string)
+	for _, entry := range report.Entries {
+		if entry.Job != "" {
+			warnings[entry.Job] = append(warnings[entry.Job], fmt.Sprintf("%s: %s", entry.Category, entry.Message))
+		}
+	}
+
+	for name, task := range taskfile.Tasks {
+		graphTask := GraphTask{Desc: task.Desc, Cmds: task.Cmds.lines(), Deps: task.Deps, Warnings: warnings[name]}
+		var source interface{}
+		if job, ok := config.Jobs[name]; ok {
+			source = map[string]interface{}{"jobs": map[string]Job{name: job}}
+		} else if command, ok := config.Commands[name]; ok {
+			source = map[string]interface{}{"commands": map[string]Command{name: command}}
+		}
+		if source != nil {
+			if out, err := yaml.Marshal(source); err == nil {
+				graphTask.Source = string(out)
+			}
+		}
+		model.Tasks[name] = graphTask
+	}
+
+	return model, nil
+}
+
+// writeGraphDOT writes the workflow DAGs in Graphviz DOT, one cluster per workflow
+func writeGraphDOT(model GraphModel, workflowName string) string {
+	quote := func(s string) string {
+		return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph workflows {\n  rankdir=LR;\n  node [shape=box, style=rounded, fontname=Helvetica];\n")
+	for _, workflow := range model.Workflows {
+		if workflowName != "" && workflow.Name != workflowName {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  subgraph %s {\n    label=%s;\n", quote("cluster_"+workflow.Name), quote(workflow.Name)))
+		for _, node := range workflow.Nodes {
+			id := quote(workflow.Name + "/" + node.Name)
+			label := node.Name
+			if node.Task != node.Name {
+				label += "\\n(task " + node.Task + ")"
+			}
+			attrs := "label=" + quote(label)
+			if node.Approval {
+				attrs += ", shape=diamond"
+			}
+			b.WriteString(fmt.Sprintf("    %s [%s];\n", id, attrs))
+			for _, req := range node.Requires {
+				b.WriteString(fmt.Sprintf("    %s -> %s;\n", quote(workflow.Name+"/"+req), id))
+			}
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// runGraphCommand implements the `graph` subcommand: `graph dot` prints the workflow
+// DAGs as Graphviz DOT and `graph serve` explores them in the browser
+func runGraphCommand(args []string) error {
+	usage := "usage: circle-to-task graph dot|serve -input <config.yml>"
+	if len(args) == 0 || (args[0] != "dot" && args[0] != "serve") {
+		return fmt.Errorf("%s", usage)
+	}
+
+	fs := flag.NewFlagSet("graph "+args[0], flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input CircleCI config file (required)")
+	workflowName := fs.String("workflow", "", "Only this workflow (dot)")
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to serve on (serve)")
+	orbsDir := fs.String("orbs-dir", "orbs", "Directory of vendored orb sources (see 'orbs vendor')")
+	offline := fs.Bool("offline", false, "Forbid network access; resolve orbs only from the vendor directory")
+	fs.Parse(args[1:])
+
+	if *inputFile == "" {
+		fs.Usage()
+		return fmt.Errorf("-input is required")
+	}
+	opts := ConvertOptions{Orbs: NewOrbResolver(*orbsDir, *offline, "", "")}
+
+	if args[0] == "dot" {
+		model, err := buildGraphModel(*inputFile, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(writeGraphDOT(model, *workflowName))
+		return nil
+	}
+
+	// The model is rebuilt on every request, so reloading the page picks up config edits
+	if _, err := buildGraphModel(*inputFile, opts); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(graphViewer)
+	})
+	mux.HandleFunc("/graph.json", func(w http.ResponseWriter, r *http.Request) {
+		model, err := buildGraphModel(*inputFile, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(model)
+	})
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", *addr, err)
+	}
+	fmt.Printf("🔎 Serving the workflow graph of %s at http://%s (Ctrl-C to stop)\n", *inputFile, listener.Addr())
+	return http.Serve(listener, mux)
+}
@@ -0,0 +1,259 @@
+package converter
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hookMarker identifies hooks written by circle-to-task so they can be safely replaced
+const hookMarker = "# Generated by circle-to-task"
+
+// PathMapping maps a regex over changed file paths to a task that should run
+type PathMapping struct {
+	Pattern string
+	Task    string
+	Source  string
+}
+
+var cdPrefixRegex = regexp.MustCompile(`^\s*cd\s+([^\s;&|]+)`)
+
+// buildPathMappings derives which paths affect which job tasks, from path-filtering
+// orb mappings, job working directories and leading `cd <dir>` commands
+func buildPathMappings(config CircleCIConfig) ([]PathMapping, []string) {
+	var mappings []PathMapping
+	mapped := make(map[string]bool)
+
+	add := func(pattern, task, source string) {
+		for _, existing := range mappings {
+			if existing.Pattern == pattern && existing.Task == task {
+				return
+			}
+		}
+		mappings = append(mappings, PathMapping{Pattern: pattern, Task: task, Source: source})
+		mapped[task] = true
+	}
+
+	// path-filtering orb: "<regex> <pipeline-parameter> <value>" lines gate workflows
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		for _, step := range config.Jobs[jobName].Steps {
+			for _, line := range pathFilteringMapping(step) {
+				fields := strings.Fields(line)
+				if len(fields) < 2 {
+					continue
+				}
+				for _, job := range jobsGatedByParameter(config.Workflows, fields[1]) {
+					add("^("+fields[0]+")$", job, "path-filtering "+fields[1])
+				}
+			}
+		}
+	}
+
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		if mapped[jobName] {
+			continue
+		}
+		job := config.Jobs[jobName]
+
+		if dir := monorepoDir(job.WorkingDirectory); dir != "" {
+			add("^"+regexp.QuoteMeta(dir)+"/", jobName, "working_directory")
+			continue
+		}
+		for _, step := range job.Steps {
+			if match := cdPrefixRegex.FindStringSubmatch(extractCommand(step)); match != nil {
+				if dir := monorepoDir(match[1]); dir != "" {
+					add("^"+regexp.QuoteMeta(dir)+"/", jobName, "cd "+match[1])
+				}
+			}
+		}
+	}
+
+	var unmapped []string
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		if !mapped[jobName] {
+			unmapped = append(unmapped, jobName)
+		}
+	}
+
+	return mappings, unmapped
+}
+
+// pathFilteringMapping returns the mapping lines of a path-filtering/filter step
+func pathFilteringMapping(step Step) []string {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, value := range stepMap {
+		if !strings.HasSuffix(key, "/filter") {
+			continue
+		}
+		params, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if mapping, ok := params["mapping"].(string); ok {
+			var lines []string
+			for _, line := range strings.Split(mapping, "\n") {
+				if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+					lines = append(lines, line)
+				}
+			}
+			return lines
+		}
+	}
+	return nil
+}
+
+// jobsGatedByParameter lists jobs of workflows whose `when` references a pipeline parameter
+func jobsGatedByParameter(workflows map[string]Workflow, param string) []string {
+	var jobs []string
+	for _, invocation := range extractWorkflowJobs(workflows) {
+		if strings.Contains(fmt.Sprintf("%v", workflows[invocation.Workflow].When), "pipeline.parameters."+param) {
+			jobs = append(jobs, invocation.Job)
+		}
+	}
+	return jobs
+}
+
+// monorepoDir normalizes a working directory to a repo-relative subdirectory, if any
+func monorepoDir(dir string) string {
+	if rest, ok := projectSubdir(dir); ok {
+		dir = rest
+	} else if strings.HasPrefix(dir, "/") {
+		return ""
+	}
+	dir = strings.TrimPrefix(dir, "./")
+	dir = strings.Trim(dir, "/")
+	if dir == "" || dir == "." || strings.ContainsAny(dir, "$<{~") {
+		return ""
+	}
+	return dir
+}
+
+// generatePrePushHook renders a pre-push hook running tasks affected by the pushed commits
+func generatePrePushHook(mappings []PathMapping, unmapped []string, taskfileDir string) string {
+	var b strings.Builder
+
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(hookMarker + " - runs converted CI tasks affected by the pushed changes.\n")
+	b.WriteString("# Skip with: git push --no-verify\n\n")
+	b.WriteString(fmt.Sprintf("TASKFILE_DIR=%s\n", shellQuote(taskfileDir)))
+	b.WriteString(`zero=0000000000000000000000000000000000000000
+changed=""
+while read -r local_ref local_sha remote_ref remote_sha; do
+  # Branch deletions have nothing to check
+  [ "$local_sha" = "$zero" ] && continue
+  if [ "$remote_sha" = "$zero" ]; then
+    base=$(git merge-base "$local_sha" origin/HEAD 2>/dev/null || git rev-list --max-parents=0 "$local_sha" | tail -n 1)
+  else
+    base="$remote_sha"
+  fi
+  changed="$changed
+$(git diff --name-only "$base" "$local_sha")"
+done
+
+tasks=""
+# Each line is a task and its path regex, which gets the rest of the line
+while read -r task_name pattern; do
+  [ -z "$pattern" ] && continue
+  if printf '%s\n' "$changed" | grep -Eq "$pattern"; then
+    case " $tasks " in
+      *" $task_name "*) ;;
+      *) tasks="$tasks $task_name" ;;
+    esac
+  fi
+done <<'MAPPING'
+`)
+	for _, mapping := range mappings {
+		b.WriteString(fmt.Sprintf("%s %s\n", mapping.Task, mapping.Pattern))
+	}
+	b.WriteString("MAPPING\n\n")
+
+	if len(unmapped) > 0 {
+		b.WriteString(fmt.Sprintf("# Tasks without a path mapping always run: %s\n", strings.Join(unmapped, " ")))
+		b.WriteString(fmt.Sprintf("[ -n \"$changed\" ] && tasks=\"$tasks %s\"\n\n", strings.Join(unmapped, " ")))
+	}
+
+	b.WriteString(`if [ -z "$tasks" ]; then
+  echo "circle-to-task: no affected tasks"
+  exit 0
+fi
+
+echo "circle-to-task: running affected tasks:$tasks"
+exec task --dir "$TASKFILE_DIR" $tasks
+`)
+
+	return b.String()
+}
+
+// runHooksCommand implements the `hooks install` subcommand
+func runHooksCommand(args []string) error {
+	if len(args) == 0 || args[0] != "install" {
+		return fmt.Errorf("usage: circle-to-task hooks install -input <config.yml> [-taskfile-dir .] [-include-unmapped] [-force]")
+	}
+
+	fs := flag.NewFlagSet("hooks install", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input CircleCI config file (required)")
+	taskfileDir := fs.String("taskfile-dir", ".", "Directory containing the generated Taskfile.yml, relative to the repo root")
+	includeUnmapped := fs.Bool("include-unmapped", false, "Also run tasks that have no path mapping on every push")
+	force := fs.Bool("force", false, "Overwrite an existing pre-push hook not written by circle-to-task")
+	fs.Parse(args[1:])
+
+	if *inputFile == "" {
+		fs.Usage()
+		return fmt.Errorf("-input is required")
+	}
+
+	data, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseConfig(*inputFile, data)
+	if err != nil {
+		return err
+	}
+
+	mappings, unmapped := buildPathMappings(config)
+	sort.SliceStable(mappings, func(i, j int) bool { return mappings[i].Task < mappings[j].Task })
+
+	hookUnmapped := unmapped
+	if !*includeUnmapped {
+		hookUnmapped = nil
+	}
+	hook := generatePrePushHook(mappings, hookUnmapped, *taskfileDir)
+
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks/pre-push").Output()
+	if err != nil {
+		return fmt.Errorf("error locating git hooks directory (not a git repository?): %w", err)
+	}
+	hookPath := strings.TrimSpace(string(out))
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), hookMarker) && !*force {
+		return fmt.Errorf("%s already exists and was not written by circle-to-task (use -force to overwrite)", hookPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return fmt.Errorf("error creating hooks directory: %w", err)
+	}
+	if err := os.WriteFile(hookPath, []byte(hook), 0755); err != nil {
+		return fmt.Errorf("error writing hook: %w", err)
+	}
+
+	fmt.Printf("✅ Installed pre-push hook at %s\n", hookPath)
+	for _, mapping := range mappings {
+		fmt.Printf("   %s → task %s (%s)\n", mapping.Pattern, mapping.Task, mapping.Source)
+	}
+	if len(unmapped) > 0 {
+		if *includeUnmapped {
+			fmt.Printf("   always run (no path mapping): %s\n", strings.Join(unmapped, ", "))
+		} else {
+			fmt.Printf("   skipped (no path mapping, see -include-unmapped): %s\n", strings.Join(unmapped, ", "))
+		}
+	}
+	return nil
+}
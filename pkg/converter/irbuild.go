@@ -0,0 +1,210 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nichecode/circle-to-task/ir"
+)
+
+// buildPipeline describes a parsed config in the CI-agnostic model the emitters write
+// from. Inputs of every CI system are parsed to a CircleCI config first, so the model
+// is built from it.
+func buildPipeline(config CircleCIConfig) *ir.Pipeline {
+	pipeline := &ir.Pipeline{Source: inputFormatName(config)}
+
+	for _, name := range sortedKeys(config.Parameters) {
+		pipeline.Parameters = append(pipeline.Parameters, buildParam(name, config.Parameters[name]))
+	}
+
+	jobs := make(map[string]Job)
+	for name, job := range config.orbJobs {
+		jobs[name] = job
+	}
+	for name, job := range config.Jobs {
+		jobs[name] = job
+	}
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pipeline.Jobs = append(pipeline.Jobs, buildJob(name, jobs[name], config))
+	}
+
+	schedules := make(map[string][]string)
+	for _, schedule := range workflowSchedules(config) {
+		schedules[schedule.Workflow] = append(schedules[schedule.Workflow], schedule.Cron)
+	}
+	for _, name := range sortedWorkflowNames(config.Workflows) {
+		pipeline.Workflows = append(pipeline.Workflows, buildWorkflow(name, config, schedules[name]))
+	}
+	return pipeline
+}
+
+// buildParam describes a pipeline parameter
+func buildParam(name string, paramDef interface{}) ir.Param {
+	param := ir.Param{Name: name, Type: paramType(paramDef)}
+	def, _ := paramDef.(map[string]interface{})
+	if text, ok := def["description"].(string); ok {
+		param.Description = strings.TrimSpace(text)
+	}
+	if def["default"] != nil {
+		param.HasDefault = true
+		if param.Type == "enum" {
+			param.Default = fmt.Sprintf("%v", def["default"])
+		} else {
+			param.Default = formatParamValue(paramDef, def["default"])
+		}
+	}
+	if param.Type == "boolean" && param.HasDefault {
+		value, _ := yaml11Bool(def["default"])
+		param.Default = fmt.Sprintf("%t", value)
+	}
+	param.Enum = toStringList(def["enum"])
+	return param
+}
+
+// buildJob describes a job: its executor, the pipeline parameters it reads and its
+// steps, with the caches and artifacts they handle
+func buildJob(name string, job Job, config CircleCIConfig) ir.Job {
+	described := ir.Job{Name: name, Environment: make(map[string]string), Parallelism: job.Parallelism}
+	if resolved, err := resolveJobExecutor(job, config.Executors); err == nil {
+		described.Executor = ir.Executor{
+			Kind:             resolved.Kind,
+			Platform:         resolved.Platform,
+			ResourceClass:    resolved.ResourceClass,
+			WorkingDirectory: resolved.WorkingDirectory,
+			Shell:            resolved.Shell,
+		}
+		if len(resolved.Images) > 0 {
+			described.Executor.Image = resolved.Images[0]
+		}
+		for _, service := range resolved.Services {
+			described.Executor.Services = append(described.Executor.Services, service.Image)
+		}
+		for key, value := range resolved.Environment {
+			described.Environment[key] = value
+		}
+	}
+	mergeEnvironment(described.Environment, job.Environment)
+
+	for _, ref := range jobPipelineRefs(job, config.Commands) {
+		if param := strings.TrimPrefix(ref, "pipeline.parameters."); param != ref {
+			described.Parameters = append(described.Parameters, param)
+		}
+	}
+
+	for _, step := range job.Steps {
+		described.Steps = append(described.Steps, buildStep(step))
+		body, _ := step.(map[string]interface{})
+		switch kind := stepType(step); kind {
+		case "restore_cache", "save_cache":
+			def, _ := body[kind].(map[string]interface{})
+			keys := toStringList(def["keys"])
+			if key, ok := def["key"].(string); ok {
+				keys = append([]string{key}, keys...)
+			}
+			described.Caches = append(described.Caches, ir.Cache{Keys: keys, Paths: toStringList(def["paths"])})
+		case "store_artifacts", "store_test_results":
+			def, _ := body[kind].(map[string]interface{})
+			path, _ := def["path"].(string)
+			destination, _ := def["destination"].(string)
+			described.Artifacts = append(described.Artifacts, ir.Artifact{Path: path, Destination: destination, TestResults: kind == "store_test_results"})
+		}
+	}
+	return described
+}
+
+// buildStep describes a step of a job
+func buildStep(step Step) ir.Step {
+	kind := stepType(step)
+	body, _ := step.(map[string]interface{})
+	def, _ := body[kind].(map[string]interface{})
+	described := ir.Step{Name: kind, When: "on_success"}
+	switch kind {
+	case "run":
+		described.Kind = ir.StepRun
+		described.Command = extractCommand(step)
+		described.Shell = runStepShell(step)
+		described.Environment = runStepEnvironment(step)
+		described.When = runStepWhen(step)
+		described.Name, _ = def["name"].(string)
+		described.Dir, _ = def["working_directory"].(string)
+	case "checkout":
+		described.Kind = ir.StepCheckout
+	case "restore_cache":
+		described.Kind = ir.StepRestore
+	case "save_cache":
+		described.Kind = ir.StepSave
+		described.Paths = toStringList(def["paths"])
+	case "store_artifacts", "store_test_results":
+		described.Kind = ir.StepArtifact
+		if path, ok := def["path"].(string); ok {
+			described.Paths = []string{path}
+		}
+	case "persist_to_workspace":
+		described.Kind = ir.StepPersist
+		described.Paths = toStringList(def["paths"])
+	case "attach_workspace":
+		described.Kind = ir.StepAttach
+	default:
+		described.Kind = ir.StepOther
+		if _, ok := isCommandInvocation(step); ok {
+			described.Kind = ir.StepInvocation
+		}
+	}
+	if when, ok := def["when"].(string); ok && when != "" {
+		described.When = when
+	}
+	return described
+}
+
+// buildWorkflow describes a workflow: its schedules, its condition and its runs in
+// dependency order, with the filters of their invocation
+func buildWorkflow(name string, config CircleCIConfig, schedules []string) ir.Workflow {
+	workflow := config.Workflows[name]
+	described := ir.Workflow{Name: name, Schedules: schedules}
+	if workflow.When != nil || workflow.Unless != nil {
+		described.Condition = describeWorkflowCondition(workflow)
+	}
+	nodes, err := buildWorkflowGraph(config, name)
+	if err != nil {
+		described.Error = err.Error()
+		return described
+	}
+
+	// The workflow invocation of each run, matrix variants included
+	invocations := make(map[string]WorkflowJob)
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		if invocation.Workflow != name {
+			continue
+		}
+		invocations[invocation.DisplayName()] = invocation.WorkflowJob
+		if variants, _, ok := expandMatrix(invocation.WorkflowJob); ok {
+			for _, variant := range variants {
+				invocations[variant.Name] = invocation.WorkflowJob
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		invocation := invocations[node.Name]
+		run := ir.Run{
+			Name:     node.Name,
+			Task:     node.Task,
+			Args:     node.Args,
+			Requires: node.Requires,
+			Approval: node.Approval,
+			Branches: ir.Filter{Only: invocation.Filters.Branches.Only, Ignore: invocation.Filters.Branches.Ignore},
+			Tags:     ir.Filter{Only: invocation.Filters.Tags.Only, Ignore: invocation.Filters.Tags.Ignore},
+		}
+		if !node.Approval {
+			run.Job = invocation.Job
+		}
+		described.Runs = append(described.Runs, run)
+	}
+	return described
+}
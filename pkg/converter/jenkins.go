@@ -0,0 +1,309 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nichecode/circle-to-task/ir"
+)
+
+// targetJenkinsfile writes a Jenkinsfile running the Taskfile's tasks
+const targetJenkinsfile = "jenkinsfile"
+
+// jenkinsfileName is the declarative pipeline written with -target jenkinsfile
+const jenkinsfileName = "Jenkinsfile"
+
+// jenkinsPlatformLabels are the agent labels of jobs on macOS and Windows executors
+var jenkinsPlatformLabels = map[string]string{"darwin": "macos", "windows": "windows"}
+
+// groovyString returns s as a single-quoted Groovy string, which does not interpolate
+func groovyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`).Replace(s) + "'"
+}
+
+// jenkinsWriter writes the lines of a Jenkinsfile, indented by nesting
+type jenkinsWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+// line writes a line at the current depth
+func (w *jenkinsWriter) line(format string, args ...interface{}) {
+	w.b.WriteString(strings.Repeat("    ", w.depth) + fmt.Sprintf(format, args...) + "\n")
+}
+
+// open writes a line opening a block, and nests the lines after it
+func (w *jenkinsWriter) open(format string, args ...interface{}) {
+	w.line(format+" {", args...)
+	w.depth++
+}
+
+// close ends the innermost block
+func (w *jenkinsWriter) close() {
+	w.depth--
+	w.line("}")
+}
+
+// jenkinsBranchPattern returns the regular expression matching CircleCI branch
+// patterns: names, or /regexes/
+func jenkinsBranchPattern(patterns []string) string {
+	var alternatives []string
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			alternatives = append(alternatives, pattern[1:len(pattern)-1])
+		} else {
+			alternatives = append(alternatives, regexp.QuoteMeta(pattern))
+		}
+	}
+	return "^(" + strings.Join(alternatives, "|") + ")$"
+}
+
+// jenkinsBranchConditions returns the `when` conditions of a job's branch filters
+func jenkinsBranchConditions(filter ir.Filter) []string {
+	var conditions []string
+	if len(filter.Only) > 0 {
+		conditions = append(conditions, fmt.Sprintf("branch pattern: %s, comparator: 'REGEXP'", groovyString(jenkinsBranchPattern(filter.Only))))
+	}
+	if len(filter.Ignore) > 0 {
+		conditions = append(conditions, fmt.Sprintf("not { branch pattern: %s, comparator: 'REGEXP' }", groovyString(jenkinsBranchPattern(filter.Ignore))))
+	}
+	return conditions
+}
+
+// runTaskCommand is the shell command running a workflow run's task, with the run's
+// arguments and the pipeline parameters the job uses, which the CI system running it
+// sets as environment variables
+func runTaskCommand(pipeline *ir.Pipeline, run ir.Run) string {
+	args := append([]string(nil), run.Args...)
+	if job, ok := pipeline.Job(run.Job); ok {
+		for _, name := range job.Parameters {
+			args = append(args, fmt.Sprintf("%s=${%s}", taskVarName(name), taskVarName(name)))
+		}
+	}
+	for i, arg := range args {
+		arg = pipelineRefRegex.ReplaceAllStringFunc(arg, func(match string) string {
+			ref := pipelineRefRegex.FindStringSubmatch(match)[1]
+			if name := strings.TrimPrefix(ref, "pipeline.parameters."); name != ref {
+				return "${" + taskVarName(name) + "}"
+			}
+			return match
+		})
+		if strings.ContainsAny(arg, " \t\n'\"$;&|<>()") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(arg) + `"`
+		}
+		args[i] = arg
+	}
+	return strings.TrimSpace("task " + run.Task + " " + strings.Join(args, " "))
+}
+
+// jenkinsParameters writes the pipeline parameters as Jenkins build parameters
+func jenkinsParameters(w *jenkinsWriter, pipeline *ir.Pipeline) {
+	if len(pipeline.Parameters) == 0 {
+		return
+	}
+	w.open("parameters")
+	for _, param := range pipeline.Parameters {
+		description := groovyString(fmt.Sprintf("CircleCI pipeline parameter %s", param.Name))
+		if param.Description != "" {
+			description = groovyString(param.Description)
+		}
+		switch param.Type {
+		case "boolean":
+			w.line("booleanParam(name: %s, defaultValue: %t, description: %s)", groovyString(taskVarName(param.Name)), param.Default == "true", description)
+		case "enum":
+			// The first choice is the default
+			choices := []string{}
+			if param.HasDefault {
+				choices = append(choices, groovyString(param.Default))
+			}
+			for _, choice := range param.Enum {
+				if !param.HasDefault || choice != param.Default {
+					choices = append(choices, groovyString(choice))
+				}
+			}
+			w.line("choice(name: %s, choices: [%s], description: %s)", groovyString(taskVarName(param.Name)), strings.Join(choices, ", "), description)
+		default:
+			w.line("string(name: %s, defaultValue: %s, description: %s)", groovyString(taskVarName(param.Name)), groovyString(param.Default), description)
+		}
+	}
+	w.close()
+}
+
+// jenkinsAgent writes the agent of a job's stage: its docker image, a label for macOS
+// and Windows executors, else any agent, which is reported
+func jenkinsAgent(w *jenkinsWriter, pipeline *ir.Pipeline, run ir.Run, report *ConversionReport) {
+	job, _ := pipeline.Job(run.Job)
+	executor := job.Executor
+	switch {
+	case executor.Kind == "":
+		w.line("agent any")
+	case executor.Kind == "docker" && executor.Image != "" && !strings.Contains(executor.Image, "<<"):
+		w.open("agent")
+		w.line("docker { image %s }", groovyString(executor.Image))
+		w.close()
+		if len(executor.Services) > 0 {
+			report.Add("Jenkinsfile", run.Name, "the stage runs in %s without the job's %d service containers", executor.Image, len(executor.Services))
+		}
+	case executor.Kind == "docker" && executor.Image != "":
+		w.line("agent any")
+		report.Add("Jenkinsfile", run.Name, "image %s depends on parameters; its stage runs on any agent", executor.Image)
+	case jenkinsPlatformLabels[executor.Platform] != "":
+		w.line("agent { label %s }", groovyString(jenkinsPlatformLabels[executor.Platform]))
+		report.Add("Jenkinsfile", run.Name, "runs on a %s executor; its stage runs on agents labelled %s", executor.Platform, jenkinsPlatformLabels[executor.Platform])
+	default:
+		w.line("agent any")
+		report.Add("Jenkinsfile", run.Name, "runs on a %s executor; its stage runs on any agent", executor.Kind)
+	}
+}
+
+// jenkinsJobStage writes the stage of a workflow job: an input step for approval jobs,
+// else the job's task on an agent running its executor's image
+func jenkinsJobStage(w *jenkinsWriter, pipeline *ir.Pipeline, run ir.Run, stageName string, report *ConversionReport) {
+	w.open("stage(%s)", groovyString(stageName))
+	if run.Approval {
+		w.open("steps")
+		w.line("input message: %s", groovyString(fmt.Sprintf("Approve %s?", run.Name)))
+		w.close()
+		w.close()
+		return
+	}
+
+	jenkinsAgent(w, pipeline, run, report)
+	conditions := jenkinsBranchConditions(run.Branches)
+	if len(run.Tags.Only) > 0 || len(run.Tags.Ignore) > 0 {
+		report.Add("Jenkinsfile", run.Name, "tag filters have no Jenkinsfile equivalent; the stage runs on branch builds only")
+	}
+	if len(conditions) == 1 {
+		w.line("when { %s }", conditions[0])
+	} else if len(conditions) > 1 {
+		w.line("when { allOf { %s } }", strings.Join(conditions, "; "))
+	}
+
+	w.open("steps")
+	w.line("sh %s", groovyString(runTaskCommand(pipeline, run)))
+	w.close()
+	w.close()
+}
+
+// jenkinsfileEmitter writes a Jenkinsfile running the tasks
+type jenkinsfileEmitter struct{}
+
+func (jenkinsfileEmitter) Name() string { return targetJenkinsfile }
+
+func (jenkinsfileEmitter) Emit(out emission) ([]string, error) {
+	written, err := generateJenkinsfile(out.Pipeline, out.OutputDir, out.Report)
+	if err != nil {
+		return nil, fmt.Errorf("error writing %s: %w", jenkinsfileName, err)
+	}
+	if !written {
+		return nil, nil
+	}
+	return []string{jenkinsfileName + " (declarative pipeline running the tasks)"}, nil
+}
+
+// generateJenkinsfile writes a declarative Jenkinsfile with a stage per workflow, in
+// which the workflow's jobs run in dependency order, jobs that can run together
+// in parallel. Scheduled workflows run on the cron triggers of the pipeline, the
+// others on every other build. It reports whether the file was written.
+func generateJenkinsfile(pipeline *ir.Pipeline, outputDir string, report *ConversionReport) (bool, error) {
+	if len(pipeline.Workflows) == 0 {
+		return false, nil
+	}
+
+	var crons []string
+	for _, workflow := range pipeline.Workflows {
+		for _, cron := range workflow.Schedules {
+			if !containsString(crons, cron) {
+				crons = append(crons, cron)
+			}
+		}
+	}
+	if len(crons) > 1 {
+		report.Add("Jenkinsfile", "", "the scheduled workflows have %d cron schedules; every scheduled workflow runs on each of them", len(crons))
+	}
+
+	w := &jenkinsWriter{}
+	w.line("// Generated by circle-to-task from the CircleCI workflows. The stages run the")
+	w.line("// Taskfile's tasks, so the agents and images need go-task (https://taskfile.dev).")
+	w.open("pipeline")
+	w.line("agent none")
+	jenkinsParameters(w, pipeline)
+	if len(crons) > 0 {
+		w.open("triggers")
+		for _, cron := range crons {
+			// CircleCI schedules are in UTC
+			w.line("cron(%s)", groovyString("TZ=UTC\n"+cron))
+		}
+		w.close()
+	}
+
+	// Jenkins needs stage names unique across the pipeline
+	used := make(map[string]bool)
+	unique := func(name string) string {
+		candidate := name
+		for i := 2; used[candidate]; i++ {
+			candidate = fmt.Sprintf("%s (%d)", name, i)
+		}
+		used[candidate] = true
+		return candidate
+	}
+
+	w.open("stages")
+	for _, workflow := range pipeline.Workflows {
+		if workflow.Error != "" {
+			report.Add("Jenkinsfile", workflow.Name, "workflow has no stage: %s", workflow.Error)
+			continue
+		}
+		if workflow.Condition != "" {
+			report.Add("Jenkinsfile", workflow.Name, "runs only when %s; its stage runs unconditionally", workflow.Condition)
+		}
+
+		// Jobs run after the jobs they require: a level holds the jobs whose
+		// requirements all ran in the levels before it
+		level := make(map[string]int)
+		var levels [][]ir.Run
+		for _, run := range workflow.Runs {
+			for _, req := range run.Requires {
+				if level[req]+1 > level[run.Name] {
+					level[run.Name] = level[req] + 1
+				}
+			}
+			if level[run.Name] == len(levels) {
+				levels = append(levels, nil)
+			}
+			levels[level[run.Name]] = append(levels[level[run.Name]], run)
+		}
+
+		w.open("stage(%s)", groovyString(unique(workflow.Name)))
+		if len(crons) > 0 {
+			if len(workflow.Schedules) > 0 {
+				w.line("when { triggeredBy 'TimerTrigger' }")
+			} else {
+				w.line("when { not { triggeredBy 'TimerTrigger' } }")
+			}
+		}
+		w.open("stages")
+		for i, runs := range levels {
+			if len(runs) == 1 {
+				jenkinsJobStage(w, pipeline, runs[0], unique(runs[0].Name), report)
+				continue
+			}
+			w.open("stage(%s)", groovyString(unique(fmt.Sprintf("%s %d", workflow.Name, i+1))))
+			w.open("parallel")
+			for _, run := range runs {
+				jenkinsJobStage(w, pipeline, run, unique(run.Name), report)
+			}
+			w.close()
+			w.close()
+		}
+		w.close()
+		w.close()
+	}
+	w.close()
+	w.close()
+
+	return true, os.WriteFile(filepath.Join(outputDir, jenkinsfileName), []byte(w.b.String()), 0644)
+}
@@ -0,0 +1,1386 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromJenkins reads declarative Jenkinsfiles
+const fromJenkins = "jenkins"
+
+// jenkinsfilePipelineRegex matches the `pipeline {` block opening a declarative
+// Jenkinsfile
+var jenkinsfilePipelineRegex = regexp.MustCompile(`(?m)^\s*pipeline\s*\{`)
+
+// jenkinsfileVarRegex matches references to shell variables
+var jenkinsfileVarRegex = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// jenkinsfilePipelineValues are the Jenkins variables with a pipeline value equivalent,
+// which the Taskfile computes from git
+var jenkinsfilePipelineValues = map[string]string{
+	"BRANCH_NAME":  "pipeline.git.branch",
+	"GIT_BRANCH":   "pipeline.git.branch",
+	"GIT_COMMIT":   "pipeline.git.revision",
+	"TAG_NAME":     "pipeline.git.tag",
+	"BUILD_NUMBER": "pipeline.number",
+	"BUILD_ID":     "pipeline.number",
+}
+
+// jenkinsfileVariables are the other variables Jenkins sets for builds
+var jenkinsfileVariables = map[string]bool{
+	"BUILD_TAG": true, "BUILD_URL": true, "BUILD_DISPLAY_NAME": true, "JOB_NAME": true,
+	"JOB_BASE_NAME": true, "JOB_URL": true, "JENKINS_URL": true, "JENKINS_HOME": true,
+	"NODE_NAME": true, "NODE_LABELS": true, "EXECUTOR_NUMBER": true, "GIT_URL": true,
+	"GIT_PREVIOUS_COMMIT": true, "GIT_LOCAL_BRANCH": true, "CHANGE_ID": true, "CHANGE_URL": true,
+	"CHANGE_TARGET": true, "CHANGE_BRANCH": true, "CHANGE_AUTHOR": true, "CHANGE_TITLE": true,
+}
+
+// jenkinsfileScriptSteps are the steps converted into commands of the task
+var jenkinsfileScriptSteps = map[string]bool{"sh": true, "powershell": true, "pwsh": true, "echo": true, "error": true, "sleep": true}
+
+// jenkinsfileWrappers are the steps whose closure holds steps, converted as the steps
+// they wrap; the quiet ones change nothing the task needs to know
+var jenkinsfileWrappers = map[string]bool{
+	"dir": true, "withEnv": true, "withCredentials": true, "timeout": true, "retry": true,
+	"catchError": true, "warnError": true, "lock": true, "container": true, "sshagent": true,
+	"timestamps": true, "ansiColor": true,
+}
+
+// jenkinsfileQuietWrappers are the wrappers converted without a note
+var jenkinsfileQuietWrappers = map[string]bool{"dir": true, "withEnv": true, "withCredentials": true, "timestamps": true, "ansiColor": true}
+
+// jenkinsfilePostWhen are the `post` conditions converted, and the `when:` of their
+// steps
+var jenkinsfilePostWhen = map[string]string{"always": "always", "cleanup": "always", "failure": "on_fail", "unsuccessful": "on_fail", "success": ""}
+
+// jenkinsfileCredentialRegex matches the variables of withCredentials bindings
+var jenkinsfileCredentialRegex = regexp.MustCompile(`(?:[a-zA-Z]+Variable|variable)\s*:\s*['"]([A-Za-z_][A-Za-z0-9_]*)['"]`)
+
+// groovyErrorRegex matches the line number of Jenkinsfile syntax errors
+var groovyErrorRegex = regexp.MustCompile(`^line (\d+): `)
+
+// groovyNameRegex matches the names Groovy interpolates as variables
+var groovyNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// groovyToken is a token of a Jenkinsfile
+type groovyToken struct {
+	kind   byte   // 'w' for words (names and numbers), 's' for strings, else the character
+	text   string // a word, or the source of a string between its quotes
+	double bool   // a double-quoted string, which interpolates
+	start  int    // offsets of the token in the source
+	end    int
+	line   int
+}
+
+// groovyTokens splits the source of a Jenkinsfile into tokens, without comments;
+// statements end at the '\n' tokens
+func groovyTokens(src string) ([]groovyToken, error) {
+	var tokens []groovyToken
+	line := 1
+	word := func(c byte) bool {
+		return c == '_' || c == '$' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			tokens = append(tokens, groovyToken{kind: '\n', start: i, end: i + 1, line: line})
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			line++
+			i += 2
+		case strings.HasPrefix(src[i:], "//") || (i == 0 && strings.HasPrefix(src, "#!")):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case c == '\'' || c == '"':
+			quote := src[i : i+1]
+			if strings.HasPrefix(src[i:], quote+quote+quote) {
+				quote += quote + quote
+			}
+			start, j, depth := i, i+len(quote), 0
+			for ; j < len(src); j++ {
+				if src[j] == '\\' {
+					j++
+				} else if c == '"' && strings.HasPrefix(src[j:], "${") {
+					depth++
+					j++
+				} else if depth > 0 && src[j] == '{' {
+					depth++
+				} else if depth > 0 && src[j] == '}' {
+					depth--
+				} else if depth == 0 && strings.HasPrefix(src[j:], quote) {
+					break
+				} else if len(quote) == 1 && src[j] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			tokens = append(tokens, groovyToken{kind: 's', text: src[start+len(quote) : j], double: c == '"', start: start, end: j + len(quote), line: line})
+			line += strings.Count(src[start:j], "\n")
+			i = j + len(quote)
+		case word(c):
+			j := i
+			for j < len(src) && word(src[j]) {
+				j++
+			}
+			tokens = append(tokens, groovyToken{kind: 'w', text: src[i:j], start: i, end: j, line: line})
+			i = j
+		default:
+			tokens = append(tokens, groovyToken{kind: c, text: string(c), start: i, end: i + 1, line: line})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+// groovyValue is an argument or assigned value: a string, a list, or the source of
+// another expression
+type groovyValue struct {
+	kind  byte // 's' for strings, '[' for lists, else 'x'
+	token groovyToken
+	items []groovyValue
+	text  string
+}
+
+// groovyArg is an argument of a statement, named by key or positional
+type groovyArg struct {
+	key   string
+	value groovyValue
+}
+
+// groovyStatement is a statement of a Jenkinsfile: a step or directive called with
+// arguments and a closure, or an assignment
+type groovyStatement struct {
+	name   string
+	assign bool // `name = value`, the value its only argument
+	args   []groovyArg
+	body   []*groovyStatement
+	block  bool // whether it has a closure
+	close  int  // offset of the closure's closing brace
+	start  int  // offsets of the statement
+	end    int
+	after  int // offset of the end of the token before the statement
+	line   int
+}
+
+// first returns the first positional argument of a statement
+func (s *groovyStatement) first() *groovyValue {
+	for i := range s.args {
+		if s.args[i].key == "" {
+			return &s.args[i].value
+		}
+	}
+	return nil
+}
+
+// arg returns the argument named key of a statement
+func (s *groovyStatement) arg(key string) *groovyValue {
+	for i := range s.args {
+		if s.args[i].key == key {
+			return &s.args[i].value
+		}
+	}
+	return nil
+}
+
+// child returns the first statement named name in the closure of a statement
+func (s *groovyStatement) child(name string) *groovyStatement {
+	if s == nil {
+		return nil
+	}
+	for _, child := range s.body {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// groovyParser parses the statements of a Jenkinsfile's tokens
+type groovyParser struct {
+	src    string
+	tokens []groovyToken
+	pos    int
+}
+
+// peek returns the kind of the token at the offset from the current one, 0 at the end
+func (p *groovyParser) peek(offset int) byte {
+	if p.pos+offset >= len(p.tokens) {
+		return 0
+	}
+	return p.tokens[p.pos+offset].kind
+}
+
+// block parses statements up to the end of the source, or up to and including the
+// closing brace of a closure
+func (p *groovyParser) block(closing bool) ([]*groovyStatement, error) {
+	var statements []*groovyStatement
+	for {
+		switch p.peek(0) {
+		case '\n', ';':
+			p.pos++
+			continue
+		case 0:
+			if closing {
+				return nil, fmt.Errorf("line %d: unclosed {", p.tokens[len(p.tokens)-1].line)
+			}
+			return statements, nil
+		case '}':
+			if !closing {
+				return nil, fmt.Errorf("line %d: unexpected }", p.tokens[p.pos].line)
+			}
+			p.pos++
+			return statements, nil
+		}
+		statement, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+}
+
+// statement parses a statement: a name with arguments, in parentheses or bare, and
+// a closure, or an assignment; statements starting otherwise are kept as source
+func (p *groovyParser) statement() (*groovyStatement, error) {
+	first := p.tokens[p.pos]
+	s := &groovyStatement{start: first.start, line: first.line}
+	for i := p.pos - 1; i >= 0; i-- {
+		if p.tokens[i].kind != '\n' {
+			s.after = p.tokens[i].end
+			break
+		}
+	}
+	if first.kind == 'w' {
+		s.name = first.text
+		p.pos++
+		switch {
+		case p.peek(0) == '=' && p.peek(1) != '=':
+			p.pos++
+			s.assign = true
+			s.args = []groovyArg{{value: p.value('=')}}
+		case p.peek(0) == '(':
+			p.pos++
+			s.args = p.args(')')
+		case p.peek(0) != '{':
+			s.args = p.args(0)
+		}
+		if p.peek(0) == '{' {
+			p.pos++
+			body, err := p.block(true)
+			if err != nil {
+				return nil, err
+			}
+			s.body, s.block, s.close = body, true, p.tokens[p.pos-1].start
+		}
+	}
+	// The rest of the statement, if any (an `else { }`, or an expression), is not
+	// needed to read the pipeline
+	if first.kind != 'w' {
+		p.pos++
+	}
+	p.skip('=')
+	s.end = p.tokens[p.pos-1].end
+	return s, nil
+}
+
+// skip moves past the tokens of an expression, balancing brackets, up to a comma or a
+// closing bracket. The closer is ')' or ']' for arguments and list items, which span
+// lines; 0 for bare arguments, which end with the line or at a closure; '=' for
+// assigned values and the rest of statements, which end with the line.
+func (p *groovyParser) skip(closer byte) {
+	depth := 0
+	for p.pos < len(p.tokens) {
+		kind := p.peek(0)
+		bare := closer == 0 || closer == '='
+		if depth == 0 && (kind == ',' || kind == ')' || kind == ']' || kind == '}' || (bare && (kind == '\n' || kind == ';')) || (closer == 0 && kind == '{')) {
+			break
+		}
+		switch kind {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+		p.pos++
+	}
+}
+
+// args parses arguments up to the closer, or to the end of the statement (or an
+// opening brace) for bare arguments
+func (p *groovyParser) args(closer byte) []groovyArg {
+	var args []groovyArg
+	for p.pos < len(p.tokens) {
+		kind := p.peek(0)
+		if closer != 0 && kind == '\n' {
+			p.pos++
+			continue
+		}
+		if closer != 0 && kind == closer {
+			p.pos++
+			break
+		}
+		if closer == 0 && (kind == '\n' || kind == ';' || kind == '}' || kind == '{') {
+			break
+		}
+		var arg groovyArg
+		if kind == 'w' && p.peek(1) == ':' {
+			arg.key = p.tokens[p.pos].text
+			p.pos += 2
+		}
+		start := p.pos
+		arg.value = p.value(closer)
+		args = append(args, arg)
+		if p.peek(0) == ',' {
+			p.pos++
+		} else if p.pos == start {
+			p.pos++
+		}
+	}
+	return args
+}
+
+// value parses a value up to a comma, the closer or the end of the statement
+func (p *groovyParser) value(closer byte) groovyValue {
+	start := p.pos
+	ends := func(kind byte) bool {
+		bare := closer == 0 || closer == '='
+		return kind == ',' || kind == 0 || kind == ')' || kind == ']' || kind == '}' || (bare && (kind == '\n' || kind == ';')) || (closer == 0 && kind == '{')
+	}
+	if p.peek(0) == 's' && ends(p.peek(1)) {
+		p.pos++
+		return groovyValue{kind: 's', token: p.tokens[start]}
+	}
+	if p.peek(0) == '[' {
+		p.pos++
+		value := groovyValue{kind: '['}
+		for p.pos < len(p.tokens) && p.peek(0) != ']' {
+			if p.peek(0) == '\n' || p.peek(0) == ',' {
+				p.pos++
+				continue
+			}
+			if p.peek(0) == 'w' && p.peek(1) == ':' {
+				p.pos += 2
+			}
+			at := p.pos
+			value.items = append(value.items, p.value(']'))
+			if p.pos == at {
+				p.pos++
+			}
+		}
+		if p.pos < len(p.tokens) {
+			p.pos++
+		}
+		if ends(p.peek(0)) {
+			return value
+		}
+	}
+	p.pos = start
+	p.skip(closer)
+	if p.pos == start {
+		return groovyValue{kind: 'x'}
+	}
+	return groovyValue{kind: 'x', text: p.src[p.tokens[start].start:p.tokens[p.pos-1].end]}
+}
+
+// parseGroovy parses the statements of a Jenkinsfile
+func parseGroovy(src string) ([]*groovyStatement, error) {
+	tokens, err := groovyTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &groovyParser{src: src, tokens: tokens}
+	return p.block(false)
+}
+
+// groovyUnescape returns the contents of a single-quoted string
+func groovyUnescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			switch text[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '\'', '"', '$':
+				b.WriteByte(text[i])
+			case '\n':
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(text[i])
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// groovyDedent removes the leading line break and the indentation the lines of a
+// triple-quoted string share, as scripts are indented with the Jenkinsfile
+func groovyDedent(text string) string {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+	lines := strings.Split(strings.TrimRight(text, " \t\r\n"), "\n")
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " \t")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		} else {
+			lines[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plain returns the text of a value: a string's contents with interpolations as
+// written, or the source of other values
+func (v *groovyValue) plain() string {
+	if v == nil {
+		return ""
+	}
+	switch v.kind {
+	case 's':
+		return groovyDedent(groovyUnescape(v.token.text))
+	case '[':
+		var items []string
+		for i := range v.items {
+			items = append(items, v.items[i].plain())
+		}
+		return strings.Join(items, ",")
+	}
+	return v.text
+}
+
+// jenkinsfileStage is a stage of a Jenkinsfile running steps, in the stages enclosing
+// it
+type jenkinsfileStage struct {
+	name    string // the job's name
+	stage   *groovyStatement
+	parents []*groovyStatement // enclosing stages, outermost first
+}
+
+// jenkinsfilePipeline parses a Jenkinsfile and returns its `pipeline { }` block, and
+// the statements outside it
+func jenkinsfilePipeline(file string, data []byte) (*groovyStatement, []*groovyStatement, error) {
+	statements, err := parseGroovy(string(data))
+	if err != nil {
+		line := 0
+		if match := groovyErrorRegex.FindStringSubmatch(err.Error()); match != nil {
+			line, _ = strconv.Atoi(match[1])
+		}
+		return nil, nil, &ParseError{File: file, Line: line, Message: groovyErrorRegex.ReplaceAllString(err.Error(), "")}
+	}
+	var pipeline *groovyStatement
+	var others []*groovyStatement
+	for _, statement := range statements {
+		if statement.name == "pipeline" && statement.block && pipeline == nil {
+			pipeline = statement
+		} else {
+			others = append(others, statement)
+		}
+	}
+	if pipeline == nil {
+		return nil, nil, &ParseError{File: file, Message: "the Jenkinsfile has no `pipeline { }` block", Hint: "only declarative pipelines are read; scripted pipelines (`node { }`) are not"}
+	}
+	return pipeline, others, nil
+}
+
+// jenkinsfileStages walks the stages of a pipeline in order and groups the stages
+// running steps as they run: one at a time, or side by side for `parallel` stages.
+// Stages are named after their names, in order; it also returns the stages it cannot
+// convert, with `matrix` or nothing to run.
+func jenkinsfileStages(pipeline *groovyStatement) ([][]jenkinsfileStage, []*groovyStatement) {
+	used := make(map[string]int)
+	name := func(stage *groovyStatement) string {
+		base := travisSlug(stage.first().plain())
+		if base == "" {
+			base = "stage"
+		}
+		used[base]++
+		if used[base] > 1 {
+			base = fmt.Sprintf("%s-%d", base, used[base])
+		}
+		return base
+	}
+
+	var groups [][]jenkinsfileStage
+	var skipped []*groovyStatement
+	var leaves func(stages *groovyStatement, parents []*groovyStatement) []jenkinsfileStage
+	leaves = func(stages *groovyStatement, parents []*groovyStatement) []jenkinsfileStage {
+		var found []jenkinsfileStage
+		for _, stage := range stages.body {
+			if stage.name != "stage" {
+				continue
+			}
+			chain := append(append([]*groovyStatement{}, parents...), stage)
+			switch {
+			case stage.child("steps") != nil:
+				found = append(found, jenkinsfileStage{name: name(stage), stage: stage, parents: parents})
+			case stage.child("parallel") != nil:
+				found = append(found, leaves(stage.child("parallel"), chain)...)
+			case stage.child("stages") != nil:
+				found = append(found, leaves(stage.child("stages"), chain)...)
+			default:
+				skipped = append(skipped, stage)
+			}
+		}
+		return found
+	}
+	var walk func(stages *groovyStatement, parents []*groovyStatement)
+	walk = func(stages *groovyStatement, parents []*groovyStatement) {
+		for _, stage := range stages.body {
+			if stage.name != "stage" {
+				continue
+			}
+			chain := append(append([]*groovyStatement{}, parents...), stage)
+			switch {
+			case stage.child("steps") != nil:
+				groups = append(groups, []jenkinsfileStage{{name: name(stage), stage: stage, parents: parents}})
+			case stage.child("parallel") != nil:
+				if group := leaves(stage.child("parallel"), chain); len(group) > 0 {
+					groups = append(groups, group)
+				}
+			case stage.child("stages") != nil:
+				walk(stage.child("stages"), chain)
+			default:
+				skipped = append(skipped, stage)
+			}
+		}
+	}
+	if stages := pipeline.child("stages"); stages != nil {
+		walk(stages, nil)
+	}
+	return groups, skipped
+}
+
+// jenkinsfileGlobRoot returns the directory of a glob: its path up to the first
+// segment with a wildcard
+func jenkinsfileGlobRoot(glob string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimSpace(glob), "/") {
+		if strings.ContainsAny(segment, "*?[{") {
+			break
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "."
+	}
+	return path.Join(segments...)
+}
+
+// jenkinsfileTranslation translates the Groovy strings of a stage and collects notes
+// for the report
+type jenkinsfileTranslation struct {
+	job    string
+	notes  *[]ReportEntry
+	params map[string]interface{} // the pipeline's parameters
+	known  map[string]bool        // the variables the environment directives set
+}
+
+// note records a translation note, once
+func (t *jenkinsfileTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "Jenkins", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// reference translates a Groovy interpolation: parameters become pipeline parameters
+// and environment variables shell variables; other expressions are kept, and reported
+func (t *jenkinsfileTranslation) reference(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if name := strings.TrimPrefix(expr, "params."); name != expr {
+		return "<< pipeline.parameters." + name + " >>"
+	}
+	name := strings.TrimPrefix(expr, "env.")
+	if !groovyNameRegex.MatchString(name) {
+		t.note("the Groovy expression `${%s}` is not converted; the shell sees it as written", expr)
+		return "${" + expr + "}"
+	}
+	if _, ok := t.params[name]; ok && name == expr {
+		return "<< pipeline.parameters." + name + " >>"
+	}
+	if name == expr && !t.known[name] && !jenkinsfileVariables[name] && jenkinsfilePipelineValues[name] == "" && name != "WORKSPACE" {
+		t.note("`${%s}` may be a Groovy variable, which is not converted; set %s in the environment (or .env) to run the task locally", name, name)
+	}
+	return "${" + name + "}"
+}
+
+// text returns the text of a string value for the shell: Groovy interpolations of
+// double-quoted strings are translated, and the Jenkins variables with a pipeline
+// value replaced by it
+func (t *jenkinsfileTranslation) text(value *groovyValue) string {
+	if value == nil {
+		return ""
+	}
+	if value.kind != 's' || !value.token.double {
+		if value.kind == 'x' && value.text != "" {
+			t.note("the Groovy expression `%s` is not converted; the shell sees it as written", value.text)
+		}
+		return t.translate(value.plain())
+	}
+	raw := value.token.text
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\n':
+			case '\\', '"', '\'', '$':
+				b.WriteByte(raw[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(raw[i])
+			}
+		case c == '$' && strings.HasPrefix(raw[i:], "${"):
+			depth, j := 0, i+1
+			for ; j < len(raw); j++ {
+				if raw[j] == '{' {
+					depth++
+				} else if raw[j] == '}' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			b.WriteString(t.reference(raw[i+2 : j]))
+			i = j
+		case c == '$' && i+1 < len(raw) && (raw[i+1] == '_' || raw[i+1] >= 'a' && raw[i+1] <= 'z' || raw[i+1] >= 'A' && raw[i+1] <= 'Z'):
+			j := i + 1
+			for j < len(raw) && (raw[j] == '_' || raw[j] == '.' || raw[j] >= '0' && raw[j] <= '9' || raw[j] >= 'a' && raw[j] <= 'z' || raw[j] >= 'A' && raw[j] <= 'Z') {
+				j++
+			}
+			expr := strings.TrimRight(raw[i+1:j], ".")
+			b.WriteString(t.reference(expr))
+			i += len(expr)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return t.translate(groovyDedent(b.String()))
+}
+
+// translate replaces the Jenkins variables with a pipeline value by it; the others are
+// kept, and reported as needing a value outside Jenkins
+func (t *jenkinsfileTranslation) translate(text string) string {
+	return jenkinsfileVarRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := jenkinsfileVarRegex.FindStringSubmatch(ref)
+		name := match[1] + match[2]
+		if value, ok := jenkinsfilePipelineValues[name]; ok && !t.known[name] {
+			return "<< " + value + " >>"
+		}
+		if name == "WORKSPACE" && !t.known[name] {
+			return "${WORKSPACE:-$PWD}"
+		}
+		if jenkinsfileVariables[name] && !t.known[name] {
+			t.note("`$%s` is set by Jenkins; set it in the environment (or .env) to run the task locally", name)
+		}
+		return ref
+	})
+}
+
+// environment translates an `environment { }` directive into environment values and,
+// for values reading other variables, export lines; credentials are reported
+func (t *jenkinsfileTranslation) environment(directive *groovyStatement, env map[string]interface{}, exports *[]string) {
+	if directive == nil {
+		return
+	}
+	for _, assignment := range directive.body {
+		if !assignment.assign {
+			continue
+		}
+		name, value := assignment.name, &assignment.args[0].value
+		t.known[name] = true
+		if value.kind == 'x' {
+			if output := jenkinsfileCommandOutput(value.text); output != "" {
+				*exports = append(*exports, fmt.Sprintf(`export %s="$(%s)"`, name, t.translate(output)))
+				continue
+			}
+			if strings.HasPrefix(value.text, "credentials(") {
+				t.note("$%s is the Jenkins credential %s; set it in the environment (or .env) to run the task locally", name, strings.TrimSuffix(strings.TrimPrefix(value.text, "credentials("), ")"))
+			} else {
+				t.note("$%s is the Groovy expression `%s`, which is not converted; set it in the environment (or .env) to run the task locally", name, value.text)
+			}
+			continue
+		}
+		text := t.text(value)
+		if strings.Contains(text, "$") {
+			*exports = append(*exports, fmt.Sprintf(`export %s="%s"`, name, githubShellEscaper.Replace(text)))
+			continue
+		}
+		env[name] = text
+	}
+}
+
+// jenkinsfileCommandOutput returns the command of an `sh(script: ..., returnStdout:
+// true)` expression, the way Jenkinsfiles set variables from commands
+func jenkinsfileCommandOutput(expr string) string {
+	statements, err := parseGroovy(expr)
+	if err != nil || len(statements) != 1 || statements[0].name != "sh" {
+		return ""
+	}
+	sh := statements[0]
+	if returns := sh.arg("returnStdout"); returns == nil || returns.text != "true" {
+		return ""
+	}
+	script := sh.arg("script")
+	if script == nil || script.kind != 's' || script.token.double {
+		return ""
+	}
+	return script.plain()
+}
+
+// jenkinsfileContext is what the wrappers around steps set for them
+type jenkinsfileContext struct {
+	dir  string
+	env  map[string]interface{}
+	when string
+}
+
+// steps translates the steps of a closure: script steps into runs, junit,
+// archiveArtifacts, stash and unstash into their CircleCI steps, and wrappers into
+// the steps they wrap; other steps are reported
+func (t *jenkinsfileTranslation) steps(body []*groovyStatement, ctx jenkinsfileContext) []interface{} {
+	var steps []interface{}
+	run := func(command, name, shell string) {
+		def := map[string]interface{}{"command": command}
+		if name != "" {
+			def["name"] = name
+		}
+		if shell != "" {
+			def["shell"] = shell
+		}
+		if ctx.dir != "" {
+			def["working_directory"] = ctx.dir
+		}
+		if len(ctx.env) > 0 {
+			def["environment"] = ctx.env
+		}
+		if ctx.when != "" {
+			def["when"] = ctx.when
+		}
+		steps = append(steps, map[string]interface{}{"run": def})
+	}
+	message := func(s *groovyStatement) string {
+		value := s.first()
+		if value == nil {
+			value = s.arg("message")
+		}
+		if value != nil && value.kind == 's' && !value.token.double {
+			return shellQuote(value.plain())
+		}
+		return `"` + githubShellEscaper.Replace(t.text(value)) + `"`
+	}
+	for _, s := range body {
+		switch {
+		case s.name == "sh" || s.name == "powershell" || s.name == "pwsh":
+			script := s.arg("script")
+			if script == nil {
+				script = s.first()
+			}
+			shell := map[string]string{"powershell": "powershell.exe", "pwsh": "pwsh"}[s.name]
+			run(t.text(script), s.arg("label").plain(), shell)
+		case s.name == "echo":
+			run("echo "+message(s), "", "")
+		case s.name == "error":
+			run("echo "+message(s)+" >&2; exit 1", "", "")
+		case s.name == "sleep":
+			seconds, _ := strconv.Atoi(s.first().plain())
+			if value := s.arg("time"); value != nil {
+				seconds, _ = strconv.Atoi(value.plain())
+			}
+			switch strings.TrimPrefix(s.arg("unit").plain(), "TimeUnit.") {
+			case "MINUTES":
+				seconds *= 60
+			case "HOURS":
+				seconds *= 3600
+			}
+			run(fmt.Sprintf("sleep %d", seconds), "", "")
+		case s.name == "checkout":
+			if s.first().plain() == "scm" {
+				steps = append(steps, "checkout")
+			} else {
+				t.note("`checkout` of other repositories is not converted")
+			}
+		case s.name == "junit":
+			results := s.arg("testResults")
+			if results == nil {
+				results = s.first()
+			}
+			steps = append(steps, map[string]interface{}{"store_test_results": map[string]interface{}{"path": jenkinsfileGlobRoot(results.plain())}})
+		case s.name == "archiveArtifacts":
+			artifacts := s.arg("artifacts")
+			if artifacts == nil {
+				artifacts = s.first()
+			}
+			for _, glob := range strings.Split(artifacts.plain(), ",") {
+				steps = append(steps, map[string]interface{}{"store_artifacts": map[string]interface{}{"path": jenkinsfileGlobRoot(glob)}})
+			}
+		case s.name == "stash":
+			paths := []string{"."}
+			if includes := s.arg("includes"); includes != nil {
+				paths = strings.Split(includes.plain(), ",")
+				for i := range paths {
+					paths[i] = strings.TrimSpace(paths[i])
+				}
+			}
+			steps = append(steps, map[string]interface{}{"persist_to_workspace": map[string]interface{}{"root": ".", "paths": paths}})
+		case s.name == "unstash":
+			steps = append(steps, map[string]interface{}{"attach_workspace": map[string]interface{}{"at": "."}})
+		case jenkinsfileWrappers[s.name] && s.block:
+			inner := ctx
+			switch s.name {
+			case "dir":
+				inner.dir = path.Join(ctx.dir, s.first().plain())
+			case "withEnv":
+				inner.env = make(map[string]interface{})
+				for key, value := range ctx.env {
+					inner.env[key] = value
+				}
+				if list := s.first(); list != nil {
+					for i := range list.items {
+						if pair := strings.SplitN(t.text(&list.items[i]), "=", 2); len(pair) == 2 {
+							inner.env[pair[0]] = pair[1]
+						}
+					}
+				}
+			case "withCredentials":
+				var names []string
+				for _, args := range s.args {
+					for _, match := range jenkinsfileCredentialRegex.FindAllStringSubmatch(args.value.plain()+args.value.text, -1) {
+						names = append(names, "$"+match[1])
+						t.known[match[1]] = true
+					}
+				}
+				if len(names) > 0 {
+					t.note("Jenkins binds credentials to %s; set them in the environment (or .env) to run the task locally", strings.Join(names, ", "))
+				}
+			}
+			if !jenkinsfileQuietWrappers[s.name] {
+				t.note("`%s { }` wraps steps in Jenkins; the task runs them without it", s.name)
+			}
+			steps = append(steps, t.steps(s.body, inner)...)
+		case s.name == "script":
+			t.note("a `script { }` block (line %d) runs Groovy, which is not converted", s.line)
+		case s.name == "":
+			t.note("the Groovy at line %d is not converted", s.line)
+		default:
+			t.note("the `%s` step (line %d) is not converted", s.name, s.line)
+		}
+	}
+	return steps
+}
+
+// agent translates an `agent` directive into the job's executor: a docker image, or a
+// Windows or macOS machine for agent labels naming them
+func (t *jenkinsfileTranslation) agent(agent *groovyStatement) map[string]interface{} {
+	if agent == nil || !agent.block {
+		return nil
+	}
+	if docker := agent.child("docker"); docker != nil {
+		image := docker.first()
+		if image == nil {
+			image = docker.child("image").first()
+		}
+		if docker.child("args") != nil || docker.arg("args") != nil {
+			t.note("the docker agent's `args` are not converted")
+		}
+		return map[string]interface{}{"docker": []interface{}{map[string]interface{}{"image": t.text(image)}}}
+	}
+	for _, kind := range []string{"dockerfile", "kubernetes"} {
+		if agent.child(kind) != nil {
+			t.note("the `%s` agent is not converted; the task runs on the host", kind)
+			return nil
+		}
+	}
+	label := agent.child("label")
+	if node := agent.child("node"); node != nil && label == nil {
+		label = node.child("label")
+	}
+	if label != nil {
+		switch text := strings.ToLower(label.first().plain()); {
+		case strings.Contains(text, "windows"):
+			return githubExecutor("windows")
+		case strings.Contains(text, "mac") || strings.Contains(text, "osx"):
+			return githubExecutor("macos")
+		}
+	}
+	return nil
+}
+
+// filters translates the `when` conditions on branches and tags into workflow filters;
+// other conditions are reported
+func (t *jenkinsfileTranslation) filters(when *groovyStatement) map[string]interface{} {
+	filters := make(map[string]interface{})
+	if when == nil {
+		return filters
+	}
+	pattern := func(condition *groovyStatement) string {
+		value := condition.first()
+		if value == nil {
+			value = condition.arg("pattern")
+		}
+		switch strings.Trim(condition.arg("comparator").plain(), `'"`) {
+		case "REGEXP":
+			return "/" + value.plain() + "/"
+		case "EQUALS":
+			return value.plain()
+		}
+		return bitbucketGlobRegex(value.plain())
+	}
+	var only, ignore []interface{}
+	for _, condition := range when.body {
+		switch condition.name {
+		case "beforeAgent", "beforeInput", "beforeOptions":
+		case "branch":
+			only = append(only, pattern(condition))
+		case "anyOf", "not":
+			var branches []interface{}
+			for _, inner := range condition.body {
+				if inner.name != "branch" {
+					branches = nil
+					break
+				}
+				branches = append(branches, pattern(inner))
+			}
+			if len(branches) == 0 || (condition.name == "not" && len(branches) > 1) {
+				t.note("the `when { %s { } }` condition is not converted; the task runs unconditionally", condition.name)
+			} else if condition.name == "not" {
+				ignore = append(ignore, branches...)
+			} else {
+				only = append(only, branches...)
+			}
+		case "buildingTag", "tag":
+			tags := "/.*/"
+			if condition.name == "tag" && (condition.first() != nil || condition.arg("pattern") != nil) {
+				tags = pattern(condition)
+			}
+			filters["tags"] = map[string]interface{}{"only": tags}
+			filters["branches"] = map[string]interface{}{"ignore": "/.*/"}
+		default:
+			t.note("the `when { %s }` condition is not converted; the task runs unconditionally", condition.name)
+		}
+	}
+	if len(only) > 0 {
+		filters["branches"] = map[string]interface{}{"only": only}
+	} else if len(ignore) > 0 {
+		filters["branches"] = map[string]interface{}{"ignore": ignore}
+	}
+	return filters
+}
+
+// jenkinsfileParameters translates the `parameters { }` directive into pipeline
+// parameters
+func (t *jenkinsfileTranslation) parameters(directive *groovyStatement) map[string]interface{} {
+	parameters := make(map[string]interface{})
+	if directive == nil {
+		return parameters
+	}
+	for _, s := range directive.body {
+		name := s.arg("name").plain()
+		if name == "" {
+			continue
+		}
+		parameter := map[string]interface{}{"type": "string", "default": s.arg("defaultValue").plain()}
+		switch s.name {
+		case "booleanParam":
+			parameter["type"] = "boolean"
+			parameter["default"] = s.arg("defaultValue").plain() == "true"
+		case "choice":
+			choices := s.arg("choices")
+			var values []string
+			if choices != nil && choices.kind == '[' {
+				for i := range choices.items {
+					values = append(values, choices.items[i].plain())
+				}
+			} else {
+				values = strings.Split(choices.plain(), "\n")
+			}
+			if len(values) == 0 {
+				continue
+			}
+			parameter["type"] = "enum"
+			parameter["enum"] = values
+			parameter["default"] = values[0]
+		case "password":
+			t.note("the password parameter %s is a string pipeline parameter; pass it with `task <name> %s=...`", name, taskVarName(name))
+		case "string", "text":
+		default:
+			t.note("the `%s` parameter %s is not converted", s.name, name)
+			continue
+		}
+		if description := s.arg("description").plain(); description != "" {
+			parameter["description"] = description
+		}
+		parameters[name] = parameter
+	}
+	return parameters
+}
+
+// directiveNote reports the statements of a directive that the conversion leaves to
+// Jenkins, less the quiet ones
+func (t *jenkinsfileTranslation) directiveNote(directive *groovyStatement, quiet ...string) {
+	if directive == nil {
+		return
+	}
+	var names []string
+	for _, s := range directive.body {
+		if s.name != "" && !containsString(quiet, s.name) && !containsString(names, s.name) {
+			names = append(names, s.name)
+		}
+	}
+	if len(names) > 0 {
+		t.note("`%s { }` (%s) is not converted", directive.name, strings.Join(names, ", "))
+	}
+}
+
+// translateStage translates a stage into a CircleCI job: the agent and environment of
+// the stage, or of the stages and pipeline around it, the default checkout, its steps
+// and its `post` conditions. The pipeline's environment is translated once, into env
+// and exports; the exports of a job are written to $BASH_ENV before its steps. It
+// returns nil for stages with nothing to run.
+func (t *jenkinsfileTranslation) translateStage(stage jenkinsfileStage, pipeline *groovyStatement, pipelineEnv map[string]interface{}, pipelineExports []string) map[string]interface{} {
+	chain := append(append([]*groovyStatement{pipeline}, stage.parents...), stage.stage)
+	var executor map[string]interface{}
+	for _, s := range chain {
+		if agent := s.child("agent"); agent != nil {
+			executor = t.agent(agent)
+		}
+	}
+	env := make(map[string]interface{})
+	for key, value := range pipelineEnv {
+		env[key] = value
+	}
+	exports := append([]string{}, pipelineExports...)
+	for _, s := range chain[1:] {
+		t.environment(s.child("environment"), env, &exports)
+		t.directiveNote(s.child("options"), "timestamps", "ansiColor", "skipDefaultCheckout")
+		t.directiveNote(s.child("tools"))
+	}
+	for _, s := range stage.parents {
+		if s.child("post") != nil {
+			t.note("the `post` conditions of stage %s are not converted", s.first().plain())
+		}
+	}
+
+	var steps []interface{}
+	if options := pipeline.child("options"); options == nil || options.child("skipDefaultCheckout") == nil {
+		steps = append(steps, "checkout")
+	}
+	steps = append(steps, t.steps(stage.stage.child("steps").body, jenkinsfileContext{})...)
+	if post := stage.stage.child("post"); post != nil {
+		for _, condition := range post.body {
+			when, ok := jenkinsfilePostWhen[condition.name]
+			if !ok {
+				t.note("the `post { %s { } }` steps are not converted", condition.name)
+				continue
+			}
+			steps = append(steps, t.steps(condition.body, jenkinsfileContext{when: when})...)
+		}
+	}
+
+	var commands []string
+	for _, step := range steps {
+		if body, ok := step.(map[string]interface{}); ok && body["run"] != nil {
+			commands = append(commands, body["run"].(map[string]interface{})["command"].(string))
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	if len(exports) > 0 {
+		var lines []string
+		for _, export := range exports {
+			lines = append(lines, "echo "+shellQuote(export)+` >> "$BASH_ENV"`)
+		}
+		environment := map[string]interface{}{"run": map[string]interface{}{"name": "environment", "command": strings.Join(lines, "\n")}}
+		steps = append([]interface{}{environment}, steps...)
+		commands = append(commands, exports...)
+	}
+
+	// Jenkins sets the parameters as environment variables too
+	for name := range t.params {
+		if regexp.MustCompile(`\$\{?` + regexp.QuoteMeta(name) + `\b`).MatchString(strings.Join(commands, "\n")) {
+			env[name] = "<< pipeline.parameters." + name + " >>"
+		}
+	}
+
+	def := map[string]interface{}{"steps": steps}
+	for key, value := range executor {
+		def[key] = value
+	}
+	if len(env) > 0 {
+		def["environment"] = env
+	}
+	return def
+}
+
+// parseJenkinsfile translates a declarative Jenkinsfile into a CircleCI config: a
+// workflow running the stages with steps as jobs in order, parallel stages side by
+// side and stages with `input` after an approval. Script steps become commands,
+// Groovy interpolations of parameters and environment variables are translated, and
+// what has no equivalent (script blocks, other steps and directives) is recorded in
+// the config's notes.
+func parseJenkinsfile(file string, data []byte) (CircleCIConfig, error) {
+	pipeline, others, err := jenkinsfilePipeline(file, data)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	groups, skipped := jenkinsfileStages(pipeline)
+	if len(groups) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Line: pipeline.line, Message: "the pipeline has no stages with steps"}
+	}
+
+	var notes []ReportEntry
+	pt := &jenkinsfileTranslation{notes: &notes, known: make(map[string]bool)}
+	parameters := pt.parameters(pipeline.child("parameters"))
+	pt.params = parameters
+	pipelineEnv := make(map[string]interface{})
+	var pipelineExports []string
+	pt.environment(pipeline.child("environment"), pipelineEnv, &pipelineExports)
+	for _, other := range others {
+		pt.note("the Groovy outside `pipeline { }` (line %d) is not converted", other.line)
+	}
+	pt.directiveNote(pipeline.child("options"), "timestamps", "ansiColor", "skipDefaultCheckout")
+	pt.directiveNote(pipeline.child("triggers"))
+	pt.directiveNote(pipeline.child("tools"))
+	if pipeline.child("post") != nil {
+		pt.note("the pipeline's `post { }` is not converted; the slim Jenkinsfile keeps it")
+	}
+	for _, stage := range skipped {
+		pt.note("stage %s has no steps (or a `matrix`), and is not converted", stage.first().plain())
+	}
+
+	jobs := make(map[string]interface{})
+	var invocations []interface{}
+	var previous []string
+	for _, group := range groups {
+		var current []string
+		for _, stage := range group {
+			t := &jenkinsfileTranslation{job: stage.name, notes: &notes, params: parameters, known: make(map[string]bool)}
+			for name := range pt.known {
+				t.known[name] = true
+			}
+			def := t.translateStage(stage, pipeline, pipelineEnv, pipelineExports)
+			if def == nil {
+				t.note("the stage has no steps to convert")
+				continue
+			}
+			jobs[stage.name] = def
+
+			var when *groovyStatement
+			for _, s := range append(append([]*groovyStatement{}, stage.parents...), stage.stage) {
+				if s.child("when") != nil {
+					when = s.child("when")
+				}
+			}
+			filters := t.filters(when)
+			invocation := make(map[string]interface{})
+			if len(previous) > 0 {
+				invocation["requires"] = previous
+			}
+			if len(filters) > 0 {
+				invocation["filters"] = filters
+			}
+			if stage.stage.child("input") != nil {
+				hold := "approve-" + stage.name
+				approval := map[string]interface{}{"type": "approval"}
+				for key, value := range invocation {
+					approval[key] = value
+				}
+				invocations = append(invocations, map[string]interface{}{hold: approval})
+				invocation["requires"] = []string{hold}
+			}
+			invocations = append(invocations, map[string]interface{}{stage.name: invocation})
+			current = append(current, stage.name)
+		}
+		if len(current) > 0 {
+			previous = current
+		}
+	}
+	if len(jobs) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Line: pipeline.line, Message: "the pipeline has no steps to convert", Hint: "only `sh`, `powershell`, `echo` and the like become commands; see the report of a stage with one"}
+	}
+
+	circle := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      jobs,
+		"workflows": map[string]interface{}{"pipeline": map[string]interface{}{"jobs": invocations}},
+	}
+	if len(parameters) > 0 {
+		circle["parameters"] = parameters
+	}
+	translated, err := yaml.Marshal(circle)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	converted, err := parseConfig(file, translated)
+	if err != nil {
+		return converted, err
+	}
+	converted.format = fromJenkins
+	converted.notes = notes
+	converted.source = nil
+	return converted, nil
+}
+
+// groovyEdit replaces a span of a Jenkinsfile
+type groovyEdit struct {
+	start, end int
+	text       string
+}
+
+// jenkinsfileSlimSteps removes the converted steps of a closure, with the wrappers left
+// empty, putting the call in place of the first; it reports whether any statement
+// remains
+func jenkinsfileSlimSteps(src string, body []*groovyStatement, call *string) ([]groovyEdit, bool) {
+	var edits []groovyEdit
+	remains := false
+	for _, s := range body {
+		switch {
+		case jenkinsfileScriptSteps[s.name]:
+			if *call != "" {
+				edits = append(edits, groovyEdit{s.after, s.end, src[s.after:s.start] + *call})
+				*call = ""
+				remains = true
+			} else {
+				edits = append(edits, groovyEdit{s.after, s.end, ""})
+			}
+		case jenkinsfileWrappers[s.name] && s.block:
+			inner, left := jenkinsfileSlimSteps(src, s.body, call)
+			if left {
+				edits = append(edits, inner...)
+				remains = true
+			} else {
+				edits = append(edits, groovyEdit{s.after, s.end, ""})
+			}
+		default:
+			remains = true
+		}
+	}
+	return edits, remains
+}
+
+// writeJenkinsfile writes the slim Jenkinsfile: the input with the script steps of
+// each converted stage replaced by one call to the stage's task (or script, for the
+// script targets), passing the parameters it uses, and without the script steps of
+// its `post` conditions. Everything else, including the steps and directives the
+// conversion leaves to Jenkins, is kept as written.
+func writeJenkinsfile(file string, data []byte, config CircleCIConfig, target string) error {
+	src := string(data)
+	pipeline, _, err := jenkinsfilePipeline(file, data)
+	if err != nil {
+		return err
+	}
+	groups, _ := jenkinsfileStages(pipeline)
+	var edits []groovyEdit
+	for _, group := range groups {
+		for _, stage := range group {
+			job, ok := config.Jobs[stage.name]
+			if !ok {
+				continue
+			}
+			command := "task " + stage.name
+			if scriptTarget(target) {
+				command = "./" + scriptFile(stage.name)
+			}
+			var args []string
+			for _, ref := range pipelineRefs(job) {
+				if variable := strings.TrimPrefix(ref, "pipeline.parameters."); variable != ref {
+					args = append(args, fmt.Sprintf(`%s="$%s"`, taskVarName(variable), variable))
+				}
+			}
+			if len(args) > 0 {
+				command += " " + strings.Join(args, " ")
+			}
+			call := "sh " + groovyString(command)
+
+			steps := stage.stage.child("steps")
+			inner, _ := jenkinsfileSlimSteps(src, steps.body, &call)
+			edits = append(edits, inner...)
+			if call != "" {
+				// Only the post conditions run commands: call the task first
+				indent := src[strings.LastIndex(src[:steps.start], "\n")+1 : steps.start]
+				edits = append(edits, groovyEdit{steps.close, steps.close, "    " + call + "\n" + indent})
+			}
+
+			post := stage.stage.child("post")
+			if post == nil {
+				continue
+			}
+			kept := false
+			var postEdits []groovyEdit
+			for _, condition := range post.body {
+				if _, ok := jenkinsfilePostWhen[condition.name]; !ok {
+					kept = true
+					continue
+				}
+				none := ""
+				inner, left := jenkinsfileSlimSteps(src, condition.body, &none)
+				if left {
+					postEdits = append(postEdits, inner...)
+					kept = true
+				} else {
+					postEdits = append(postEdits, groovyEdit{condition.after, condition.end, ""})
+				}
+			}
+			if kept {
+				edits = append(edits, postEdits...)
+			} else {
+				edits = append(edits, groovyEdit{post.after, post.end, ""})
+			}
+		}
+	}
+
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var b strings.Builder
+	at := 0
+	for _, edit := range edits {
+		b.WriteString(src[at:edit.start])
+		b.WriteString(edit.text)
+		at = edit.end
+	}
+	b.WriteString(src[at:])
+	return os.WriteFile(file, []byte(b.String()), 0644)
+}
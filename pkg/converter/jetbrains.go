@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// JetBrainsRunConfig is a shared IntelliJ/GoLand run configuration (.run/*.run.xml)
+type JetBrainsRunConfig struct {
+	XMLName       xml.Name               `xml:"component"`
+	Name          string                 `xml:"name,attr"`
+	Configuration JetBrainsConfiguration `xml:"configuration"`
+}
+
+// JetBrainsConfiguration is a Shell Script configuration running one go-task task
+type JetBrainsConfiguration struct {
+	Default string            `xml:"default,attr"`
+	Name    string            `xml:"name,attr"`
+	Type    string            `xml:"type,attr"`
+	Options []JetBrainsOption `xml:"option"`
+	Envs    struct{}          `xml:"envs"`
+	Method  JetBrainsMethod   `xml:"method"`
+}
+
+// JetBrainsOption is one <option name="..." value="..."/> entry
+type JetBrainsOption struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// JetBrainsMethod holds the before-launch steps (none)
+type JetBrainsMethod struct {
+	V string `xml:"v,attr"`
+}
+
+// runConfigFileRegex matches characters not safe in run configuration file names
+var runConfigFileRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// keyTasks returns the tasks worth a run configuration: ci-local and the test and build
+// tasks
+func keyTasks(taskfile Taskfile) []string {
+	var names []string
+	for name := range taskfile.Tasks {
+		if name == "ci-local" || taskGroup(name) != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildRunConfig creates the run configuration for a task
+func buildRunConfig(name string) JetBrainsRunConfig {
+	return JetBrainsRunConfig{
+		Name: "ProjectRunConfigurationManager",
+		Configuration: JetBrainsConfiguration{
+			Default: "false",
+			Name:    "task: " + name,
+			Type:    "ShConfigurationType",
+			Options: []JetBrainsOption{
+				{"SCRIPT_TEXT", "task " + name},
+				{"INDEPENDENT_SCRIPT_PATH", "true"},
+				{"SCRIPT_PATH", ""},
+				{"SCRIPT_OPTIONS", ""},
+				{"INDEPENDENT_SCRIPT_WORKING_DIRECTORY", "true"},
+				{"SCRIPT_WORKING_DIRECTORY", "$PROJECT_DIR$"},
+				{"INDEPENDENT_INTERPRETER_PATH", "true"},
+				{"INTERPRETER_PATH", "/bin/bash"},
+				{"INTERPRETER_OPTIONS", ""},
+				{"EXECUTE_IN_TERMINAL", "true"},
+				{"EXECUTE_SCRIPT_FILE", "false"},
+			},
+			Method: JetBrainsMethod{V: "2"},
+		},
+	}
+}
+
+// generateJetBrainsRunConfigs writes a .run/<task>.run.xml configuration for each key task
+func generateJetBrainsRunConfigs(taskfile Taskfile, outputDir string) error {
+	dir := filepath.Join(outputDir, ".run")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating .run directory: %w", err)
+	}
+
+	for _, name := range keyTasks(taskfile) {
+		data, err := xml.MarshalIndent(buildRunConfig(name), "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling run configuration for %s: %w", name, err)
+		}
+		file := filepath.Join(dir, runConfigFileRegex.ReplaceAllString(name, "_")+".run.xml")
+		if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", file, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// ConversionModel is the intermediate model written by -emit-json: the parsed input,
+// the slimmed CircleCI config, the generated Taskfile and the conversion notes
+type ConversionModel struct {
+	GeneratedBy string         `json:"generatedBy"`
+	Input       string         `json:"input"`
+	Source      CircleCIConfig `json:"source"`
+	Config      CircleCIConfig `json:"config"`
+	Taskfile    Taskfile       `json:"taskfile"`
+	Report      []ReportEntry  `json:"report"`
+}
+
+// generateJSONOutput writes Taskfile.json and CONVERSION_MODEL.json to the output directory
+func generateJSONOutput(inputFile string, config, newConfig CircleCIConfig, taskfile Taskfile, report *ConversionReport, outputDir string) error {
+	if err := writeJSONFile(filepath.Join(outputDir, "Taskfile.json"), taskfile); err != nil {
+		return err
+	}
+
+	model := ConversionModel{
+		GeneratedBy: "circle-to-task " + Version,
+		Input:       inputFile,
+		Source:      config,
+		Config:      newConfig,
+		Taskfile:    taskfile,
+		Report:      []ReportEntry{},
+	}
+	if report != nil && len(report.Entries) > 0 {
+		model.Report = report.Entries
+	}
+
+	return writeJSONFile(filepath.Join(outputDir, "CONVERSION_MODEL.json"), model)
+}
+
+// writeJSONFile writes data as indented JSON, leaving << parameters >> unescaped
+func writeJSONFile(path string, data interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("error marshaling JSON: %w", err)
+	}
+	return writeFileContent(path, buf.Bytes())
+}
@@ -0,0 +1,303 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockFileName is written next to the generated Taskfile
+const lockFileName = ".circle-to-task.lock"
+
+// LockFile records what a conversion was generated from, so later runs can detect
+// changed environments and hand edits, and only regenerate tasks whose source changed
+type LockFile struct {
+	ConverterVersion string                `json:"converterVersion"`
+	InputHash        string                `json:"inputHash"`
+	Options          map[string]string     `json:"options"`
+	Orbs             map[string]string     `json:"orbs,omitempty"` // orb reference → source hash
+	Tasks            map[string]LockedTask `json:"tasks"`
+}
+
+// LockedTask holds the hashes of a task's source config and of the generated task, and
+// the generated task itself as the base for merging hand edits
+type LockedTask struct {
+	Source    string `json:"source"`
+	Output    string `json:"output"`
+	Generated *Task  `json:"generated,omitempty"`
+}
+
+// hashValue returns a short sha256 of a value's JSON encoding (map keys are sorted)
+func hashValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", value))
+	}
+	return hashBytes(data)
+}
+
+// hashBytes returns a short sha256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// taskOutputHash hashes a task the way it is written to Taskfile.yml, so a task read
+// back from the file hashes the same as the one that was generated
+func taskOutputHash(task Task) string {
+	data, err := yaml.Marshal(task)
+	if err != nil {
+		return hashValue(task)
+	}
+	return hashBytes(data)
+}
+
+// referencedCommands adds the custom commands the steps invoke, transitively, to found
+func referencedCommands(steps []Step, commands map[string]Command, found map[string]Command) {
+	for _, step := range steps {
+		name, ok := isCommandInvocation(step)
+		if stepName, isString := step.(string); isString {
+			name, ok = stepName, true
+		}
+		if command, exists := commands[name]; ok && exists {
+			if _, seen := found[name]; !seen {
+				found[name] = command
+				referencedCommands(command.Steps, commands, found)
+			}
+		}
+	}
+}
+
+// taskSourceHashes hashes the config entries each job and command task is generated
+// from: the definition, the commands it uses, its executor and its workflow invocations
+func taskSourceHashes(config CircleCIConfig) map[string]string {
+	hashes := make(map[string]string)
+
+	invocations := make(map[string][]map[string]interface{})
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		invocations[invocation.Job] = append(invocations[invocation.Job], invocation.Config)
+	}
+
+	for name, job := range config.Jobs {
+		commands := make(map[string]Command)
+		referencedCommands(job.Steps, config.Commands, commands)
+		executorName, _ := executorReference(job.Executor)
+		hashes[name] = hashValue(map[string]interface{}{
+			"job":         job,
+			"commands":    commands,
+			"executor":    config.Executors[executorName],
+			"invocations": invocations[name],
+		})
+	}
+
+	for name, command := range config.Commands {
+		commands := make(map[string]Command)
+		referencedCommands(command.Steps, config.Commands, commands)
+		hashes[name] = hashValue(map[string]interface{}{"command": command, "commands": commands})
+	}
+
+	return hashes
+}
+
+// buildLockFile records the inputs and outputs of a conversion. Tasks not generated
+// from a single job or command (patterns, matrix variants, helpers) depend on the
+// whole input.
+func buildLockFile(input []byte, config CircleCIConfig, taskfile Taskfile, options map[string]string, resolver *OrbResolver) LockFile {
+	lock := LockFile{
+		ConverterVersion: Version,
+		InputHash:        hashBytes(input),
+		Options:          options,
+		Tasks:            make(map[string]LockedTask),
+	}
+
+	if resolver != nil && len(resolver.sources) > 0 {
+		lock.Orbs = make(map[string]string)
+		for ref, source := range resolver.sources {
+			lock.Orbs[ref] = hashBytes(source)
+		}
+	}
+
+	sources := taskSourceHashes(config)
+	for name, task := range taskfile.Tasks {
+		source, ok := sources[name]
+		if !ok {
+			source = lock.InputHash
+		}
+		generated := task
+		lock.Tasks[name] = LockedTask{Source: source, Output: taskOutputHash(task), Generated: &generated}
+	}
+
+	return lock
+}
+
+// readLockFile loads a previous lock; ok is false when there is none
+func readLockFile(outputDir string) (LockFile, bool, error) {
+	var lock LockFile
+	data, err := os.ReadFile(filepath.Join(outputDir, lockFileName))
+	if os.IsNotExist(err) {
+		return lock, false, nil
+	}
+	if err != nil {
+		return lock, false, fmt.Errorf("error reading %s: %w", lockFileName, err)
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, false, fmt.Errorf("error parsing %s: %w", lockFileName, err)
+	}
+	return lock, true, nil
+}
+
+// writeLockFile writes the lock next to the generated Taskfile
+func writeLockFile(outputDir string, lock LockFile) error {
+	return writeJSONFile(filepath.Join(outputDir, lockFileName), lock)
+}
+
+// keepHandAddedTasks adds the tasks of the existing Taskfile that the previous lock does
+// not own, so tasks added by hand survive re-runs; without a lock, every task not
+// generated is taken to be added by hand. A hand-added task named like a generated one
+// is replaced by it, and owned tasks whose source was removed are dropped; both are
+// reported, with a warning when hand work is lost.
+func keepHandAddedTasks(previous LockFile, existing Taskfile, taskfile *Taskfile, report *ConversionReport) []string {
+	var names []string
+	for name := range existing.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []string
+	for _, name := range names {
+		task := existing.Tasks[name]
+		_, generated := taskfile.Tasks[name]
+		if locked, owned := previous.Tasks[name]; owned {
+			if generated {
+				continue
+			}
+			report.Add("Lockfile", name, "removed: its source is no longer in the config")
+			if taskOutputHash(task) != locked.Output {
+				warnings = append(warnings, fmt.Sprintf("task %s was edited by hand but its source was removed from the config; it was dropped", name))
+			}
+			continue
+		}
+		if generated {
+			warnings = append(warnings, fmt.Sprintf("task %s was added by hand with the name of a generated task; the generated task replaced it (rename yours to keep it)", name))
+			report.Add("Lockfile", name, "merge conflict: a task added by hand has the name of a generated task, which replaced it")
+			continue
+		}
+		taskfile.Tasks[name] = task
+		report.Add("Lockfile", name, "added by hand; kept")
+	}
+	return warnings
+}
+
+// environmentChanges lists differences between two locks that affect every task
+func environmentChanges(previous, current LockFile) []string {
+	var changes []string
+	if previous.ConverterVersion != current.ConverterVersion {
+		changes = append(changes, fmt.Sprintf("converter version changed from %s to %s", previous.ConverterVersion, current.ConverterVersion))
+	}
+
+	var optionNames []string
+	for name := range current.Options {
+		optionNames = append(optionNames, name)
+	}
+	for name := range previous.Options {
+		if _, ok := current.Options[name]; !ok {
+			optionNames = append(optionNames, name)
+		}
+	}
+	sort.Strings(optionNames)
+	for _, name := range optionNames {
+		if previous.Options[name] != current.Options[name] {
+			changes = append(changes, fmt.Sprintf("option -%s changed from %q to %q", name, previous.Options[name], current.Options[name]))
+		}
+	}
+
+	for _, ref := range sortedStringKeys(current.Orbs) {
+		if hash, ok := previous.Orbs[ref]; ok && hash != current.Orbs[ref] {
+			changes = append(changes, fmt.Sprintf("source of orb %s changed", ref))
+		}
+	}
+	return changes
+}
+
+// reconcileWithLock compares a new conversion with the previous one. When the environment
+// is unchanged, tasks whose source did not change keep their current contents in
+// Taskfile.yml, so hand edits survive re-runs. Hand-edited tasks whose source changed
+// are three-way merged with their regenerated version, using the task generated last
+// time as the base; locks without that base (from older versions) regenerate them.
+// Tasks added by hand are kept (see keepHandAddedTasks). Conflicts, overwritten edits
+// and environment changes are returned as warnings. An existing Taskfile.yml that cannot
+// be parsed is an error, so hand work in it is never overwritten.
+func reconcileWithLock(outputDir string, lock LockFile, taskfile *Taskfile, report *ConversionReport) ([]string, error) {
+	previous, ok, err := readLockFile(outputDir)
+	if err != nil {
+		return []string{err.Error() + "; regenerating everything"}, nil
+	}
+
+	var existing Taskfile
+	if data, err := os.ReadFile(filepath.Join(outputDir, taskfileName)); err == nil {
+		if err := yaml.Unmarshal(data, &existing); err != nil {
+			return nil, fmt.Errorf("existing %s could not be parsed (%w); fix or remove it to regenerate it", taskfileName, err)
+		}
+	}
+
+	warnings := keepHandAddedTasks(previous, existing, taskfile, report)
+	if !ok {
+		return warnings, nil
+	}
+
+	changes := environmentChanges(previous, lock)
+	for _, change := range changes {
+		warnings = append(warnings, change+"; all tasks were regenerated")
+	}
+
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	regenerated := 0
+	for _, name := range names {
+		locked, wasLocked := previous.Tasks[name]
+		current, onDisk := existing.Tasks[name]
+		sourceChanged := !wasLocked || locked.Source != lock.Tasks[name].Source
+		if sourceChanged && wasLocked {
+			regenerated++
+		}
+		if !wasLocked || !onDisk || taskOutputHash(current) == locked.Output {
+			continue
+		}
+
+		// The task on disk differs from what was generated: it was edited by hand
+		switch {
+		case len(changes) == 0 && !sourceChanged:
+			taskfile.Tasks[name] = current
+			report.Add("Lockfile", name, "edited by hand since the last conversion; kept as is because its source did not change")
+		case locked.Generated != nil:
+			merged, conflicts := mergeTask(*locked.Generated, current, taskfile.Tasks[name])
+			taskfile.Tasks[name] = merged
+			if len(conflicts) == 0 {
+				report.Add("Lockfile", name, "hand edits merged with the regenerated task")
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("task %s: hand edits merged with %d conflicts; search Taskfile.yml for \"merge conflict\"", name, len(conflicts)))
+			for _, conflict := range conflicts {
+				report.Add("Lockfile", name, "merge conflict: %s; the hand edit was kept and the regenerated version added as a comment", conflict)
+			}
+		default:
+			warnings = append(warnings, fmt.Sprintf("task %s was edited by hand; the edits were overwritten because its source changed", name))
+			report.Add("Lockfile", name, "hand edits overwritten: the task was regenerated because its source or the converter environment changed")
+		}
+	}
+
+	if len(changes) == 0 && previous.InputHash != lock.InputHash {
+		report.Add("Lockfile", "", "input config changed; %d tasks regenerated from changed sources", regenerated)
+	}
+	return warnings, nil
+}
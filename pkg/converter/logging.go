@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger is the structured logger of the converter. It writes warnings and errors to
+// stderr until setupLogging applies -log-format and -log-level.
+var logger = newLogger("text", slog.LevelWarn)
+
+// newLogger returns a logger writing text or JSON records to stderr. Text records
+// leave out the time, since they are read by people next to the other output.
+func newLogger(format string, level slog.Level) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+}
+
+// setupLogging configures the logger from -log-format (text or json) and -log-level.
+// Without a level, JSON logs include the info records (conversion report entries),
+// which automation usually wants, and text logs only warnings and errors.
+func setupLogging(format, level string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	lvl := slog.LevelWarn
+	if format == "json" {
+		lvl = slog.LevelInfo
+	}
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+			return fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+		}
+	}
+	logger = newLogger(format, lvl)
+	return nil
+}
+
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,114 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nichecode/circle-to-task/ir"
+)
+
+// targetMakefile writes a Makefile running the standalone scripts
+const targetMakefile = "makefile"
+
+// makefileName is the Makefile written with -target makefile
+const makefileName = "Makefile"
+
+// makefileEmitter writes a Makefile
+type makefileEmitter struct{}
+
+func (makefileEmitter) Name() string { return targetMakefile }
+
+func (makefileEmitter) Emit(out emission) ([]string, error) {
+	if err := generateMakefile(out.Pipeline, out.OutputDir); err != nil {
+		return nil, fmt.Errorf("error writing %s: %w", makefileName, err)
+	}
+	return []string{makefileName + " (make targets running the scripts)"}, nil
+}
+
+// makeTarget returns the make target of a task: the name of its script
+func makeTarget(task string) string {
+	return strings.TrimSuffix(filepath.Base(scriptFile(task)), ".sh")
+}
+
+// makeArg returns a KEY=value argument of a run as a make recipe word: pipeline
+// parameters read the make variables, and $ is escaped from make
+func makeArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	quoted := strings.ContainsAny(arg, " \t'\"$`\\;&|<>()")
+	arg = pipelineRefRegex.ReplaceAllStringFunc(arg, func(match string) string {
+		ref := pipelineRefRegex.FindStringSubmatch(match)[1]
+		if name := strings.TrimPrefix(ref, "pipeline.parameters."); name != ref {
+			return "$(" + taskVarName(name) + ")"
+		}
+		return match
+	})
+	if quoted {
+		return shellQuote(arg)
+	}
+	return arg
+}
+
+// generateMakefile writes a Makefile with a target per workflow, running the scripts
+// of its jobs in dependency order, and a target per job script. Pipeline parameters
+// are make variables, exported to the scripts.
+func generateMakefile(pipeline *ir.Pipeline, outputDir string) error {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("# Generated by circle-to-task. The targets run the standalone scripts in %s/;\n", scriptsDir))
+	b.WriteString("# a workflow's target runs the scripts of its jobs in dependency order.\n")
+	b.WriteString("# Parameters are make variables: make <target> NAME=value\n")
+
+	if len(pipeline.Parameters) > 0 {
+		b.WriteString("\n")
+		for _, param := range pipeline.Parameters {
+			if param.HasDefault {
+				b.WriteString(fmt.Sprintf("export %s ?= %s\n", taskVarName(param.Name), strings.ReplaceAll(param.Default, "$", "$$")))
+			} else {
+				b.WriteString(fmt.Sprintf("export %s\n", taskVarName(param.Name)))
+			}
+		}
+	}
+
+	var phony []string
+	var recipes []string
+	tasks := make(map[string]bool)
+	for _, workflow := range pipeline.Workflows {
+		if workflow.Error != "" {
+			continue
+		}
+		target := "workflow-" + travisSlug(workflow.Name)
+		phony = append(phony, target)
+		recipe := target + ":\n"
+		for _, run := range workflow.Runs {
+			if run.Approval {
+				recipe += fmt.Sprintf("\t@printf '%%s ' %s && read -r _\n", shellQuote(fmt.Sprintf("Approve %s? Press Enter to continue", run.Name)))
+				continue
+			}
+			tasks[run.Task] = true
+			args := []string{"./" + scriptFile(run.Task)}
+			for _, arg := range run.Args {
+				args = append(args, makeArg(arg))
+			}
+			recipe += "\t" + strings.Join(args, " ") + "\n"
+		}
+		recipes = append(recipes, recipe)
+	}
+
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		phony = append(phony, makeTarget(name))
+		recipes = append(recipes, fmt.Sprintf("%s:\n\t./%s\n", makeTarget(name), scriptFile(name)))
+	}
+
+	b.WriteString("\n.PHONY: " + strings.Join(phony, " ") + "\n")
+	for _, recipe := range recipes {
+		b.WriteString("\n" + recipe)
+	}
+	return os.WriteFile(filepath.Join(outputDir, makefileName), []byte(b.String()), 0644)
+}
@@ -0,0 +1,200 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MatrixVariant is one parameter combination of a matrix job invocation
+type MatrixVariant struct {
+	Name   string            // job name CircleCI gives the variant (test-1.21-linux)
+	Params map[string]string // matrix parameter values
+}
+
+var matrixRefRegex = regexp.MustCompile(`<<\s*matrix\.([A-Za-z0-9_-]+)\s*>>`)
+
+// expandMatrix expands a workflow job's matrix into the variants CircleCI would run,
+// honoring exclude combinations and << matrix.x >> references in the job name
+func expandMatrix(job WorkflowJob) ([]MatrixVariant, int, bool) {
+	if job.Matrix == nil {
+		return nil, 0, false
+	}
+
+	// CircleCI orders the values in generated names by parameter name
+	paramNames := sortedKeysOf(job.Matrix.Parameters)
+
+	combinations := []map[string]string{{}}
+	for _, paramName := range paramNames {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range job.Matrix.Parameters[paramName] {
+				extended := make(map[string]string, len(combination)+1)
+				for k, v := range combination {
+					extended[k] = v
+				}
+				extended[paramName] = value
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+
+	var variants []MatrixVariant
+	excluded := 0
+	for _, combination := range combinations {
+		if matchesAnyExclude(combination, job.Matrix.Exclude) {
+			excluded++
+			continue
+		}
+
+		name := job.Job
+		if job.Name != "" {
+			name = substituteMatrix(job.Name, combination)
+		} else {
+			var values []string
+			for _, paramName := range paramNames {
+				values = append(values, combination[paramName])
+			}
+			name = job.Job + "-" + strings.Join(values, "-")
+		}
+
+		variants = append(variants, MatrixVariant{Name: name, Params: combination})
+	}
+
+	return variants, excluded, true
+}
+
+// matchesAnyExclude reports whether a combination matches one of the exclude entries
+func matchesAnyExclude(combination map[string]string, excludes []map[string]string) bool {
+	for _, exclude := range excludes {
+		matches := len(exclude) > 0
+		for k, v := range exclude {
+			if combination[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// substituteMatrix replaces << matrix.x >> references with a variant's values
+func substituteMatrix(s string, params map[string]string) string {
+	return matrixRefRegex.ReplaceAllStringFunc(s, func(ref string) string {
+		name := matrixRefRegex.FindStringSubmatch(ref)[1]
+		if value, ok := params[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// matrixAlias returns the name the workflow uses to refer to all variants of a matrix job
+func matrixAlias(job WorkflowJob) string {
+	if job.Matrix != nil && job.Matrix.Alias != "" {
+		return job.Matrix.Alias
+	}
+	return job.Job
+}
+
+// addMatrixTasks adds one task per matrix variant, calling the job task with the
+// variant's parameters, plus an aggregate task running every variant
+func addMatrixTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	aggregates := make(map[string][]string)
+
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		variants, excluded, ok := expandMatrix(invocation.WorkflowJob)
+		if !ok {
+			continue
+		}
+		if _, exists := taskfile.Tasks[invocation.Job]; !exists {
+			report.Add("Matrix", invocation.Job, "matrix job has no task to call; variants were not generated")
+			continue
+		}
+
+		args := invocation.Arguments
+		var variantNames []string
+		for _, variant := range variants {
+			params := make(map[string]string)
+			for argName, argValue := range args {
+				params[argName] = substituteMatrix(fmt.Sprintf("%v", argValue), variant.Params)
+			}
+			for paramName, value := range variant.Params {
+				params[paramName] = value
+			}
+
+			desc := fmt.Sprintf("Matrix variant of job %s (%s)", invocation.Job, describeMatrixParams(variant.Params))
+			variantNames = append(variantNames, variant.Name)
+
+			// A variant choosing the executor runs the job's commands in that executor,
+			// since the job task's environment is the default executor's
+			job := config.Jobs[invocation.Job]
+			if param, ok := executorParameter(job); ok && params[param] != "" {
+				variantArgs := make(map[string]interface{}, len(params))
+				for name, value := range params {
+					variantArgs[name] = value
+				}
+				task, _, err := withInvocationExecutor(taskfile.Tasks[invocation.Job], job, variantArgs, config.Executors)
+				if err != nil {
+					report.Add("Executors", invocation.Job, "could not resolve the executor of matrix variant %s: %v", variant.Name, err)
+				}
+				task.Desc = desc
+				taskfile.Tasks[variant.Name] = task
+				continue
+			}
+
+			var pairs []string
+			for _, paramName := range sortedStringKeys(params) {
+				pairs = append(pairs, fmt.Sprintf("%s=%s", taskVarName(paramName), shellQuote(params[paramName])))
+			}
+
+			taskfile.Tasks[variant.Name] = Task{
+				Desc: desc,
+				Cmds: shellCommands(fmt.Sprintf("task %s %s", invocation.Job, strings.Join(pairs, " "))),
+			}
+		}
+
+		// The alias defaults to the job name, whose task already exists
+		aggregate := matrixAlias(invocation.WorkflowJob)
+		if aggregate == invocation.Job {
+			aggregate = invocation.Job + "-matrix"
+		}
+		// Several matrix invocations of one job share the aggregate
+		aggregates[aggregate] = append(aggregates[aggregate], variantNames...)
+		desc := fmt.Sprintf("Run all %d matrix variants of job %s", len(aggregates[aggregate]), invocation.Job)
+		if len(aggregates[aggregate]) == 1 {
+			desc = fmt.Sprintf("Run the only matrix variant of job %s", invocation.Job)
+		}
+		taskfile.Tasks[aggregate] = Task{
+			Desc: desc,
+			Cmds: TaskCmds{},
+			Deps: aggregates[aggregate],
+		}
+
+		report.Add("Matrix", invocation.Job, "expanded into %d variant tasks (%d excluded), run them all with `task %s`", len(variantNames), excluded, aggregate)
+	}
+}
+
+// describeMatrixParams renders variant parameters as name=value pairs
+func describeMatrixParams(params map[string]string) string {
+	var pairs []string
+	for _, name := range sortedStringKeys(params) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, params[name]))
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// sortedStringKeys returns the keys of a string map in sorted order
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,240 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Conflict markers written into the commands of merged tasks. Markers and the
+// regenerated alternative are shell comments, so a task with conflicts still runs the
+// hand-edited commands.
+const (
+	conflictStartMarker = "# <<<<<<< circle-to-task merge conflict: hand-edited commands (kept)"
+	conflictSplitMarker = "# ======= regenerated commands (not applied):"
+	conflictEndMarker   = "# >>>>>>> end of conflict"
+)
+
+// mergeTask three-way merges a hand-edited task (ours) with its regenerated version
+// (theirs), using the previously generated task as the common base. Changes made on
+// only one side are applied; where both sides changed the same thing differently the
+// hand edit is kept and the conflict is marked with comments in the commands. It
+// returns the merged task and a description of each conflict.
+func mergeTask(base, ours, theirs Task) (Task, []string) {
+	var conflicts []string
+	var notes []string
+	conflict := func(field string, regenerated interface{}) {
+		conflicts = append(conflicts, fmt.Sprintf("%s changed both by hand and in the source", field))
+		notes = append(notes, fmt.Sprintf("# circle-to-task merge conflict: kept hand-edited %s; regenerated value: %v", field, regenerated))
+	}
+
+	merged := Task{}
+
+	var ok bool
+	if merged.Desc, ok = mergeValue(base.Desc, ours.Desc, theirs.Desc); !ok {
+		conflict("desc", theirs.Desc)
+	}
+	if merged.Dir, ok = mergeValue(base.Dir, ours.Dir, theirs.Dir); !ok {
+		conflict("dir", theirs.Dir)
+	}
+	if merged.Dotenv, ok = mergeValue(base.Dotenv, ours.Dotenv, theirs.Dotenv); !ok {
+		conflict("dotenv", theirs.Dotenv)
+	}
+	if merged.Silent, ok = mergeValue(base.Silent, ours.Silent, theirs.Silent); !ok {
+		conflict("silent", theirs.Silent)
+	}
+	if merged.Preconditions, ok = mergeValue(base.Preconditions, ours.Preconditions, theirs.Preconditions); !ok {
+		conflict("preconditions", theirs.Preconditions)
+	}
+	if merged.Platforms, ok = mergeValue(base.Platforms, ours.Platforms, theirs.Platforms); !ok {
+		conflict("platforms", theirs.Platforms)
+	}
+
+	var depConflicts int
+	merged.Deps, depConflicts = mergeLines(base.Deps, ours.Deps, theirs.Deps, nil)
+	if depConflicts > 0 {
+		conflict("deps", theirs.Deps)
+	}
+
+	var keys []string
+	merged.Vars, keys = mergeStringMaps(base.Vars, ours.Vars, theirs.Vars)
+	for _, key := range keys {
+		conflict("var "+key, theirs.Vars[key])
+	}
+	merged.Env, keys = mergeStringMaps(base.Env, ours.Env, theirs.Env)
+	for _, key := range keys {
+		conflict("env "+key, theirs.Env[key])
+	}
+
+	cmds, cmdConflicts := mergeLines(base.Cmds, ours.Cmds, theirs.Cmds, conflictCommands)
+	for i := 0; i < cmdConflicts; i++ {
+		conflicts = append(conflicts, "cmds changed both by hand and in the source")
+	}
+	merged.Cmds = append(shellCommands(notes...), cmds...)
+
+	return merged, conflicts
+}
+
+// mergeValue three-way merges a single value; ok is false on a conflict, in which case
+// ours is returned
+func mergeValue[T any](base, ours, theirs T) (T, bool) {
+	switch {
+	case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(theirs, base):
+		return ours, true
+	case reflect.DeepEqual(ours, base):
+		return theirs, true
+	}
+	return ours, false
+}
+
+// mergeStringMaps three-way merges vars or env key by key, treating a missing key as a
+// value of its own. It returns the merged map and the conflicting keys.
+func mergeStringMaps(base, ours, theirs map[string]string) (map[string]string, []string) {
+	keys := make(map[string]bool)
+	for _, m := range []map[string]string{base, ours, theirs} {
+		for key := range m {
+			keys[key] = true
+		}
+	}
+
+	merged := make(map[string]string)
+	var conflicts []string
+	lookup := func(m map[string]string, key string) *string {
+		if value, ok := m[key]; ok {
+			return &value
+		}
+		return nil
+	}
+	for key := range keys {
+		value, ok := mergeValue(lookup(base, key), lookup(ours, key), lookup(theirs, key))
+		if !ok {
+			conflicts = append(conflicts, key)
+		}
+		if value != nil {
+			merged[key] = *value
+		}
+	}
+	sort.Strings(conflicts)
+
+	if len(merged) == 0 {
+		return nil, conflicts
+	}
+	return merged, conflicts
+}
+
+// mergeLines is a diff3 merge of lists: lines changed on one side only are taken from
+// that side. Conflicting regions keep our lines, or with conflict set, the lines it
+// returns for ours and the regenerated ones. It returns the merged lines and the number
+// of conflicting regions.
+func mergeLines[T comparable](base, ours, theirs []T, conflict func(ours, theirs []T) []T) ([]T, int) {
+	oursMatch := lcsMatch(base, ours)
+	theirsMatch := lcsMatch(base, theirs)
+
+	merged := []T{}
+	conflicts := 0
+	emit := func(o, a, b []T) {
+		switch {
+		case equalLines(a, o):
+			merged = append(merged, b...)
+		case equalLines(b, o), equalLines(a, b):
+			merged = append(merged, a...)
+		default:
+			conflicts++
+			if conflict == nil {
+				merged = append(merged, a...)
+				return
+			}
+			merged = append(merged, conflict(a, b)...)
+		}
+	}
+
+	i, ia, ib := 0, 0, 0
+	for {
+		// The next base line kept unchanged on both sides
+		j := i
+		for j < len(base) && (oursMatch[j] < 0 || theirsMatch[j] < 0) {
+			j++
+		}
+		if j == len(base) {
+			emit(base[i:], ours[ia:], theirs[ib:])
+			break
+		}
+		emit(base[i:j], ours[ia:oursMatch[j]], theirs[ib:theirsMatch[j]])
+		merged = append(merged, base[j])
+		i, ia, ib = j+1, oursMatch[j]+1, theirsMatch[j]+1
+	}
+
+	if len(merged) == 0 && ours == nil {
+		return nil, conflicts
+	}
+	return merged, conflicts
+}
+
+// lcsMatch maps each index of base to the index of the same line in other along a
+// longest common subsequence, or -1 when the line is not part of it
+func lcsMatch[T comparable](base, other []T) []int {
+	n, m := len(base), len(other)
+	lengths := make([][]int, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if base[i] == other[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	match := make([]int, n)
+	i, j := 0, 0
+	for i < n {
+		switch {
+		case j < m && base[i] == other[j]:
+			match[i] = j
+			i++
+			j++
+		case j < m && lengths[i][j+1] > lengths[i+1][j]:
+			j++
+		default:
+			match[i] = -1
+			i++
+		}
+	}
+	return match
+}
+
+// equalLines reports whether two lists hold the same lines
+func equalLines[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// conflictCommands wraps the hand-edited commands of a conflicting region in conflict
+// markers, followed by the regenerated commands as comments
+func conflictCommands(ours, theirs []TaskCmd) []TaskCmd {
+	marked := append([]TaskCmd{{Cmd: conflictStartMarker}}, ours...)
+	alternative := []string{conflictSplitMarker}
+	for _, cmd := range theirs {
+		alternative = append(alternative, commentOut(cmd.String()))
+	}
+	alternative = append(alternative, conflictEndMarker)
+	return append(marked, TaskCmd{Cmd: strings.Join(alternative, "\n")})
+}
+
+// commentOut turns a (possibly multi-line) command into shell comments
+func commentOut(cmd string) string {
+	return "#   " + strings.ReplaceAll(cmd, "\n", "\n#   ")
+}
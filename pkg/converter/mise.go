@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// targetMise writes mise.toml tasks running the standalone scripts
+const targetMise = "mise"
+
+// miseFileName is the mise config written with -target mise
+const miseFileName = "mise.toml"
+
+// miseTools maps the tools of the inventory to mise's names for them
+var miseTools = map[string]string{
+	"node": "node", "go": "go", "python": "python", "ruby": "ruby", "java": "java",
+	"rust": "rust", "php": "php", "terraform": "terraform", "awscli": "aws-cli",
+	"google-cloud-sdk": "gcloud", "elixir": "elixir", "clojure": "clojure",
+	"kubectl": "kubectl", "helm": "helm", "deno": "deno",
+}
+
+// tomlBareKeyRegex matches the TOML keys that need no quotes
+var tomlBareKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlString returns s as a TOML basic string; JSON strings are valid ones
+func tomlString(s string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// tomlKey returns name as a TOML key, quoted when it is not a bare key
+func tomlKey(name string) string {
+	if tomlBareKeyRegex.MatchString(name) {
+		return name
+	}
+	return tomlString(name)
+}
+
+// miseToolVersions returns the mise tools the primary images of the jobs pin, each with
+// a comment naming the image, in job order. Jobs pinning another version of a tool
+// already pinned are reported, as mise.toml holds one version per tool.
+func miseToolVersions(config CircleCIConfig, report *ConversionReport) []string {
+	pinned := make(map[string]string)
+	var tools []string
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		resolved, err := resolveJobExecutor(config.Jobs[jobName], config.Executors)
+		if err != nil || len(resolved.Images) == 0 {
+			continue
+		}
+		tool, version := imageToolVersion(resolved.Images[0])
+		name, ok := miseTools[tool]
+		if !ok || strings.Contains(version, "<<") {
+			continue
+		}
+		if existing, ok := pinned[name]; ok {
+			if existing != version {
+				report.Add("mise", jobName, "image %s pins %s %s, but mise.toml pins %s", resolved.Images[0], name, version, existing)
+			}
+			continue
+		}
+		pinned[name] = version
+		tools = append(tools, fmt.Sprintf("%s = %s # image %s (job %s)", tomlKey(name), tomlString(version), resolved.Images[0], jobName))
+	}
+	return tools
+}
+
+// miseEmitter writes mise.toml
+type miseEmitter struct{}
+
+func (miseEmitter) Name() string { return targetMise }
+
+func (miseEmitter) Emit(out emission) ([]string, error) {
+	if err := generateMiseToml(out.Config, out.NewConfig, out.Taskfile, out.OutputDir, out.Report); err != nil {
+		return nil, fmt.Errorf("error writing %s: %w", miseFileName, err)
+	}
+	return []string{miseFileName + " (mise tools and tasks running the scripts)"}, nil
+}
+
+// generateMiseToml writes mise.toml: the tool versions the executor images pin, and a
+// task per script, which `mise run <job>` runs with NAME=value arguments passed on
+func generateMiseToml(config, newConfig CircleCIConfig, taskfile Taskfile, outputDir string, report *ConversionReport) error {
+	var b strings.Builder
+	b.WriteString("# Generated by circle-to-task. Tool versions come from the jobs' executor images;\n")
+	b.WriteString(fmt.Sprintf("# the tasks run the standalone scripts in %s/.\n", scriptsDir))
+	if tools := miseToolVersions(config, report); len(tools) > 0 {
+		b.WriteString("\n[tools]\n" + strings.Join(tools, "\n") + "\n")
+	}
+
+	for _, name := range scriptTasks(taskfile, config, newConfig) {
+		b.WriteString(fmt.Sprintf("\n[tasks.%s]\n", tomlKey(name)))
+		if desc := taskfile.Tasks[name].Desc; desc != "" {
+			b.WriteString(fmt.Sprintf("description = %s\n", tomlString(desc)))
+		}
+		b.WriteString(fmt.Sprintf("run = %s\n", tomlString(scriptFile(name))))
+	}
+	return os.WriteFile(filepath.Join(outputDir, miseFileName), []byte(b.String()), 0644)
+}
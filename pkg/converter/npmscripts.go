@@ -0,0 +1,217 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// targetNpmScripts adds package.json scripts running the standalone scripts
+const targetNpmScripts = "npm-scripts"
+
+// npmScriptPrefix namespaces the converted scripts that clash with existing ones
+const npmScriptPrefix = "ci:"
+
+// Strategies for converted scripts named like a script package.json already has
+const (
+	npmConflictPrefix    = "prefix"    // add the converted script as ci:<job>
+	npmConflictSkip      = "skip"      // keep the existing script, leave the job out
+	npmConflictOverwrite = "overwrite" // replace the existing script
+)
+
+// nodeBinaries are the commands that make a job a Node job
+var nodeBinaries = map[string]bool{"node": true, "npm": true, "npx": true, "yarn": true, "pnpm": true}
+
+// nodeJobs returns how many of the jobs run Node: on a node image, or calling node or
+// its package managers
+func nodeJobs(config CircleCIConfig, taskfile Taskfile) (int, int) {
+	count := 0
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		isNode := false
+		if resolved, err := resolveJobExecutor(config.Jobs[jobName], config.Executors); err == nil && len(resolved.Images) > 0 {
+			tool, _ := imageToolVersion(resolved.Images[0])
+			isNode = tool == "node" || strings.Contains(resolved.Images[0], "/node:")
+		}
+		for _, cmd := range taskfile.Tasks[jobName].Cmds {
+			for _, binary := range commandBinaries(cmd.Cmd) {
+				isNode = isNode || nodeBinaries[binary]
+			}
+		}
+		if isNode {
+			count++
+		}
+	}
+	return count, len(config.Jobs)
+}
+
+// npmScriptsHint suggests -target npm-scripts when at least four jobs in five run Node
+func npmScriptsHint(config CircleCIConfig, taskfile Taskfile) string {
+	node, total := nodeJobs(config, taskfile)
+	if total == 0 || node*5 < total*4 {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d jobs run Node; -target %s would run them from package.json scripts, without go-task", node, total, targetNpmScripts)
+}
+
+// jsonMember is a member of a JSON object, kept in the order it was written
+type jsonMember struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeJSONObject decodes a JSON object into its members, in order
+func decodeJSONObject(data []byte) ([]jsonMember, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	var members []jsonMember
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		members = append(members, jsonMember{Key: token.(string), Value: value})
+	}
+	return members, nil
+}
+
+// encodeJSONObject encodes members as an indented JSON object
+func encodeJSONObject(members []jsonMember, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, member := range members {
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n" + indent)
+		b.Write(key)
+		b.WriteString(": ")
+		if err := json.Indent(&b, member.Value, indent, indent); err != nil {
+			return nil, err
+		}
+	}
+	if len(members) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// jsonIndent returns the indentation of the first member of a JSON document, two
+// spaces when it has none
+func jsonIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" {
+			if indent := line[:len(line)-len(trimmed)]; indent != "" {
+				return indent
+			}
+			break
+		}
+	}
+	return "  "
+}
+
+// jsonString encodes s as a JSON string without escaping HTML characters
+func jsonString(s string) json.RawMessage {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// npmScriptsEmitter adds the jobs to package.json scripts
+type npmScriptsEmitter struct{}
+
+func (npmScriptsEmitter) Name() string { return targetNpmScripts }
+
+func (npmScriptsEmitter) Emit(out emission) ([]string, error) {
+	if err := mergeNpmScripts(out.Config, out.Project, out.OutputDir, out.Settings.NpmConflict, out.Report); err != nil {
+		return nil, fmt.Errorf("error writing package.json scripts: %w", err)
+	}
+	return []string{"package.json (scripts running the jobs)"}, nil
+}
+
+// mergeNpmScripts adds a script per job to the package.json in the output directory,
+// creating one when there is none, with the other members and scripts kept in their
+// order. A job named like an existing script is handled by the conflict strategy, and
+// reported.
+func mergeNpmScripts(config CircleCIConfig, project, outputDir, conflict string, report *ConversionReport) error {
+	path := filepath.Join(outputDir, "package.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data = []byte(fmt.Sprintf("{\n  \"name\": %s,\n  \"private\": true\n}\n", jsonString(project)))
+	} else if err != nil {
+		return err
+	}
+	members, err := decodeJSONObject(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	scriptsAt := -1
+	var scripts []jsonMember
+	for i, member := range members {
+		if member.Key == "scripts" {
+			scriptsAt = i
+			if scripts, err = decodeJSONObject(member.Value); err != nil {
+				return fmt.Errorf("%s: scripts: %w", path, err)
+			}
+		}
+	}
+	existing := make(map[string]int)
+	for i, script := range scripts {
+		existing[script.Key] = i
+	}
+
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		command := jsonString("bash " + scriptFile(jobName))
+		name := jobName
+		if i, ok := existing[name]; ok && !bytes.Equal(scripts[i].Value, command) {
+			switch conflict {
+			case npmConflictSkip:
+				report.Add("npm scripts", jobName, "package.json already has a %s script, which was kept; run the job with `bash %s`", name, scriptFile(jobName))
+				continue
+			case npmConflictOverwrite:
+				report.Add("npm scripts", jobName, "replaced the existing %s script of package.json", name)
+			default:
+				name = npmScriptPrefix + jobName
+				report.Add("npm scripts", jobName, "package.json already has a %s script; the job is `npm run %s`", jobName, name)
+			}
+		}
+		if i, ok := existing[name]; ok {
+			scripts[i].Value = command
+			continue
+		}
+		existing[name] = len(scripts)
+		scripts = append(scripts, jsonMember{Key: name, Value: command})
+	}
+
+	indent := jsonIndent(data)
+	encoded, err := encodeJSONObject(scripts, indent)
+	if err != nil {
+		return err
+	}
+	if scriptsAt == -1 {
+		members = append(members, jsonMember{Key: "scripts", Value: encoded})
+	} else {
+		members[scriptsAt].Value = encoded
+	}
+	out, err := encodeJSONObject(members, indent)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0644)
+}
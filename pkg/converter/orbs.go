@@ -0,0 +1,458 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultCircleCIHost is the CircleCI cloud host; CircleCI Server installs use their own
+const defaultCircleCIHost = "https://circleci.com"
+
+// pinnedOrbRegex matches orb references with a full semver version. Only those are
+// cached, since their source never changes; volatile and partial versions move.
+var pinnedOrbRegex = regexp.MustCompile(`@\d+\.\d+\.\d+$`)
+
+// OrbSource is the parsed body of an orb
+type OrbSource struct {
+	Orbs      map[string]interface{} `yaml:"orbs,omitempty"`
+	Commands  map[string]Command     `yaml:"commands,omitempty"`
+	Jobs      map[string]Job         `yaml:"jobs,omitempty"`
+	Executors map[string]interface{} `yaml:"executors,omitempty"`
+}
+
+// OrbResolver loads orb sources from a vendor directory or the CircleCI registry
+type OrbResolver struct {
+	VendorDir   string // directory of vendored orbs (<namespace>/<name>@<version>.yml)
+	CacheDir    string // cache of pinned orbs fetched from the registry; empty disables it
+	Offline     bool   // never touch the network; only use VendorDir
+	RegistryURL string // GraphQL endpoint serving orb sources
+	Token       string // API token, required by most CircleCI Server installations
+	Client      *http.Client
+
+	sources map[string][]byte // already loaded sources by reference
+}
+
+// NewOrbResolver creates a resolver for the given CircleCI host (empty for circleci.com)
+func NewOrbResolver(vendorDir string, offline bool, host, token string) *OrbResolver {
+	if host == "" {
+		host = defaultCircleCIHost
+	}
+	if token == "" {
+		token = os.Getenv("CIRCLECI_CLI_TOKEN")
+	}
+	return &OrbResolver{
+		VendorDir:   vendorDir,
+		CacheDir:    defaultOrbCacheDir(),
+		Offline:     offline,
+		RegistryURL: strings.TrimRight(host, "/") + "/graphql-unstable",
+		Token:       token,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// defaultOrbCacheDir is circle-to-task/orbs in the user cache directory
+// (~/.cache on Linux, ~/Library/Caches on macOS), or empty when there is none
+func defaultOrbCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "circle-to-task", "orbs")
+}
+
+// Source returns the YAML source of an orb reference like circleci/node@5.1.0
+func (r *OrbResolver) Source(ref string) ([]byte, error) {
+	if data, ok := r.sources[ref]; ok {
+		return data, nil
+	}
+
+	data, err := r.load(ref)
+	if err != nil {
+		return nil, err
+	}
+	if r.sources == nil {
+		r.sources = make(map[string][]byte)
+	}
+	r.sources[ref] = data
+	return data, nil
+}
+
+// load reads an orb source from the vendor directory, falling back to the registry
+func (r *OrbResolver) load(ref string) ([]byte, error) {
+	if r.VendorDir != "" {
+		data, err := os.ReadFile(vendorPath(r.VendorDir, ref))
+		if err == nil {
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading vendored orb %s: %w", ref, err)
+		}
+	}
+
+	if r.Offline {
+		return nil, fmt.Errorf("orb %s is not vendored in %s (offline mode; run 'circle-to-task orbs vendor' while online)", ref, r.VendorDir)
+	}
+
+	cached := r.CacheDir != "" && pinnedOrbRegex.MatchString(ref)
+	if cached {
+		if data, err := os.ReadFile(vendorPath(r.CacheDir, ref)); err == nil {
+			logger.Debug("orb loaded from cache", "orb", ref)
+			return data, nil
+		}
+	}
+
+	data, err := r.fetch(ref)
+	if err != nil {
+		return nil, err
+	}
+	if cached {
+		// A cache that cannot be written only costs the next run a download
+		if err := writeOrbCache(r.CacheDir, ref, data); err != nil {
+			logger.Warn("could not cache orb", "orb", ref, "error", err)
+		}
+	}
+	return data, nil
+}
+
+// writeOrbCache stores a fetched orb source in the cache directory
+func writeOrbCache(dir, ref string, data []byte) error {
+	path := vendorPath(dir, ref)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// fetch downloads an orb source from the registry
+func (r *OrbResolver) fetch(ref string) ([]byte, error) {
+	query := map[string]interface{}{
+		"query":     "query($ref: String!) { orbVersion(orbVersionRef: $ref) { version source } }",
+		"variables": map[string]string{"ref": ref},
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.RegistryURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "circle-to-task/"+Version)
+	if r.Token != "" {
+		req.Header.Set("Authorization", r.Token)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching orb %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("error fetching orb %s: registry returned %s: %s", ref, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Data struct {
+			OrbVersion *struct {
+				Source string `json:"source"`
+			} `json:"orbVersion"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding registry response for %s: %w", ref, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("error fetching orb %s: %s", ref, result.Errors[0].Message)
+	}
+	if result.Data.OrbVersion == nil || result.Data.OrbVersion.Source == "" {
+		return nil, fmt.Errorf("orb %s not found in registry", ref)
+	}
+
+	return []byte(result.Data.OrbVersion.Source), nil
+}
+
+// vendorPath returns where a vendored orb reference is stored
+func vendorPath(dir, ref string) string {
+	return filepath.Join(dir, filepath.FromSlash(ref)+".yml")
+}
+
+// resolveOrbs inlines the commands, executors and jobs of every orb used by the config.
+// Inlined names are prefixed with the orb alias (node/install-packages).
+func resolveOrbs(config *CircleCIConfig, resolver *OrbResolver, report *ConversionReport) {
+	if len(config.Orbs) == 0 || resolver == nil {
+		return
+	}
+
+	// Copy the maps so the caller's config is left untouched
+	commands := make(map[string]Command, len(config.Commands))
+	for name, command := range config.Commands {
+		commands[name] = command
+	}
+	executors := make(map[string]interface{}, len(config.Executors))
+	for name, executor := range config.Executors {
+		executors[name] = executor
+	}
+	config.Commands = commands
+	config.Executors = executors
+	config.orbJobs = make(map[string]Job)
+
+	for _, alias := range sortedKeys(config.Orbs) {
+		orb, err := loadOrb(config.Orbs[alias], resolver)
+		if err != nil {
+			report.Add("Orbs", alias, "not resolved; well-known steps use built-in equivalents, the others stay as stubs: %v", err)
+			continue
+		}
+		inlineOrb(config, alias+"/", orb, resolver, report, 0)
+		report.Add("Orbs", alias, "inlined %d commands, %d executors and %d jobs", len(orb.Commands), len(orb.Executors), len(orb.Jobs))
+	}
+}
+
+// loadOrb parses an orb from a registry reference or an inline orb definition
+func loadOrb(value interface{}, resolver *OrbResolver) (OrbSource, error) {
+	var orb OrbSource
+
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		source, err := resolver.Source(v)
+		if err != nil {
+			return orb, err
+		}
+		data = source
+	case map[string]interface{}:
+		inline, err := yaml.Marshal(v)
+		if err != nil {
+			return orb, err
+		}
+		data = inline
+	default:
+		return orb, fmt.Errorf("unexpected orb definition %v", value)
+	}
+
+	if err := yaml.Unmarshal(data, &orb); err != nil {
+		return orb, fmt.Errorf("error parsing orb source: %w", err)
+	}
+	return orb, nil
+}
+
+// inlineOrb copies an orb's commands, executors and jobs into the config under prefix,
+// rewriting references between orb elements (and nested orbs) to the prefixed names
+func inlineOrb(config *CircleCIConfig, prefix string, orb OrbSource, resolver *OrbResolver, report *ConversionReport, depth int) {
+	if depth > 5 {
+		report.Add("Orbs", strings.TrimSuffix(prefix, "/"), "nested orbs deeper than 5 levels were not resolved")
+		return
+	}
+
+	local := make(map[string]bool)
+	for name := range orb.Commands {
+		local[name] = true
+	}
+	for name := range orb.Executors {
+		local[name] = true
+	}
+	for alias := range orb.Orbs {
+		local[alias] = true
+	}
+
+	for _, alias := range sortedKeys(orb.Orbs) {
+		nested, err := loadOrb(orb.Orbs[alias], resolver)
+		if err != nil {
+			report.Add("Orbs", prefix+alias, "nested orb not resolved: %v", err)
+			continue
+		}
+		inlineOrb(config, prefix+alias+"/", nested, resolver, report, depth+1)
+	}
+
+	for name, command := range orb.Commands {
+		command.Steps = prefixOrbSteps(command.Steps, prefix, local)
+		config.Commands[prefix+name] = command
+	}
+	for name, executor := range orb.Executors {
+		config.Executors[prefix+name] = executor
+	}
+	for name, job := range orb.Jobs {
+		job.Steps = prefixOrbSteps(job.Steps, prefix, local)
+		switch executor := job.Executor.(type) {
+		case string:
+			job.Executor = prefixOrbName(executor, prefix, local)
+		case map[string]interface{}:
+			copied := make(map[string]interface{}, len(executor))
+			for key, value := range executor {
+				copied[key] = value
+			}
+			if executorName, ok := executor["name"].(string); ok {
+				copied["name"] = prefixOrbName(executorName, prefix, local)
+			}
+			job.Executor = copied
+		}
+		config.orbJobs[prefix+name] = job
+	}
+}
+
+// prefixOrbSteps rewrites step references to orb-local commands to their prefixed names
+func prefixOrbSteps(steps []Step, prefix string, local map[string]bool) []Step {
+	result := make([]Step, len(steps))
+	for i, step := range steps {
+		result[i] = prefixOrbStep(step, prefix, local)
+	}
+	return result
+}
+
+func prefixOrbStep(step Step, prefix string, local map[string]bool) Step {
+	switch v := step.(type) {
+	case string:
+		return prefixOrbName(v, prefix, local)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if key == "when" || key == "unless" {
+				if block, ok := value.(map[string]interface{}); ok {
+					copied := make(map[string]interface{}, len(block))
+					for k, item := range block {
+						copied[k] = item
+					}
+					if nested, ok := block["steps"].([]interface{}); ok {
+						var nestedSteps []interface{}
+						for _, nestedStep := range nested {
+							nestedSteps = append(nestedSteps, prefixOrbStep(nestedStep, prefix, local))
+						}
+						copied["steps"] = nestedSteps
+					}
+					value = copied
+				}
+			}
+			result[prefixOrbName(key, prefix, local)] = value
+		}
+		return result
+	}
+	return step
+}
+
+// prefixOrbName prefixes a command name or nested-orb reference defined inside the orb
+func prefixOrbName(name, prefix string, local map[string]bool) string {
+	head, _, _ := strings.Cut(name, "/")
+	if local[name] || (strings.Contains(name, "/") && local[head]) {
+		return prefix + name
+	}
+	return name
+}
+
+// userDefinedOnly drops inlined orb entries (whose names contain "/") from a map
+func userDefinedOnly(entries map[string]interface{}) map[string]interface{} {
+	if entries == nil {
+		return nil
+	}
+	result := make(map[string]interface{})
+	for name, value := range entries {
+		if !strings.Contains(name, "/") {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orbReferences collects every registry orb reference used by a config, including nested ones
+func orbReferences(orbs map[string]interface{}, resolver *OrbResolver, seen map[string]bool) []string {
+	var refs []string
+	for _, alias := range sortedKeys(orbs) {
+		switch v := orbs[alias].(type) {
+		case string:
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			refs = append(refs, v)
+			if orb, err := loadOrb(v, resolver); err == nil {
+				refs = append(refs, orbReferences(orb.Orbs, resolver, seen)...)
+			}
+		case map[string]interface{}:
+			if nested, ok := v["orbs"].(map[string]interface{}); ok {
+				refs = append(refs, orbReferences(nested, resolver, seen)...)
+			}
+		}
+	}
+	return refs
+}
+
+// runOrbsCommand implements the `orbs` subcommand
+func runOrbsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "vendor" {
+		return fmt.Errorf("usage: circle-to-task orbs vendor -input <config.yml> [-dir orbs]")
+	}
+
+	fs := flag.NewFlagSet("orbs vendor", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input CircleCI config file (required)")
+	vendorDir := fs.String("dir", "orbs", "Directory to write vendored orb sources to")
+	host := fs.String("circleci-host", os.Getenv("CIRCLECI_CLI_HOST"), "CircleCI host, for CircleCI Server installations (default https://circleci.com)")
+	token := fs.String("circleci-token", "", "CircleCI API token (default $CIRCLECI_CLI_TOKEN)")
+	fs.Parse(args[1:])
+
+	if *inputFile == "" {
+		fs.Usage()
+		return fmt.Errorf("-input is required")
+	}
+
+	data, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseConfig(*inputFile, data)
+	if err != nil {
+		return err
+	}
+
+	// Fetch from the registry, but reuse anything already vendored
+	resolver := NewOrbResolver(*vendorDir, false, *host, *token)
+	refs := orbReferences(config.Orbs, resolver, make(map[string]bool))
+	if len(refs) == 0 {
+		fmt.Println("No registry orbs referenced - nothing to vendor")
+		return nil
+	}
+
+	for _, ref := range refs {
+		source, err := resolver.Source(ref)
+		if err != nil {
+			return err
+		}
+		path := vendorPath(*vendorDir, ref)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("error creating vendor directory: %w", err)
+		}
+		if err := os.WriteFile(path, source, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", path, err)
+		}
+		fmt.Printf("📦 Vendored %s → %s\n", ref, path)
+	}
+
+	fmt.Printf("✅ Vendored %d orbs into %s (use -offline to convert without network access)\n", len(refs), *vendorDir)
+	return nil
+}
@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// paramType returns the declared type of a parameter definition (string when unset)
+func paramType(paramDef interface{}) string {
+	if def, ok := paramDef.(map[string]interface{}); ok {
+		if kind, ok := def["type"].(string); ok {
+			return kind
+		}
+	}
+	return "string"
+}
+
+// parameterTypes maps the references to a set of parameters (parameters.deploy,
+// pipeline.parameters.nightly) to their declared types
+func parameterTypes(prefix string, params map[string]interface{}) map[string]string {
+	types := make(map[string]string, len(params))
+	for name, def := range params {
+		types[prefix+name] = paramType(def)
+	}
+	return types
+}
+
+// paramVar returns the task variable of a parameter: the value passed by the caller,
+// or the declared default. Integer and number defaults are not quoted.
+func paramVar(name string, paramDef interface{}) string {
+	defaultValue := ""
+	if def, ok := paramDef.(map[string]interface{}); ok && def["default"] != nil {
+		defaultValue = formatParamValue(paramDef, def["default"])
+	}
+	if kind := paramType(paramDef); (kind == "integer" || kind == "number") && defaultValue != "" {
+		return fmt.Sprintf("{{.%s | default %s}}", taskVarName(name), defaultValue)
+	}
+	return fmt.Sprintf("{{.%s | default \"%s\"}}", taskVarName(name), defaultValue)
+}
+
+// paramVars converts the parameters of a job or command to task variables. Executor
+// and steps parameters are resolved at conversion time and get no variable.
+func paramVars(params map[string]interface{}) map[string]string {
+	vars := make(map[string]string)
+	for name, def := range params {
+		if _, ok := def.(map[string]interface{}); !ok {
+			continue
+		}
+		if kind := paramType(def); kind == "executor" || kind == "steps" {
+			continue
+		}
+		vars[taskVarName(name)] = paramVar(name, def)
+	}
+	return vars
+}
+
+// enumPreconditions check that enum parameters hold one of their declared values, as
+// CircleCI does when it compiles the config
+func enumPreconditions(params map[string]interface{}) []Precondition {
+	var preconditions []Precondition
+	for _, name := range sortedKeys(params) {
+		def, ok := params[name].(map[string]interface{})
+		if !ok || def["type"] != "enum" {
+			continue
+		}
+		values, _ := def["enum"].([]interface{})
+		if len(values) == 0 {
+			continue
+		}
+		var allowed []string
+		for _, value := range values {
+			allowed = append(allowed, fmt.Sprintf("%v", value))
+		}
+		quoted := make([]string, len(allowed))
+		for i, value := range allowed {
+			quoted[i] = shellQuote(value)
+		}
+		preconditions = append(preconditions, Precondition{
+			Sh:  fmt.Sprintf(`case "{{.%s}}" in %s) true ;; *) false ;; esac`, taskVarName(name), strings.Join(quoted, "|")),
+			Msg: fmt.Sprintf("%s must be one of: %s", taskVarName(name), strings.Join(allowed, ", ")),
+		})
+	}
+	return preconditions
+}
+
+// stepsParameter returns the default steps of a `- steps: << parameters.x >>` step,
+// when x is a steps parameter. The steps are inlined in place of the step.
+func stepsParameter(step Step, params map[string]interface{}) ([]Step, bool) {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok || len(stepMap) != 1 {
+		return nil, false
+	}
+	ref, ok := stepMap["steps"].(string)
+	if !ok {
+		return nil, false
+	}
+	match := conditionRefRegex.FindStringSubmatch(strings.TrimSpace(ref))
+	if match == nil || !strings.HasPrefix(match[1], "parameters.") {
+		return nil, false
+	}
+	def, ok := params[strings.TrimPrefix(match[1], "parameters.")].(map[string]interface{})
+	if !ok || def["type"] != "steps" {
+		return nil, false
+	}
+	defaults, _ := def["default"].([]interface{})
+	steps := make([]Step, len(defaults))
+	for i, s := range defaults {
+		steps[i] = s
+	}
+	return steps, true
+}
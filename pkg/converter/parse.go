@@ -0,0 +1,319 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError describes a config parsing failure with its location in the source
+type ParseError struct {
+	File    string
+	Line    int // 1-based, 0 when unknown
+	Column  int // 1-based, 0 when unknown
+	Message string
+	Snippet string
+	Hint    string
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+
+	location := e.File
+	if e.Line > 0 {
+		location = fmt.Sprintf("%s:%d", location, e.Line)
+		if e.Column > 0 {
+			location = fmt.Sprintf("%s:%d", location, e.Column)
+		}
+	}
+	b.WriteString(fmt.Sprintf("%s: %s\n", location, e.Message))
+
+	if e.Snippet != "" {
+		b.WriteString(e.Snippet)
+	}
+	if e.Hint != "" {
+		b.WriteString(fmt.Sprintf("hint: %s\n", e.Hint))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+var yamlLineRegex = regexp.MustCompile(`line (\d+): `)
+
+// Input formats: the CI systems whose configs are converted. fromAuto picks one from
+// the path and contents of the input.
+const (
+	fromAuto     = "auto"
+	fromCircleCI = "circleci"
+)
+
+// inputFormatNames name the CI systems of the input formats
+var inputFormatNames = map[string]string{
+	fromCircleCI:  "CircleCI",
+	fromGitHub:    "GitHub Actions",
+	fromGitLab:    "GitLab CI",
+	fromTravis:    "Travis CI",
+	fromBitbucket: "Bitbucket Pipelines",
+	fromJenkins:   "Jenkins",
+	fromAzure:     "Azure Pipelines",
+}
+
+// inputFileNames are the usual file names of the input formats, which name the slim
+// config of a config read from stdin
+var inputFileNames = map[string]string{
+	fromGitHub:    "workflow.yml",
+	fromGitLab:    ".gitlab-ci.yml",
+	fromTravis:    ".travis.yml",
+	fromBitbucket: "bitbucket-pipelines.yml",
+	fromJenkins:   "Jenkinsfile",
+	fromAzure:     "azure-pipelines.yml",
+}
+
+// stdinSource names the config read from stdin with -input - in messages
+const stdinSource = "<stdin>"
+
+// inputFormatName names the CI system of a parsed config
+func inputFormatName(config CircleCIConfig) string {
+	if config.format == "" {
+		return inputFormatNames[fromCircleCI]
+	}
+	return inputFormatNames[config.format]
+}
+
+// relabelTaskSources names the CI system of a translated config in the descriptions
+// of the tasks converted from its jobs
+func relabelTaskSources(taskfile *Taskfile, system string) {
+	for name, task := range taskfile.Tasks {
+		task.Desc = strings.Replace(task.Desc, "from CircleCI job", "from "+system+" job", 1)
+		taskfile.Tasks[name] = task
+	}
+}
+
+// detectInputFormat picks the format of an input: Jenkins for Jenkinsfiles and
+// documents opening a `pipeline {` block, GitHub Actions for files under
+// .github/workflows or documents with `on:` and `jobs:` but no CircleCI `version:`,
+// Travis CI for .travis.yml files or documents with a top-level `language:` or
+// `script:`, Bitbucket Pipelines for bitbucket-pipelines.yml files or documents with
+// `pipelines:` but no `version:`, Azure Pipelines for azure-pipelines.yml files or
+// documents without `version:` with a `pool:` or `trigger:`, or lists of stages, jobs
+// or steps, GitLab CI for .gitlab-ci.yml files or documents without `version:` whose
+// `stages:` or jobs with a `script:` say so, else CircleCI
+func detectInputFormat(file string, data []byte) string {
+	if base := filepath.Base(file); strings.HasPrefix(base, "Jenkinsfile") || strings.HasSuffix(strings.ToLower(base), ".jenkinsfile") || jenkinsfilePipelineRegex.Match(data) {
+		return fromJenkins
+	}
+	if strings.Contains(filepath.ToSlash(file), ".github/workflows/") {
+		return fromGitHub
+	}
+	if strings.HasSuffix(filepath.Base(file), ".gitlab-ci.yml") {
+		return fromGitLab
+	}
+	if filepath.Base(file) == ".travis.yml" {
+		return fromTravis
+	}
+	if filepath.Base(file) == "bitbucket-pipelines.yml" {
+		return fromBitbucket
+	}
+	if base := filepath.Base(file); base == "azure-pipelines.yml" || base == ".azure-pipelines.yml" || strings.Contains(filepath.ToSlash(file), ".azure-pipelines/") {
+		return fromAzure
+	}
+	var top map[string]interface{}
+	if yaml.Unmarshal(data, &top) != nil {
+		return fromCircleCI
+	}
+	_, hasOn := top["on"]
+	_, hasJobs := top["jobs"]
+	_, hasVersion := top["version"]
+	if hasOn && hasJobs && !hasVersion {
+		return fromGitHub
+	}
+	if !hasVersion && (top["language"] != nil || top["script"] != nil) {
+		return fromTravis
+	}
+	if _, ok := top["pipelines"]; ok && !hasVersion {
+		return fromBitbucket
+	}
+	if !hasVersion && (top["pool"] != nil || top["trigger"] != nil || azureList(top["stages"], "stage") || azureList(top["jobs"], "job") || azureList(top["steps"], "")) {
+		return fromAzure
+	}
+	if !hasVersion && !hasJobs {
+		if _, ok := top["stages"]; ok {
+			return fromGitLab
+		}
+		for _, value := range top {
+			if job, ok := value.(map[string]interface{}); ok && job["script"] != nil {
+				return fromGitLab
+			}
+		}
+	}
+	return fromCircleCI
+}
+
+// azureList reports whether a value is a list of mappings, with the key if one is
+// given, as the stages, jobs and steps of Azure Pipelines are
+func azureList(value interface{}, key string) bool {
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	first, ok := list[0].(map[string]interface{})
+	return ok && (key == "" || first[key] != nil)
+}
+
+// parseInput parses a config of the given format, detecting it with fromAuto. Configs
+// of other CI systems are translated into the CircleCI model the converter works on.
+func parseInput(file string, data []byte, from string) (CircleCIConfig, error) {
+	if from == fromAuto || from == "" {
+		from = detectInputFormat(file, data)
+	}
+	switch from {
+	case fromGitHub:
+		return parseGitHubWorkflow(file, data)
+	case fromGitLab:
+		return parseGitLabCI(file, data)
+	case fromTravis:
+		return parseTravisCI(file, data)
+	case fromBitbucket:
+		return parseBitbucketPipelines(file, data)
+	case fromJenkins:
+		return parseJenkinsfile(file, data)
+	case fromAzure:
+		return parseAzurePipelines(file, data)
+	default:
+		return parseConfig(file, data)
+	}
+}
+
+// parseConfig parses a CircleCI config, turning yaml errors into friendly ParseErrors
+func parseConfig(file string, data []byte) (CircleCIConfig, error) {
+	var config CircleCIConfig
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, yamlParseError(file, data, err)
+	}
+
+	// A config defining a `deploy` command means the command, not the deprecated step
+	if _, isCommand := config.Commands["deploy"]; !isCommand {
+		for _, job := range config.Jobs {
+			deployStepsAsRun(job.Steps)
+		}
+		for _, command := range config.Commands {
+			deployStepsAsRun(command.Steps)
+		}
+	}
+
+	var source yaml.Node
+	if err := yaml.Unmarshal(data, &source); err == nil {
+		config.source = &source
+	}
+
+	return config, nil
+}
+
+// yamlParseError turns a yaml error into a ParseError pointing at the failing line
+func yamlParseError(file string, data []byte, err error) error {
+	lines := strings.Split(string(data), "\n")
+
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	message = strings.TrimPrefix(message, "unmarshal errors:\n")
+	message = strings.TrimSpace(strings.Split(message, "\n")[0])
+
+	line := 0
+	if match := yamlLineRegex.FindStringSubmatch(message); match != nil {
+		line, _ = strconv.Atoi(match[1])
+		message = strings.Replace(message, match[0], "", 1)
+	}
+
+	// Tabs are the most common mistake and yaml reports them poorly, as a character that
+	// cannot start any token; only a tab indenting the failing line itself is blamed, since
+	// tabs elsewhere, like inside block scalars, are legal
+	if strings.Contains(message, "cannot start any token") && line > 0 && line <= len(lines) {
+		indent := lines[line-1][:len(lines[line-1])-len(strings.TrimLeft(lines[line-1], " \t"))]
+		if col := strings.Index(indent, "\t"); col != -1 {
+			return newParseError(file, lines, line, col+1, "tab character used for indentation")
+		}
+	}
+
+	// yaml reports no column; without one the snippet has no caret
+	column := 0
+	if strings.Contains(message, "mapping values are not allowed") && line > 0 && line <= len(lines) {
+		column = mappingValueColumn(lines[line-1])
+	}
+
+	return newParseError(file, lines, line, column, message)
+}
+
+// mappingValueColumn returns the column of the ": " yaml rejects in a line: the one
+// after the key's, which makes a plain value look like a mapping, or the only one when
+// the key continues a plain value from the line before. It returns 0 without any.
+func mappingValueColumn(line string) int {
+	var colons []int
+	for i := 0; i < len(line); i++ {
+		if line[i] == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t') {
+			colons = append(colons, i)
+		}
+	}
+	switch len(colons) {
+	case 0:
+		return 0
+	case 1:
+		return utf8.RuneCountInString(line[:colons[0]]) + 1
+	default:
+		return utf8.RuneCountInString(line[:colons[1]]) + 1
+	}
+}
+
+// newParseError builds a ParseError with a source snippet and a suggested fix
+func newParseError(file string, lines []string, line, column int, message string) *ParseError {
+	parseErr := &ParseError{
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Message: message,
+		Hint:    parseErrorHint(message),
+	}
+
+	if line > 0 && line <= len(lines) {
+		var snippet strings.Builder
+		start := line - 2
+		if start < 1 {
+			start = 1
+		}
+		for n := start; n <= line; n++ {
+			snippet.WriteString(fmt.Sprintf("%5d | %s\n", n, strings.ReplaceAll(lines[n-1], "\t", "→")))
+		}
+		if column > 0 {
+			snippet.WriteString(fmt.Sprintf("      | %s^\n", strings.Repeat(" ", column-1)))
+		}
+		parseErr.Snippet = snippet.String()
+	}
+
+	return parseErr
+}
+
+// parseErrorHint suggests a likely fix for common yaml error messages
+func parseErrorHint(message string) string {
+	switch {
+	case strings.Contains(message, "tab character"):
+		return "YAML does not allow tabs for indentation; replace them with spaces"
+	case strings.Contains(message, "cannot start any token"):
+		return "a plain value cannot start with @ or `; quote it"
+	case strings.Contains(message, "already defined"):
+		return "duplicate key; merge the two blocks or rename one of them"
+	case strings.Contains(message, "did not find expected key"),
+		strings.Contains(message, "did not find expected '-' indicator"):
+		return "check the indentation of this block; sibling keys must line up exactly"
+	case strings.Contains(message, "mapping values are not allowed"):
+		return "a value contains ': ' - quote it, or check for a missing newline/indentation"
+	case strings.Contains(message, "did not find expected"):
+		return "an inline list or map is not closed; check brackets and quotes"
+	case strings.Contains(message, "cannot unmarshal"):
+		return "this key has the wrong shape (e.g. a list where a map is expected); compare with the CircleCI config reference"
+	}
+	return ""
+}
@@ -1,4 +1,4 @@
-package main
+package converter
 
 import (
 	"fmt"
@@ -10,10 +10,15 @@ func analyzePatterns(config CircleCIConfig) map[string]Task {
 	patterns := make(map[string]Task)
 	commandCounts := make(map[string]int)
 	
-	// Count command occurrences across all jobs
+	// Count command occurrences across all jobs. Jobs with their own working directory
+	// or shell, and steps with their own environment, directory or shell, or running in
+	// the background, keep their commands inline.
 	for _, job := range config.Jobs {
+		if monorepoDir(job.WorkingDirectory) != "" || job.Shell != "" {
+			continue
+		}
 		for _, step := range job.Steps {
-			if cmd := extractCommand(step); cmd != "" {
+			if cmd := extractCommand(step); cmd != "" && runStepCommand(step) == cmd && !isBackgroundStep(step) {
 				// Normalize command for pattern matching
 				normalized := normalizeCommand(cmd)
 				commandCounts[normalized]++
@@ -27,7 +32,7 @@ func analyzePatterns(config CircleCIConfig) map[string]Task {
 			taskName := generateTaskName(cmd)
 			patterns[taskName] = Task{
 				Desc: fmt.Sprintf("Common task - used in %d jobs", count),
-				Cmds: []string{cmd},
+				Cmds: shellCommands(cmd),
 			}
 		}
 	}
@@ -73,7 +78,7 @@ func isCommonWord(word string) bool {
 // findPatternTask finds if a normalized command matches an existing pattern
 func findPatternTask(normalized string, patterns map[string]Task) string {
 	for taskName, task := range patterns {
-		if len(task.Cmds) > 0 && normalizeCommand(task.Cmds[0]) == normalized {
+		if len(task.Cmds) > 0 && normalizeCommand(task.Cmds[0].Cmd) == normalized {
 			return taskName
 		}
 	}
@@ -0,0 +1,151 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pipelineRefRegex matches << pipeline.* >> references, pipeline parameters included
+var pipelineRefRegex = regexp.MustCompile(`<<\s*(pipeline\.[A-Za-z0-9_.-]+)\s*>>`)
+
+// pipelineValue is a CircleCI pipeline value with its local equivalent: a global var
+// set from git where possible (honoring the CircleCI variable when it is set), or a
+// fixed value
+type pipelineValue struct {
+	Var   string
+	Sh    string
+	Value string
+}
+
+// pipelineValues are the pipeline values usable in commands and conditions, by reference
+var pipelineValues = map[string]pipelineValue{
+	"pipeline.id":                {Var: "PIPELINE_ID", Value: "local"},
+	"pipeline.number":            {Var: "PIPELINE_NUMBER", Sh: `git rev-list --count HEAD 2>/dev/null || echo 0`},
+	"pipeline.project.git_url":   {Var: "PIPELINE_PROJECT_GIT_URL", Sh: `git config --get remote.origin.url`},
+	"pipeline.project.type":      {Var: "PIPELINE_PROJECT_TYPE", Value: "github"},
+	"pipeline.git.branch":        {Var: "PIPELINE_GIT_BRANCH", Sh: `echo "` + localBranchExpr + `"`},
+	"pipeline.git.tag":           {Var: "PIPELINE_GIT_TAG", Sh: `echo "${CIRCLE_TAG:-$(git describe --tags --exact-match 2>/dev/null)}"`},
+	"pipeline.git.revision":      {Var: "PIPELINE_GIT_REVISION", Sh: `echo "${CIRCLE_SHA1:-$(git rev-parse HEAD 2>/dev/null)}"`},
+	"pipeline.git.base_revision": {Var: "PIPELINE_GIT_BASE_REVISION", Sh: `git rev-parse HEAD~1 2>/dev/null || true`},
+	"pipeline.trigger_source":    {Var: "PIPELINE_TRIGGER_SOURCE", Value: "local"},
+	"pipeline.schedule.name":     {Var: "PIPELINE_SCHEDULE_NAME", Value: ""},
+	"pipeline.schedule.id":       {Var: "PIPELINE_SCHEDULE_ID", Value: ""},
+	"pipeline.in_setup":          {Var: "PIPELINE_IN_SETUP", Value: "false"},
+}
+
+// pipelineRefVar returns the task variable holding a pipeline parameter or value
+func pipelineRefVar(ref string) (string, bool) {
+	if name := strings.TrimPrefix(ref, "pipeline.parameters."); name != ref {
+		return taskVarName(name), true
+	}
+	value, ok := pipelineValues[ref]
+	return value.Var, ok
+}
+
+// convertPipelineRefs replaces the pipeline parameter and value references of a
+// command with their task variables; references with no local equivalent are kept
+func convertPipelineRefs(cmd string) string {
+	return pipelineRefRegex.ReplaceAllStringFunc(cmd, func(ref string) string {
+		if name, ok := pipelineRefVar(pipelineRefRegex.FindStringSubmatch(ref)[1]); ok {
+			return "{{." + name + "}}"
+		}
+		return ref
+	})
+}
+
+// pipelineRefs lists the pipeline references used in values, sorted
+func pipelineRefs(values ...interface{}) []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for _, value := range values {
+		for _, match := range pipelineRefRegex.FindAllStringSubmatch(fmt.Sprintf("%v", value), -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				refs = append(refs, match[1])
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// addPipelineValueVars declares the pipeline values the config uses as global vars,
+// and reports references that have no local equivalent
+func addPipelineValueVars(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	for _, ref := range pipelineRefs(config.Jobs, config.Commands, config.orbJobs, config.Workflows) {
+		if strings.HasPrefix(ref, "pipeline.parameters.") {
+			continue
+		}
+		value, ok := pipelineValues[ref]
+		if !ok {
+			report.Add("Pipeline values", "", "`<< %s >>` has no local equivalent and is left as is", ref)
+			continue
+		}
+		if taskfile.Vars == nil {
+			taskfile.Vars = make(map[string]interface{})
+		}
+		if value.Sh != "" {
+			taskfile.Vars[value.Var] = DynamicVar{Sh: value.Sh}
+			report.Add("Pipeline values", "", "`<< %s >>` is the global var %s, from `%s`", ref, value.Var, value.Sh)
+		} else {
+			taskfile.Vars[value.Var] = value.Value
+			report.Add("Pipeline values", "", "`<< %s >>` is the global var %s (locally %q)", ref, value.Var, value.Value)
+		}
+	}
+}
+
+// addPipelineParameterVars declares the pipeline parameters as global Taskfile vars
+// holding their defaults; `task build TARGET=production` overrides one for a run.
+// Parameters without a default are empty, which conditions treat as false.
+func addPipelineParameterVars(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	if len(config.Parameters) == 0 {
+		return
+	}
+	if taskfile.Vars == nil {
+		taskfile.Vars = make(map[string]interface{})
+	}
+	for _, name := range sortedKeys(config.Parameters) {
+		paramDef := config.Parameters[name]
+		value := ""
+		if def, ok := paramDef.(map[string]interface{}); ok && def["default"] != nil {
+			value = formatParamValue(paramDef, def["default"])
+		}
+		taskfile.Vars[taskVarName(name)] = value
+		report.Add("Pipeline parameters", "", "`%s` is the global var %s (default %q); override it with `task <name> %s=...`", name, taskVarName(name), value, taskVarName(name))
+	}
+}
+
+// jobPipelineRefs lists the pipeline references with a local equivalent that a job's
+// steps use, directly or through the commands they invoke
+func jobPipelineRefs(job Job, commands map[string]Command) []string {
+	found := make(map[string]Command)
+	referencedCommands(job.Steps, commands, found)
+
+	sources := []interface{}{job.Steps}
+	for _, command := range found {
+		sources = append(sources, command.Steps)
+	}
+	var refs []string
+	for _, ref := range pipelineRefs(sources...) {
+		if _, ok := pipelineRefVar(ref); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// pipelineArgs passes the pipeline parameters and values a job uses to its task in the
+// slim config, where CircleCI fills in the pipeline's values
+func pipelineArgs(job Job, commands map[string]Command) string {
+	var args []string
+	for _, ref := range jobPipelineRefs(job, commands) {
+		name, _ := pipelineRefVar(ref)
+		args = append(args, fmt.Sprintf("%s='<< %s >>'", name, ref))
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return " " + strings.Join(args, " ")
+}
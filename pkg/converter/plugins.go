@@ -0,0 +1,144 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pluginPrefix names the executables of step-converter plugins: -plugin acme runs
+// circle-to-task-plugin-acme from PATH
+const pluginPrefix = "circle-to-task-plugin-"
+
+// pluginTimeout bounds each run of a plugin
+const pluginTimeout = 30 * time.Second
+
+// Plugin is an external step converter, run once per distinct step
+type Plugin struct {
+	Name string
+	Path string // executable
+}
+
+// PluginRequest is the JSON a plugin reads on stdin: one step of the config
+type PluginRequest struct {
+	Name string                 `json:"name"`           // step type, like acme/deploy or checkout
+	Args map[string]interface{} `json:"args,omitempty"` // arguments of the step
+	Step interface{}            `json:"step"`           // the step as written in the config
+	Job  string                 `json:"job"`            // job or command holding the step (the first, for steps repeated across them)
+}
+
+// PluginResponse is the JSON a plugin writes to stdout. Without commands the step is
+// left to the next plugin, and then converted as usual.
+type PluginResponse struct {
+	Commands []string `json:"commands,omitempty"`
+}
+
+// lookupPlugins finds the executables of the named plugins on PATH
+func lookupPlugins(names []string) ([]Plugin, error) {
+	var plugins []Plugin
+	for _, name := range names {
+		path, err := exec.LookPath(pluginPrefix + name)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", name, err)
+		}
+		plugins = append(plugins, Plugin{Name: name, Path: path})
+	}
+	return plugins, nil
+}
+
+// convert runs the plugin on one step and returns its commands
+func (p Plugin) convert(request PluginRequest) ([]string, error) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, firstLine(message))
+		}
+		return nil, err
+	}
+	var response PluginResponse
+	if strings.TrimSpace(stdout.String()) == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return response.Commands, nil
+}
+
+// applyPlugins replaces the steps the plugins convert with run steps of their commands,
+// in the jobs, commands and orb jobs of the config. Plugins are asked in order, the
+// first returning commands wins; run and when/unless steps are not sent, but the steps
+// nested in when/unless are. A plugin failing on a step is reported, and the step left
+// to the next one.
+func applyPlugins(config *CircleCIConfig, plugins []Plugin, report *ConversionReport) {
+	if len(plugins) == 0 {
+		return
+	}
+
+	// Identical steps get the answer of their first conversion
+	type answer struct {
+		command string
+		plugin  string
+	}
+	answers := make(map[string]answer)
+	converters := make(map[string]map[string]bool)
+	used := replaceConfigSteps(config, func(name string, step Step, args map[string]interface{}, owner string) (string, bool) {
+		if name == "run" || name == "when" || name == "unless" {
+			return "", false
+		}
+		key, _ := json.Marshal(step)
+		result, seen := answers[string(key)]
+		if !seen {
+			request := PluginRequest{Name: name, Args: args, Step: step, Job: owner}
+			for _, plugin := range plugins {
+				commands, err := plugin.convert(request)
+				if err != nil {
+					report.Add("Plugins", owner, "plugin %s failed on `%s`: %v; the step was left to the next plugin or the converter", plugin.Name, name, err)
+					continue
+				}
+				if len(commands) > 0 {
+					result = answer{command: strings.Join(commands, "\n"), plugin: plugin.Name}
+					break
+				}
+			}
+			answers[string(key)] = result
+		}
+		if result.plugin == "" {
+			return "", false
+		}
+		if converters[name] == nil {
+			converters[name] = make(map[string]bool)
+		}
+		converters[name][result.plugin] = true
+		return result.command, true
+	})
+
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var by []string
+		for plugin := range converters[name] {
+			by = append(by, plugin)
+		}
+		sort.Strings(by)
+		report.Add("Plugins", "", "`%s` converted by plugin %s in %s", name, strings.Join(by, ", "), strings.Join(uniqueStrings(used[name]), ", "))
+	}
+}
@@ -0,0 +1,218 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// InvocationSteps are the pre-steps and post-steps a workflow invocation adds around
+// its job's steps
+type InvocationSteps struct {
+	Pre  []Step
+	Post []Step
+}
+
+// IsEmpty reports whether the invocation adds no steps
+func (s InvocationSteps) IsEmpty() bool {
+	return len(s.Pre) == 0 && len(s.Post) == 0
+}
+
+// invocationSteps returns the pre-steps and post-steps of a workflow invocation
+func invocationSteps(invocation WorkflowJob) InvocationSteps {
+	return InvocationSteps{Pre: invocation.PreSteps, Post: invocation.PostSteps}
+}
+
+// withInvocationSteps returns a job's steps with pre-steps before and post-steps after them
+func withInvocationSteps(steps []Step, added InvocationSteps, commands map[string]Command) []Step {
+	all := make([]Step, 0, len(added.Pre)+len(steps)+len(added.Post))
+	all = append(all, commandCallSteps(added.Pre, commands)...)
+	all = append(all, steps...)
+	return append(all, commandCallSteps(added.Post, commands)...)
+}
+
+// commandCallSteps writes bare command names among steps as invocations without
+// arguments, so their tasks are called in place rather than run as dependencies:
+// pre-steps and post-steps have to keep their place around the job's steps
+func commandCallSteps(steps []Step, commands map[string]Command) []Step {
+	result := make([]Step, len(steps))
+	for i, step := range steps {
+		result[i] = step
+		if name, ok := step.(string); ok && isDefinedCommand(name, commands) {
+			result[i] = map[string]interface{}{name: map[string]interface{}{}}
+		}
+	}
+	return result
+}
+
+// sharedInvocationSteps maps each job whose invocations all add the same pre-steps and
+// post-steps to those steps. They become part of the job's own task; jobs whose
+// invocations differ get a task per invocation instead (see addInvocationStepTasks).
+func sharedInvocationSteps(workflows map[string]Workflow) map[string]InvocationSteps {
+	first := make(map[string]InvocationSteps)
+	differ := make(map[string]bool)
+	for _, invocation := range extractWorkflowJobs(workflows) {
+		added := invocationSteps(invocation.WorkflowJob)
+		if previous, seen := first[invocation.Job]; seen {
+			if !reflect.DeepEqual(previous, added) {
+				differ[invocation.Job] = true
+			}
+			continue
+		}
+		first[invocation.Job] = added
+	}
+
+	shared := make(map[string]InvocationSteps)
+	for job, added := range first {
+		if !differ[job] && !added.IsEmpty() {
+			shared[job] = added
+		}
+	}
+	return shared
+}
+
+// expandInvocationSteps adds the shared pre-steps and post-steps of local jobs (see
+// sharedInvocationSteps) to their steps, so their tasks run them like the jobs did on
+// CircleCI. It returns the jobs expanded.
+func expandInvocationSteps(config *CircleCIConfig, shared map[string]InvocationSteps, report *ConversionReport) map[string]InvocationSteps {
+	expanded := make(map[string]InvocationSteps)
+	// The caller's jobs are left as they are
+	jobs := make(map[string]Job, len(config.Jobs))
+	for name, job := range config.Jobs {
+		jobs[name] = job
+	}
+	for _, name := range sortedJobNames(config.Jobs) {
+		added, ok := shared[name]
+		if !ok {
+			continue
+		}
+		job := jobs[name]
+		job.Steps = withInvocationSteps(job.Steps, added, config.Commands)
+		jobs[name] = job
+		expanded[name] = added
+		report.Add("Pre/post steps", name, "the %d pre-steps and %d post-steps of its workflow invocations run in its task; the new config's workflows no longer add them", len(added.Pre), len(added.Post))
+	}
+	config.Jobs = jobs
+	return expanded
+}
+
+// withoutInvocationSteps returns the workflows with the pre-steps and post-steps of the
+// given jobs' invocations removed, for the new config whose job tasks run them
+func withoutInvocationSteps(workflows map[string]Workflow, jobs map[string]InvocationSteps) map[string]Workflow {
+	if len(jobs) == 0 {
+		return workflows
+	}
+	result := make(map[string]Workflow, len(workflows))
+	for name, workflow := range workflows {
+		result[name] = workflow
+		body, ok := workflow.raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entries, _ := body["jobs"].([]interface{})
+
+		newEntries := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			newEntries[i] = entry
+			invocation, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			newInvocation := make(map[string]interface{}, len(invocation))
+			for jobName, value := range invocation {
+				settings, ok := value.(map[string]interface{})
+				if _, expanded := jobs[jobName]; !ok || !expanded {
+					newInvocation[jobName] = value
+					continue
+				}
+				newSettings := make(map[string]interface{}, len(settings))
+				for key, setting := range settings {
+					if key != "pre-steps" && key != "post-steps" {
+						newSettings[key] = setting
+					}
+				}
+				newInvocation[jobName] = newSettings
+				if len(newSettings) == 0 && len(invocation) == 1 {
+					// Nothing left but the job name
+					newEntries[i] = jobName
+				}
+			}
+			if _, isName := newEntries[i].(string); !isName {
+				newEntries[i] = newInvocation
+			}
+		}
+
+		newBody := make(map[string]interface{}, len(body))
+		for key, value := range body {
+			newBody[key] = value
+		}
+		newBody["jobs"] = newEntries
+		workflow.raw = newBody
+		result[name] = workflow
+	}
+	return result
+}
+
+// invocationStepsTask names the task of an invocation adding pre-steps or post-steps
+// of its own: the invocation's `name:`, or the job name followed by the workflow's
+func invocationStepsTask(invocation WorkflowJobInvocation, shared map[string]InvocationSteps) (string, bool) {
+	if invocationSteps(invocation.WorkflowJob).IsEmpty() {
+		return "", false
+	}
+	if _, ok := shared[invocation.Job]; ok {
+		return "", false
+	}
+	if invocation.Name != "" && invocation.Name != invocation.Job {
+		return invocation.Name, true
+	}
+	return invocation.Job + "-" + invocation.Workflow, true
+}
+
+// addInvocationStepTasks adds a task for each invocation whose pre-steps and post-steps
+// differ from the other invocations of its job: it runs them around the job's task
+func addInvocationStepTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	shared := sharedInvocationSteps(config.Workflows)
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		name, ok := invocationStepsTask(invocation, shared)
+		if !ok {
+			continue
+		}
+		if invocation.Matrix != nil {
+			report.Add("Pre/post steps", invocation.Job, "matrix invocation in workflow %s adds its own pre-steps and post-steps; the variant tasks do not run them", invocation.Workflow)
+			continue
+		}
+		if _, exists := taskfile.Tasks[name]; exists {
+			continue
+		}
+
+		// The job's task, or the task of an invocation choosing its executor, which is
+		// given the other arguments
+		job := config.Jobs[invocation.Job]
+		call := jobTaskCall(invocation.Job, job, invocation.Arguments)
+		if executorTask, ok := executorInvocationTask(job, invocation.WorkflowJob); ok {
+			param, _ := executorParameter(job)
+			args := make(map[string]interface{}, len(invocation.Arguments))
+			for key, value := range invocation.Arguments {
+				if key != param {
+					args[key] = value
+				}
+			}
+			call = jobTaskCall(executorTask, job, args)
+		}
+		if _, exists := taskfile.Tasks[strings.Fields(call)[1]]; !exists {
+			report.Add("Pre/post steps", invocation.Job, "invocation %s adds pre-steps and post-steps, but the job has no task to run them around", name)
+			continue
+		}
+
+		added := invocationSteps(invocation.WorkflowJob)
+		var cmds TaskCmds
+		cmds = append(cmds, inlineStepCommands(name, commandCallSteps(added.Pre, config.Commands), config.Commands, config.Orbs, nil)...)
+		cmds = append(cmds, TaskCmd{Cmd: call})
+		cmds = append(cmds, inlineStepCommands(name, commandCallSteps(added.Post, config.Commands), config.Commands, config.Orbs, nil)...)
+		taskfile.Tasks[name] = Task{
+			Desc: fmt.Sprintf("Task converted from CircleCI job: %s (with the pre-steps and post-steps of workflow %s)", invocation.Job, invocation.Workflow),
+			Cmds: cmds,
+		}
+		report.Add("Pre/post steps", invocation.Job, "invocation %s in workflow %s adds its own pre-steps and post-steps; converted to task %s running them around %s", invocation.DisplayName(), invocation.Workflow, name, call)
+	}
+}
@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// defaultRemoteInput is the config path used with -repo when -input is not given
+const defaultRemoteInput = ".circleci/config.yml"
+
+// fetchRemoteConfig reads file from ref of a remote git repository without a full
+// clone: it makes a shallow, blobless clone with nothing checked out, so only the
+// commit, its trees and the one requested file are downloaded. ref is a branch or tag;
+// empty means the remote's default branch. Authentication is left to git (credential
+// helpers, SSH keys).
+func fetchRemoteConfig(repo, ref, file string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("-repo needs git on PATH: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "circle-to-task-remote-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"clone", "--quiet", "--depth", "1", "--filter=blob:none", "--no-checkout", "--single-branch"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	args = append(args, "--", repo, dir)
+	if _, err := runGit("", args...); err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", remoteSource(repo, ref, ""), err)
+	}
+
+	data, err := runGit(dir, "show", "HEAD:"+strings.TrimPrefix(path.Clean(file), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", remoteSource(repo, ref, file), err)
+	}
+	return data, nil
+}
+
+// runGit runs git in dir and returns its output; errors include git's stderr
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	// Never prompt: a missing credential should fail, not hang a batch audit
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// remoteSource describes a remote config for messages and reports, as repo@ref:file
+func remoteSource(repo, ref, file string) string {
+	source := repo
+	if ref != "" {
+		source += "@" + ref
+	}
+	if file != "" {
+		source += ":" + file
+	}
+	return source
+}
+
+// repoName returns the repository name of a git URL, for naming generated projects
+func repoName(repo string) string {
+	name := strings.TrimSuffix(strings.TrimRight(repo, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.ToLower(name)
+	if name == "" {
+		return "project"
+	}
+	return name
+}
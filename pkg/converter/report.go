@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReportEntry is a single note recorded while converting a config
+type ReportEntry struct {
+	Category string `json:"category"`
+	Job      string `json:"job,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ConversionReport collects notes about decisions made during conversion
+type ConversionReport struct {
+	Entries []ReportEntry
+}
+
+// Add records a note for the given category and job (job may be empty).
+// Suspected secrets in the message are redacted.
+func (r *ConversionReport) Add(category, job, format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	entry := ReportEntry{
+		Category: category,
+		Job:      job,
+		Message:  redactSecrets(fmt.Sprintf(format, args...)),
+	}
+	r.Entries = append(r.Entries, entry)
+	logger.Info(entry.Message, "category", category, "job", job)
+}
+
+// generateConversionReport writes CONVERSION_REPORT.md grouped by category
+func generateConversionReport(report *ConversionReport, outputDir string) error {
+	var content strings.Builder
+
+	content.WriteString("# Conversion Report\n\n")
+	content.WriteString("This file lists conversion decisions that may need manual review.\n\n")
+
+	if report == nil || len(report.Entries) == 0 {
+		content.WriteString("No notes - everything converted without special handling.\n")
+		return writeTextFile(filepath.Join(outputDir, "CONVERSION_REPORT.md"), content.String())
+	}
+
+	byCategory := make(map[string][]ReportEntry)
+	var categories []string
+	for _, entry := range report.Entries {
+		if _, seen := byCategory[entry.Category]; !seen {
+			categories = append(categories, entry.Category)
+		}
+		byCategory[entry.Category] = append(byCategory[entry.Category], entry)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		entries := byCategory[category]
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Job != entries[j].Job {
+				return entries[i].Job < entries[j].Job
+			}
+			return entries[i].Message < entries[j].Message
+		})
+
+		content.WriteString(fmt.Sprintf("## %s\n\n", category))
+		for _, entry := range entries {
+			if entry.Job != "" {
+				content.WriteString(fmt.Sprintf("- **%s**: %s\n", entry.Job, entry.Message))
+			} else {
+				content.WriteString(fmt.Sprintf("- %s\n", entry.Message))
+			}
+		}
+		content.WriteString("\n")
+	}
+
+	return writeTextFile(filepath.Join(outputDir, "CONVERSION_REPORT.md"), content.String())
+}
@@ -0,0 +1,69 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resourceClassCPUs are the CPUs of the resource classes larger than a typical
+// developer machine may offer, by size (arm.xlarge is xlarge)
+var resourceClassCPUs = map[string]int{
+	"large": 4, "xlarge": 8, "2xlarge": 16, "2xlarge+": 20,
+}
+
+// gpuPrecondition checks that an NVIDIA GPU is available
+var gpuPrecondition = Precondition{
+	Sh:  "nvidia-smi -L >/dev/null 2>&1",
+	Msg: "resource_class: the job needs an NVIDIA GPU (nvidia-smi finds none)",
+}
+
+// armCheck warns when the host is not an arm64 machine
+func armCheck(class string) string {
+	return fmt.Sprintf(`case "$(uname -m)" in arm64|aarch64) ;; *) echo %s >&2 ;; esac`,
+		shellQuote(fmt.Sprintf("Warning: resource_class %s runs on arm64; this host is not, so what the job builds targets another architecture", class)))
+}
+
+// cpuCheck warns when the host has fewer CPUs than a resource class
+func cpuCheck(class string, cpus int) string {
+	return fmt.Sprintf(`[ "$(getconf _NPROCESSORS_ONLN 2>/dev/null || echo %d)" -ge %d ] || echo %s >&2`,
+		cpus, cpus, shellQuote(fmt.Sprintf("Warning: resource_class %s has %d CPUs; this host has fewer, so the job may be slower or run out of memory", class, cpus)))
+}
+
+// addResourceClassChecks checks that the host can run jobs on resource classes a
+// developer machine may lack: GPU classes get a precondition, arm and large classes a
+// warning at the start of the task, and self-hosted runner classes a report entry
+func addResourceClassChecks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		task, ok := taskfile.Tasks[jobName]
+		resolved, err := resolveJobExecutor(config.Jobs[jobName], config.Executors)
+		class := resolved.ResourceClass
+		if !ok || err != nil || class == "" || strings.Contains(class, "<<") {
+			continue
+		}
+
+		// Self-hosted runners are named <namespace>/<name>
+		if strings.Contains(class, "/") {
+			report.Add("Resource classes", jobName, "runs on the self-hosted runner class %s; locally it runs on this machine", class)
+			continue
+		}
+
+		var checks []string
+		size := class[strings.LastIndex(class, ".")+1:]
+		switch {
+		case strings.HasPrefix(class, "gpu."):
+			task.Preconditions = append(task.Preconditions, gpuPrecondition)
+			report.Add("Resource classes", jobName, "resource_class %s needs a GPU; the task checks for one with nvidia-smi", class)
+		case strings.HasPrefix(class, "arm."):
+			checks = append(checks, armCheck(class))
+			report.Add("Resource classes", jobName, "resource_class %s runs on arm64; the task warns on other hosts", class)
+		}
+		if cpus, ok := resourceClassCPUs[size]; ok && !strings.HasPrefix(class, "gpu.") {
+			checks = append(checks, cpuCheck(class, cpus))
+			report.Add("Resource classes", jobName, "resource_class %s has %d CPUs; the task warns on hosts with fewer", class, cpus)
+		}
+		if len(checks) > 0 {
+			task.Cmds = append(shellCommands(checks...), task.Cmds...)
+		}
+		taskfile.Tasks[jobName] = task
+	}
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RetryPolicy retries a failing command with exponential backoff
+type RetryPolicy struct {
+	Attempts int // total attempts, including the first; below 2 disables retrying
+	Delay    int // seconds before the first retry, doubled after each further failure
+}
+
+// retryMarker starts every retry wrapper, so commands are never wrapped twice
+const retryMarker = "retry_attempt=1\n"
+
+// retryOrbAttemptKeys and retryOrbDelayKeys are the parameter names retry orbs use
+var retryOrbAttemptKeys = []string{"retry-count", "retry_count", "retries", "attempts", "max-attempts", "max_attempts"}
+var retryOrbDelayKeys = []string{"sleep", "delay", "wait", "sleep-time", "backoff"}
+
+// retryLoopRegex matches hand-written retry loops such as
+// `for i in 1 2 3; do make test && break; sleep 5; done`
+var retryLoopRegex = regexp.MustCompile(`(?s)^\s*for\s+\w+\s+in\s+([^;\n]+?)\s*[;\n]\s*do\s+(.+?)\s*&&\s*break\s*(?:;|\|\||\n)\s*(?:sleep\s+(\d+)\s*[;\n]?\s*)?done\s*$`)
+
+var seqRegex = regexp.MustCompile(`^\$\(\s*seq\s+(?:1\s+)?(\d+)\s*\)$|^\{1\.\.(\d+)\}$`)
+
+// retryWrap wraps a command so it is retried according to the policy. The command runs
+// in a subshell so an `exit` inside it ends the attempt rather than the wrapper.
+func retryWrap(cmd string, policy RetryPolicy) string {
+	if policy.Attempts < 2 || strings.HasPrefix(cmd, retryMarker) {
+		return cmd
+	}
+	delay := policy.Delay
+	if delay < 1 {
+		delay = 1
+	}
+	return retryMarker + fmt.Sprintf(`until (
+%s
+); do
+  if [ "$retry_attempt" -ge %d ]; then echo "Failed after %d attempts" >&2; exit 1; fi
+  retry_delay=$((%d << (retry_attempt - 1)))
+  echo "Attempt $retry_attempt failed; retrying in ${retry_delay}s" >&2
+  sleep "$retry_delay"
+  retry_attempt=$((retry_attempt + 1))
+done`, strings.TrimSpace(cmd), policy.Attempts, policy.Attempts, delay)
+}
+
+// parseRetryLoop recognizes a hand-written retry loop and returns the retried command
+// and the policy it implements
+func parseRetryLoop(cmd string) (string, RetryPolicy, bool) {
+	match := retryLoopRegex.FindStringSubmatch(cmd)
+	if match == nil {
+		return "", RetryPolicy{}, false
+	}
+
+	attempts := 0
+	list := strings.TrimSpace(match[1])
+	if seq := seqRegex.FindStringSubmatch(list); seq != nil {
+		attempts, _ = strconv.Atoi(seq[1] + seq[2])
+	} else {
+		for _, word := range strings.Fields(list) {
+			if _, err := strconv.Atoi(word); err != nil {
+				return "", RetryPolicy{}, false
+			}
+			attempts++
+		}
+	}
+
+	delay := 1
+	if match[3] != "" {
+		delay, _ = strconv.Atoi(match[3])
+	}
+	return match[2], RetryPolicy{Attempts: attempts, Delay: delay}, attempts >= 2
+}
+
+// retryOrbStep recognizes a retry orb step (run-with-retry and similar) and returns
+// the retried command and its policy
+func retryOrbStep(step Step) (string, RetryPolicy, bool) {
+	name, ok := isCommandInvocation(step)
+	if !ok || !strings.Contains(strings.ToLower(name), "retry") {
+		return "", RetryPolicy{}, false
+	}
+	params, ok := step.(map[string]interface{})[name].(map[string]interface{})
+	if !ok {
+		return "", RetryPolicy{}, false
+	}
+	cmd, ok := params["command"].(string)
+	if !ok || cmd == "" {
+		return "", RetryPolicy{}, false
+	}
+	cmd = escapeTemplateBraces(cmd)
+
+	policy := RetryPolicy{Attempts: 3, Delay: 5}
+	for _, key := range retryOrbAttemptKeys {
+		if value, err := strconv.Atoi(fmt.Sprintf("%v", params[key])); err == nil {
+			policy.Attempts = value
+			// retry-count style parameters count retries after the first attempt
+			if strings.HasPrefix(key, "retr") {
+				policy.Attempts++
+			}
+			break
+		}
+	}
+	for _, key := range retryOrbDelayKeys {
+		if value, err := strconv.Atoi(strings.TrimSuffix(fmt.Sprintf("%v", params[key]), "s")); err == nil {
+			policy.Delay = value
+			break
+		}
+	}
+	return cmd, policy, true
+}
+
+// applyRetryPolicies turns hand-written retry loops into retry wrappers with backoff
+// and, when a global policy is set, wraps every other command of the generated tasks
+func applyRetryPolicies(taskfile *Taskfile, global RetryPolicy, report *ConversionReport) {
+	var taskNames []string
+	for name := range taskfile.Tasks {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+
+	wrapped := 0
+	for _, name := range taskNames {
+		task := taskfile.Tasks[name]
+		for i, cmd := range task.Cmds {
+			if strings.HasPrefix(cmd.Cmd, "#") || strings.HasPrefix(cmd.Cmd, retryMarker) || cmd.Defer || cmd.blocked {
+				continue
+			}
+			if inner, policy, ok := parseRetryLoop(cmd.Cmd); ok {
+				task.Cmds[i] = TaskCmd{Cmd: retryWrap(inner, policy)}
+				report.Add("Retries", name, "retry loop `%s` converted to %d attempts with %ds exponential backoff; unlike the loop, it fails when every attempt fails", firstLine(cmd.Cmd), policy.Attempts, policy.Delay)
+				continue
+			}
+			// Task calls are retried by the wrapped commands of the called task
+			if global.Attempts >= 2 && !strings.HasPrefix(cmd.Cmd, "task ") {
+				task.Cmds[i] = TaskCmd{Cmd: retryWrap(cmd.Cmd, global)}
+				wrapped++
+			}
+		}
+		taskfile.Tasks[name] = task
+	}
+
+	if wrapped > 0 {
+		report.Add("Retries", "", "global retry policy: %d commands retried up to %d attempts with %ds exponential backoff", wrapped, global.Attempts, global.Delay)
+	}
+}
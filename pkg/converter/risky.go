@@ -0,0 +1,77 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// riskyPatterns are command shapes security-conscious orgs don't want to run blindly
+var riskyPatterns = []struct {
+	Reason string
+	Regex  *regexp.Regexp
+}{
+	{"remote script piped to a shell", regexp.MustCompile(`\b(curl|wget)\b[^|;&\n]*\|\s*(sudo\s+)?(-E\s+)?(ba|z|da)?sh\b`)},
+	{"remote script executed via process substitution", regexp.MustCompile(`\b(ba|z)?sh\s+<\(\s*(curl|wget)\b`)},
+	{"unpinned install script", regexp.MustCompile(`\b(curl|wget)\b[^\n]*https?://\S*/(master|main|HEAD|latest)/\S*\.(sh|py|ps1)\b`)},
+	{"world-writable permissions", regexp.MustCompile(`\bchmod\s+(-R\s+)?0?777\b`)},
+	{"plaintext password on the command line", regexp.MustCompile(`\b((docker|helm|podman)\s+login\b[^\n]*\s(-p|--password)[= ]\s*|sshpass\s+-p\s*)[^\s$"'-]`)},
+}
+
+// detectRiskyCommand returns the reasons a command is considered risky
+func detectRiskyCommand(cmd string) []string {
+	var reasons []string
+	for _, pattern := range riskyPatterns {
+		if pattern.Regex.MatchString(cmd) {
+			reasons = append(reasons, pattern.Reason)
+		}
+	}
+	for _, match := range findSecrets(cmd) {
+		reasons = append(reasons, fmt.Sprintf("plaintext credential (%s)", match.Kind))
+	}
+	return reasons
+}
+
+// guardRiskyCommands reports risky commands in the generated tasks and, unless
+// allowed, replaces them with a command that fails with an explanation
+func guardRiskyCommands(taskfile *Taskfile, allowRisky bool, report *ConversionReport) {
+	var taskNames []string
+	for name := range taskfile.Tasks {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+
+	for _, name := range taskNames {
+		task := taskfile.Tasks[name]
+		for i, cmd := range task.Cmds {
+			if strings.HasPrefix(cmd.Cmd, "#") {
+				continue
+			}
+			reasons := detectRiskyCommand(cmd.Cmd)
+			if len(reasons) == 0 {
+				continue
+			}
+
+			summary := strings.Join(reasons, ", ")
+			if allowRisky {
+				report.Add("Risky commands", name, "`%s`: %s (kept because -allow-risky was set)", firstLine(cmd.Cmd), summary)
+				continue
+			}
+
+			report.Add("Risky commands", name, "`%s`: %s (blocked; re-run with -allow-risky to keep it)", firstLine(cmd.Cmd), summary)
+			task.Cmds[i] = cmd.withCmd(fmt.Sprintf("echo %s >&2 && exit 1", shellQuote(fmt.Sprintf("Blocked risky command (%s); re-run circle-to-task with -allow-risky to keep it", summary))))
+			task.Cmds[i].blocked = true
+		}
+		taskfile.Tasks[name] = task
+	}
+}
+
+// firstLine returns the first line of a possibly multi-line command
+func firstLine(cmd string) string {
+	line, _, found := strings.Cut(strings.TrimSpace(cmd), "\n")
+	if found {
+		return line + " …"
+	}
+	return line
+}
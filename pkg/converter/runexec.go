@@ -0,0 +1,237 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunOptions controls how the local runner executes jobs
+type RunOptions struct {
+	TaskfileDir string
+	MaxParallel int
+	LogMode     string // "prefix" or "group"
+	DryRun      bool
+	Env         []string // KEY=value variables added to every task's environment
+}
+
+// JobResult is the outcome of one job of a local run
+type JobResult struct {
+	Name     string
+	Status   string // success, failed, blocked, skipped
+	Reason   string
+	Duration time.Duration
+	Output   string // last lines of the job's output, for failure reports
+}
+
+// outputTailLines is how much job output results keep
+const outputTailLines = 50
+
+// executeWorkflow runs the nodes as soon as the jobs they require have succeeded,
+// at most opts.MaxParallel at a time, and returns the results in workflow order
+func executeWorkflow(nodes []RunNode, skipped map[string]string, opts RunOptions) []JobResult {
+	results := make(map[string]JobResult)
+	started := make(map[string]bool)
+	done := make(chan JobResult)
+	running := 0
+
+	width := 0
+	for _, node := range nodes {
+		if len(node.Name) > width {
+			width = len(node.Name)
+		}
+	}
+	console := &sync.Mutex{}
+
+	settle := func(result JobResult) {
+		results[result.Name] = result
+		console.Lock()
+		defer console.Unlock()
+		switch result.Status {
+		case "success":
+			if !opts.DryRun {
+				fmt.Printf("✅ %s (%s)\n", result.Name, formatDuration(result.Duration))
+			}
+		case "failed":
+			fmt.Printf("❌ %s failed after %s: %s\n", result.Name, formatDuration(result.Duration), result.Reason)
+		case "blocked":
+			fmt.Printf("⛔ %s (%s)\n", result.Name, result.Reason)
+		case "skipped":
+			fmt.Printf("⏭  %s (%s)\n", result.Name, result.Reason)
+		}
+	}
+
+	for len(results) < len(nodes) {
+		progress := false
+		for _, node := range nodes {
+			if started[node.Name] {
+				continue
+			}
+
+			ready, blockedBy := true, ""
+			for _, req := range node.Requires {
+				result, ok := results[req]
+				if !ok {
+					ready = false
+					break
+				}
+				if result.Status == "failed" || result.Status == "blocked" {
+					blockedBy = req
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			switch reason, isSkipped := skipped[node.Name]; {
+			case isSkipped:
+				settle(JobResult{Name: node.Name, Status: "skipped", Reason: reason})
+			case blockedBy != "":
+				settle(JobResult{Name: node.Name, Status: "blocked", Reason: "blocked by failed " + blockedBy})
+			case node.Approval:
+				settle(JobResult{Name: node.Name, Status: "skipped", Reason: "approval job, nothing to run locally"})
+			case opts.DryRun:
+				fmt.Printf("▶ %s: %s\n", node.Name, taskCommandLine(node))
+				settle(JobResult{Name: node.Name, Status: "success"})
+			case running < opts.MaxParallel:
+				running++
+				go func(node RunNode) {
+					done <- runJob(node, opts, width, console)
+				}(node)
+			default:
+				continue
+			}
+			started[node.Name] = true
+			progress = true
+		}
+
+		if progress {
+			continue
+		}
+		if running == 0 {
+			break
+		}
+		result := <-done
+		running--
+		settle(result)
+	}
+
+	ordered := make([]JobResult, 0, len(nodes))
+	for _, node := range nodes {
+		ordered = append(ordered, results[node.Name])
+	}
+	return ordered
+}
+
+// taskArguments returns the task name and variables to invoke for a node
+func taskArguments(node RunNode) []string {
+	return append([]string{node.Task}, node.Args...)
+}
+
+// taskCommandLine shows the task call of a node as a shell command line
+func taskCommandLine(node RunNode) string {
+	return strings.Join(append([]string{"task", node.Task}, quotedTaskArgs(node.Args)...), " ")
+}
+
+// runJob runs one job's task, tagging or grouping its output so parallel jobs stay readable
+func runJob(node RunNode, opts RunOptions, width int, console *sync.Mutex) JobResult {
+	args := taskArguments(node)
+
+	console.Lock()
+	fmt.Printf("▶ %s: %s\n", node.Name, taskCommandLine(node))
+	console.Unlock()
+
+	var out io.Writer
+	var buffer bytes.Buffer
+	var prefixed *prefixWriter
+	if opts.LogMode == "group" {
+		out = &buffer
+	} else {
+		prefixed = &prefixWriter{prefix: fmt.Sprintf("[%-*s] ", width, node.Name), out: os.Stdout, console: console}
+		out = prefixed
+	}
+
+	var captured bytes.Buffer
+	out = io.MultiWriter(out, &captured)
+
+	cmd := exec.Command("task", append([]string{"--dir", opts.TaskfileDir}, args...)...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = append(os.Environ(), opts.Env...)
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+
+	if prefixed != nil {
+		prefixed.Flush()
+	} else {
+		if buffer.Len() > 0 && !bytes.HasSuffix(buffer.Bytes(), []byte("\n")) {
+			buffer.WriteByte('\n')
+		}
+		console.Lock()
+		fmt.Printf("── %s ──\n%s", node.Name, buffer.String())
+		console.Unlock()
+	}
+
+	if err != nil {
+		return JobResult{Name: node.Name, Status: "failed", Reason: err.Error(), Duration: duration, Output: lastLines(captured.String(), outputTailLines)}
+	}
+	return JobResult{Name: node.Name, Status: "success", Duration: duration}
+}
+
+// prefixWriter writes complete lines to out, each tagged with prefix. It is shared by
+// the job's stdout and stderr, so writes are serialized.
+type prefixWriter struct {
+	prefix  string
+	out     io.Writer
+	console *sync.Mutex
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a trailing line that did not end in a newline
+func (w *prefixWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.writeLine(append(w.partial, '\n'))
+		w.partial = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.console.Lock()
+	defer w.console.Unlock()
+	fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+}
+
+// lastLines returns the final n lines of text
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
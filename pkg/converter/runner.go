@@ -0,0 +1,338 @@
+package converter
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunNode is one job of a workflow, as the local runner executes it
+type RunNode struct {
+	Name     string   `json:"name"`           // name the workflow uses for the job (its `name:` or matrix variant name)
+	Task     string   `json:"task"`           // Taskfile task to run
+	Args     []string `json:"args,omitempty"` // KEY=value task variables from the invocation, unquoted
+	Requires []string `json:"requires,omitempty"`
+	Approval bool     `json:"approval,omitempty"` // `type: approval` jobs have nothing to run locally
+}
+
+// buildWorkflowGraph returns the jobs of a workflow in a dependency-respecting order
+func buildWorkflowGraph(config CircleCIConfig, workflowName string) ([]RunNode, error) {
+	var nodes []RunNode
+	// Requires may name a matrix job by its alias, meaning every variant
+	aliases := make(map[string][]string)
+	sharedSteps := sharedInvocationSteps(config.Workflows)
+	aliasTasks := aliasTaskNames(config)
+
+	for _, invocation := range extractWorkflowJobs(config.Workflows) {
+		if invocation.Workflow != workflowName {
+			continue
+		}
+		requires := invocation.Requires
+
+		if variants, _, ok := expandMatrix(invocation.WorkflowJob); ok {
+			alias := matrixAlias(invocation.WorkflowJob)
+			for _, variant := range variants {
+				nodes = append(nodes, RunNode{Name: variant.Name, Task: variant.Name, Requires: requires})
+				aliases[alias] = append(aliases[alias], variant.Name)
+			}
+			continue
+		}
+
+		node := RunNode{Name: invocation.DisplayName(), Task: invocation.Job, Requires: requires}
+		if invocation.Type == "approval" {
+			node.Approval = true
+		}
+		args := invocation.Arguments
+		// Invocations with a `name:` alias have a task calling the job with their arguments
+		if task, ok := aliasTasks[aliasKey(invocation.Workflow, invocation.Name)]; ok {
+			node.Task = task
+			args = nil
+		}
+		// Invocations choosing the executor have their own task, which sets the rest
+		if task, ok := executorInvocationTask(config.Jobs[invocation.Job], invocation.WorkflowJob); ok {
+			node.Task = task
+			args = nil
+		}
+		// Invocations adding their own pre-steps and post-steps also have a task of their own
+		if task, ok := invocationStepsTask(invocation, sharedSteps); ok {
+			node.Task = task
+			args = nil
+		}
+		for _, argName := range sortedKeys(args) {
+			node.Args = append(node.Args, taskVarName(argName)+"="+formatParamValue(config.Jobs[invocation.Job].Parameters[argName], args[argName]))
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("workflow %q has no jobs", workflowName)
+	}
+
+	byName := make(map[string]bool)
+	for _, node := range nodes {
+		byName[node.Name] = true
+	}
+	for i, node := range nodes {
+		var requires []string
+		for _, req := range node.Requires {
+			if variants, ok := aliases[req]; ok && !byName[req] {
+				requires = append(requires, variants...)
+			} else if byName[req] {
+				requires = append(requires, req)
+			} else {
+				return nil, fmt.Errorf("job %s requires %s, which is not in workflow %s", node.Name, req, workflowName)
+			}
+		}
+		nodes[i].Requires = requires
+	}
+
+	return topologicalOrder(nodes)
+}
+
+// topologicalOrder sorts nodes so every job comes after the jobs it requires,
+// keeping workflow order among independent jobs
+func topologicalOrder(nodes []RunNode) ([]RunNode, error) {
+	var ordered []RunNode
+	placed := make(map[string]bool)
+
+	for len(ordered) < len(nodes) {
+		progress := false
+		for _, node := range nodes {
+			if placed[node.Name] {
+				continue
+			}
+			ready := true
+			for _, req := range node.Requires {
+				if !placed[req] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, node)
+				placed[node.Name] = true
+				progress = true
+			}
+		}
+		if !progress {
+			var stuck []string
+			for _, node := range nodes {
+				if !placed[node.Name] {
+					stuck = append(stuck, node.Name)
+				}
+			}
+			return nil, fmt.Errorf("workflow has a dependency cycle between: %s", strings.Join(stuck, ", "))
+		}
+	}
+
+	return ordered, nil
+}
+
+// defaultWorkflow picks the workflow to run when none is given
+func defaultWorkflow(workflows map[string]Workflow) (string, error) {
+	var names []string
+	for name, workflow := range workflows {
+		if workflow.IsWorkflow() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("config has no workflows")
+	case 1:
+		return names[0], nil
+	}
+	return "", fmt.Errorf("config has several workflows, choose one with -workflow: %s", strings.Join(names, ", "))
+}
+
+// runRunCommand implements the `run` subcommand: it executes a workflow's jobs locally
+// through the generated Taskfile, in dependency order
+func runRunCommand(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	inputFile := fs.String("input", "", "Input CircleCI config file (required)")
+	workflowName := fs.String("workflow", "", "Workflow to run (default: the only workflow)")
+	taskfileDir := fs.String("taskfile-dir", ".", "Directory containing the generated Taskfile.yml")
+	from := fs.String("from", "", "Resume from this job: the jobs it requires, directly or not, are treated as done, reusing ./workspace")
+	skip := fs.String("skip", "", "Comma-separated jobs to skip (treated as succeeded)")
+	dryRun := fs.Bool("dry-run", false, "Print the execution plan without running anything")
+	maxParallel := fs.Int("max-parallel", runtime.NumCPU(), "Maximum number of jobs to run at once")
+	logMode := fs.String("log", "prefix", "Job output: prefix (stream lines tagged with the job name) or group (print each job's output when it finishes)")
+	junitPath := fs.String("junit", "", "Write a JUnit XML summary (one testcase per job) to this file")
+	summaryPath := fs.String("summary", "", "Write a markdown job summary to this file")
+	timings := fs.Bool("timings", true, "Record job durations and write TIMING_REPORT.md comparing them with earlier runs")
+	fs.Parse(args)
+
+	if *maxParallel < 1 {
+		return fmt.Errorf("-max-parallel must be at least 1")
+	}
+	if *logMode != "prefix" && *logMode != "group" {
+		return fmt.Errorf("-log must be prefix or group")
+	}
+
+	if *inputFile == "" {
+		fs.Usage()
+		return fmt.Errorf("-input is required")
+	}
+
+	data, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	config, err := parseConfig(*inputFile, data)
+	if err != nil {
+		return err
+	}
+
+	if *workflowName == "" {
+		if *workflowName, err = defaultWorkflow(config.Workflows); err != nil {
+			return err
+		}
+	}
+	nodes, err := buildWorkflowGraph(config, *workflowName)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, node := range nodes {
+		known[node.Name] = true
+	}
+
+	skipped := make(map[string]string)
+	for _, job := range strings.Split(*skip, ",") {
+		if job = strings.TrimSpace(job); job == "" {
+			continue
+		}
+		if !known[job] {
+			return fmt.Errorf("-skip: job %s is not in workflow %s", job, *workflowName)
+		}
+		skipped[job] = "skipped"
+	}
+	if *from != "" {
+		if !known[*from] {
+			return fmt.Errorf("-from: job %s is not in workflow %s", *from, *workflowName)
+		}
+		// The jobs it requires ran in the previous attempt; the other branches run again
+		for _, name := range requiredJobs(nodes, *from) {
+			if _, ok := skipped[name]; !ok {
+				skipped[name] = "done in a previous run"
+			}
+		}
+		if _, err := os.Stat(filepath.Join(*taskfileDir, workspaceDirName)); err != nil {
+			fmt.Printf("⚠️  %s has no workspace directory; jobs attaching the workspace may miss earlier output\n", *taskfileDir)
+		}
+	}
+
+	// Like on CircleCI, a new run starts with an empty workspace
+	if *from == "" && !*dryRun {
+		if err := os.RemoveAll(filepath.Join(*taskfileDir, workspaceDirName)); err != nil {
+			return fmt.Errorf("error clearing the workspace: %w", err)
+		}
+	}
+
+	fmt.Printf("▶ Running workflow %s (%d jobs, up to %d in parallel)\n", *workflowName, len(nodes), *maxParallel)
+
+	started := time.Now()
+	results := executeWorkflow(nodes, skipped, RunOptions{
+		TaskfileDir: *taskfileDir,
+		MaxParallel: *maxParallel,
+		LogMode:     *logMode,
+		DryRun:      *dryRun,
+		// Identifies the run, like on CircleCI; the artifacts index groups by it
+		Env: []string{fmt.Sprintf("CIRCLE_WORKFLOW_ID=%s-%s", *workflowName, started.Format("20060102-150405"))},
+	})
+
+	wall := time.Since(started)
+
+	if *junitPath != "" && !*dryRun {
+		if err := writeJUnitReport(*junitPath, *workflowName, started, wall, results); err != nil {
+			fmt.Printf("⚠️  Could not write JUnit report: %v\n", err)
+		}
+	}
+	if *summaryPath != "" && !*dryRun {
+		if err := writeMarkdownSummary(*summaryPath, *workflowName, wall, results); err != nil {
+			fmt.Printf("⚠️  Could not write summary: %v\n", err)
+		}
+	}
+
+	if *timings && !*dryRun {
+		run := newTimingRun(*workflowName, started, wall, results)
+		path, _ := criticalPath(nodes, run.Jobs)
+		fmt.Printf("⏱  Wall time %s, critical path: %s\n", formatSeconds(run.WallSeconds), strings.Join(path, " → "))
+		if reportPath, err := recordTimings(*taskfileDir, nodes, run); err != nil {
+			fmt.Printf("⚠️  Could not record timings: %v\n", err)
+		} else {
+			fmt.Printf("⏱  Timing report: %s\n", reportPath)
+		}
+	}
+
+	failed := make(map[string]bool)
+	for _, result := range results {
+		if result.Status == "failed" || result.Status == "blocked" {
+			failed[result.Name] = true
+		}
+	}
+
+	for _, node := range nodes {
+		if failed[node.Name] && !hasFailedRequirement(node, failed) {
+			resume := fmt.Sprintf("%s run -input %s -workflow %s -from %s", filepath.Base(os.Args[0]), *inputFile, *workflowName, node.Name)
+			if *taskfileDir != "." {
+				resume += " -taskfile-dir " + *taskfileDir
+			}
+			return fmt.Errorf("job %s failed; fix it and resume with: %s", node.Name, resume)
+		}
+	}
+	return nil
+}
+
+// requiredJobs returns the jobs a job requires, directly or through other jobs, in
+// workflow order
+func requiredJobs(nodes []RunNode, name string) []string {
+	byName := make(map[string]RunNode, len(nodes))
+	for _, node := range nodes {
+		byName[node.Name] = node
+	}
+	required := make(map[string]bool)
+	pending := byName[name].Requires
+	for len(pending) > 0 {
+		req := pending[0]
+		pending = pending[1:]
+		if !required[req] {
+			required[req] = true
+			pending = append(pending, byName[req].Requires...)
+		}
+	}
+	var names []string
+	for _, node := range nodes {
+		if required[node.Name] {
+			names = append(names, node.Name)
+		}
+	}
+	return names
+}
+
+// quotedTaskArgs quotes the values of KEY=value task arguments for a shell command line
+func quotedTaskArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		name, value, _ := strings.Cut(arg, "=")
+		quoted[i] = name + "=" + shellQuote(value)
+	}
+	return quoted
+}
+
+// hasFailedRequirement reports whether a job was blocked rather than failing itself
+func hasFailedRequirement(node RunNode, failed map[string]bool) bool {
+	for _, req := range node.Requires {
+		if failed[req] {
+			return true
+		}
+	}
+	return false
+}
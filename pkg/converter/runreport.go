@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// JUnitTestSuite is the JUnit XML document written by `run -junit`
+type JUnitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is one job of the run
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Skipped   *JUnitSkipped `xml:"skipped,omitempty"`
+}
+
+// JUnitFailure holds the failure message and the tail of the job's output
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Output  string `xml:",cdata"`
+}
+
+// JUnitSkipped marks skipped and blocked jobs
+type JUnitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes a JUnit XML summary with one testcase per job
+func writeJUnitReport(path, workflow string, started time.Time, wall time.Duration, results []JobResult) error {
+	suite := JUnitTestSuite{
+		Name:      workflow,
+		Tests:     len(results),
+		Time:      fmt.Sprintf("%.3f", wall.Seconds()),
+		Timestamp: started.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, result := range results {
+		testCase := JUnitTestCase{
+			Name:      result.Name,
+			ClassName: workflow,
+			Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+		}
+		switch result.Status {
+		case "failed":
+			suite.Failures++
+			testCase.Failure = &JUnitFailure{Message: result.Reason, Output: result.Output}
+		case "skipped", "blocked":
+			suite.Skipped++
+			testCase.Skipped = &JUnitSkipped{Message: result.Reason}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JUnit report: %w", err)
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// writeMarkdownSummary writes a markdown table of job outcomes, with the output of
+// failed jobs in collapsible sections
+func writeMarkdownSummary(path, workflow string, wall time.Duration, results []JobResult) error {
+	var b strings.Builder
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	icon := "✅"
+	if counts["failed"] > 0 {
+		icon = "❌"
+	}
+	b.WriteString(fmt.Sprintf("# %s Workflow %s\n\n", icon, workflow))
+	b.WriteString(fmt.Sprintf("%d succeeded, %d failed, %d blocked, %d skipped in %s.\n\n",
+		counts["success"], counts["failed"], counts["blocked"], counts["skipped"], formatDuration(wall)))
+
+	b.WriteString("| Job | Status | Duration | Notes |\n")
+	b.WriteString("|-----|--------|----------|-------|\n")
+	for _, result := range results {
+		duration := "-"
+		if result.Status == "success" || result.Status == "failed" {
+			duration = formatDuration(result.Duration)
+		}
+		b.WriteString(fmt.Sprintf("| %s | %s %s | %s | %s |\n", result.Name, statusIcon(result.Status), result.Status, duration, result.Reason))
+	}
+
+	for _, result := range results {
+		if result.Status != "failed" || result.Output == "" {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("\n<details><summary>%s output</summary>\n\n```\n%s\n```\n\n</details>\n", result.Name, result.Output))
+	}
+
+	return writeTextFile(path, b.String())
+}
+
+// statusIcon returns the icon the runner prints for a job status
+func statusIcon(status string) string {
+	switch status {
+	case "success":
+		return "✅"
+	case "failed":
+		return "❌"
+	case "blocked":
+		return "⛔"
+	}
+	return "⏭"
+}
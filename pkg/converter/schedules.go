@@ -0,0 +1,135 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// schedulesFileName is the overview of scheduled workflows written next to the Taskfile
+const schedulesFileName = "SCHEDULES.md"
+
+// Schedule is a `triggers: schedule:` entry of a workflow
+type Schedule struct {
+	Workflow string
+	Cron     string
+	Branches BranchFilter
+}
+
+// plainBranchRegex matches branch names that are not /regex/ patterns
+var plainBranchRegex = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// workflowSchedules lists the scheduled triggers of the config's workflows
+func workflowSchedules(config CircleCIConfig) []Schedule {
+	var schedules []Schedule
+	for _, name := range sortedWorkflowNames(config.Workflows) {
+		for _, trigger := range config.Workflows[name].Triggers {
+			body, _ := trigger.(map[string]interface{})
+			schedule, ok := body["schedule"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entry := Schedule{Workflow: name}
+			entry.Cron, _ = schedule["cron"].(string)
+			if filters, ok := schedule["filters"].(map[string]interface{}); ok {
+				entry.Branches = parseBranchFilter(filters["branches"])
+			}
+			schedules = append(schedules, entry)
+		}
+	}
+	return schedules
+}
+
+// scheduleTaskName names the task running a scheduled workflow
+func scheduleTaskName(workflow string) string {
+	return "schedule:" + workflow
+}
+
+// scheduleBranch returns the branch a schedule checks out, when it names exactly one
+func scheduleBranch(schedule Schedule) (string, bool) {
+	if len(schedule.Branches.Only) != 1 || !plainBranchRegex.MatchString(schedule.Branches.Only[0]) {
+		return "", false
+	}
+	return schedule.Branches.Only[0], true
+}
+
+// describeSchedule renders a schedule for task descriptions and reports
+func describeSchedule(schedule Schedule) string {
+	description := fmt.Sprintf("cron `%s` (UTC)", schedule.Cron)
+	if len(schedule.Branches.Only) > 0 {
+		description += " on " + strings.Join(schedule.Branches.Only, ", ")
+	}
+	if len(schedule.Branches.Ignore) > 0 {
+		description += " except " + strings.Join(schedule.Branches.Ignore, ", ")
+	}
+	return description
+}
+
+// addScheduleTasks adds a schedule:<workflow> task running the jobs of each scheduled
+// workflow in order, for cron or another CI to call
+func addScheduleTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	described := make(map[string][]string)
+	var workflows []string
+	for _, schedule := range workflowSchedules(config) {
+		if described[schedule.Workflow] == nil {
+			workflows = append(workflows, schedule.Workflow)
+		}
+		described[schedule.Workflow] = append(described[schedule.Workflow], describeSchedule(schedule))
+	}
+
+	for _, name := range workflows {
+		nodes, err := buildWorkflowGraph(config, name)
+		if err != nil {
+			report.Add("Schedules", name, "runs on %s; no schedule task: %v", strings.Join(described[name], " and "), err)
+			continue
+		}
+		taskName := scheduleTaskName(name)
+		taskfile.Tasks[taskName] = Task{
+			Desc: fmt.Sprintf("Run the jobs of scheduled workflow %s in order (CircleCI: %s)", name, strings.Join(described[name], " and ")),
+			Cmds: workflowTaskCmds(config, nodes),
+		}
+		report.Add("Schedules", name, "runs on %s; converted to task %s, see %s for a crontab entry", strings.Join(described[name], " and "), taskName, schedulesFileName)
+	}
+}
+
+// generateSchedules writes SCHEDULES.md, listing the scheduled workflows with a
+// crontab snippet running their tasks. It reports whether the config has any.
+func generateSchedules(config CircleCIConfig, outputDir string) (bool, error) {
+	schedules := workflowSchedules(config)
+	if len(schedules) == 0 {
+		return false, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("# Scheduled Workflows\n\n")
+	b.WriteString("These workflows ran on a schedule on CircleCI. Each has a task running its jobs in order.\n\n")
+	b.WriteString("| Workflow | Cron (UTC) | Branches | Task |\n")
+	b.WriteString("|----------|------------|----------|------|\n")
+	for _, schedule := range schedules {
+		branches := "all"
+		if len(schedule.Branches.Only) > 0 {
+			branches = strings.Join(schedule.Branches.Only, ", ")
+		}
+		if len(schedule.Branches.Ignore) > 0 {
+			branches += " except " + strings.Join(schedule.Branches.Ignore, ", ")
+		}
+		b.WriteString(fmt.Sprintf("| %s | `%s` | %s | `task %s` |\n", schedule.Workflow, schedule.Cron, branches, scheduleTaskName(schedule.Workflow)))
+	}
+
+	b.WriteString("\n## Crontab\n\n")
+	b.WriteString("CircleCI schedules are in UTC. Replace `/path/to/repo` with a checkout of the repository; `CRON_TZ` needs cronie, other crons use the system time zone.\n\n")
+	b.WriteString("```crontab\nCRON_TZ=UTC\n")
+	for _, schedule := range schedules {
+		checkout := ""
+		if branch, ok := scheduleBranch(schedule); ok {
+			checkout = fmt.Sprintf("git checkout -q %s && git pull -q && ", branch)
+		}
+		b.WriteString(fmt.Sprintf("%s cd /path/to/repo && %stask %s >> schedule-%s.log 2>&1\n", schedule.Cron, checkout, scheduleTaskName(schedule.Workflow), schedule.Workflow))
+	}
+	b.WriteString("```\n\n")
+	b.WriteString("The cron expressions use the standard five fields, so they also work as they are in the `schedule` triggers of GitHub Actions and GitLab CI.\n")
+
+	return true, os.WriteFile(filepath.Join(outputDir, schedulesFileName), []byte(b.String()), 0644)
+}
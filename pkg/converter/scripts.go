@@ -0,0 +1,673 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template/parse"
+)
+
+// Output targets of -target
+const (
+	targetTaskfile = "taskfile"
+	targetScripts  = "scripts"
+)
+
+// scriptTarget reports whether a target runs the jobs with the standalone scripts
+// rather than go-task
+func scriptTarget(target string) bool {
+	return target == targetScripts || target == targetMise || target == targetNpmScripts || target == targetMakefile
+}
+
+// scriptsDir holds the standalone scripts written with -target scripts
+const scriptsDir = "scripts"
+
+// scriptTaskCallRegex matches the `task <name>` calls of commands
+var scriptTaskCallRegex = regexp.MustCompile(`(^|[\s;&|(])task\s+([A-Za-z0-9_.:/-]+)`)
+
+// scriptFile is the path of a task's script, relative to the output directory
+func scriptFile(taskName string) string {
+	return scriptsDir + "/" + strings.NewReplacer("/", "-", ":", "-").Replace(taskName) + ".sh"
+}
+
+// scriptTasks returns the tasks to write scripts for, sorted: the jobs, the commands,
+// the tasks the workflows and the new config run, and every task these call or depend on
+func scriptTasks(taskfile Taskfile, config, newConfig CircleCIConfig) []string {
+	var pending []string
+	for name := range config.Jobs {
+		pending = append(pending, name)
+	}
+	for name := range config.Commands {
+		pending = append(pending, name)
+	}
+	// Invocations with an alias, executor or steps of their own run a task of their own
+	for _, workflowName := range sortedWorkflowNames(config.Workflows) {
+		nodes, _ := buildWorkflowGraph(config, workflowName)
+		for _, node := range nodes {
+			if !node.Approval {
+				pending = append(pending, node.Task)
+			}
+		}
+	}
+	for _, job := range newConfig.Jobs {
+		for _, step := range job.Steps {
+			if run, ok := step.(map[string]interface{})["run"].(string); ok {
+				for _, match := range scriptTaskCallRegex.FindAllStringSubmatch(run, -1) {
+					pending = append(pending, match[2])
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		task, ok := taskfile.Tasks[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		pending = append(pending, task.Deps...)
+		for _, cmd := range task.Cmds {
+			for _, match := range scriptTaskCallRegex.FindAllStringSubmatch(cmd.Cmd, -1) {
+				pending = append(pending, match[2])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scriptTaskCalls replaces the `task <name>` calls of a command with calls of the
+// tasks' scripts, their path formatted with format
+func scriptTaskCalls(cmd string, scripts map[string]bool, format string) string {
+	return scriptTaskCallRegex.ReplaceAllStringFunc(cmd, func(match string) string {
+		groups := scriptTaskCallRegex.FindStringSubmatch(match)
+		if !scripts[groups[2]] {
+			return match
+		}
+		return groups[1] + fmt.Sprintf(format, scriptFile(groups[2]))
+	})
+}
+
+// scriptTranslator translates the go-task templates of a task to bash. Variables become
+// parameter expansions, `default` pipelines `${VAR:-default}`, and commands wrapped in
+// {{if}} if statements; other templates are errors.
+type scriptTranslator struct {
+	known map[string]bool // variables the script always sets
+}
+
+// parseTemplate parses a go-task template without checking its functions
+func parseTemplate(s string) (*parse.ListNode, error) {
+	tree := parse.New("cmd")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(s, "", "", map[string]*parse.Tree{}); err != nil {
+		return nil, err
+	}
+	return tree.Root, nil
+}
+
+// command translates a task command to bash code
+func (t scriptTranslator) command(cmd string) (string, error) {
+	root, err := parseTemplate(cmd)
+	if err != nil {
+		return "", err
+	}
+	if len(root.Nodes) == 1 {
+		if node, ok := root.Nodes[0].(*parse.IfNode); ok {
+			return t.ifStatement(node)
+		}
+	}
+	return t.code(root.Nodes)
+}
+
+// ifStatement translates a command wrapped in {{if}} to an if statement
+func (t scriptTranslator) ifStatement(node *parse.IfNode) (string, error) {
+	condition, err := t.condition(node.Pipe)
+	if err != nil {
+		return "", err
+	}
+	body, err := t.code(node.List.Nodes)
+	if err != nil {
+		return "", err
+	}
+	statement := fmt.Sprintf("if %s; then\n%s\n", condition, body)
+	if node.ElseList != nil {
+		elseBody, err := t.code(node.ElseList.Nodes)
+		if err != nil {
+			return "", err
+		}
+		statement += fmt.Sprintf("else\n%s\n", elseBody)
+	}
+	return statement + "fi", nil
+}
+
+// code translates a command's nodes, keeping the text as is. Variables in single quotes
+// are expanded between the quotes, as go-task renders them before the shell runs.
+func (t scriptTranslator) code(nodes []parse.Node) (string, error) {
+	var b strings.Builder
+	quote := byte(0)
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			b.Write(n.Text)
+			quote = shellQuoteState(string(n.Text), quote)
+		case *parse.ActionNode:
+			expansion, literal, err := t.expansion(n.Pipe, false)
+			if err != nil {
+				return "", err
+			}
+			if quote == '\'' && !literal {
+				expansion = `'"` + expansion + `"'`
+			}
+			b.WriteString(expansion)
+		default:
+			return "", fmt.Errorf("template %s has no bash equivalent", node)
+		}
+	}
+	return b.String(), nil
+}
+
+// value translates a variable or environment value to the contents of a double-quoted
+// bash string
+func (t scriptTranslator) value(s string) (string, error) {
+	root, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, node := range root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			b.WriteString(escapeDoubleQuoted(string(n.Text)))
+		case *parse.ActionNode:
+			expansion, _, err := t.expansion(n.Pipe, true)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(expansion)
+		default:
+			return "", fmt.Errorf("template %s has no bash equivalent", node)
+		}
+	}
+	return b.String(), nil
+}
+
+// expansion translates {{.VAR}}, {{.VAR | default ...}} and {{"literal"}} actions, and
+// reports whether the action is a literal
+func (t scriptTranslator) expansion(pipe *parse.PipeNode, quoted bool) (string, bool, error) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false, fmt.Errorf("template {{%s}} has no bash equivalent", pipe)
+	}
+	if s, ok := pipe.Cmds[0].Args[0].(*parse.StringNode); ok && len(pipe.Cmds) == 1 {
+		if quoted {
+			return escapeDoubleQuoted(s.Text), true, nil
+		}
+		return s.Text, true, nil
+	}
+	name, ok := templateVar(pipe.Cmds[0].Args[0])
+	if !ok {
+		return "", false, fmt.Errorf("template {{%s}} has no bash equivalent", pipe)
+	}
+
+	// Each default applies when what precedes it is empty: a variable default to its
+	// own defaults, a literal one to its value
+	expansion := ""
+	for i := len(pipe.Cmds) - 1; i >= 1; i-- {
+		cmd := pipe.Cmds[i]
+		if len(cmd.Args) != 2 || cmd.Args[0].String() != "default" {
+			return "", false, fmt.Errorf("template {{%s}} has no bash equivalent", pipe)
+		}
+		switch arg := cmd.Args[1].(type) {
+		case *parse.StringNode:
+			if arg.Text != "" {
+				expansion = escapeDoubleQuoted(arg.Text)
+			}
+		case *parse.NumberNode, *parse.BoolNode:
+			expansion = arg.String()
+		default:
+			other, ok := templateVar(arg)
+			if !ok {
+				return "", false, fmt.Errorf("template {{%s}} has no bash equivalent", pipe)
+			}
+			expansion = "${" + other + ":-" + expansion + "}"
+		}
+	}
+	if len(pipe.Cmds) == 1 && t.known[name] {
+		return "${" + name + "}", false, nil
+	}
+	return "${" + name + ":-" + expansion + "}", false, nil
+}
+
+// templateVar returns the variable a template field refers to, with the special
+// variables of go-task mapped to those of the scripts
+func templateVar(node parse.Node) (string, bool) {
+	field, ok := node.(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return "", false
+	}
+	if field.Ident[0] == "TASKFILE_DIR" {
+		return "ROOT_DIR", true
+	}
+	return field.Ident[0], true
+}
+
+// condition translates an {{if}} condition to a bash test
+func (t scriptTranslator) condition(pipe *parse.PipeNode) (string, error) {
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 {
+		return "", fmt.Errorf("condition %s has no bash equivalent", pipe)
+	}
+	cmd := pipe.Cmds[0]
+	if len(cmd.Args) == 1 {
+		return t.test(cmd.Args[0])
+	}
+
+	function := cmd.Args[0].String()
+	args := cmd.Args[1:]
+	switch function {
+	case "eq", "ne":
+		if len(args) != 2 {
+			break
+		}
+		left, err := t.operand(args[0])
+		if err != nil {
+			return "", err
+		}
+		right, err := t.operand(args[1])
+		if err != nil {
+			return "", err
+		}
+		operator := "="
+		if function == "ne" {
+			operator = "!="
+		}
+		return fmt.Sprintf("[ %s %s %s ]", left, operator, right), nil
+	case "and", "or":
+		var tests []string
+		for _, arg := range args {
+			test, err := t.test(arg)
+			if err != nil {
+				return "", err
+			}
+			tests = append(tests, test)
+		}
+		operator := " && "
+		if function == "or" {
+			operator = " || "
+		}
+		return "{ " + strings.Join(tests, operator) + "; }", nil
+	case "not":
+		if len(args) != 1 {
+			break
+		}
+		test, err := t.test(args[0])
+		if err != nil {
+			return "", err
+		}
+		return "! { " + test + "; }", nil
+	case "regexMatch":
+		pattern, ok := args[0].(*parse.StringNode)
+		if len(args) != 2 || !ok {
+			break
+		}
+		subject, err := t.operand(args[1])
+		if err != nil {
+			return "", err
+		}
+		// grep -E has groups but not their non-capturing form
+		return fmt.Sprintf("printf '%%s' %s | grep -Eq %s", subject, shellQuote(strings.ReplaceAll(pattern.Text, "(?:", "("))), nil
+	}
+	return "", fmt.Errorf("condition %s has no bash equivalent", pipe)
+}
+
+// test translates an argument of a condition: nested conditions, and variables, which
+// hold when they are not empty
+func (t scriptTranslator) test(node parse.Node) (string, error) {
+	if pipe, ok := node.(*parse.PipeNode); ok {
+		return t.condition(pipe)
+	}
+	name, ok := templateVar(node)
+	if !ok {
+		return "", fmt.Errorf("condition %s has no bash equivalent", node)
+	}
+	return fmt.Sprintf(`[ -n "${%s:-}" ]`, name), nil
+}
+
+// operand translates a compared value: a variable, the host's OS or a literal
+func (t scriptTranslator) operand(node parse.Node) (string, error) {
+	switch n := node.(type) {
+	case *parse.StringNode:
+		return shellQuote(n.Text), nil
+	case *parse.NumberNode, *parse.BoolNode:
+		return shellQuote(n.String()), nil
+	case *parse.IdentifierNode:
+		if n.Ident == "OS" {
+			return `"$(uname -s | tr '[:upper:]' '[:lower:]')"`, nil
+		}
+	}
+	if name, ok := templateVar(node); ok {
+		return fmt.Sprintf(`"${%s:-}"`, name), nil
+	}
+	return "", fmt.Errorf("value %s has no bash equivalent", node)
+}
+
+// shellQuoteState returns the quoting in effect after text, from the quoting before it:
+// 0, a single or a double quote
+func shellQuoteState(text string, quote byte) byte {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		}
+	}
+	return quote
+}
+
+// escapeDoubleQuoted escapes s for a double-quoted bash string, braces included so it
+// can be the default of a ${VAR:-default} expansion
+func escapeDoubleQuoted(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "}", `\}`).Replace(s)
+}
+
+// scriptPlatformPatterns are the `uname -s` patterns of go-task's platforms
+var scriptPlatformPatterns = map[string]string{
+	"linux":   "linux*",
+	"darwin":  "darwin*",
+	"windows": "mingw*|msys*|cygwin*",
+}
+
+// scriptVarDefault returns the default of a task variable shown in a script's usage:
+// the literal default of `{{.VAR | default "x"}}`, or the value as written
+func scriptVarDefault(value string) string {
+	root, err := parseTemplate(value)
+	if err != nil || len(root.Nodes) != 1 {
+		return value
+	}
+	action, ok := root.Nodes[0].(*parse.ActionNode)
+	if !ok {
+		return value
+	}
+	cmds := action.Pipe.Cmds
+	last := cmds[len(cmds)-1]
+	if len(cmds) > 1 && len(last.Args) == 2 {
+		if s, ok := last.Args[1].(*parse.StringNode); ok {
+			return s.Text
+		}
+		return last.Args[1].String()
+	}
+	return value
+}
+
+// taskScript renders a task as a standalone bash script. Commands run in subshells, so
+// `cd` and exported variables stay scoped to their command as in go-task, and deferred
+// commands run from an EXIT trap. It returns the commands that could not be translated.
+func taskScript(name string, task Task, taskfile Taskfile, scripts map[string]bool) (string, []string) {
+	t := scriptTranslator{known: map[string]bool{"ROOT_DIR": true, "TASK": true}}
+	for varName := range taskfile.Vars {
+		t.known[varName] = true
+	}
+	var varNames []string
+	for varName := range task.Vars {
+		t.known[varName] = true
+		varNames = append(varNames, varName)
+	}
+	sort.Strings(varNames)
+
+	var failed []string
+	translate := func(cmd string, value bool) string {
+		var translated string
+		var err error
+		if value {
+			translated, err = t.value(cmd)
+		} else {
+			translated, err = t.command(cmd)
+		}
+		if err != nil {
+			failed = append(failed, cmd)
+			logger.Debug("command not translated to bash", "task", name, "error", err)
+			return ""
+		}
+		return translated
+	}
+	unsupported := func(cmd string) string {
+		return fmt.Sprintf("# Not converted: %s\necho %s >&2\nexit 1", strings.ReplaceAll(firstLine(cmd), "\n", " "),
+			shellQuote(fmt.Sprintf("%s: a command has no bash equivalent; see CONVERSION_REPORT.md", scriptFile(name))))
+	}
+
+	var b strings.Builder
+	desc := task.Desc
+	if desc == "" {
+		desc = "Task " + name
+	}
+	b.WriteString("#!/usr/bin/env bash\n")
+	b.WriteString(fmt.Sprintf("# %s\n", desc))
+	b.WriteString("# Generated by circle-to-task. Parameters are NAME=value arguments or environment variables.\n")
+	b.WriteString("set -euo pipefail\n\n")
+
+	b.WriteString("usage() {\n  cat <<'USAGE'\n")
+	b.WriteString(fmt.Sprintf("Usage: %s [NAME=value...]\n\n%s\n", scriptFile(name), desc))
+	if len(varNames) > 0 {
+		b.WriteString("\nParameters (NAME=value arguments or environment variables):\n")
+		for _, varName := range varNames {
+			b.WriteString(fmt.Sprintf("  %s (default: %s)\n", varName, firstLine(scriptVarDefault(task.Vars[varName]))))
+		}
+	}
+	b.WriteString("USAGE\n}\n\n")
+	b.WriteString("for arg in \"$@\"; do\n  case \"$arg\" in\n    -h|--help) usage; exit 0 ;;\n")
+	b.WriteString("    [A-Za-z_]*=*) declare -- \"$arg\" ;;\n    *) usage >&2; exit 2 ;;\n  esac\ndone\n\n")
+
+	b.WriteString("ROOT_DIR=\"$(cd \"$(dirname \"${BASH_SOURCE[0]}\")/..\" && pwd)\"\n")
+	b.WriteString(fmt.Sprintf("TASK=%s\n", shellQuote(name)))
+	b.WriteString("cd \"$ROOT_DIR\"\n")
+
+	if len(task.Platforms) > 0 {
+		var patterns []string
+		for _, platform := range task.Platforms {
+			goos := strings.SplitN(platform, "/", 2)[0]
+			if pattern, ok := scriptPlatformPatterns[goos]; ok {
+				patterns = append(patterns, pattern)
+			}
+		}
+		if len(patterns) > 0 {
+			b.WriteString(fmt.Sprintf("case \"$(uname -s | tr '[:upper:]' '[:lower:]')\" in\n  %s) ;;\n  *) echo %s >&2; exit 0 ;;\nesac\n",
+				strings.Join(patterns, "|"), shellQuote(fmt.Sprintf("Skipping %s: it runs on %s only", name, strings.Join(task.Platforms, ", ")))))
+		}
+	}
+
+	// Dotenv files do not override variables already set, as in go-task
+	dotenv := append(append([]string(nil), taskfile.Dotenv...), task.Dotenv...)
+	if len(dotenv) > 0 {
+		b.WriteString("\n# Dotenv files\n")
+	}
+	for _, file := range dotenv {
+		path := translate(file, true)
+		if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "$") {
+			path = "${ROOT_DIR}/" + path
+		}
+		b.WriteString(fmt.Sprintf("if [ -f \"%s\" ]; then\n  while IFS= read -r line || [ -n \"$line\" ]; do\n", path))
+		b.WriteString("    line=\"${line#export }\"\n    case \"$line\" in [A-Za-z_]*=*) ;; *) continue ;; esac\n")
+		b.WriteString("    key=\"${line%%=*}\"\n    [ -n \"${!key+x}\" ] || eval \"export $line\"\n")
+		b.WriteString(fmt.Sprintf("  done < \"%s\"\nfi\n", path))
+	}
+
+	var globalNames []string
+	for varName := range taskfile.Vars {
+		globalNames = append(globalNames, varName)
+	}
+	sort.Strings(globalNames)
+	if len(globalNames) > 0 || len(varNames) > 0 {
+		b.WriteString("\n# Variables\n")
+	}
+	for _, varName := range globalNames {
+		switch value := taskfile.Vars[varName].(type) {
+		case DynamicVar:
+			b.WriteString(fmt.Sprintf("%s=\"${%s:-$(%s)}\"\n", varName, varName, translate(value.Sh, false)))
+		case string:
+			b.WriteString(fmt.Sprintf("%s=\"${%s:-%s}\"\n", varName, varName, translate(value, true)))
+		}
+	}
+	for _, varName := range varNames {
+		value := translate(task.Vars[varName], true)
+		if !strings.HasPrefix(value, "${"+varName+":-") {
+			value = "${" + varName + ":-" + value + "}"
+		}
+		b.WriteString(fmt.Sprintf("%s=\"%s\"\n", varName, value))
+	}
+
+	var envNames []string
+	for envName := range taskfile.Env {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+	if len(envNames) > 0 || len(task.Env) > 0 {
+		b.WriteString("\n# Environment\n")
+	}
+	for _, envName := range envNames {
+		b.WriteString(fmt.Sprintf("export %s=\"${%s:-%s}\"\n", envName, envName, translate(taskfile.Env[envName], true)))
+	}
+	envNames = nil
+	for envName := range task.Env {
+		envNames = append(envNames, envName)
+	}
+	sort.Strings(envNames)
+	for _, envName := range envNames {
+		b.WriteString(fmt.Sprintf("export %s=\"%s\"\n", envName, translate(task.Env[envName], true)))
+	}
+
+	if len(task.Deps) > 0 {
+		b.WriteString("\n# Dependencies\n")
+		for _, dep := range task.Deps {
+			b.WriteString(fmt.Sprintf("\"${ROOT_DIR}/%s\"\n", scriptFile(dep)))
+		}
+	}
+
+	if len(task.Preconditions) > 0 {
+		b.WriteString("\n# Preconditions\n")
+		for _, precondition := range task.Preconditions {
+			check := translate(precondition.Sh, false)
+			b.WriteString(fmt.Sprintf("if ! (\n%s\n) >/dev/null 2>&1; then\n  echo %s >&2\n  exit 1\nfi\n", check, shellQuote(precondition.Msg)))
+		}
+	}
+
+	if task.Dir != "" {
+		b.WriteString(fmt.Sprintf("cd \"%s\"\n", translate(task.Dir, true)))
+	}
+
+	// go-task runs the last deferred command first
+	var deferred, cmds []string
+	for _, cmd := range task.Cmds {
+		if strings.HasPrefix(cmd.Cmd, "#") && !strings.Contains(cmd.Cmd, "\n") {
+			cmds = append(cmds, cmd.Cmd)
+			continue
+		}
+		// on_fail steps run only when on_exit was given a failure
+		body, guard := cmd.Cmd, ""
+		if inner, ok := onFailBody(cmd); ok {
+			body, guard = inner, `[ -z "$EXIT_CODE" ] || `
+		}
+		translated := translate(trimLineEnds(body), false)
+		if cmd.raw != "" || (translated == "" && body != "") {
+			translated = unsupported(cmd.String())
+		}
+		step := fmt.Sprintf("%s(\n%s\n)", guard, scriptTaskCalls(translated, scripts, `"${ROOT_DIR}/%s"`))
+		if cmd.Defer {
+			deferred = append([]string{step + " || true"}, deferred...)
+		} else {
+			cmds = append(cmds, step)
+		}
+	}
+	if len(deferred) > 0 {
+		b.WriteString("\n# Deferred commands, run when the script exits\non_exit() {\n  status=$?\n")
+		b.WriteString("  EXIT_CODE=\n  [ \"$status\" -eq 0 ] || EXIT_CODE=$status\n")
+		for _, step := range deferred {
+			b.WriteString(step + "\n")
+		}
+		b.WriteString("  exit \"$status\"\n}\ntrap on_exit EXIT\n")
+	}
+	if len(cmds) > 0 {
+		b.WriteString("\n")
+		b.WriteString(strings.Join(cmds, "\n") + "\n")
+	}
+	return b.String(), failed
+}
+
+// onFailBody returns the command of a deferred on_fail step, without its EXIT_CODE
+// condition
+func onFailBody(cmd TaskCmd) (string, bool) {
+	if !cmd.Defer || !strings.HasPrefix(cmd.Cmd, onFailPrefix) || !strings.HasSuffix(cmd.Cmd, onFailSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(cmd.Cmd, onFailPrefix), onFailSuffix), true
+}
+
+// writeScripts writes scripts/<task>.sh for each job and command task, the tasks the
+// workflows run, and the tasks they call, so the jobs can run without go-task. Untranslatable commands make the
+// script fail when it reaches them and are listed in the report under Scripts.
+func writeScripts(taskfile Taskfile, config, newConfig CircleCIConfig, outputDir string, report *ConversionReport) error {
+	names := scriptTasks(taskfile, config, newConfig)
+	scripts := make(map[string]bool)
+	for _, name := range names {
+		scripts[name] = true
+	}
+	if err := os.MkdirAll(filepath.Join(outputDir, scriptsDir), 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		script, failed := taskScript(name, taskfile.Tasks[name], taskfile, scripts)
+		for _, cmd := range failed {
+			report.Add("Scripts", name, "`%s` uses a go-task template with no bash equivalent; the script stops there", firstLine(cmd))
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, scriptFile(name)), []byte(script), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scriptsEmitter writes the standalone scripts
+type scriptsEmitter struct{}
+
+func (scriptsEmitter) Name() string { return targetScripts }
+
+func (scriptsEmitter) Emit(out emission) ([]string, error) {
+	if err := writeScripts(out.Taskfile, out.Config, out.NewConfig, out.OutputDir, out.Report); err != nil {
+		return nil, fmt.Errorf("error writing scripts: %w", err)
+	}
+	return []string{scriptsDir + "/ (standalone bash scripts of the jobs)"}, nil
+}
+
+// scriptConfigSteps makes the jobs of the new config run the scripts instead of go-task
+func scriptConfigSteps(newConfig *CircleCIConfig, taskfile Taskfile) {
+	scripts := make(map[string]bool)
+	for name := range taskfile.Tasks {
+		scripts[name] = true
+	}
+	for name, job := range newConfig.Jobs {
+		steps := make([]Step, len(job.Steps))
+		for i, step := range job.Steps {
+			steps[i] = step
+			if run, ok := step.(map[string]interface{})["run"].(string); ok {
+				steps[i] = map[string]interface{}{"run": scriptTaskCalls(run, scripts, "./%s")}
+			}
+		}
+		job.Steps = steps
+		newConfig.Jobs[name] = job
+	}
+}
@@ -0,0 +1,164 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretMatch is a value that looks like a hardcoded credential
+type SecretMatch struct {
+	Kind  string
+	Value string
+}
+
+// secretPatterns are well-known credential formats
+var secretPatterns = []struct {
+	Kind  string
+	Regex *regexp.Regexp
+}{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"credential assignment", regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:password|passwd|secret|token|api_?key)[A-Z0-9_]*\s*[=:]\s*["']?([^\s"'$]{6,})`)},
+}
+
+var entropyTokenRegex = regexp.MustCompile(`[A-Za-z0-9+/_-]{24,}={0,2}`)
+
+// sshFingerprintRegex matches SHA256 fingerprints of SSH keys (add_ssh_keys), which
+// look random but are public
+var sshFingerprintRegex = regexp.MustCompile(`SHA256:[A-Za-z0-9+/]{43}=?`)
+
+// secretEnvNameRegex matches env var names that conventionally hold credentials
+var secretEnvNameRegex = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key|credentials?)`)
+
+// findSecrets scans text for values that look like hardcoded credentials
+func findSecrets(text string) []SecretMatch {
+	var matches []SecretMatch
+	seen := make(map[string]bool)
+
+	add := func(kind, value string) {
+		if value == "" || seen[value] {
+			return
+		}
+		seen[value] = true
+		matches = append(matches, SecretMatch{Kind: kind, Value: value})
+	}
+
+	for _, pattern := range secretPatterns {
+		for _, match := range pattern.Regex.FindAllStringSubmatch(text, -1) {
+			value := match[0]
+			if len(match) > 1 {
+				value = match[1]
+			}
+			add(pattern.Kind, value)
+		}
+	}
+
+	for _, token := range entropyTokenRegex.FindAllString(sshFingerprintRegex.ReplaceAllString(text, ""), -1) {
+		if looksHighEntropy(token) {
+			add("high-entropy string", token)
+		}
+	}
+
+	return matches
+}
+
+// looksHighEntropy reports whether a token is a random-looking mixed-case base64 string
+func looksHighEntropy(token string) bool {
+	hasUpper := strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	hasLower := strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz")
+	hasDigit := strings.ContainsAny(token, "0123456789")
+	if !hasUpper || !hasLower || !hasDigit {
+		return false
+	}
+	return shannonEntropy(token) >= 4.2
+}
+
+// shannonEntropy returns the per-character entropy of a string in bits
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	length := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactValue masks a secret, keeping a short prefix so it can still be located
+func redactValue(value string) string {
+	if len(value) <= 4 {
+		return "[REDACTED]"
+	}
+	return value[:4] + "…[REDACTED]"
+}
+
+// redactSecrets masks every suspected secret in text
+func redactSecrets(text string) string {
+	matches := findSecrets(text)
+	// Replace longer values first so overlapping matches are fully masked
+	sort.Slice(matches, func(i, j int) bool {
+		return len(matches[i].Value) > len(matches[j].Value)
+	})
+	for _, match := range matches {
+		text = strings.ReplaceAll(text, match.Value, redactValue(match.Value))
+	}
+	return text
+}
+
+// auditSecrets flags hardcoded credentials in job/command steps and environment maps
+func auditSecrets(config CircleCIConfig, report *ConversionReport) {
+	flag := func(location, owner string, text string) {
+		for _, match := range findSecrets(text) {
+			report.Add("Secrets", owner, "%s: possible %s `%s` is copied into the generated files; move it to a secret store", location, match.Kind, redactValue(match.Value))
+		}
+	}
+	flagEnv := func(location, owner string, env map[string]string) {
+		for key, value := range env {
+			if strings.Contains(value, "$") || strings.Contains(value, "<<") {
+				continue
+			}
+			if secretEnvNameRegex.MatchString(key) && value != "" {
+				report.Add("Secrets", owner, "%s: %s has a hardcoded value `%s`; move it to a secret store", location, key, redactValue(value))
+				continue
+			}
+			flag(fmt.Sprintf("%s %s", location, key), owner, value)
+		}
+	}
+
+	for jobName, job := range config.Jobs {
+		for i, step := range job.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				flag(fmt.Sprintf("step %d", i+1), jobName, cmd)
+			}
+			flagEnv(fmt.Sprintf("step %d environment", i+1), jobName, runStepEnvironment(step))
+		}
+		env := make(map[string]string)
+		mergeEnvironment(env, job.Environment)
+		flagEnv("environment", jobName, env)
+	}
+
+	for commandName, command := range config.Commands {
+		for i, step := range command.Steps {
+			if cmd := extractCommand(step); cmd != "" {
+				flag(fmt.Sprintf("command step %d", i+1), commandName, cmd)
+			}
+			flagEnv(fmt.Sprintf("command step %d environment", i+1), commandName, runStepEnvironment(step))
+		}
+	}
+
+	for executorName, def := range config.Executors {
+		if defMap, ok := def.(map[string]interface{}); ok {
+			resolved := parseExecutorDefinition(executorName, defMap)
+			flagEnv("executor environment", executorName, resolved.Environment)
+		}
+	}
+}
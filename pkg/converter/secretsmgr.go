@@ -0,0 +1,351 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretsManagers are the values accepted by -secrets-manager
+var secretsManagers = []string{"vault", "doppler", "1password"}
+
+// defaultContext groups variables used by jobs that run without a CircleCI context;
+// they come from project-level environment variables
+const defaultContext = "project"
+
+// SecretVar is an environment variable that must move to a secrets manager
+type SecretVar struct {
+	Name       string
+	Contexts   []string
+	Jobs       []string
+	Hardcoded  bool // the config sets a literal value that has to be rotated
+	OnCircleCI bool // listed by the context on CircleCI, not referenced by the config
+}
+
+var envRefRegex = regexp.MustCompile(`\$\{?([A-Z_][A-Z0-9_]*)\}?`)
+
+// builtinEnvVars are provided by the runner or the shell, not by contexts
+var builtinEnvVars = map[string]bool{
+	"HOME": true, "PWD": true, "PATH": true, "USER": true, "SHELL": true, "BASH_ENV": true,
+	"CI": true, "CIRCLECI": true, "TERM": true, "TMPDIR": true, "HOSTNAME": true,
+}
+
+// jobContexts maps each job to the contexts its workflow invocations attach
+func jobContexts(workflows map[string]Workflow) map[string][]string {
+	contexts := make(map[string][]string)
+	for _, invocation := range extractWorkflowJobs(workflows) {
+		for _, context := range invocation.Context {
+			if !containsString(contexts[invocation.Job], context) {
+				contexts[invocation.Job] = append(contexts[invocation.Job], context)
+			}
+		}
+	}
+	return contexts
+}
+
+// jobEnvReferences returns the env vars a job's steps reference, following custom commands
+func jobEnvReferences(steps []Step, commands map[string]Command, seen map[string]bool) map[string]bool {
+	refs := make(map[string]bool)
+	for _, step := range steps {
+		for _, match := range envRefRegex.FindAllStringSubmatch(extractCommand(step), -1) {
+			refs[match[1]] = true
+		}
+
+		name, ok := isCommandInvocation(step)
+		if stepName, isString := step.(string); isString {
+			name, ok = stepName, true
+		}
+		if command, exists := commands[name]; ok && exists && !seen[name] {
+			seen[name] = true
+			for ref := range jobEnvReferences(command.Steps, commands, seen) {
+				refs[ref] = true
+			}
+		}
+	}
+	return refs
+}
+
+// jobStepEnvironment returns the variables the run steps of a job, and of the commands
+// it invokes, set in their `environment:`
+func jobStepEnvironment(steps []Step, commands map[string]Command, seen map[string]bool) map[string]bool {
+	names := make(map[string]bool)
+	for _, step := range steps {
+		for name := range runStepEnvironment(step) {
+			names[name] = true
+		}
+
+		name, ok := isCommandInvocation(step)
+		if stepName, isString := step.(string); isString {
+			name, ok = stepName, true
+		}
+		if command, exists := commands[name]; ok && exists && !seen[name] {
+			seen[name] = true
+			for set := range jobStepEnvironment(command.Steps, commands, seen) {
+				names[set] = true
+			}
+		}
+	}
+	return names
+}
+
+// collectSecretVars lists the variables a secrets manager has to provide: credentials
+// referenced by jobs, anything a context-bound job uses that the config does not
+// define, and credentials the config hardcodes
+func collectSecretVars(config CircleCIConfig) []SecretVar {
+	contexts := jobContexts(config.Workflows)
+	vars := make(map[string]*SecretVar)
+
+	// Variables exported to $BASH_ENV are set by the tasks' commands
+	exported := make(map[string]bool)
+	for _, name := range bashEnvExports(config) {
+		exported[name] = true
+	}
+
+	add := func(name, job string, hardcoded bool) {
+		v, ok := vars[name]
+		if !ok {
+			v = &SecretVar{Name: name}
+			vars[name] = v
+		}
+		v.Hardcoded = v.Hardcoded || hardcoded
+		if !containsString(v.Jobs, job) {
+			v.Jobs = append(v.Jobs, job)
+		}
+		jobContexts := contexts[job]
+		if len(jobContexts) == 0 {
+			jobContexts = []string{defaultContext}
+		}
+		for _, context := range jobContexts {
+			if !containsString(v.Contexts, context) {
+				v.Contexts = append(v.Contexts, context)
+			}
+		}
+	}
+
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		job := config.Jobs[jobName]
+
+		// Variables the job or its executor defines don't come from a context
+		defined := make(map[string]string)
+		if resolved, err := resolveJobExecutor(job, config.Executors); err == nil {
+			for k, v := range resolved.Environment {
+				defined[k] = v
+			}
+		}
+		mergeEnvironment(defined, job.Environment)
+
+		for name, value := range defined {
+			if secretEnvNameRegex.MatchString(name) && value != "" && !strings.Contains(value, "$") && !strings.Contains(value, "<<") {
+				add(name, jobName, true)
+			}
+		}
+
+		// Nor do the variables its steps set in their environment
+		stepEnv := jobStepEnvironment(job.Steps, config.Commands, make(map[string]bool))
+		for ref := range jobEnvReferences(job.Steps, config.Commands, make(map[string]bool)) {
+			if _, ok := defined[ref]; ok || stepEnv[ref] || exported[ref] || builtinEnvVars[ref] || strings.HasPrefix(ref, "CIRCLE_") {
+				continue
+			}
+			if secretEnvNameRegex.MatchString(ref) || len(contexts[jobName]) > 0 {
+				add(ref, jobName, false)
+			}
+		}
+	}
+
+	var result []SecretVar
+	for _, v := range vars {
+		sort.Strings(v.Contexts)
+		sort.Strings(v.Jobs)
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// secretsByContext groups secret variable names by the context that provides them.
+// A job attached to several contexts doesn't say which one holds a variable, so it
+// goes to the first and the choice is reported.
+func secretsByContext(vars []SecretVar, report *ConversionReport) (map[string][]string, []string) {
+	byContext := make(map[string][]string)
+	for _, v := range vars {
+		context := v.Contexts[0]
+		if len(v.Contexts) > 1 {
+			report.Add("Secrets", strings.Join(v.Jobs, ", "), "%s could come from any of the contexts %s; it was placed under %s", v.Name, strings.Join(v.Contexts, ", "), context)
+		}
+		byContext[context] = append(byContext[context], v.Name)
+	}
+	var contexts []string
+	for context := range byContext {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+	return byContext, contexts
+}
+
+// parseSecretsManagers validates a comma-separated -secrets-manager value
+func parseSecretsManagers(value string) ([]string, error) {
+	var managers []string
+	for _, manager := range strings.Split(value, ",") {
+		manager = strings.ToLower(strings.TrimSpace(manager))
+		if manager == "" {
+			continue
+		}
+		if !containsString(secretsManagers, manager) {
+			return nil, fmt.Errorf("unknown secrets manager %q (supported: %s)", manager, strings.Join(secretsManagers, ", "))
+		}
+		managers = append(managers, manager)
+	}
+	return managers, nil
+}
+
+// projectName guesses the project name from the input path (<repo>/.circleci/config.yml)
+func projectName(inputFile string) string {
+	dir, err := filepath.Abs(filepath.Dir(inputFile))
+	if err != nil {
+		return "project"
+	}
+	if filepath.Base(dir) == ".circleci" {
+		dir = filepath.Dir(dir)
+	}
+	if filepath.Base(dir) == "workflows" && filepath.Base(filepath.Dir(dir)) == ".github" {
+		dir = filepath.Dir(filepath.Dir(dir))
+	}
+	name := strings.ToLower(filepath.Base(dir))
+	if name == "" || name == "/" || name == "." {
+		return "project"
+	}
+	return name
+}
+
+// generateSecretsTemplates writes secrets manager scaffolding to <output>/secrets and,
+// for 1Password, adds `op run` wrapper tasks to the Taskfile
+func generateSecretsTemplates(config CircleCIConfig, taskfile *Taskfile, managers []string, project, outputDir string, report *ConversionReport) error {
+	vars := collectSecretVars(config)
+	if len(vars) == 0 {
+		report.Add("Secrets", "", "no secrets or context variables found; no secrets manager templates written")
+		return nil
+	}
+
+	secretsDir := filepath.Join(outputDir, "secrets")
+	if err := os.MkdirAll(secretsDir, 0755); err != nil {
+		return fmt.Errorf("error creating secrets directory: %w", err)
+	}
+
+	byContext, contexts := secretsByContext(vars, report)
+
+	for _, manager := range managers {
+		var err error
+		switch manager {
+		case "vault":
+			err = writeVaultTemplates(byContext, contexts, project, secretsDir)
+		case "doppler":
+			err = writeDopplerTemplates(vars, project, secretsDir)
+		case "1password":
+			err = write1PasswordTemplates(byContext, contexts, project, secretsDir)
+			if err == nil {
+				addOpRunTasks(taskfile, vars)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		report.Add("Secrets", "", "%s templates for %d variables written to secrets/", manager, len(vars))
+	}
+	return nil
+}
+
+// writeVaultTemplates writes a read policy and a kv layout script for HashiCorp Vault
+func writeVaultTemplates(byContext map[string][]string, contexts []string, project, dir string) error {
+	var policy strings.Builder
+	policy.WriteString(fmt.Sprintf("# Vault policy granting read access to %s secrets (KV v2 mounted at secret/)\n", project))
+	policy.WriteString(fmt.Sprintf("# vault policy write %s-ci secrets/vault-policy.hcl\n\n", project))
+	for _, context := range contexts {
+		policy.WriteString(fmt.Sprintf("path \"secret/data/%s/%s\" {\n  capabilities = [\"read\"]\n}\n\n", project, context))
+	}
+
+	var layout strings.Builder
+	layout.WriteString("#!/bin/sh\n")
+	layout.WriteString("# Seeds Vault with one KV entry per CircleCI context, from the current environment.\n")
+	layout.WriteString("# Export the values (e.g. copied from the CircleCI context) before running.\n")
+	layout.WriteString("set -eu\n\n")
+	for _, context := range contexts {
+		var pairs []string
+		for _, name := range byContext[context] {
+			pairs = append(pairs, fmt.Sprintf("%s=\"$%s\"", name, name))
+		}
+		layout.WriteString(fmt.Sprintf("vault kv put secret/%s/%s \\\n    %s\n\n", project, context, strings.Join(pairs, " \\\n    ")))
+	}
+
+	if err := writeTextFile(filepath.Join(dir, "vault-policy.hcl"), policy.String()); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "vault-layout.sh"), []byte(layout.String()), 0755)
+}
+
+// writeDopplerTemplates writes a doppler.yaml setup file and an upload template
+func writeDopplerTemplates(vars []SecretVar, project, dir string) error {
+	setup := fmt.Sprintf("# doppler setup reads this file; run tasks with: doppler run -- task <job>\nsetup:\n  project: %s\n  config: dev\n", project)
+
+	var env strings.Builder
+	env.WriteString("# Fill in the values, then: doppler secrets upload secrets/doppler-secrets.env\n")
+	env.WriteString("# Do not commit this file once it contains values.\n")
+	for _, v := range vars {
+		note := ""
+		if v.Hardcoded {
+			note = "; hardcoded in the CircleCI config, rotate it"
+		}
+		env.WriteString(fmt.Sprintf("# used by %s (context: %s%s)\n%s=\n", strings.Join(v.Jobs, ", "), strings.Join(v.Contexts, ", "), note, v.Name))
+	}
+
+	if err := writeTextFile(filepath.Join(dir, "doppler.yaml"), setup); err != nil {
+		return err
+	}
+	return writeTextFile(filepath.Join(dir, "doppler-secrets.env"), env.String())
+}
+
+// write1PasswordTemplates writes an env file of op:// references, one item per context
+func write1PasswordTemplates(byContext map[string][]string, contexts []string, project, dir string) error {
+	var env strings.Builder
+	env.WriteString(fmt.Sprintf("# 1Password secret references for op run; create one item per context in the %q vault\n", project))
+	env.WriteString("# with a field per variable. Safe to commit: it contains no secret values.\n")
+	for _, context := range contexts {
+		env.WriteString(fmt.Sprintf("\n# context: %s\n", context))
+		for _, name := range byContext[context] {
+			env.WriteString(fmt.Sprintf("%s=op://%s/%s/%s\n", name, project, context, name))
+		}
+	}
+
+	return writeTextFile(filepath.Join(dir, "op.env"), env.String())
+}
+
+// addOpRunTasks adds op:<job> tasks running each job with its secrets injected by `op run`
+func addOpRunTasks(taskfile *Taskfile, vars []SecretVar) {
+	jobs := make(map[string]bool)
+	for _, v := range vars {
+		for _, job := range v.Jobs {
+			jobs[job] = true
+		}
+	}
+	for job := range jobs {
+		if _, ok := taskfile.Tasks[job]; !ok {
+			continue
+		}
+		taskfile.Tasks["op:"+job] = Task{
+			Desc: fmt.Sprintf("Run %s with secrets from 1Password", job),
+			Cmds: shellCommands(fmt.Sprintf("op run --env-file=secrets/op.env -- task %s", job)),
+		}
+	}
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
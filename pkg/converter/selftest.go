@@ -0,0 +1,198 @@
+package converter
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// selftestCorpus holds representative configs (<case>.yml) with their expectations
+// (<case>.expect.yml) and the vendored orbs they use (orbs/)
+//
+//go:embed selftest
+var selftestCorpus embed.FS
+
+// SelftestExpectation lists what the conversion of a corpus config must produce
+type SelftestExpectation struct {
+	Tasks         []string            `yaml:"tasks"`         // tasks that must exist
+	Absent        []string            `yaml:"absent"`        // tasks that must not exist
+	Contains      map[string][]string `yaml:"contains"`      // task → substrings of its commands
+	Vars          map[string][]string `yaml:"vars"`          // task → variables it must declare
+	Preconditions []string            `yaml:"preconditions"` // tasks that must have preconditions
+	Report        []string            `yaml:"report"`        // report categories that must have entries
+}
+
+// runSelftestCommand implements the `selftest` subcommand: it converts the embedded
+// corpus and checks the results, to verify a build behaves correctly on this platform
+func runSelftestCommand(args []string) error {
+	// Not named fs: that is the io/fs package in this file
+	flags := flag.NewFlagSet("selftest", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "List every check, not only failures")
+	flags.Parse(args)
+
+	orbsDir, err := os.MkdirTemp("", "circle-to-task-selftest-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(orbsDir)
+	if err := extractSelftestOrbs(orbsDir); err != nil {
+		return err
+	}
+
+	entries, err := selftestCorpus.ReadDir("selftest")
+	if err != nil {
+		return fmt.Errorf("error reading corpus: %w", err)
+	}
+
+	failedCases := 0
+	total := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".expect.yml") {
+			continue
+		}
+		caseName := strings.TrimSuffix(name, ".yml")
+		total++
+
+		checks, failures := runSelftestCase(caseName, orbsDir)
+		if len(failures) > 0 {
+			failedCases++
+			fmt.Printf("❌ FAIL %s (%d of %d checks failed)\n", caseName, len(failures), checks)
+			for _, failure := range failures {
+				fmt.Printf("     - %s\n", failure)
+			}
+		} else {
+			fmt.Printf("✅ PASS %s", caseName)
+			if *verbose {
+				fmt.Printf(" (%d checks)", checks)
+			}
+			fmt.Println()
+		}
+	}
+
+	if failedCases > 0 {
+		return fmt.Errorf("selftest: %d of %d corpus configs failed (circle-to-task %s)", failedCases, total, Version)
+	}
+	fmt.Printf("\nAll %d corpus configs converted as expected (circle-to-task %s)\n", total, Version)
+	return nil
+}
+
+// extractSelftestOrbs copies the embedded vendored orbs to dir for the offline resolver
+func extractSelftestOrbs(dir string) error {
+	return fs.WalkDir(selftestCorpus, "selftest/orbs", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := selftestCorpus.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(p, "selftest/orbs/")))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
+
+// runSelftestCase converts one corpus config and returns the number of checks made
+// and the failed ones
+func runSelftestCase(caseName, orbsDir string) (int, []string) {
+	checks := 0
+	var failures []string
+	check := func(ok bool, format string, args ...interface{}) {
+		checks++
+		if !ok {
+			failures = append(failures, fmt.Sprintf(format, args...))
+		}
+	}
+
+	file := path.Join("selftest", caseName+".yml")
+	data, err := selftestCorpus.ReadFile(file)
+	if err != nil {
+		return 1, []string{err.Error()}
+	}
+	var expect SelftestExpectation
+	if expectData, err := selftestCorpus.ReadFile(path.Join("selftest", caseName+".expect.yml")); err == nil {
+		if err := yaml.Unmarshal(expectData, &expect); err != nil {
+			return 1, []string{fmt.Sprintf("invalid expectations: %v", err)}
+		}
+	}
+
+	config, err := parseConfig(file, data)
+	if err != nil {
+		return 1, []string{fmt.Sprintf("parse failed: %v", err)}
+	}
+
+	report := &ConversionReport{}
+	newConfig, taskfile := convertConfig(config, ConvertOptions{Orbs: NewOrbResolver(orbsDir, true, "", "")}, report)
+
+	// Every conversion: one task per job, and slim jobs that only call their task
+	for jobName := range config.Jobs {
+		_, ok := taskfile.Tasks[jobName]
+		check(ok, "job %s has no task", jobName)
+
+		steps := newConfig.Jobs[jobName].Steps
+		check(len(steps) == 1 && reflect.DeepEqual(steps[0], map[string]interface{}{"run": "task " + jobName}),
+			"slim config job %s should only run `task %s`", jobName, jobName)
+	}
+
+	// The Taskfile must survive a YAML round trip
+	out, err := yaml.Marshal(taskfile)
+	check(err == nil, "Taskfile does not marshal: %v", err)
+	var roundTrip Taskfile
+	err = yaml.Unmarshal(out, &roundTrip)
+	check(err == nil && len(roundTrip.Tasks) == len(taskfile.Tasks), "Taskfile does not round-trip through YAML")
+
+	for _, name := range expect.Tasks {
+		_, ok := taskfile.Tasks[name]
+		check(ok, "expected task %s is missing", name)
+	}
+	for _, name := range expect.Absent {
+		_, ok := taskfile.Tasks[name]
+		check(!ok, "task %s should not exist", name)
+	}
+	for _, name := range sortedKeysOf(expect.Contains) {
+		cmds := strings.Join(taskfile.Tasks[name].Cmds.lines(), "\n")
+		for _, want := range expect.Contains[name] {
+			check(strings.Contains(cmds, want), "task %s commands do not contain %q", name, want)
+		}
+	}
+	for _, name := range sortedKeysOf(expect.Vars) {
+		for _, want := range expect.Vars[name] {
+			_, ok := taskfile.Tasks[name].Vars[want]
+			check(ok, "task %s does not declare var %s", name, want)
+		}
+	}
+	for _, name := range expect.Preconditions {
+		check(len(taskfile.Tasks[name].Preconditions) > 0, "task %s has no preconditions", name)
+	}
+	for _, category := range expect.Report {
+		found := false
+		for _, entry := range report.Entries {
+			found = found || entry.Category == category
+		}
+		check(found, "report has no %s entries", category)
+	}
+
+	return checks, failures
+}
+
+// sortedKeysOf returns the keys of a string-list map in sorted order
+func sortedKeysOf(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
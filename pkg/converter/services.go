@@ -0,0 +1,232 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFileName is the compose file of the jobs' service containers
+const composeFileName = "docker-compose.yml"
+
+// ServiceContainer is a secondary docker image of an executor: a service (database,
+// cache) the job's steps reach on localhost
+type ServiceContainer struct {
+	Name        string // the image's `name:`, empty when unset
+	Image       string
+	Environment map[string]string
+	Command     interface{}
+	Entrypoint  interface{}
+}
+
+// ComposeService is a service of the generated compose file
+type ComposeService struct {
+	Image       string              `yaml:"image"`
+	Environment map[string]string   `yaml:"environment,omitempty"`
+	Command     interface{}         `yaml:"command,omitempty"`
+	Entrypoint  interface{}         `yaml:"entrypoint,omitempty"`
+	Ports       []string            `yaml:"ports,omitempty"`
+	Healthcheck *ComposeHealthcheck `yaml:"healthcheck,omitempty"`
+	Profiles    []string            `yaml:"profiles"`
+}
+
+// ComposeHealthcheck lets `docker compose up --wait` wait until a service is ready
+type ComposeHealthcheck struct {
+	Test     []string `yaml:"test"`
+	Interval string   `yaml:"interval"`
+	Retries  int      `yaml:"retries"`
+}
+
+// serviceImagePorts are the ports services listen on, by image name. CircleCI runs
+// every container of a job in one network namespace, so they are published on
+// localhost where the steps expect them.
+var serviceImagePorts = map[string][]string{
+	"postgres": {"5432"}, "postgis": {"5432"}, "mysql": {"3306"}, "mariadb": {"3306"},
+	"redis": {"6379"}, "mongo": {"27017"}, "mongodb": {"27017"}, "memcached": {"11211"},
+	"rabbitmq": {"5672", "15672"}, "elasticsearch": {"9200"}, "opensearch": {"9200"},
+	"kafka": {"9092"}, "zookeeper": {"2181"}, "localstack": {"4566"}, "minio": {"9000"},
+	"dynamodb-local": {"8000"}, "selenium": {"4444"}, "mailhog": {"1025", "8025"},
+}
+
+// serviceImageHealthchecks are readiness checks by image name
+var serviceImageHealthchecks = map[string][]string{
+	"postgres": {"CMD-SHELL", "pg_isready -U \"$${POSTGRES_USER:-postgres}\""},
+	"postgis":  {"CMD-SHELL", "pg_isready -U \"$${POSTGRES_USER:-postgres}\""},
+	"mysql":    {"CMD-SHELL", "mysqladmin ping -h 127.0.0.1 --silent"},
+	"mariadb":  {"CMD-SHELL", "mysqladmin ping -h 127.0.0.1 --silent || healthcheck.sh --connect"},
+	"redis":    {"CMD", "redis-cli", "ping"},
+	"mongo":    {"CMD-SHELL", "mongosh --quiet --eval 'db.runCommand({ping: 1})' || mongo --quiet --eval 'db.runCommand({ping: 1})'"},
+}
+
+// composeNameRegex matches characters compose service and profile names cannot hold
+var composeNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// parseServiceContainer reads a secondary image entry of a docker executor
+func parseServiceContainer(image map[string]interface{}) (ServiceContainer, bool) {
+	name, ok := image["image"].(string)
+	if !ok || name == "" {
+		return ServiceContainer{}, false
+	}
+	service := ServiceContainer{
+		Image:       name,
+		Environment: make(map[string]string),
+		Command:     image["command"],
+		Entrypoint:  image["entrypoint"],
+	}
+	service.Name, _ = image["name"].(string)
+	mergeEnvironment(service.Environment, image["environment"])
+	return service, true
+}
+
+// serviceImageName is the name of a service's image without registry, owner and tag
+// (cimg/postgres:14.1 → postgres)
+func serviceImageName(image string) string {
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.IndexAny(name, ":@"); i != -1 {
+		name = name[:i]
+	}
+	return name
+}
+
+// composeName makes a name usable as a compose service or profile
+func composeName(name string) string {
+	name = strings.Trim(composeNameRegex.ReplaceAllString(name, "-"), "-._")
+	if name == "" {
+		return "service"
+	}
+	return name
+}
+
+// composeService converts a service container into a compose service
+func composeService(service ServiceContainer) ComposeService {
+	imageName := serviceImageName(service.Image)
+	converted := ComposeService{
+		Image:       service.Image,
+		Environment: service.Environment,
+		Command:     service.Command,
+		Entrypoint:  service.Entrypoint,
+	}
+	if len(converted.Environment) == 0 {
+		converted.Environment = nil
+	}
+	for _, port := range serviceImagePorts[imageName] {
+		converted.Ports = append(converted.Ports, port+":"+port)
+	}
+	if test, ok := serviceImageHealthchecks[imageName]; ok {
+		converted.Healthcheck = &ComposeHealthcheck{Test: test, Interval: "2s", Retries: 30}
+	}
+	return converted
+}
+
+// JobServices are the compose services of the config's jobs
+type JobServices struct {
+	Services map[string]ComposeService // by compose service name
+	Jobs     map[string][]string       // job to its compose service names
+}
+
+// collectJobServices gathers the service containers of every job into compose
+// services. Jobs using the same image with the same settings share a service, whose
+// profiles name the jobs; other settings get a service named after the job.
+func collectJobServices(config CircleCIConfig) JobServices {
+	collected := JobServices{
+		Services: make(map[string]ComposeService),
+		Jobs:     make(map[string][]string),
+	}
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		resolved, err := resolveJobExecutor(config.Jobs[jobName], config.Executors)
+		if err != nil {
+			continue
+		}
+		profile := composeName(jobName)
+		for _, service := range resolved.Services {
+			converted := composeService(service)
+			name := composeName(service.Name)
+			if service.Name == "" {
+				name = composeName(serviceImageName(service.Image))
+			}
+			if existing, ok := collected.Services[name]; ok && !sameComposeService(existing, converted) {
+				name = composeName(jobName + "-" + name)
+			}
+			if existing, ok := collected.Services[name]; ok {
+				converted.Profiles = existing.Profiles
+			}
+			converted.Profiles = append(converted.Profiles, profile)
+			collected.Services[name] = converted
+			collected.Jobs[jobName] = append(collected.Jobs[jobName], name)
+		}
+	}
+	return collected
+}
+
+// sameComposeService reports whether two services differ only in their profiles
+func sameComposeService(a, b ComposeService) bool {
+	a.Profiles, b.Profiles = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// composeCommand runs docker compose on the generated file for a job's services
+func composeCommand(jobName, args string) string {
+	return fmt.Sprintf(`docker compose -f "{{.ROOT_DIR}}/%s" --profile %s %s`, composeFileName, composeName(jobName), args)
+}
+
+// addServiceContainers starts the service containers of jobs with several docker
+// images with docker compose before their commands, and stops them when the task
+// ends. On CircleCI, where the executor already runs them, the commands do nothing.
+func addServiceContainers(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	services := collectJobServices(config)
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		names := services.Jobs[jobName]
+		task, ok := taskfile.Tasks[jobName]
+		if len(names) == 0 || !ok {
+			continue
+		}
+		up := TaskCmd{Cmd: fmt.Sprintf(`[ -n "${CIRCLECI:-}" ] || %s`, composeCommand(jobName, "up -d --wait"))}
+		down := deferredCommand(fmt.Sprintf(`[ -n "${CIRCLECI:-}" ] || %s`, composeCommand(jobName, "down")))
+		task.Cmds = append(TaskCmds{up, down}, task.Cmds...)
+		taskfile.Tasks[jobName] = task
+
+		var described, unpublished []string
+		for _, name := range names {
+			service := services.Services[name]
+			described = append(described, fmt.Sprintf("%s (%s)", name, service.Image))
+			if len(service.Ports) == 0 {
+				unpublished = append(unpublished, name)
+			}
+		}
+		report.Add("Service containers", jobName, "runs %s with docker compose (see %s)", strings.Join(described, ", "), composeFileName)
+		if len(unpublished) > 0 {
+			report.Add("Service containers", jobName, "ports of %s are unknown; publish them in %s", strings.Join(unpublished, ", "), composeFileName)
+		}
+	}
+}
+
+// generateComposeFile writes docker-compose.yml with the service containers of the
+// jobs, and reports whether any job has some
+func generateComposeFile(config CircleCIConfig, outputDir string) (bool, error) {
+	services := collectJobServices(config)
+	if len(services.Services) == 0 {
+		return false, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("# Service containers of the CircleCI jobs (their secondary docker images).\n")
+	b.WriteString("# Each job task starts its profile: docker compose --profile <job> up -d --wait\n")
+	b.WriteString("# Ports are published on localhost, where CircleCI's steps reach the services;\n")
+	b.WriteString("# add the ports of images the converter does not know.\n")
+	var doc bytes.Buffer
+	encoder := yaml.NewEncoder(&doc)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]interface{}{"services": services.Services}); err != nil {
+		return false, err
+	}
+	encoder.Close()
+	b.Write(doc.Bytes())
+
+	return true, os.WriteFile(filepath.Join(outputDir, composeFileName), []byte(b.String()), 0644)
+}
@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// shellLibPath is where -shell-lib writes the function library, relative to the Taskfile
+const shellLibPath = "scripts/ci-lib.sh"
+
+// shellFunctionRegex matches characters not allowed in a shell function name
+var shellFunctionRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// shellFunctionName names the function of a pattern task. The ci_ prefix keeps it from
+// shadowing the command it wraps (a pattern `npm ci` would otherwise define npm).
+func shellFunctionName(taskName string) string {
+	return "ci_" + shellFunctionRegex.ReplaceAllString(taskName, "_")
+}
+
+// applyShellLib replaces the commands matching a pattern with calls to shell functions
+// defined in scripts/ci-lib.sh, instead of dependencies on pattern tasks. Calls stay
+// in step order, and consecutive calls share one command. The library is kept on the
+// Taskfile for main to write.
+func applyShellLib(taskfile *Taskfile, patterns map[string]Task, report *ConversionReport) {
+	if len(patterns) == 0 {
+		return
+	}
+	source := fmt.Sprintf(`. "{{.TASKFILE_DIR}}/%s"`, shellLibPath)
+
+	var taskNames []string
+	for name := range taskfile.Tasks {
+		taskNames = append(taskNames, name)
+	}
+	sort.Strings(taskNames)
+
+	bodies := make(map[string]string)
+	uses := make(map[string][]string)
+	for _, name := range taskNames {
+		task := taskfile.Tasks[name]
+		var cmds TaskCmds
+		changed := false
+		for _, cmd := range task.Cmds {
+			pattern := ""
+			if !cmd.Defer {
+				pattern = findPatternTask(normalizeCommand(cmd.Cmd), patterns)
+			}
+			if pattern == "" {
+				cmds = append(cmds, cmd)
+				continue
+			}
+			// The body keeps the first occurrence as written, line breaks included
+			if _, ok := bodies[pattern]; !ok {
+				bodies[pattern] = cmd.Cmd
+			}
+			uses[pattern] = append(uses[pattern], name)
+			call := shellFunctionName(pattern)
+			if changed && len(cmds) > 0 && strings.HasPrefix(cmds[len(cmds)-1].Cmd, source+" && ") {
+				cmds[len(cmds)-1].Cmd += " && " + call
+			} else {
+				cmds = append(cmds, TaskCmd{Cmd: source + " && " + call})
+			}
+			changed = true
+		}
+		if changed {
+			task.Cmds = cmds
+			taskfile.Tasks[name] = task
+		}
+	}
+	if len(bodies) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/usr/bin/env bash\n")
+	b.WriteString(fmt.Sprintf("# Shared CI steps, generated by circle-to-task %s from commands repeated across jobs.\n", Version))
+	b.WriteString("# Tasks source this file and call the functions they need.\n")
+	names := make([]string, 0, len(bodies))
+	for name := range bodies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		usedBy := strings.Join(uniqueStrings(uses[name]), ", ")
+		b.WriteString(fmt.Sprintf("\n# Used by %s\n", usedBy))
+		b.WriteString(shellFunctionName(name) + "() {\n")
+		b.WriteString(indentFunctionBody(bodies[name]))
+		b.WriteString("}\n")
+		report.Add("Shell library", "", "`%s` is repeated in %s; defined as %s in %s", firstLine(bodies[name]), usedBy, shellFunctionName(name), shellLibPath)
+	}
+	taskfile.shellLib = b.String()
+}
+
+// indentFunctionBody indents a command for a function body. Commands with heredocs
+// are left as they are, since indenting would change the heredoc contents.
+func indentFunctionBody(cmd string) string {
+	cmd = strings.TrimRight(cmd, "\n")
+	if strings.Contains(cmd, "<<") {
+		return cmd + "\n"
+	}
+	lines := strings.Split(cmd, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// uniqueStrings returns values without repeats, keeping the first occurrence of each
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// writeShellLib writes the shell function library collected by applyShellLib
+func writeShellLib(taskfile Taskfile, outputDir string) error {
+	path := filepath.Join(outputDir, shellLibPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeTextFile(path, taskfile.shellLib); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0755)
+}
@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sshKeyFingerprints returns the fingerprints of an add_ssh_keys step, none meaning all
+// the project's keys
+func sshKeyFingerprints(value interface{}) []string {
+	settings, _ := value.(map[string]interface{})
+	list, _ := settings["fingerprints"].([]interface{})
+	var fingerprints []string
+	for _, item := range list {
+		if fingerprint, ok := item.(string); ok && fingerprint != "" {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	return fingerprints
+}
+
+// sshFingerprintMatch returns the ssh-keygen hash option and the text its listings show
+// for a fingerprint: SHA256:... as is, and MD5 colon-separated hex with an MD5: prefix
+func sshFingerprintMatch(fingerprint string) (string, string) {
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return "sha256", fingerprint
+	}
+	return "md5", "MD5:" + strings.ToLower(strings.TrimPrefix(fingerprint, "MD5:"))
+}
+
+// sshKeyLoaded checks whether ssh-agent holds the key with a fingerprint
+func sshKeyLoaded(fingerprint string) string {
+	hash, match := sshFingerprintMatch(fingerprint)
+	return fmt.Sprintf("ssh-add -l -E %s 2>/dev/null | grep -qF %s", hash, shellQuote(match))
+}
+
+// sshKeyFiles lists the public keys in ~/.ssh with their fingerprints
+func sshKeyFiles(hash string) string {
+	return fmt.Sprintf(`for k in ~/.ssh/*.pub; do ssh-keygen -l -E %s -f "$k" 2>/dev/null; done`, hash)
+}
+
+// addSSHKeysCommand converts an add_ssh_keys step: keys of ~/.ssh with the step's
+// fingerprints are added to the running ssh-agent, unless it holds them already.
+// Without fingerprints CircleCI adds every key of the project; locally the agent's
+// keys are used as they are.
+func addSSHKeysCommand(value interface{}) string {
+	fingerprints := sshKeyFingerprints(value)
+	if len(fingerprints) == 0 {
+		return "ssh-add -l >/dev/null 2>&1 || echo 'add_ssh_keys: no keys in ssh-agent; add the keys the job needs with ssh-add' >&2"
+	}
+	lines := make([]string, len(fingerprints))
+	for i, fingerprint := range fingerprints {
+		hash, match := sshFingerprintMatch(fingerprint)
+		lines[i] = fmt.Sprintf(`[ -z "$SSH_AUTH_SOCK" ] || %s || for k in ~/.ssh/*.pub; do ! ssh-keygen -l -E %s -f "$k" 2>/dev/null | grep -qF %s || ssh-add "${k%%.pub}"; done`,
+			sshKeyLoaded(fingerprint), hash, shellQuote(match))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sshKeySteps lists the add_ssh_keys steps among steps, including those nested in
+// when/unless steps
+func sshKeySteps(steps []Step) []Step {
+	var found []Step
+	for _, step := range steps {
+		switch kind := stepType(step); kind {
+		case "add_ssh_keys":
+			found = append(found, step)
+		case "when", "unless":
+			if body, ok := step.(map[string]interface{})[kind].(map[string]interface{}); ok {
+				nested, _ := body["steps"].([]interface{})
+				for _, s := range nested {
+					found = append(found, sshKeySteps([]Step{s})...)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// addSSHKeyPreconditions adds a precondition per fingerprint of the add_ssh_keys steps
+// of jobs and commands to their tasks: a key with the fingerprint has to be in
+// ssh-agent or ~/.ssh, since the keys CircleCI stores are not available locally
+func addSSHKeyPreconditions(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	stepsByTask := make(map[string][]Step)
+	for name, job := range config.Jobs {
+		stepsByTask[name] = job.Steps
+	}
+	for name, command := range config.Commands {
+		stepsByTask[name] = command.Steps
+	}
+	var names []string
+	for name := range stepsByTask {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		steps := sshKeySteps(stepsByTask[name])
+		task, ok := taskfile.Tasks[name]
+		if len(steps) == 0 || !ok {
+			continue
+		}
+		var fingerprints []string
+		for _, step := range steps {
+			value, _ := step.(map[string]interface{})
+			for _, fingerprint := range sshKeyFingerprints(value["add_ssh_keys"]) {
+				if containsString(fingerprints, fingerprint) {
+					continue
+				}
+				fingerprints = append(fingerprints, fingerprint)
+				hash, match := sshFingerprintMatch(fingerprint)
+				task.Preconditions = append(task.Preconditions, Precondition{
+					Sh:  fmt.Sprintf("%s || %s | grep -qF %s", sshKeyLoaded(fingerprint), sshKeyFiles(hash), shellQuote(match)),
+					Msg: fmt.Sprintf("add_ssh_keys: no SSH key with fingerprint %s in ssh-agent or ~/.ssh", fingerprint),
+				})
+			}
+		}
+		taskfile.Tasks[name] = task
+
+		if len(fingerprints) == 0 {
+			report.Add("SSH keys", name, "add_ssh_keys without fingerprints adds every key of the CircleCI project; locally the keys loaded in ssh-agent are used")
+			continue
+		}
+		report.Add("SSH keys", name, "needs local credentials: the SSH keys with fingerprints %s, in ssh-agent or ~/.ssh (checked by a precondition)", strings.Join(fingerprints, ", "))
+	}
+}
@@ -0,0 +1,184 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StepMapping is what a -step-map entry turns a step into: a shell command, or a call
+// to a task of the generated (or an included) Taskfile
+type StepMapping struct {
+	Run  string `yaml:"run,omitempty" json:"run,omitempty"`   // << parameters.x >> is replaced by the step's arguments
+	Task string `yaml:"task,omitempty" json:"task,omitempty"` // the step's arguments are passed as variables
+}
+
+// UnmarshalYAML accepts a plain string as shorthand for a run mapping
+func (m *StepMapping) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		m.Run = node.Value
+		return nil
+	}
+	type plain StepMapping
+	return node.Decode((*plain)(m))
+}
+
+// stepMapParamRegex matches the parameter references of a run mapping
+var stepMapParamRegex = regexp.MustCompile(`<<\s*parameters\.([A-Za-z0-9_-]+)\s*>>`)
+
+// loadStepMap reads a -step-map file, YAML or JSON, mapping step names to commands
+func loadStepMap(path string) (map[string]StepMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading step map: %w", err)
+	}
+	var mappings map[string]StepMapping
+	if err := yaml.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("error parsing step map %s: %w", path, err)
+	}
+	for name, mapping := range mappings {
+		if (mapping.Run == "") == (mapping.Task == "") {
+			return nil, fmt.Errorf("step map %s: %s needs exactly one of run or task", path, name)
+		}
+	}
+	return mappings, nil
+}
+
+// applyStepMap replaces the steps named in the step map with run steps, in the jobs,
+// commands and orb jobs of the config. It runs after orb resolution, so the mappings
+// also apply inside inlined orbs, and takes precedence over the orbs and commands
+// the steps would otherwise invoke.
+func applyStepMap(config *CircleCIConfig, mappings map[string]StepMapping, report *ConversionReport) {
+	if len(mappings) == 0 {
+		return
+	}
+
+	used := replaceConfigSteps(config, func(name string, step Step, args map[string]interface{}, owner string) (string, bool) {
+		mapping, ok := mappings[name]
+		if !ok {
+			return "", false
+		}
+		return stepMapCommand(mapping, args), true
+	})
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.Add("Step map", "", "`%s` mapped to `%s` in %s", name, firstLine(stepMapCommand(mappings[name], nil)), strings.Join(uniqueStrings(used[name]), ", "))
+	}
+}
+
+// stepReplacer returns the command replacing a step of owner (a job or command), and
+// whether it replaces it; args are the step's arguments
+type stepReplacer func(name string, step Step, args map[string]interface{}, owner string) (string, bool)
+
+// replaceConfigSteps replaces steps with run steps in the jobs, commands and orb jobs of
+// the config, returning the owners each step name was replaced in. Owners are visited
+// by name, jobs first, so replacers see them in a stable order. The maps are copied so
+// the caller's config is left untouched.
+func replaceConfigSteps(config *CircleCIConfig, replace stepReplacer) map[string][]string {
+	used := make(map[string][]string)
+	jobs := make(map[string]Job, len(config.Jobs))
+	for _, name := range sortedJobNames(config.Jobs) {
+		job := config.Jobs[name]
+		job.Steps = mapSteps(job.Steps, replace, name, used)
+		jobs[name] = job
+	}
+	commands := make(map[string]Command, len(config.Commands))
+	for _, name := range sortedCommandNames(config.Commands) {
+		command := config.Commands[name]
+		command.Steps = mapSteps(command.Steps, replace, name, used)
+		commands[name] = command
+	}
+	orbJobs := make(map[string]Job, len(config.orbJobs))
+	for _, name := range sortedJobNames(config.orbJobs) {
+		job := config.orbJobs[name]
+		job.Steps = mapSteps(job.Steps, replace, name, used)
+		orbJobs[name] = job
+	}
+	config.Jobs, config.Commands, config.orbJobs = jobs, commands, orbJobs
+	return used
+}
+
+// sortedCommandNames returns command names in sorted order
+func sortedCommandNames(commands map[string]Command) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mapSteps returns steps with the replaced ones turned into run steps, including those
+// nested in when/unless steps. used collects where each step name was replaced.
+func mapSteps(steps []Step, replace stepReplacer, owner string, used map[string][]string) []Step {
+	mapped := make([]Step, 0, len(steps))
+	for _, step := range steps {
+		name := stepType(step)
+		var args map[string]interface{}
+		if stepMap, ok := step.(map[string]interface{}); ok {
+			args, _ = stepMap[name].(map[string]interface{})
+		}
+		if command, ok := replace(name, step, args, owner); ok {
+			used[name] = append(used[name], owner)
+			mapped = append(mapped, map[string]interface{}{
+				"run": map[string]interface{}{"name": name, "command": command},
+			})
+			continue
+		}
+		if stepMap, ok := step.(map[string]interface{}); ok && (name == "when" || name == "unless") {
+			if condition, ok := stepMap[name].(map[string]interface{}); ok {
+				if nested, ok := condition["steps"].([]interface{}); ok {
+					copied := make(map[string]interface{}, len(condition))
+					for key, value := range condition {
+						copied[key] = value
+					}
+					var nestedSteps []Step
+					for _, s := range nested {
+						nestedSteps = append(nestedSteps, s)
+					}
+					var mappedNested []interface{}
+					for _, s := range mapSteps(nestedSteps, replace, owner, used) {
+						mappedNested = append(mappedNested, s)
+					}
+					copied["steps"] = mappedNested
+					step = map[string]interface{}{name: copied}
+				}
+			}
+		}
+		mapped = append(mapped, step)
+	}
+	return mapped
+}
+
+// stepMapCommand builds the command of a mapped step from the step's arguments.
+// Run mappings keep references to parameters the step does not set, so they resolve
+// like any other parameter of the enclosing job or command.
+func stepMapCommand(mapping StepMapping, args map[string]interface{}) string {
+	if mapping.Task != "" {
+		keys := make([]string, 0, len(args))
+		for key := range args {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		cmd := "task " + mapping.Task
+		for _, key := range keys {
+			cmd += fmt.Sprintf(" %s=%s", taskVarName(key), shellQuote(formatParamValue(nil, args[key])))
+		}
+		return cmd
+	}
+	return stepMapParamRegex.ReplaceAllStringFunc(mapping.Run, func(ref string) string {
+		name := stepMapParamRegex.FindStringSubmatch(ref)[1]
+		if value, ok := args[name]; ok {
+			return formatParamValue(nil, value)
+		}
+		return ref
+	})
+}
@@ -0,0 +1,676 @@
+package converter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// orbStepMappings translates steps of popular orbs into their local shell equivalents.
+// They are used when the orb is not resolved (offline, private registry, network
+// failure); resolved orbs are converted from their own source instead. Each mapping
+// gets the step's parameters.
+var orbStepMappings = map[string]func(params map[string]string) string{
+	"node/install": func(params map[string]string) string {
+		return "node --version"
+	},
+	"node/install-packages": func(params map[string]string) string {
+		install := map[string]string{
+			"npm":  "npm ci",
+			"yarn": "yarn install --frozen-lockfile",
+			"pnpm": "pnpm install --frozen-lockfile",
+		}[orbParam(params, "pkg-manager", "npm")]
+		if install == "" {
+			install = "npm ci"
+		}
+		return inAppDir(params, install)
+	},
+	"node/test": func(params map[string]string) string {
+		return inAppDir(params, fmt.Sprintf("%s run %s", orbParam(params, "pkg-manager", "npm"), orbParam(params, "run-command", "test")))
+	},
+	"docker/check": func(params map[string]string) string {
+		return fmt.Sprintf(`echo "$%s" | docker login -u "$%s" --password-stdin %s`,
+			orbParam(params, "docker-password", "DOCKER_PASSWORD"), orbParam(params, "docker-username", "DOCKER_LOGIN"), orbParam(params, "registry", "docker.io"))
+	},
+	"docker/build": func(params map[string]string) string {
+		image := orbParam(params, "image", "$CIRCLE_PROJECT_REPONAME")
+		dockerfile := path.Join(orbParam(params, "path", "."), orbParam(params, "dockerfile", "Dockerfile"))
+		cmd := fmt.Sprintf("docker build -f %s -t %s:%s", dockerfile, image, orbParam(params, "tag", "latest"))
+		if extra := orbParam(params, "extra_build_args", ""); extra != "" {
+			cmd += " " + extra
+		}
+		return cmd + " " + orbParam(params, "docker-context", ".")
+	},
+	"docker/push": func(params map[string]string) string {
+		return fmt.Sprintf("docker push %s:%s", orbParam(params, "image", "$CIRCLE_PROJECT_REPONAME"), orbParam(params, "tag", "latest"))
+	},
+	"aws-cli/install": func(params map[string]string) string {
+		return "aws --version"
+	},
+	"aws-cli/setup": func(params map[string]string) string {
+		// Local runs use the developer's own credentials; this checks they work
+		cmd := "aws sts get-caller-identity"
+		if profile := orbParam(params, "profile_name", orbParam(params, "profile-name", "")); profile != "" {
+			cmd += " --profile " + profile
+		}
+		return "aws --version && " + cmd
+	},
+	"python/install-packages": func(params map[string]string) string {
+		var install string
+		switch orbParam(params, "pkg-manager", "pipenv") {
+		case "pip":
+			install = "pip install -r " + orbParam(params, "pip-dependency-file", "requirements.txt")
+		case "poetry":
+			install = "poetry install"
+		default:
+			install = "pipenv install --dev"
+		}
+		return inAppDir(params, install)
+	},
+	"python/test": func(params map[string]string) string {
+		tool := "pytest"
+		if orbParam(params, "test-tool", "pytest") == "unittest" {
+			tool = "python -m unittest"
+		}
+		switch orbParam(params, "pkg-manager", "pipenv") {
+		case "poetry":
+			tool = "poetry run " + tool
+		case "pipenv":
+			tool = "pipenv run " + tool
+		}
+		return inAppDir(params, tool)
+	},
+}
+
+// orbMappingName returns the name of an orb step or job in the built-in mappings, which
+// are keyed by the names of circleci orbs: its orb alias is resolved through the
+// config's orbs, so `n/install` of `n: circleci/node@5` is `node/install`. Names of
+// undeclared orbs are kept, and other orbs have no mappings.
+func orbMappingName(name string, orbs map[string]interface{}) (string, bool) {
+	alias, rest, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", false
+	}
+	ref, declared := orbs[alias]
+	if !declared {
+		return name, true
+	}
+	// Inline orbs are converted from their own definition
+	refStr, ok := ref.(string)
+	if !ok {
+		return "", false
+	}
+	registry, _, _ := strings.Cut(refStr, "@")
+	namespace, orb, ok := strings.Cut(registry, "/")
+	if !ok || namespace != "circleci" {
+		return "", false
+	}
+	return orb + "/" + rest, true
+}
+
+// orbStepCommand returns the built-in equivalent of a popular orb step, if there is one
+func orbStepCommand(step Step, orbs map[string]interface{}) (string, bool) {
+	name := stepType(step)
+	mappingName, ok := orbMappingName(name, orbs)
+	if !ok {
+		return "", false
+	}
+	mapping, ok := orbStepMappings[mappingName]
+	if !ok {
+		return "", false
+	}
+	params := make(map[string]string)
+	if stepMap, ok := step.(map[string]interface{}); ok {
+		if values, ok := stepMap[name].(map[string]interface{}); ok {
+			for key, value := range values {
+				params[key] = convertParameterSyntax(escapeTemplateBraces(fmt.Sprintf("%v", value)))
+			}
+		}
+	}
+	return mapping(params), true
+}
+
+// OrbJobMapping describes a popular orb job by the mapped orb steps it runs, for
+// workflows invoking it when the orb is not resolved
+type OrbJobMapping struct {
+	Image        string // docker image, %s taking the version; empty for a machine
+	VersionParam string
+	Version      string // default version
+	Steps        []string
+}
+
+// orbJobMappings are the popular orb jobs, by name
+var orbJobMappings = map[string]OrbJobMapping{
+	"node/test": {Image: "cimg/node:%s", VersionParam: "version", Version: "lts",
+		Steps: []string{"checkout", "node/install-packages", "node/test"}},
+	"python/test": {Image: "cimg/python:%s", VersionParam: "version", Version: "3.12",
+		Steps: []string{"checkout", "python/install-packages", "python/test"}},
+	"docker/publish": {Steps: []string{"checkout", "docker/check", "docker/build", "docker/push"}},
+}
+
+// orbJobFromMapping builds a job running the mapped steps of a popular orb job, each
+// given the invocation's arguments as parameters. The steps are named after the orb's
+// alias in the config, like the job.
+func orbJobFromMapping(name string, args map[string]interface{}, orbs map[string]interface{}) (Job, bool) {
+	mappingName, ok := orbMappingName(name, orbs)
+	if !ok {
+		return Job{}, false
+	}
+	mapping, ok := orbJobMappings[mappingName]
+	if !ok {
+		return Job{}, false
+	}
+	alias, _, _ := strings.Cut(name, "/")
+	job := Job{Machine: map[string]interface{}{"image": "ubuntu-2204:current"}}
+	if mapping.Image != "" {
+		version := mapping.Version
+		if value, ok := args[mapping.VersionParam]; ok && fmt.Sprintf("%v", value) != "" {
+			version = fmt.Sprintf("%v", value)
+		}
+		job = Job{Docker: []DockerImage{{Image: fmt.Sprintf(mapping.Image, version)}}}
+	}
+	for _, step := range mapping.Steps {
+		if step == "checkout" {
+			job.Steps = append(job.Steps, step)
+			continue
+		}
+		params := make(map[string]interface{}, len(args))
+		for key, value := range args {
+			params[key] = value
+		}
+		_, command, _ := strings.Cut(step, "/")
+		job.Steps = append(job.Steps, map[string]interface{}{alias + "/" + command: params})
+	}
+	return job, true
+}
+
+// orbParam returns an orb step parameter, or its default when the step does not set it
+func orbParam(params map[string]string, name, def string) string {
+	if value, ok := params[name]; ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// inAppDir runs a command in the step's app-dir, when it has one
+func inAppDir(params map[string]string, cmd string) string {
+	if dir := orbParam(params, "app-dir", "."); dir != "." && dir != "~/project" {
+		return fmt.Sprintf("cd %s && %s", dir, cmd)
+	}
+	return cmd
+}
+
+// templateOpen starts a go-task template action
+const templateOpen = "{{"
+
+// escapeTemplateBraces escapes the {{ of a value from the config (jq filters, Helm
+// and docker --format templates), which go-task would otherwise render as a template,
+// as {{"{{"}}; a }} outside a template action is printed as is
+func escapeTemplateBraces(value string) string {
+	return strings.ReplaceAll(value, templateOpen, `{{"{{"}}`)
+}
+
+// extractCommand extracts the command string from a CircleCI step, with its template
+// braces escaped
+func extractCommand(step Step) string {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	
+	if run, ok := stepMap["run"]; ok {
+		switch v := run.(type) {
+		case string:
+			return escapeTemplateBraces(v)
+		case map[string]interface{}:
+			if command, exists := v["command"]; exists {
+				if cmdStr, ok := command.(string); ok {
+					return escapeTemplateBraces(cmdStr)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// runStepEnvironment returns the `environment:` of a run step
+func runStepEnvironment(step Step) map[string]string {
+	env := make(map[string]string)
+	if stepMap, ok := step.(map[string]interface{}); ok {
+		if run, ok := stepMap["run"].(map[string]interface{}); ok {
+			mergeEnvironment(env, run["environment"])
+		}
+	}
+	for key, value := range env {
+		env[key] = escapeTemplateBraces(value)
+	}
+	return env
+}
+
+// projectDirPrefixes are the usual spellings of the CircleCI project directory, where
+// checkout puts the code
+var projectDirPrefixes = []string{"~/project", "/home/circleci/project", "/root/project", "$CIRCLE_WORKING_DIRECTORY", "${CIRCLE_WORKING_DIRECTORY}"}
+
+// projectSubdir returns the part of a path in the CircleCI project directory after
+// the project directory itself ("" or "/sub/dir"), and false for other paths
+func projectSubdir(dir string) (string, bool) {
+	for _, prefix := range projectDirPrefixes {
+		if rest := strings.TrimPrefix(dir, prefix); rest != dir && (rest == "" || strings.HasPrefix(rest, "/")) {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// stepDirectory converts the working_directory of a run step to the argument of a
+// local cd: relative paths stay relative to the job's directory, paths in the project
+// directory are rooted at the Taskfile's directory, and other paths keep their ~ and
+// $VARIABLES for the shell to expand
+func stepDirectory(dir string) string {
+	if rest, ok := projectSubdir(dir); ok {
+		return `"{{.ROOT_DIR}}` + strings.TrimSuffix(rest, "/") + `"`
+	}
+	if strings.ContainsAny(dir, "~$") {
+		return dir
+	}
+	return shellQuote(dir)
+}
+
+// runStepWhen returns the `when:` of a run step: always, on_fail, or on_success (the
+// default)
+func runStepWhen(step Step) string {
+	if stepMap, ok := step.(map[string]interface{}); ok {
+		if run, ok := stepMap["run"].(map[string]interface{}); ok {
+			if when, ok := run["when"].(string); ok && when != "" {
+				return when
+			}
+		}
+	}
+	return "on_success"
+}
+
+// runStepShell returns the `shell:` of a run step, empty when it has none
+func runStepShell(step Step) string {
+	if stepMap, ok := step.(map[string]interface{}); ok {
+		if run, ok := stepMap["run"].(map[string]interface{}); ok {
+			shell, _ := run["shell"].(string)
+			return shell
+		}
+	}
+	return ""
+}
+
+// onFailPrefix and onFailSuffix wrap the deferred commands of on_fail steps
+const (
+	onFailPrefix = "{{if .EXIT_CODE}}"
+	onFailSuffix = "{{end}}"
+)
+
+// finalCommand turns the command of an always or on_fail step into a deferred command,
+// which go-task runs when the task ends. On failure go-task sets EXIT_CODE.
+func finalCommand(when, cmd string) TaskCmd {
+	if when == "on_fail" {
+		cmd = onFailPrefix + cmd + onFailSuffix
+	}
+	return deferredCommand(cmd)
+}
+
+// withFinalCommands places the deferred commands of always and on_fail steps first, in
+// reverse: go-task runs deferred commands last registered first, so they run in step
+// order once the other commands are done or one of them failed
+func withFinalCommands(cmds, finals TaskCmds) TaskCmds {
+	if len(finals) == 0 {
+		return cmds
+	}
+	ordered := make(TaskCmds, 0, len(finals)+len(cmds))
+	for i := len(finals) - 1; i >= 0; i-- {
+		ordered = append(ordered, finals[i])
+	}
+	return append(ordered, cmds...)
+}
+
+// shellScriptFlags are the options taking an inline script, by interpreter; others take -c
+var shellScriptFlags = map[string]string{
+	"node":       "-e",
+	"ruby":       "-e",
+	"perl":       "-e",
+	"powershell": "-Command",
+	"pwsh":       "-Command",
+	"cmd":        "/C",
+}
+
+// inShell runs a command with the interpreter of a `shell:` setting (bash -eo pipefail,
+// pwsh, python3, ...) rather than go-task's shell, keeping its failure semantics
+func inShell(shell, cmd string) string {
+	fields := strings.Fields(shell)
+	if len(fields) == 0 {
+		return cmd
+	}
+	interpreter := fields[0]
+	if path.Base(interpreter) == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	name := strings.TrimSuffix(strings.ToLower(path.Base(strings.ReplaceAll(interpreter, `\`, "/"))), ".exe")
+	flag, ok := shellScriptFlags[name]
+	if !ok {
+		flag = "-c"
+	}
+	return fmt.Sprintf("%s %s %s", shell, flag, shellQuote(strings.TrimRight(cmd, "\n")))
+}
+
+// deployStepsAsRun rewrites the deprecated `deploy` steps of 2.0-era configs, nested
+// ones included, as the `run` steps they are equivalent to
+func deployStepsAsRun(steps []Step) {
+	for _, step := range steps {
+		stepMap, ok := step.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if deploy, ok := stepMap["deploy"]; ok && len(stepMap) == 1 {
+			delete(stepMap, "deploy")
+			stepMap["run"] = deploy
+			continue
+		}
+		for _, kind := range []string{"when", "unless"} {
+			body, _ := stepMap[kind].(map[string]interface{})
+			nested, _ := body["steps"].([]interface{})
+			var nestedSteps []Step
+			for _, s := range nested {
+				nestedSteps = append(nestedSteps, s)
+			}
+			deployStepsAsRun(nestedSteps)
+		}
+	}
+}
+
+// withJobShell sets the job's `shell:` on its run steps that have none, nested ones
+// included, returning copies of the steps
+func withJobShell(steps []Step, shell string) []Step {
+	if shell == "" {
+		return steps
+	}
+	result := make([]Step, len(steps))
+	for i, step := range steps {
+		result[i] = step
+		stepMap, ok := step.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch kind := stepType(step); kind {
+		case "run":
+			run, ok := stepMap["run"].(map[string]interface{})
+			if !ok {
+				run = map[string]interface{}{"command": stepMap["run"]}
+			}
+			if _, set := run["shell"]; set {
+				continue
+			}
+			copied := map[string]interface{}{"shell": shell}
+			for key, value := range run {
+				copied[key] = value
+			}
+			result[i] = map[string]interface{}{"run": copied}
+		case "when", "unless":
+			body, ok := stepMap[kind].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			nested, _ := body["steps"].([]interface{})
+			var nestedSteps []Step
+			for _, s := range nested {
+				nestedSteps = append(nestedSteps, s)
+			}
+			copied := make(map[string]interface{}, len(body))
+			for key, value := range body {
+				copied[key] = value
+			}
+			var shelled []interface{}
+			for _, s := range withJobShell(nestedSteps, shell) {
+				shelled = append(shelled, s)
+			}
+			copied["steps"] = shelled
+			result[i] = map[string]interface{}{kind: copied}
+		}
+	}
+	return result
+}
+
+// runStepCommand returns the command of a run step, in the step's `shell:` when it has
+// one, changing to its working_directory and exporting its environment first. Each
+// task command runs in its own shell, so both stay scoped to the step as in CircleCI.
+func runStepCommand(step Step) string {
+	cmd := extractCommand(step)
+	if cmd == "" {
+		return ""
+	}
+	if run, ok := step.(map[string]interface{})["run"].(map[string]interface{}); ok {
+		if shell, ok := run["shell"].(string); ok {
+			cmd = inShell(shell, cmd)
+		}
+		if dir, ok := run["working_directory"].(string); ok && dir != "" && dir != "." {
+			cmd = fmt.Sprintf("cd %s && %s", stepDirectory(dir), cmd)
+		}
+	}
+	env := runStepEnvironment(step)
+	if len(env) == 0 {
+		return cmd
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	exports := make([]string, len(keys))
+	for i, key := range keys {
+		exports[i] = fmt.Sprintf("%s=%s", key, shellQuote(env[key]))
+	}
+	return "export " + strings.Join(exports, " ") + "\n" + cmd
+}
+
+// checkoutCommand is the local equivalent of a checkout step into the project
+// directory: the working copy is used as is
+const checkoutCommand = "git checkout HEAD"
+
+// checkoutPath returns the `path:` of a checkout step, "" for the project directory
+func checkoutPath(value interface{}) string {
+	settings, _ := value.(map[string]interface{})
+	path, _ := settings["path"].(string)
+	if rest, ok := projectSubdir(path); (ok && strings.Trim(rest, "/") == "") || strings.Trim(path, "./") == "" {
+		return ""
+	}
+	return path
+}
+
+// checkoutPathCommand converts a checkout step with a `path:` outside the project
+// directory, or in a subdirectory of it, into a clone of the local repo there, updated
+// to the working copy's HEAD on later runs. Uncommitted changes are not included.
+func checkoutPathCommand(value interface{}) string {
+	path := checkoutPath(value)
+	if path == "" {
+		return checkoutCommand
+	}
+	dir := stepDirectory(path)
+	return fmt.Sprintf(`{ [ -d %s/.git ] || git clone --quiet "{{.ROOT_DIR}}" %s; } && git -C %s fetch --quiet "{{.ROOT_DIR}}" HEAD && git -C %s checkout --quiet --detach FETCH_HEAD`,
+		dir, dir, dir, dir)
+}
+
+// remoteDockerSkip is the command of a setup_remote_docker step, unless -host-docker
+// turns it into a check of the host's Docker daemon (see applyHostDocker)
+const remoteDockerSkip = "echo 'Skipping setup_remote_docker (CircleCI server only)'"
+
+// convertStepToCommand converts CircleCI steps to local equivalent commands
+func convertStepToCommand(step Step, orbs map[string]interface{}) string {
+	// Handle string steps (like "checkout" or command name)
+	if stepStr, ok := step.(string); ok {
+		switch stepStr {
+		case "checkout":
+			return checkoutCommand
+		case "setup_remote_docker":
+			return remoteDockerSkip
+		case "add_ssh_keys":
+			return addSSHKeysCommand(nil)
+		default:
+			if cmd, ok := orbStepCommand(step, orbs); ok {
+				return cmd
+			}
+			// This could be a command invocation without parameters
+			return fmt.Sprintf("task %s", stepStr)
+		}
+	}
+
+	// Handle map steps
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return "echo 'Unknown step type'"
+	}
+
+	for key, value := range stepMap {
+		switch key {
+		case "checkout":
+			return checkoutPathCommand(value)
+		case "setup_remote_docker":
+			return remoteDockerSkip
+		case "add_ssh_keys":
+			return addSSHKeysCommand(value)
+		case "save_cache":
+			// Create local cache simulation
+			if cacheConfig, ok := value.(map[string]interface{}); ok {
+				if paths, exists := cacheConfig["paths"]; exists {
+					return fmt.Sprintf("# Local cache: would save %v", paths)
+				}
+			}
+			return "echo 'Skipping save_cache (CircleCI server only)'"
+		case "restore_cache":
+			return "echo 'Skipping restore_cache (CircleCI server only)'"
+		case "persist_to_workspace":
+			return persistWorkspaceCommand(value)
+		case "attach_workspace":
+			return attachWorkspaceCommand(value)
+		case "store_artifacts":
+			if artifactConfig, ok := value.(map[string]interface{}); ok {
+				if path, ok := artifactConfig["path"].(string); ok && path != "" {
+					return storeArtifactsCommand(path, artifactDestination(artifactConfig["destination"]))
+				}
+			}
+			return "mkdir -p ./artifacts"
+		case "store_test_results":
+			if testConfig, ok := value.(map[string]interface{}); ok {
+				if path, ok := testConfig["path"].(string); ok && path != "" {
+					return storeTestResultsCommand(path)
+				}
+			}
+			return "mkdir -p ./test-results"
+		default:
+			// Custom command or orb usage
+			if valueStr, ok := value.(string); ok {
+				return valueStr
+			}
+			if cmd, ok := orbStepCommand(step, orbs); ok {
+				return cmd
+			}
+			return fmt.Sprintf("echo 'Custom step not converted: %s'", key)
+		}
+	}
+	return "echo 'Unknown step type'"
+}
+
+// isCommandInvocation checks if a step is a command invocation
+func isCommandInvocation(step Step) (string, bool) {
+	stepMap, ok := step.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	
+	// Look for keys that aren't built-in CircleCI steps
+	builtInSteps := map[string]bool{
+		"run": true, "checkout": true, "setup_remote_docker": true,
+		"save_cache": true, "restore_cache": true, "persist_to_workspace": true,
+		"attach_workspace": true, "store_artifacts": true, "store_test_results": true,
+		"add_ssh_keys": true, "when": true, "unless": true,
+	}
+	
+	for key := range stepMap {
+		if !builtInSteps[key] {
+			return key, true
+		}
+	}
+	
+	return "", false
+}
+
+// stepType names the kind of a step: its key for map steps, or the step itself for
+// string steps like "checkout"
+func stepType(step Step) string {
+	switch v := step.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			return key
+		}
+	}
+	return "unknown"
+}
+
+// normalizeCommand performs basic command normalization: whitespace is collapsed in
+// single-line commands, while multi-line scripts keep their lines, which heredocs and
+// compound commands depend on
+func normalizeCommand(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+	if !strings.Contains(cmd, "\n") {
+		return strings.Join(strings.Fields(cmd), " ")
+	}
+	return trimLineEnds(cmd)
+}
+
+// heredocRegex matches the redirection starting a heredoc (<<EOF, <<-'EOF', << "EOF"),
+// capturing the dash and the delimiter; here-strings (<<<) are told apart by the
+// character before
+var heredocRegex = regexp.MustCompile(`(^|[^<])<<(-?)[ \t]*(?:'([^']+)'|"([^"]+)"|\\?([A-Za-z_][A-Za-z0-9_]*))`)
+
+// trimLineEnds removes the trailing whitespace of each line of a script, which keeps
+// YAML from writing it as a quoted string instead of a literal block scalar.
+// Whitespace escaped by a backslash is kept, and so are heredoc bodies, whose lines
+// are data; such scripts are written quoted.
+func trimLineEnds(script string) string {
+	lines := strings.Split(script, "\n")
+	var delimiters []string // of the heredocs whose bodies follow, in order
+	var tabsStripped []bool
+	for i, line := range lines {
+		if len(delimiters) > 0 {
+			end := line
+			if tabsStripped[0] {
+				end = strings.TrimLeft(end, "\t")
+			}
+			if end == delimiters[0] {
+				delimiters, tabsStripped = delimiters[1:], tabsStripped[1:]
+			}
+			continue
+		}
+		if trimmed := strings.TrimRight(line, " \t\r"); !strings.HasSuffix(trimmed, `\`) {
+			lines[i] = trimmed
+		}
+		for _, match := range heredocRegex.FindAllStringSubmatchIndex(line, -1) {
+			// << pipeline.git.branch >> and the like are CircleCI values, not heredocs
+			if end := match[1]; end < len(line) && line[end] == '.' {
+				continue
+			}
+			delimiter := ""
+			for group := 3; group <= 5; group++ {
+				if match[2*group] >= 0 {
+					delimiter = line[match[2*group]:match[2*group+1]]
+				}
+			}
+			delimiters = append(delimiters, delimiter)
+			tabsStripped = append(tabsStripped, match[4] < match[5])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shellQuote wraps a value in single quotes for safe use in shell commands
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,109 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// testResultsDir holds the store_test_results copies, one directory per task
+const testResultsDir = "./test-results"
+
+// taskTestResultsDir is the test results directory of a task in its commands, next
+// to the Taskfile whatever the task's directory
+const taskTestResultsDir = `"{{.ROOT_DIR}}/test-results/{{.TASK}}"`
+
+// testReportFile is the merged JUnit report written by the test-report task
+const testReportFile = testResultsDir + "/report.xml"
+
+// testReportAwk reads the JUnit XML files named on stdin, one per line, merges their
+// test suites into the report file `out`, and prints the tests, failures and skipped
+// tests of each suite, by task. It exits 1 when any test failed. It splits the XML
+// at "<", which is enough for the reports test runners write, and contains no single
+// quotes, so it can be passed to awk in a single-quoted argument.
+const testReportAwk = `function attr(r, name) {
+  if (!match(r, "[ \t\n]" name "=\"[^\"]*\"")) return ""
+  return substr(r, RSTART + length(name) + 3, RLENGTH - length(name) - 4)
+}
+BEGIN {
+  while ((getline f < "/dev/stdin") > 0) if (f != "") files[n++] = f
+  if (n == 0) {
+    print "No test results stored yet."
+    exit 0
+  }
+  RS = "<"
+  print "<?xml version=\"1.0\" encoding=\"UTF-8\"?>" > out
+  print "<testsuites>" > out
+  for (i = 0; i < n; i++) {
+    f = files[i]
+    task = f
+    sub(/^\.\/test-results\//, "", task)
+    sub(/\/.*/, "", task)
+    suite = f
+    sub(/.*\//, "", suite)
+    sub(/\.xml$/, "", suite)
+    failed = 0
+    while ((getline r < f) > 0) {
+      tag = r
+      sub(/[ \t\n\/>].*/, "", tag)
+      if (tag == "" && r !~ /^\//) continue
+      if (tag == "testsuite" && attr(r, "name") != "") suite = attr(r, "name")
+      key = task "/" suite
+      if (tag == "testcase") {
+        if (!(key in tests)) keys[k++] = key
+        tests[key]++
+        failed = 0
+      } else if ((tag == "failure" || tag == "error") && !failed) {
+        failures[key]++
+        failed = 1
+      } else if (tag == "skipped") {
+        skipped[key]++
+      }
+      if (tag != "?xml" && tag != "testsuites" && r !~ /^\/testsuites/) printf "<%s", r > out
+    }
+    close(f)
+    print "" > out
+  }
+  print "</testsuites>" > out
+  close(out)
+
+  printf "%-50s %7s %7s %7s\n", "SUITE", "TESTS", "FAILED", "SKIPPED"
+  for (i = 0; i < k; i++) {
+    key = keys[i]
+    printf "%-50s %7d %7d %7d\n", key, tests[key], failures[key], skipped[key]
+    total += tests[key]; totalFailures += failures[key]; totalSkipped += skipped[key]
+  }
+  printf "%-50s %7d %7d %7d\n", "TOTAL", total, totalFailures, totalSkipped
+  print "Merged report: " out
+  exit (totalFailures > 0)
+}`
+
+// storeTestResultsCommand copies a store_test_results path to a directory of the task
+// under test-results, so results of jobs using the same path do not overwrite each
+// other
+func storeTestResultsCommand(source string) string {
+	return fmt.Sprintf("mkdir -p %s && cp -r %s %s/", taskTestResultsDir, stepDirectory(source), taskTestResultsDir)
+}
+
+// addTestReportTask adds test-report, merging the JUnit XML stored by every task into
+// test-results/report.xml and printing the tests and failures of each suite, when any
+// task stores test results. It approximates CircleCI's test insights for local runs.
+func addTestReportTask(taskfile *Taskfile) {
+	stores := false
+	for _, task := range taskfile.Tasks {
+		for _, cmd := range task.Cmds {
+			stores = stores || strings.Contains(cmd.Cmd, taskTestResultsDir)
+		}
+	}
+	if !stores {
+		return
+	}
+
+	taskfile.Tasks["test-report"] = Task{
+		Desc: "Merge the stored JUnit test results and print a summary per suite",
+		Cmds: shellCommands(
+			fmt.Sprintf("mkdir -p %s && find %s -type f -name '*.xml' ! -path %s | sort | awk -v out=%s '{{.TEST_REPORT}}'",
+				testResultsDir, testResultsDir, testReportFile, testReportFile),
+		),
+		Vars: map[string]string{"TEST_REPORT": testReportAwk},
+	}
+}
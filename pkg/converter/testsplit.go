@@ -0,0 +1,223 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// testsSplitVar is the Taskfile variable holding the awk program that splits tests
+const testsSplitVar = "CIRCLECI_TESTS_SPLIT"
+
+// testsSplitAwk assigns the file names read from input to CIRCLE_NODE_TOTAL buckets and
+// prints those of bucket CIRCLE_NODE_INDEX, in input order. Files are weighted by the
+// timings file (lines of "<file> <seconds>"; unknown files get the average), by size,
+// or equally, and placed heaviest first into the least loaded bucket. With equal
+// weights this is a round-robin over the sorted names. It contains no single quotes,
+// so it can be passed to awk in a single-quoted argument.
+const testsSplitAwk = `BEGIN {
+  known = 0; sum = 0
+  if (by == "timings" && timings != "") {
+    while ((getline line < timings) > 0) {
+      if (split(line, f, " ") >= 2) { weight[f[1]] = f[2] + 0; known++; sum += f[2] }
+    }
+  }
+  average = known > 0 ? sum / known : 1
+}
+NF { files[count++] = $0 }
+END {
+  for (i = 0; i < count; i++) {
+    w[i] = 1
+    if (by == "timings") w[i] = (files[i] in weight) ? weight[files[i]] : average
+    if (by == "filesize") { cmd = "wc -c < \"" files[i] "\""; cmd | getline w[i]; close(cmd); w[i] += 0 }
+    order[i] = i
+  }
+  for (i = 1; i < count; i++) {
+    for (j = i; j > 0; j--) {
+      a = order[j-1]; b = order[j]
+      if (w[a] > w[b] || (w[a] == w[b] && files[a] <= files[b])) break
+      order[j-1] = b; order[j] = a
+    }
+  }
+  for (n = 0; n < total; n++) load[n] = 0
+  for (i = 0; i < count; i++) {
+    best = 0
+    for (n = 1; n < total; n++) if (load[n] < load[best]) best = n
+    load[best] += w[order[i]]; bucket[order[i]] = best
+  }
+  for (i = 0; i < count; i++) if (bucket[i] == node) print files[i]
+}`
+
+// testsCommandRegex matches `circleci tests glob|split` (or `circleci-agent tests`) and
+// their arguments, up to the end of the pipeline stage
+var testsCommandRegex = regexp.MustCompile("circleci(?:-agent)?\\s+tests\\s+(glob|split|run)\\b([^|;&)<>\\n`]*)")
+
+// splitByRegex reads --split-by from `circleci tests split` arguments
+var splitByRegex = regexp.MustCompile(`--split-by[= ](\S+)`)
+
+// emulateTestsGlob replaces `circleci tests glob` with a bash glob (with ** and braces)
+// outside CircleCI
+func emulateTestsGlob(original, args string) (string, bool) {
+	var patterns []string
+	for _, arg := range strings.Fields(args) {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if strings.Contains(arg, "'") {
+			return "", false
+		}
+		patterns = append(patterns, strings.Trim(arg, `"'`))
+	}
+	if len(patterns) == 0 {
+		return "", false
+	}
+	local := fmt.Sprintf(`bash -O globstar -O nullglob -c 'printf "%%s\n" %s'`, strings.Join(patterns, " "))
+	return onCircleCI(original, local), true
+}
+
+// emulateTestsSplit replaces `circleci tests split` with the awk splitter outside CircleCI
+func emulateTestsSplit(original, args string) string {
+	by := "name"
+	if match := splitByRegex.FindStringSubmatch(args); match != nil {
+		by = strings.Trim(match[1], `"'`)
+	}
+
+	// A file operand is read instead of stdin
+	var files []string
+	fields := strings.Fields(args)
+	for i := 0; i < len(fields); i++ {
+		switch {
+		case fields[i] == "--split-by" || fields[i] == "--timings-type":
+			i++
+		case !strings.HasPrefix(fields[i], "-"):
+			files = append(files, fields[i])
+		}
+	}
+
+	local := fmt.Sprintf(`awk -v by=%s -v total="${CIRCLE_NODE_TOTAL:-1}" -v node="${CIRCLE_NODE_INDEX:-0}" -v timings="${TESTS_TIMINGS:-}" '{{.%s}}'`, by, testsSplitVar)
+	if len(files) > 0 {
+		local += " " + strings.Join(files, " ")
+	}
+	return onCircleCI(original, local)
+}
+
+// onCircleCI runs the original command on CircleCI, where it uses CircleCI's timing
+// data, and the local emulation elsewhere
+func onCircleCI(original, local string) string {
+	return fmt.Sprintf(`{ if [ -n "${CIRCLECI:-}" ]; then %s; else %s; fi; }`, strings.TrimSpace(original), local)
+}
+
+// convertTestsCommands rewrites the `circleci tests` calls of a command. It reports
+// whether any were rewritten and lists the calls left as they were.
+func convertTestsCommands(cmd string) (string, bool, []string) {
+	var unsupported []string
+	used := false
+	converted := testsCommandRegex.ReplaceAllStringFunc(cmd, func(match string) string {
+		parts := testsCommandRegex.FindStringSubmatch(match)
+		trailing := match[len(strings.TrimRight(match, " \t")):]
+		switch parts[1] {
+		case "glob":
+			if local, ok := emulateTestsGlob(match, parts[2]); ok {
+				used = true
+				return local + trailing
+			}
+		case "split":
+			used = true
+			return emulateTestsSplit(match, parts[2]) + trailing
+		}
+		unsupported = append(unsupported, strings.TrimSpace(match))
+		return match
+	})
+	return converted, used, unsupported
+}
+
+// applyTestsSplit makes `circleci tests glob | circleci tests split` runnable locally.
+// Tasks using it set CIRCLE_NODE_INDEX and CIRCLE_NODE_TOTAL from the NODE_INDEX and
+// NODE_TOTAL vars, falling back to the values CircleCI sets and then to a single node.
+func applyTestsSplit(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	splitting := make(map[string]bool)
+	for _, name := range names {
+		task := taskfile.Tasks[name]
+		changed := false
+		// Matrix variants may share their commands with the job task
+		task.Cmds = append(TaskCmds(nil), task.Cmds...)
+		for i, cmd := range task.Cmds {
+			converted, used, unsupported := convertTestsCommands(cmd.Cmd)
+			for _, call := range unsupported {
+				report.Add("Test splitting", name, "`%s` has no local emulation and was left as is", firstLine(call))
+			}
+			if used {
+				task.Cmds[i] = cmd.withCmd(converted)
+				changed = true
+			}
+		}
+		if changed {
+			splitting[name] = true
+			taskfile.Tasks[name] = task
+		}
+	}
+	if len(splitting) == 0 {
+		return
+	}
+
+	if taskfile.Vars == nil {
+		taskfile.Vars = make(map[string]interface{})
+	}
+	taskfile.Vars[testsSplitVar] = testsSplitAwk
+
+	// CLI vars reach dependencies but not nested `task` calls, which inherit the
+	// environment instead, so jobs calling a splitting command set it too
+	for _, name := range names {
+		uses := splitting[name]
+		if job, isJob := config.Jobs[name]; isJob && !uses {
+			commands := make(map[string]Command)
+			referencedCommands(job.Steps, config.Commands, commands)
+			for command := range commands {
+				uses = uses || splitting[command]
+			}
+		}
+		if !uses {
+			continue
+		}
+
+		task := taskfile.Tasks[name]
+		if task.Env == nil {
+			task.Env = make(map[string]string)
+		}
+		task.Env["CIRCLE_NODE_INDEX"] = `{{.NODE_INDEX | default .CIRCLE_NODE_INDEX | default "0"}}`
+		task.Env["CIRCLE_NODE_TOTAL"] = `{{.NODE_TOTAL | default .CIRCLE_NODE_TOTAL | default "1"}}`
+		taskfile.Tasks[name] = task
+		if _, isCommand := config.Commands[name]; !isCommand {
+			report.Add("Test splitting", name, "`circleci tests split` is emulated locally and runs every test by default; run one node with `task %s NODE_INDEX=<i> NODE_TOTAL=<n>`, and set TESTS_TIMINGS to a file of \"<file> <seconds>\" lines to split by timings", name)
+		}
+		if job, isJob := config.Jobs[name]; isJob && job.Parallelism > 1 {
+			addParallelTask(taskfile, name, job.Parallelism, report)
+		}
+	}
+}
+
+// addParallelTask adds a <job>:parallel task running the job's nodes side by side, as
+// `parallelism: N` does on CircleCI, and failing when any of them fails
+func addParallelTask(taskfile *Taskfile, jobName string, nodes int, report *ConversionReport) {
+	name := jobName + ":parallel"
+	if _, exists := taskfile.Tasks[name]; exists {
+		report.Add("Test splitting", jobName, "task %s already exists; no parallel task added", name)
+		return
+	}
+	taskfile.Tasks[name] = Task{
+		Desc: fmt.Sprintf("Run the %d nodes of job %s in parallel, each with its share of the tests", nodes, jobName),
+		Cmds: shellCommands(fmt.Sprintf(`pids=""
+for i in $(seq 0 %d); do task %s NODE_INDEX=$i NODE_TOTAL=%d & pids="$pids $!"; done
+status=0
+for pid in $pids; do wait $pid || status=1; done
+exit $status`, nodes-1, jobName, nodes)),
+	}
+	report.Add("Test splitting", jobName, "`parallelism: %d` is simulated by task %s", nodes, name)
+}
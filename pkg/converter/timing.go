@@ -0,0 +1,229 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timingHistoryLimit is how many runs the timing history keeps
+const timingHistoryLimit = 50
+
+// TimingRun records the job durations of one local run
+type TimingRun struct {
+	Workflow    string             `json:"workflow"`
+	Started     time.Time          `json:"started"`
+	WallSeconds float64            `json:"wallSeconds"`
+	Jobs        map[string]float64 `json:"jobs"` // seconds, for jobs that ran
+	Failed      []string           `json:"failed,omitempty"`
+}
+
+// timingHistoryPath is where runs of a Taskfile directory are recorded
+func timingHistoryPath(taskfileDir string) string {
+	return filepath.Join(taskfileDir, ".circle-to-task", "timings.json")
+}
+
+// loadTimingHistory reads previous runs; a missing file is an empty history
+func loadTimingHistory(path string) ([]TimingRun, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading timing history: %w", err)
+	}
+	var history []TimingRun
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("error parsing timing history %s: %w", path, err)
+	}
+	return history, nil
+}
+
+// newTimingRun builds the timing record of a finished run
+func newTimingRun(workflow string, started time.Time, wall time.Duration, results []JobResult) TimingRun {
+	run := TimingRun{Workflow: workflow, Started: started, WallSeconds: wall.Seconds(), Jobs: make(map[string]float64)}
+	for _, result := range results {
+		if result.Status != "success" && result.Status != "failed" {
+			continue
+		}
+		run.Jobs[result.Name] = result.Duration.Seconds()
+		if result.Status == "failed" {
+			run.Failed = append(run.Failed, result.Name)
+		}
+	}
+	return run
+}
+
+// criticalPath returns the chain of required jobs with the longest total duration,
+// which bounds the wall time of the run however many jobs run in parallel
+func criticalPath(nodes []RunNode, jobs map[string]float64) ([]string, float64) {
+	finish := make(map[string]float64)
+	previous := make(map[string]string)
+
+	// nodes are in topological order, so requirements are finished first
+	for _, node := range nodes {
+		start := 0.0
+		for _, req := range node.Requires {
+			if finish[req] > start {
+				start = finish[req]
+				previous[node.Name] = req
+			}
+		}
+		finish[node.Name] = start + jobs[node.Name]
+	}
+
+	last, longest := "", 0.0
+	for _, node := range nodes {
+		if finish[node.Name] > longest {
+			last, longest = node.Name, finish[node.Name]
+		}
+	}
+
+	var path []string
+	for job := last; job != ""; job = previous[job] {
+		if jobs[job] > 0 {
+			path = append([]string{job}, path...)
+		}
+	}
+	return path, longest
+}
+
+// medianJobSeconds returns a job's median duration over the runs of a workflow
+func medianJobSeconds(history []TimingRun, workflow, job string) (float64, int) {
+	var samples []float64
+	for _, run := range history {
+		if seconds, ok := run.Jobs[job]; ok && run.Workflow == workflow {
+			samples = append(samples, seconds)
+		}
+	}
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(samples)
+	middle := len(samples) / 2
+	if len(samples)%2 == 0 {
+		return (samples[middle-1] + samples[middle]) / 2, len(samples)
+	}
+	return samples[middle], len(samples)
+}
+
+// recordTimings appends a run to the history and writes TIMING_REPORT.md comparing it
+// with earlier runs of the same workflow
+func recordTimings(taskfileDir string, nodes []RunNode, run TimingRun) (string, error) {
+	historyPath := timingHistoryPath(taskfileDir)
+	history, err := loadTimingHistory(historyPath)
+	if err != nil {
+		return "", err
+	}
+
+	reportPath := filepath.Join(taskfileDir, "TIMING_REPORT.md")
+	if err := writeTextFile(reportPath, generateTimingReport(nodes, run, history)); err != nil {
+		return "", err
+	}
+
+	history = append(history, run)
+	if len(history) > timingHistoryLimit {
+		history = history[len(history)-timingHistoryLimit:]
+	}
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return "", fmt.Errorf("error creating timing history directory: %w", err)
+	}
+	if err := writeJSONFile(historyPath, history); err != nil {
+		return "", err
+	}
+	return reportPath, nil
+}
+
+// generateTimingReport renders a run's job durations next to the previous run and the
+// historical median, with the critical path that bounds the wall time
+func generateTimingReport(nodes []RunNode, run TimingRun, history []TimingRun) string {
+	var b strings.Builder
+
+	total := 0.0
+	for _, seconds := range run.Jobs {
+		total += seconds
+	}
+
+	b.WriteString("# Timing Report\n\n")
+	b.WriteString(fmt.Sprintf("Workflow **%s**, run %s.\n\n", run.Workflow, run.Started.Format("2006-01-02 15:04:05")))
+	b.WriteString(fmt.Sprintf("- Wall time: %s\n", formatSeconds(run.WallSeconds)))
+	b.WriteString(fmt.Sprintf("- Job time: %s", formatSeconds(total)))
+	if run.WallSeconds > 0 && total > run.WallSeconds {
+		b.WriteString(fmt.Sprintf(" (%.1fx from parallelism)", total/run.WallSeconds))
+	}
+	b.WriteString("\n")
+
+	path, pathSeconds := criticalPath(nodes, run.Jobs)
+	if len(path) > 0 {
+		b.WriteString(fmt.Sprintf("- Critical path: %s (%s)\n", strings.Join(path, " → "), formatSeconds(pathSeconds)))
+	}
+
+	var previous *TimingRun
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Workflow == run.Workflow {
+			previous = &history[i]
+			break
+		}
+	}
+
+	names := make([]string, 0, len(run.Jobs))
+	for name := range run.Jobs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if run.Jobs[names[i]] != run.Jobs[names[j]] {
+			return run.Jobs[names[i]] > run.Jobs[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	b.WriteString("\n## Jobs\n\n")
+	b.WriteString("| Job | This run | Previous run | Median | Change vs median |\n")
+	b.WriteString("|-----|----------|--------------|--------|------------------|\n")
+	for _, name := range names {
+		seconds := run.Jobs[name]
+		status := ""
+		if containsString(run.Failed, name) {
+			status = " ❌"
+		}
+
+		prev := "-"
+		if previous != nil {
+			if prevSeconds, ok := previous.Jobs[name]; ok {
+				prev = formatSeconds(prevSeconds)
+			}
+		}
+
+		median, change := "-", "-"
+		if medianSeconds, samples := medianJobSeconds(history, run.Workflow, name); samples > 0 {
+			median = fmt.Sprintf("%s (%d runs)", formatSeconds(medianSeconds), samples)
+			if medianSeconds > 0 {
+				change = fmt.Sprintf("%+.0f%%", (seconds-medianSeconds)/medianSeconds*100)
+			}
+		}
+
+		b.WriteString(fmt.Sprintf("| %s%s | %s | %s | %s | %s |\n", name, status, formatSeconds(seconds), prev, median, change))
+	}
+
+	return b.String()
+}
+
+// formatSeconds renders a duration in seconds the way formatDuration does
+func formatSeconds(seconds float64) string {
+	return formatDuration(time.Duration(seconds * float64(time.Second)))
+}
+
+// formatDuration renders a duration rounded for humans (850ms, 12.3s, 4m05s)
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return fmt.Sprintf("%dm%02ds", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,188 @@
+package converter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// toolchainDockerfileName is written next to the generated Taskfile
+const toolchainDockerfileName = "Dockerfile.toolchain"
+
+// toolchainBaseImage is the base of the toolchain image; runtimes are copied in from
+// their official images so versions match the executor images
+const toolchainBaseImage = "debian:bookworm-slim"
+
+// taskVersion is the go-task release installed in the toolchain image
+const taskVersion = "3.38.0"
+
+// aptPackages maps tools (and binaries) to the Debian packages providing them
+var aptPackages = map[string]string{
+	"git": "git", "curl": "curl", "wget": "wget", "jq": "jq", "make": "make", "zip": "zip",
+	"unzip": "unzip", "gcc": "build-essential", "g++": "build-essential", "rsync": "rsync",
+	"ssh": "openssh-client", "scp": "openssh-client", "ssh-keyscan": "openssh-client",
+	"python": "python3 python3-pip python3-venv", "pipenv": "pipenv", "ruby": "ruby-full bundler",
+	"maven": "maven", "gradle": "gradle", "shellcheck": "shellcheck", "psql": "postgresql-client",
+	"mysql": "default-mysql-client", "redis-cli": "redis-tools", "xz": "xz-utils",
+	"gpg": "gnupg", "php": "php-cli", "composer": "composer", "docker-compose": "docker-compose",
+	"awscli": "awscli", "yq": "yq", "bc": "bc", "file": "file", "patch": "patch",
+}
+
+// toolchainStages copy a tool from its official image; %s is the version tag
+var toolchainStages = map[string][]string{
+	"go": {
+		"COPY --from=golang:%s /usr/local/go /usr/local/go",
+		"ENV PATH=/usr/local/go/bin:/root/go/bin:$PATH",
+	},
+	"node": {
+		"COPY --from=node:%s /usr/local/bin/node /usr/local/bin/node",
+		"COPY --from=node:%s /usr/local/lib/node_modules /usr/local/lib/node_modules",
+		"RUN ln -s ../lib/node_modules/npm/bin/npm-cli.js /usr/local/bin/npm \\",
+		" && ln -s ../lib/node_modules/npm/bin/npx-cli.js /usr/local/bin/npx \\",
+		" && ln -s ../lib/node_modules/corepack/dist/corepack.js /usr/local/bin/corepack",
+	},
+	"java": {
+		"COPY --from=eclipse-temurin:%s /opt/java/openjdk /opt/java/openjdk",
+		"ENV JAVA_HOME=/opt/java/openjdk PATH=/opt/java/openjdk/bin:$PATH",
+	},
+	"rust": {
+		"COPY --from=rust:%s /usr/local/cargo /usr/local/cargo",
+		"COPY --from=rust:%s /usr/local/rustup /usr/local/rustup",
+		"ENV CARGO_HOME=/usr/local/cargo RUSTUP_HOME=/usr/local/rustup PATH=/usr/local/cargo/bin:$PATH",
+	},
+	"terraform": {
+		"COPY --from=hashicorp/terraform:%s /bin/terraform /usr/local/bin/terraform",
+	},
+	"kubectl": {
+		"COPY --from=bitnami/kubectl:%s /opt/bitnami/kubectl/bin/kubectl /usr/local/bin/kubectl",
+	},
+	"helm": {
+		"COPY --from=alpine/helm:%s /usr/bin/helm /usr/local/bin/helm",
+	},
+	"docker": {
+		"COPY --from=docker:%s-cli /usr/local/bin/docker /usr/local/bin/docker",
+	},
+}
+
+// toolchainLatestTags are the tags used when no version was detected
+var toolchainLatestTags = map[string]string{
+	"go": "1", "node": "lts", "java": "21", "rust": "1", "terraform": "latest",
+	"kubectl": "latest", "helm": "latest", "docker": "27",
+}
+
+// corepackTools are package managers enabled through node's corepack
+var corepackTools = map[string]bool{"yarn": true, "pnpm": true}
+
+// generateToolchainDockerfile writes Dockerfile.toolchain bundling the tools of the
+// inventory, and adds a ci-shell task that builds it and opens a shell (or runs a
+// command) in it with the repository mounted
+func generateToolchainDockerfile(config CircleCIConfig, taskfile *Taskfile, project, outputDir string, report *ConversionReport) error {
+	inventory := buildToolInventory(config, *taskfile)
+
+	var b strings.Builder
+	b.WriteString("# Toolchain image bundling every tool the generated tasks use\n")
+	b.WriteString(fmt.Sprintf("# Generated by circle-to-task %s from TOOL_INVENTORY.json; build with `task ci-shell`\n", Version))
+	b.WriteString(fmt.Sprintf("FROM %s\n\n", toolchainBaseImage))
+
+	// Debian packages, always with what the tasks themselves need
+	packages := map[string]bool{"bash": true, "ca-certificates": true, "curl": true, "git": true}
+	var stages, todos []string
+	needsCorepack, hasNode := false, false
+
+	for _, tool := range inventory.Tools {
+		if tool.Standard || tool.Name == "task" {
+			continue
+		}
+		if lines, ok := toolchainStages[tool.Name]; ok {
+			hasNode = hasNode || tool.Name == "node"
+			tag := tool.Version
+			if tag == "" {
+				tag = toolchainLatestTags[tool.Name]
+			}
+			stages = append(stages, fmt.Sprintf("# %s %s, %s", tool.Name, tag, toolVersionNote(tool)))
+			for _, line := range lines {
+				if strings.Contains(line, "%s") {
+					line = fmt.Sprintf(line, tag)
+				}
+				stages = append(stages, line)
+			}
+			stages = append(stages, "")
+			continue
+		}
+		if corepackTools[tool.Name] {
+			needsCorepack = true
+			continue
+		}
+		if pkg, ok := aptPackages[tool.Name]; ok {
+			for _, name := range strings.Fields(pkg) {
+				packages[name] = true
+			}
+			if tool.Version != "" {
+				report.Add("Toolchain", "", "%s is installed from Debian packages, not pinned to %s (%s)", tool.Name, tool.Version, tool.VersionSource)
+			}
+			continue
+		}
+		todos = append(todos, fmt.Sprintf("# TODO: install %s (binaries: %s; used by %s)", tool.Name, strings.Join(tool.Binaries, ", "), strings.Join(tool.UsedBy, ", ")))
+		report.Add("Toolchain", "", "no install recipe for %s; add it to %s by hand", tool.Name, toolchainDockerfileName)
+	}
+
+	var packageNames []string
+	for name := range packages {
+		packageNames = append(packageNames, name)
+	}
+	sort.Strings(packageNames)
+	b.WriteString("RUN apt-get update \\\n")
+	b.WriteString(fmt.Sprintf(" && apt-get install -y --no-install-recommends %s \\\n", strings.Join(packageNames, " ")))
+	b.WriteString(" && rm -rf /var/lib/apt/lists/*\n\n")
+
+	for _, line := range stages {
+		b.WriteString(line + "\n")
+	}
+	if needsCorepack {
+		if hasNode {
+			b.WriteString("RUN corepack enable\n\n")
+		} else {
+			todos = append(todos, "# TODO: install node to use yarn/pnpm through corepack")
+		}
+	}
+
+	b.WriteString("# go-task runs the generated Taskfile\n")
+	b.WriteString(fmt.Sprintf("ARG TASK_VERSION=%s\n", taskVersion))
+	b.WriteString("ARG TARGETARCH=amd64\n")
+	b.WriteString("RUN curl -fsSL \"https://github.com/go-task/task/releases/download/v${TASK_VERSION}/task_linux_${TARGETARCH}.tar.gz\" \\\n")
+	b.WriteString("  | tar -xz -C /usr/local/bin task\n\n")
+
+	for _, todo := range todos {
+		b.WriteString(todo + "\n")
+	}
+	if len(todos) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("WORKDIR /workspace\n")
+	b.WriteString("CMD [\"bash\"]\n")
+
+	if err := writeTextFile(filepath.Join(outputDir, toolchainDockerfileName), b.String()); err != nil {
+		return err
+	}
+
+	taskfile.Tasks["ci-shell"] = Task{
+		Desc: fmt.Sprintf("Open a shell in the toolchain image (%s); `task ci-shell -- task <job>` runs a task in it", toolchainDockerfileName),
+		Cmds: shellCommands(
+			fmt.Sprintf("docker build -f %s -t {{.TOOLCHAIN_IMAGE}} .", toolchainDockerfileName),
+			`docker run --rm -it -v "$PWD:/workspace" -w /workspace {{.TOOLCHAIN_IMAGE}} {{.CLI_ARGS | default "bash"}}`,
+		),
+		Vars: map[string]string{
+			"TOOLCHAIN_IMAGE": fmt.Sprintf(`{{.TOOLCHAIN_IMAGE | default "%s-toolchain"}}`, project),
+		},
+	}
+	return nil
+}
+
+// toolVersionNote says where a tool's version came from
+func toolVersionNote(tool ToolInfo) string {
+	if tool.VersionSource == "" {
+		return "no version detected"
+	}
+	return "from " + tool.VersionSource
+}
@@ -0,0 +1,227 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ToolInventory lists the external tools the generated tasks invoke
+type ToolInventory struct {
+	GeneratedBy string     `json:"generatedBy"`
+	Tools       []ToolInfo `json:"tools"`
+}
+
+// ToolInfo describes one external tool and where its version constraint came from
+type ToolInfo struct {
+	Name          string   `json:"name"`
+	Binaries      []string `json:"binaries"`
+	Version       string   `json:"version,omitempty"`
+	VersionSource string   `json:"versionSource,omitempty"`
+	Standard      bool     `json:"standard,omitempty"` // POSIX/coreutils, present on most machines
+	UsedBy        []string `json:"usedBy"`
+}
+
+// shellBuiltins are not external binaries
+var shellBuiltins = map[string]bool{
+	"echo": true, "cd": true, "export": true, "set": true, "unset": true, "if": true,
+	"then": true, "else": true, "elif": true, "fi": true, "for": true, "do": true,
+	"done": true, "while": true, "until": true, "case": true, "esac": true, "[": true,
+	"[[": true, "test": true, "true": true, "false": true, "exit": true, "source": true,
+	".": true, "read": true, "local": true, "printf": true, "eval": true, "exec": true,
+	"return": true, "shift": true, "trap": true, "wait": true, "pwd": true, "type": true,
+	"command": true, "function": true, "{": true, "}": true, "(": true, ")": true,
+	"!": true, "in": true, "time": true, "alias": true, "ulimit": true, "umask": true,
+}
+
+// standardTools are POSIX/coreutils binaries expected on any dev machine or runner
+var standardTools = map[string]bool{
+	"mkdir": true, "cp": true, "rm": true, "mv": true, "cat": true, "ls": true, "chmod": true,
+	"chown": true, "ln": true, "touch": true, "grep": true, "sed": true, "awk": true,
+	"find": true, "xargs": true, "sort": true, "uniq": true, "head": true, "tail": true,
+	"tr": true, "cut": true, "wc": true, "tee": true, "sleep": true, "date": true, "env": true,
+	"basename": true, "dirname": true, "sh": true, "bash": true, "tar": true, "gzip": true,
+	"gunzip": true, "diff": true, "sudo": true, "which": true, "uname": true, "whoami": true,
+	"id": true, "kill": true, "ps": true, "rmdir": true, "realpath": true, "mktemp": true,
+}
+
+// toolPackages maps binaries to the tool that provides them
+var toolPackages = map[string]string{
+	"npm": "node", "npx": "node", "yarn": "yarn", "pnpm": "pnpm",
+	"python3": "python", "pip": "python", "pip3": "python", "pipenv": "pipenv",
+	"aws": "awscli", "gcloud": "google-cloud-sdk", "gsutil": "google-cloud-sdk",
+	"az": "azure-cli", "gofmt": "go", "bundle": "ruby", "gem": "ruby", "rake": "ruby",
+	"mvn": "maven", "gradle": "gradle", "java": "java", "javac": "java",
+	"cargo": "rust", "rustc": "rust", "rustup": "rust", "composer": "composer",
+	"docker-compose": "docker-compose", "kubectl": "kubectl", "helm": "helm",
+}
+
+// imageTools maps docker image names (without registry/namespace) to the tool they pin
+var imageTools = map[string]string{
+	"node": "node", "golang": "go", "go": "go", "python": "python", "ruby": "ruby",
+	"openjdk": "java", "eclipse-temurin": "java", "rust": "rust", "php": "php",
+	"terraform": "terraform", "aws-cli": "awscli", "gcloud": "google-cloud-sdk",
+	"google-cloud-sdk": "google-cloud-sdk", "elixir": "elixir", "clojure": "clojure",
+	"android": "android-sdk", "kubectl": "kubectl", "helm": "helm", "postgres": "postgres",
+	"mysql": "mysql", "redis": "redis", "mongo": "mongo", "deno": "deno",
+}
+
+// commandVersionRegex finds version pins in installer commands (nvm install 18, tfenv install 1.5.0)
+var commandVersionRegex = regexp.MustCompile(`\b(nvm|tfenv|pyenv|rbenv|goenv|rustup toolchain|rustup default)\s+(?:install\s+|use\s+)?v?([0-9][0-9A-Za-z.\-]*)`)
+
+var commandVersionTools = map[string]string{
+	"nvm": "node", "tfenv": "terraform", "pyenv": "python", "rbenv": "ruby",
+	"goenv": "go", "rustup toolchain": "rust", "rustup default": "rust",
+}
+
+var envAssignmentRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// commandBinaries returns the binaries invoked by a shell command line
+func commandBinaries(cmd string) []string {
+	var binaries []string
+
+	replacer := strings.NewReplacer("&&", "\n", "||", "\n", ";", "\n", "|", "\n", "$(", "\n", "`", "\n")
+	for _, segment := range strings.Split(replacer.Replace(cmd), "\n") {
+		words := strings.Fields(segment)
+		for len(words) > 0 {
+			word := strings.Trim(words[0], `"'()`)
+			if envAssignmentRegex.MatchString(word) || word == "sudo" || word == "exec" || word == "time" || word == "env" || word == "then" || word == "do" || word == "else" || word == "!" {
+				words = words[1:]
+				continue
+			}
+			break
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		binary := strings.Trim(words[0], `"'()`)
+		if binary == "" || strings.HasPrefix(binary, "#") || strings.HasPrefix(binary, "-") || strings.Contains(binary, "{{") ||
+			strings.HasPrefix(binary, "$") || strings.ContainsAny(binary, "=<>") || shellBuiltins[binary] {
+			continue
+		}
+		// Relative scripts (./deploy.sh) are part of the repo, not tools
+		if strings.HasPrefix(binary, "./") || strings.HasPrefix(binary, "../") {
+			continue
+		}
+		binaries = append(binaries, filepath.Base(binary))
+	}
+
+	return binaries
+}
+
+// imageToolVersion extracts the tool and version pinned by a docker image reference
+func imageToolVersion(image string) (string, string) {
+	name, tag, _ := strings.Cut(image[strings.LastIndex(image, "/")+1:], ":")
+	tool, ok := imageTools[name]
+	if !ok || tag == "" || tag == "latest" {
+		return "", ""
+	}
+	// cimg/node:18.17-browsers → 18.17
+	version, _, _ := strings.Cut(tag, "-")
+	return tool, version
+}
+
+// buildToolInventory collects the tools used by the generated tasks
+func buildToolInventory(config CircleCIConfig, taskfile Taskfile) ToolInventory {
+	tools := make(map[string]*ToolInfo)
+
+	getTool := func(name string) *ToolInfo {
+		if tool, ok := tools[name]; ok {
+			return tool
+		}
+		tool := &ToolInfo{Name: name, Standard: standardTools[name]}
+		tools[name] = tool
+		return tool
+	}
+	addUnique := func(list []string, value string) []string {
+		for _, existing := range list {
+			if existing == value {
+				return list
+			}
+		}
+		return append(list, value)
+	}
+
+	// Every generated Taskfile needs go-task itself
+	getTool("task").Binaries = []string{"task"}
+
+	for taskName, task := range taskfile.Tasks {
+		for _, taskCmd := range task.Cmds {
+			cmd := taskCmd.Cmd
+			for _, binary := range commandBinaries(cmd) {
+				name := binary
+				if pkg, ok := toolPackages[binary]; ok {
+					name = pkg
+				}
+				tool := getTool(name)
+				tool.Binaries = addUnique(tool.Binaries, binary)
+				tool.UsedBy = addUnique(tool.UsedBy, taskName)
+			}
+
+			if match := commandVersionRegex.FindStringSubmatch(cmd); match != nil {
+				tool := getTool(commandVersionTools[match[1]])
+				if tool.Version == "" {
+					tool.Version = match[2]
+					tool.VersionSource = fmt.Sprintf("task %s: %s", taskName, match[0])
+				}
+			}
+		}
+	}
+
+	// Executor images pin versions for the tools they ship
+	for _, jobName := range sortedJobNames(config.Jobs) {
+		resolved, err := resolveJobExecutor(config.Jobs[jobName], config.Executors)
+		if err != nil || len(resolved.Images) == 0 {
+			continue
+		}
+		toolName, version := imageToolVersion(resolved.Images[0])
+		if toolName == "" {
+			continue
+		}
+		if tool, ok := tools[toolName]; ok && tool.Version == "" {
+			tool.Version = version
+			tool.VersionSource = fmt.Sprintf("image %s (job %s)", resolved.Images[0], jobName)
+		}
+	}
+
+	inventory := ToolInventory{GeneratedBy: "circle-to-task " + Version}
+	for _, tool := range tools {
+		sort.Strings(tool.Binaries)
+		sort.Strings(tool.UsedBy)
+		if tool.UsedBy == nil {
+			tool.UsedBy = []string{}
+		}
+		inventory.Tools = append(inventory.Tools, *tool)
+	}
+	sort.Slice(inventory.Tools, func(i, j int) bool {
+		if inventory.Tools[i].Standard != inventory.Tools[j].Standard {
+			return !inventory.Tools[i].Standard
+		}
+		return inventory.Tools[i].Name < inventory.Tools[j].Name
+	})
+
+	return inventory
+}
+
+// sortedJobNames returns job names in sorted order
+func sortedJobNames(jobs map[string]Job) []string {
+	names := make([]string, 0, len(jobs))
+	for name := range jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// generateToolInventory writes TOOL_INVENTORY.json to the output directory
+func generateToolInventory(config CircleCIConfig, taskfile Taskfile, outputDir string) error {
+	data, err := json.MarshalIndent(buildToolInventory(config, taskfile), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling tool inventory: %w", err)
+	}
+	return writeFileContent(filepath.Join(outputDir, "TOOL_INVENTORY.json"), append(data, '\n'))
+}
@@ -0,0 +1,775 @@
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fromTravis reads Travis CI configs (.travis.yml)
+const fromTravis = "travis"
+
+// travisLanguage is how Travis CI sets up a language: the key listing its versions,
+// the variable holding a job's version, the command checking it is installed, and
+// the install and script phases run when a config leaves them out
+type travisLanguage struct {
+	VersionKey string
+	VersionVar string
+	Check      string
+	Install    string
+	Script     string
+}
+
+// travisLanguages are the languages whose setup and default phases are converted
+var travisLanguages = map[string]travisLanguage{
+	"node_js": {VersionKey: "node_js", VersionVar: "TRAVIS_NODE_VERSION", Check: "node --version", Install: "npm ci", Script: "npm test"},
+	"go":      {VersionKey: "go", VersionVar: "TRAVIS_GO_VERSION", Check: "go version", Install: "go mod download", Script: "go test -v ./..."},
+	"python":  {VersionKey: "python", VersionVar: "TRAVIS_PYTHON_VERSION", Check: "python --version", Install: "pip install -r requirements.txt"},
+	"ruby":    {VersionKey: "rvm", VersionVar: "TRAVIS_RUBY_VERSION", Check: "ruby --version", Install: "bundle install --jobs=3 --retry=3", Script: "bundle exec rake"},
+	"rust":    {VersionKey: "rust", VersionVar: "TRAVIS_RUST_VERSION", Check: "cargo --version", Script: "cargo build --verbose && cargo test --verbose"},
+	"java":    {VersionKey: "jdk", VersionVar: "TRAVIS_JDK_VERSION", Check: "java -version"},
+	"php":     {VersionKey: "php", VersionVar: "TRAVIS_PHP_VERSION", Check: "php --version", Script: "phpunit"},
+}
+
+// travisPhases are the phases of a job the task runs, in order: the first four in one
+// command, as Travis runs them in one shell session, then the after_ phases
+var travisPhases = []string{"before_install", "install", "before_script", "script", "after_success", "after_failure", "after_script"}
+
+// travisPipelineValues are the Travis variables with a pipeline value equivalent,
+// which the Taskfile computes from git
+var travisPipelineValues = map[string]string{
+	"TRAVIS_COMMIT":       "pipeline.git.revision",
+	"TRAVIS_BRANCH":       "pipeline.git.branch",
+	"TRAVIS_TAG":          "pipeline.git.tag",
+	"TRAVIS_BUILD_NUMBER": "pipeline.number",
+	"TRAVIS_BUILD_ID":     "pipeline.id",
+}
+
+// travisVarRegex matches the references to TRAVIS_ variables
+var travisVarRegex = regexp.MustCompile(`\$(?:\{(TRAVIS_[A-Za-z0-9_]+)\}|(TRAVIS_[A-Za-z0-9_]+))`)
+
+// travisEnvRegex matches the NAME=value pairs of an env entry, values optionally quoted
+var travisEnvRegex = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)=("[^"]*"|'[^']*'|\S*)`)
+
+// travisTranslation translates the variable references of a job and collects notes
+// for the report; set holds the variables the job sets itself
+type travisTranslation struct {
+	job   string
+	set   map[string]bool
+	notes *[]ReportEntry
+}
+
+// note records a translation note, once
+func (t *travisTranslation) note(format string, args ...interface{}) {
+	entry := ReportEntry{Category: "Travis CI", Job: t.job, Message: fmt.Sprintf(format, args...)}
+	for _, existing := range *t.notes {
+		if existing == entry {
+			return
+		}
+	}
+	*t.notes = append(*t.notes, entry)
+}
+
+// translate replaces the Travis variables with a pipeline value by it; the others the
+// job does not set are kept, and reported as needing a value outside Travis
+func (t *travisTranslation) translate(text string) string {
+	return travisVarRegex.ReplaceAllStringFunc(text, func(ref string) string {
+		match := travisVarRegex.FindStringSubmatch(ref)
+		name := match[1] + match[2]
+		if value, ok := travisPipelineValues[name]; ok {
+			return "<< " + value + " >>"
+		}
+		if name == "TRAVIS_BUILD_DIR" {
+			return "${TRAVIS_BUILD_DIR:-$PWD}"
+		}
+		if !t.set[name] {
+			t.note("`$%s` is set by Travis; set it in the environment (or .env) to run the task locally", name)
+		}
+		return ref
+	})
+}
+
+// travisEnvPairs splits an env entry like `DB=postgres OPTS="-v -race"` into its
+// variables, unquoting the values
+func travisEnvPairs(entry string) [][2]string {
+	var pairs [][2]string
+	for _, match := range travisEnvRegex.FindAllStringSubmatch(entry, -1) {
+		value := match[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		pairs = append(pairs, [2]string{match[1], value})
+	}
+	return pairs
+}
+
+// travisEnv returns the global env entries of a config and its matrix rows: `env:` as
+// a list is the rows, as a mapping `global:` and `jobs:` (or `matrix:`). Encrypted
+// entries are reported.
+func (t *travisTranslation) travisEnv(value interface{}) ([]string, []string) {
+	entries := func(value interface{}) []string {
+		var list []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			list = v
+		case nil:
+		default:
+			list = []interface{}{v}
+		}
+		var result []string
+		for _, item := range list {
+			if body, ok := item.(map[string]interface{}); ok && body["secure"] != nil {
+				t.note("an encrypted variable is not converted; set it in the environment (or .env) to run the task locally")
+				continue
+			}
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	}
+	body, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, entries(value)
+	}
+	rows := entries(body["jobs"])
+	if rows == nil {
+		rows = entries(body["matrix"])
+	}
+	return entries(body["global"]), rows
+}
+
+// travisSlug makes a job name of a Travis job's name
+func travisSlug(name string) string {
+	return strings.Trim(githubNameRegex.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// travisJob is a job of a config: the root job, expanded by the matrix, or an entry of
+// `jobs: include:` with the root keys it does not set
+type travisJob struct {
+	name    string
+	stage   string
+	include int // index in `jobs: include:`, -1 for the root job
+	body    map[string]interface{}
+	own     map[string]interface{} // the keys the include sets
+}
+
+// travisMatrixKeys returns the `jobs:` (or the older `matrix:`) mapping of a config
+func travisMatrixKeys(doc map[string]interface{}) map[string]interface{} {
+	if jobs, ok := doc["jobs"].(map[string]interface{}); ok {
+		return jobs
+	}
+	matrix, _ := doc["matrix"].(map[string]interface{})
+	return matrix
+}
+
+// travisRootExpands reports whether the root of a config makes jobs besides its
+// includes: always without includes, else when it lists versions or env rows
+func travisRootExpands(doc map[string]interface{}) bool {
+	includes, _ := travisMatrixKeys(doc)["include"].([]interface{})
+	if len(includes) == 0 {
+		return true
+	}
+	if language, ok := travisLanguages[gitlabString(doc["language"])]; ok {
+		if versions, ok := doc[language.VersionKey].([]interface{}); ok && len(versions) > 0 {
+			return true
+		}
+	}
+	_, isList := doc["env"].([]interface{})
+	env, _ := doc["env"].(map[string]interface{})
+	return isList || env["jobs"] != nil || env["matrix"] != nil
+}
+
+// travisJobs lists the jobs of a config with their names and stages: the root job is
+// named after its stage, the first, and the includes after their names or stages. An
+// include without a stage is in the stage of the one before it.
+func travisJobs(doc map[string]interface{}) []travisJob {
+	var jobs []travisJob
+	used := make(map[string]int)
+	unique := func(name string) string {
+		if name == "" {
+			name = "build"
+		}
+		used[name]++
+		if used[name] > 1 {
+			return fmt.Sprintf("%s-%d", name, used[name])
+		}
+		return name
+	}
+	if travisRootExpands(doc) {
+		jobs = append(jobs, travisJob{name: unique("test"), stage: "test", include: -1, body: doc})
+	}
+	includes, _ := travisMatrixKeys(doc)["include"].([]interface{})
+	stage := "test"
+	for i, item := range includes {
+		entry, _ := item.(map[string]interface{})
+		if s := gitlabString(entry["stage"]); s != "" {
+			stage = s
+		}
+		body := make(map[string]interface{}, len(doc)+len(entry))
+		for key, value := range doc {
+			body[key] = value
+		}
+		for key, value := range entry {
+			body[key] = value
+		}
+		name := travisSlug(gitlabString(entry["name"]))
+		if name == "" {
+			name = travisSlug(stage)
+		}
+		jobs = append(jobs, travisJob{name: unique(name), stage: stage, include: i, body: body, own: entry})
+	}
+	return jobs
+}
+
+// travisStages orders the stages of the jobs: those of `stages:` first, in order, then
+// the others as they appear
+func travisStages(doc map[string]interface{}, jobs []travisJob) map[string]int {
+	order := make(map[string]int)
+	add := func(stage string) {
+		if _, ok := order[strings.ToLower(stage)]; !ok {
+			order[strings.ToLower(stage)] = len(order)
+		}
+	}
+	if stages, ok := doc["stages"].([]interface{}); ok {
+		for _, stage := range stages {
+			if body, ok := stage.(map[string]interface{}); ok {
+				add(gitlabString(body["name"]))
+			} else {
+				add(gitlabString(stage))
+			}
+		}
+	}
+	for _, job := range jobs {
+		add(job.stage)
+	}
+	return order
+}
+
+// travisRows expands the root job's matrix: every language version and os with every
+// env row, less the `exclude:` entries
+func travisRows(doc map[string]interface{}, language travisLanguage, rows []string) []map[string]string {
+	combinations := []map[string]string{{}}
+	extend := func(key string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range values {
+				extended := map[string]string{key: value}
+				for k, v := range combination {
+					extended[k] = v
+				}
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	if language.VersionKey != "" {
+		extend(language.VersionKey, toStringList(doc[language.VersionKey]))
+	}
+	extend("os", toStringList(doc["os"]))
+	extend("env", rows)
+
+	excludes, _ := travisMatrixKeys(doc)["exclude"].([]interface{})
+	var result []map[string]string
+	for _, combination := range combinations {
+		excluded := false
+		for _, item := range excludes {
+			exclude, _ := item.(map[string]interface{})
+			matches := len(exclude) > 0
+			for key, value := range exclude {
+				if combination[key] != gitlabString(value) {
+					matches = false
+				}
+			}
+			excluded = excluded || matches
+		}
+		if excluded {
+			continue
+		}
+		// The variables of the env row are matrix values too
+		row := make(map[string]string)
+		for key, value := range combination {
+			if key != "env" {
+				row[key] = value
+			}
+		}
+		for _, pair := range travisEnvPairs(combination["env"]) {
+			row[pair[0]] = pair[1]
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// travisMatrix turns matrix rows into a CircleCI matrix: the values that differ
+// between rows are parameters, with the combinations that are not rows excluded; the
+// values all rows share are returned apart
+func travisMatrix(rows []map[string]string) (map[string]interface{}, map[string]string) {
+	values := make(map[string][]string)
+	for _, row := range rows {
+		for key := range row {
+			values[key] = nil
+		}
+	}
+	for key := range values {
+		for _, row := range rows {
+			if !containsString(values[key], row[key]) {
+				values[key] = append(values[key], row[key])
+			}
+		}
+	}
+	fixed := make(map[string]string)
+	parameters := make(map[string]interface{})
+	for key, list := range values {
+		if len(list) == 1 {
+			fixed[key] = list[0]
+			continue
+		}
+		items := make([]interface{}, len(list))
+		for i, value := range list {
+			items[i] = value
+		}
+		parameters[key] = items
+	}
+	if len(parameters) == 0 {
+		return nil, fixed
+	}
+
+	// Exclude the combinations of the parameters that are not rows
+	names := sortedKeys(parameters)
+	combinations := []map[string]string{{}}
+	for _, name := range names {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range values[name] {
+				extended := map[string]string{name: value}
+				for k, v := range combination {
+					extended[k] = v
+				}
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	var excludes []interface{}
+	for _, combination := range combinations {
+		isRow := false
+		for _, row := range rows {
+			matches := true
+			for _, name := range names {
+				matches = matches && row[name] == combination[name]
+			}
+			isRow = isRow || matches
+		}
+		if !isRow {
+			exclude := make(map[string]interface{})
+			for k, v := range combination {
+				exclude[k] = v
+			}
+			excludes = append(excludes, exclude)
+		}
+	}
+	matrix := map[string]interface{}{"parameters": parameters}
+	if len(excludes) > 0 {
+		matrix["exclude"] = excludes
+	}
+	return matrix, fixed
+}
+
+// travisVarSource returns the variable Travis sets for a matrix value: the version
+// variable for language versions, TRAVIS_OS_NAME for the os, else the env variable
+func travisVarSource(key string, language travisLanguage) string {
+	switch {
+	case key == "os":
+		return "TRAVIS_OS_NAME"
+	case key == language.VersionKey:
+		return language.VersionVar
+	}
+	return key
+}
+
+// travisPhase returns the commands of a phase; `skip` leaves it out
+func travisPhase(body map[string]interface{}, phase string) ([]string, bool) {
+	value, ok := body[phase]
+	if !ok {
+		return nil, false
+	}
+	if gitlabString(value) == "skip" {
+		return nil, true
+	}
+	return gitlabLines(value), true
+}
+
+// steps translates the phases of a job into steps: the checkout, a check that its
+// language is installed, the cache, the phases up to script in one run step and the
+// after_ phases as run steps depending on how it ended
+func (t *travisTranslation) steps(body map[string]interface{}, languageName string, exports []string) []interface{} {
+	steps := []interface{}{"checkout"}
+	language, known := travisLanguages[languageName]
+	if known {
+		steps = append(steps, githubRun(language.Check)...)
+	}
+
+	var directories []string
+	if cache, ok := body["cache"].(map[string]interface{}); ok {
+		directories = toStringList(cache["directories"])
+	}
+	if len(directories) > 0 {
+		steps = append(steps, map[string]interface{}{"restore_cache": map[string]interface{}{"keys": []interface{}{"travis-cache"}}})
+	}
+
+	script := append([]string{}, exports...)
+	for _, phase := range travisPhases[:4] {
+		commands, ok := travisPhase(body, phase)
+		if !ok && known {
+			defaults := map[string]string{"install": language.Install, "script": language.Script}
+			if command := defaults[phase]; command != "" {
+				t.note("runs Travis's default %s for %s: `%s`", phase, languageName, command)
+				commands = []string{command}
+			}
+		}
+		script = append(script, commands...)
+	}
+	steps = append(steps, map[string]interface{}{"run": map[string]interface{}{
+		"name":    "script",
+		"command": t.translate(strings.Join(script, "\n")),
+	}})
+
+	whens := map[string]string{"after_success": "on_success", "after_failure": "on_fail", "after_script": "always"}
+	for _, phase := range travisPhases[4:] {
+		if commands, _ := travisPhase(body, phase); len(commands) > 0 {
+			steps = append(steps, map[string]interface{}{"run": map[string]interface{}{
+				"name":    phase,
+				"command": t.translate(strings.Join(append(append([]string{}, exports...), commands...), "\n")),
+				"when":    whens[phase],
+			}})
+		}
+	}
+	if len(directories) > 0 {
+		steps = append(steps, map[string]interface{}{"save_cache": map[string]interface{}{"key": "travis-cache", "paths": directories}})
+	}
+	return steps
+}
+
+// environment splits a job's variables into its environment, for literal values, and
+// export lines for its commands, for values the shell expands
+func (t *travisTranslation) environment(vars map[string]string) (map[string]interface{}, []string) {
+	env := make(map[string]interface{})
+	var exports []string
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := t.translate(vars[key])
+		if strings.Contains(value, "$") {
+			exports = append(exports, fmt.Sprintf(`export %s="%s"`, key, githubShellEscaper.Replace(value)))
+		} else {
+			env[key] = value
+		}
+	}
+	return env, exports
+}
+
+// travisFilters translates `branches:` into CircleCI branch filters
+func travisFilters(value interface{}) map[string]interface{} {
+	body, _ := value.(map[string]interface{})
+	branches := make(map[string]interface{})
+	for _, key := range []string{"only", "except"} {
+		if names := toStringList(body[key]); len(names) > 0 {
+			circleKey := key
+			if key == "except" {
+				circleKey = "ignore"
+			}
+			branches[circleKey] = names
+		}
+	}
+	if len(branches) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"branches": branches}
+}
+
+// travisAllowedFailure reports whether a job matches an `allow_failures:` entry by its
+// name or stage
+func travisAllowedFailure(doc map[string]interface{}, job travisJob) bool {
+	allowed, _ := travisMatrixKeys(doc)["allow_failures"].([]interface{})
+	for _, item := range allowed {
+		entry, _ := item.(map[string]interface{})
+		if name := gitlabString(entry["name"]); name != "" && travisSlug(name) == job.name {
+			return true
+		}
+		if stage := gitlabString(entry["stage"]); stage != "" && strings.EqualFold(stage, job.stage) && len(entry) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTravisCI translates a Travis CI config into a CircleCI config: a workflow named
+// after the file running the jobs stage by stage, the root job expanded by its
+// language versions, os and env rows into a CircleCI matrix and the `jobs: include:`
+// entries as jobs of their own. The phases of a job become its steps, with Travis's
+// default install and script for the languages that have them. Deployments stay with
+// Travis. What has no equivalent is recorded in the config's notes.
+func parseTravisCI(file string, data []byte) (CircleCIConfig, error) {
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+	doc := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return CircleCIConfig{}, yamlParseError(file, data, err)
+	}
+
+	var notes []ReportEntry
+	name := strings.Trim(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)), ".")
+	if name = travisSlug(name); name == "" {
+		name = "travis"
+	}
+	wt := &travisTranslation{job: name, notes: &notes}
+	if doc["import"] != nil {
+		wt.note("imported configs are not converted")
+	}
+	globals, rows := wt.travisEnv(doc["env"])
+
+	jobs := travisJobs(doc)
+	if len(jobs) == 0 {
+		return CircleCIConfig{}, &ParseError{File: file, Message: "the config has no jobs", Hint: "is this a Travis CI config? Pass -from circleci for CircleCI configs"}
+	}
+	stages := travisStages(doc, jobs)
+	if list, ok := doc["stages"].([]interface{}); ok {
+		for _, stage := range list {
+			if body, ok := stage.(map[string]interface{}); ok && body["if"] != nil {
+				wt.note("stage %v runs only if `%v`; its tasks run unconditionally", body["name"], body["if"])
+			}
+		}
+	}
+	filters := travisFilters(doc["branches"])
+
+	circleJobs := make(map[string]interface{})
+	var invocations []interface{}
+	for _, job := range jobs {
+		t := &travisTranslation{job: job.name, set: make(map[string]bool), notes: &notes}
+		body := job.body
+		languageName := gitlabString(body["language"])
+		language := travisLanguages[languageName]
+
+		vars := make(map[string]string)
+		for _, entry := range globals {
+			for _, pair := range travisEnvPairs(entry) {
+				vars[pair[0]] = pair[1]
+			}
+		}
+		invocation := make(map[string]interface{})
+		def := make(map[string]interface{})
+		osName := ""
+		if job.include == -1 {
+			matrix, fixed := travisMatrix(travisRows(doc, language, rows))
+			if os, ok := fixed["os"]; ok {
+				osName = os
+				delete(fixed, "os")
+			}
+			for key, value := range fixed {
+				vars[travisVarSource(key, language)] = value
+			}
+			if matrix != nil {
+				parameters := matrix["parameters"].(map[string]interface{})
+				jobParameters := make(map[string]interface{})
+				for _, key := range sortedKeys(parameters) {
+					jobParameters[key] = map[string]interface{}{"type": "string", "default": parameters[key].([]interface{})[0]}
+					vars[travisVarSource(key, language)] = "<< parameters." + key + " >>"
+				}
+				if values, ok := parameters["os"].([]interface{}); ok {
+					osName = gitlabString(values[0])
+					t.note("runs on every os of the matrix (%s); the task runs on the executor of %s", strings.Join(toStringList(values), ", "), osName)
+				}
+				def["parameters"] = jobParameters
+				invocation["matrix"] = matrix
+			}
+		} else {
+			if versions := toStringList(body[language.VersionKey]); language.VersionKey != "" && len(versions) > 0 {
+				vars[language.VersionVar] = versions[0]
+			}
+			// An include's own env is its variables, as a string, a list or with `global:`
+			ownGlobals, ownRows := t.travisEnv(job.own["env"])
+			for _, entry := range append(ownGlobals, ownRows...) {
+				for _, pair := range travisEnvPairs(entry) {
+					vars[pair[0]] = pair[1]
+				}
+			}
+			if list := toStringList(body["os"]); len(list) > 0 {
+				osName = list[0]
+			}
+		}
+		for key := range vars {
+			t.set[key] = true
+		}
+
+		switch osName {
+		case "osx":
+			osName = "macos"
+		case "":
+			osName = "linux"
+		}
+		for key, value := range githubExecutor(osName) {
+			def[key] = value
+		}
+		for _, service := range toStringList(body["services"]) {
+			if service != "docker" {
+				t.note("Travis starts %s for the job; start it locally before the task", service)
+			}
+		}
+		if addons, ok := body["addons"].(map[string]interface{}); ok {
+			for _, addon := range sortedKeys(addons) {
+				t.note("the %s addon is not converted; set it up before running the task", addon)
+			}
+		}
+		if body["if"] != nil && job.include >= 0 {
+			t.note("the job runs only if `%v`; the task runs unconditionally", body["if"])
+		}
+		if travisAllowedFailure(doc, job) {
+			t.note("the job may fail without failing the build; the task fails like any other")
+		}
+		if body["deploy"] != nil {
+			t.note("the job's deployment stays in the slim .travis.yml; the task does not deploy")
+		}
+
+		env, exports := t.environment(vars)
+		if len(env) > 0 {
+			def["environment"] = env
+		}
+		def["steps"] = t.steps(body, languageName, exports)
+		circleJobs[job.name] = def
+
+		// A stage starts once the jobs of the stage before it, that may not fail, ran
+		var requires []string
+		for stage := stages[strings.ToLower(job.stage)] - 1; stage >= 0 && len(requires) == 0; stage-- {
+			for _, other := range jobs {
+				if stages[strings.ToLower(other.stage)] == stage && !travisAllowedFailure(doc, other) {
+					requires = append(requires, other.name)
+				}
+			}
+		}
+		if len(requires) > 0 {
+			invocation["requires"] = requires
+		}
+		if filters != nil {
+			invocation["filters"] = filters
+		}
+		if len(invocation) == 0 {
+			invocations = append(invocations, job.name)
+		} else {
+			invocations = append(invocations, map[string]interface{}{job.name: invocation})
+		}
+	}
+
+	circle := map[string]interface{}{
+		"version":   "2.1",
+		"jobs":      circleJobs,
+		"workflows": map[string]interface{}{name: map[string]interface{}{"jobs": invocations}},
+	}
+	translated, err := yaml.Marshal(circle)
+	if err != nil {
+		return CircleCIConfig{}, err
+	}
+	config, err := parseConfig(file, translated)
+	if err != nil {
+		return config, err
+	}
+	config.format = fromTravis
+	config.original = &original
+	config.notes = notes
+	config.source = nil
+	return config, nil
+}
+
+// travisSlimJob replaces the phases of a job's mapping with a call to its task
+func travisSlimJob(node *yaml.Node, call string) error {
+	gitlabDeleteKeys(node, "before_install", "before_script", "after_success", "after_failure", "after_script")
+	var script yaml.Node
+	if err := script.Encode(call); err != nil {
+		return err
+	}
+	if existing := mappingValue(node, "script"); existing != nil {
+		*existing = script
+	} else {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "script"}, &script)
+	}
+	skip := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "skip"}
+	if existing := mappingValue(node, "install"); existing != nil {
+		*existing = *skip
+	} else if node.Kind == yaml.MappingNode {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "install"}, skip)
+	}
+	return nil
+}
+
+// writeTravisCI writes the slim config: the input config with the phases of the root
+// job and of each include replaced by `install: skip` and a call to the job's task (or
+// script, for the script targets), passing the matrix values it runs with. Deployments
+// and the rest of the config stay as written.
+func writeTravisCI(path string, config CircleCIConfig, target string) error {
+	root := config.original.Content[0]
+	var doc map[string]interface{}
+	if err := root.Decode(&doc); err != nil {
+		return err
+	}
+	language := travisLanguages[gitlabString(doc["language"])]
+
+	var includes *yaml.Node
+	for _, key := range []string{"jobs", "matrix"} {
+		if node := mappingValue(mappingValue(root, key), "include"); node != nil {
+			includes = node
+			break
+		}
+	}
+
+	rootSlimmed := false
+	for _, job := range travisJobs(doc) {
+		call := "task " + job.name
+		if scriptTarget(target) {
+			call = "./" + scriptFile(job.name)
+		}
+		var args []string
+		for _, key := range sortedKeys(config.Jobs[job.name].Parameters) {
+			args = append(args, fmt.Sprintf(`%s="$%s"`, taskVarName(key), travisVarSource(key, language)))
+		}
+		if len(args) > 0 {
+			call += " " + strings.Join(args, " ")
+		}
+		if job.include == -1 {
+			if err := travisSlimJob(root, call); err != nil {
+				return err
+			}
+			rootSlimmed = true
+			continue
+		}
+		if includes != nil && job.include < len(includes.Content) {
+			if err := travisSlimJob(resolveAlias(includes.Content[job.include]), call); err != nil {
+				return err
+			}
+		}
+	}
+	if !rootSlimmed {
+		// Includes inherit the root phases, which their tasks run
+		gitlabDeleteKeys(root, "before_install", "install", "before_script", "script", "after_success", "after_failure", "after_script")
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.original); err != nil {
+		return err
+	}
+	encoder.Close()
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
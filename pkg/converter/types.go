@@ -0,0 +1,331 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CircleCI structures
+type CircleCIConfig struct {
+	Version   string                    `yaml:"version" json:"version"`
+	Orbs      map[string]interface{}    `yaml:"orbs,omitempty" json:"orbs,omitempty"`
+	Parameters map[string]interface{}   `yaml:"parameters,omitempty" json:"parameters,omitempty"` // pipeline parameters
+	Jobs      map[string]Job            `yaml:"jobs" json:"jobs"`
+	Commands  map[string]Command        `yaml:"commands,omitempty" json:"commands,omitempty"`
+	Workflows map[string]Workflow       `yaml:"workflows" json:"workflows"`
+	Executors map[string]interface{}    `yaml:"executors,omitempty" json:"executors,omitempty"`
+
+	source *yaml.Node // parsed document, used to write output scalars as originally written
+	orbJobs map[string]Job // jobs inlined from orbs, keyed by their prefixed name (node/test)
+
+	format   string        // input format the config was translated from, empty for CircleCI
+	original *yaml.Node    // document of a translated input, which its slim config is written from
+	notes    []ReportEntry // translation notes of a translated input, added to the report
+	includes []string      // local files included by the input, watched by -watch
+
+	contextVars map[string][]string // variables of the contexts as set on CircleCI, when looked up
+}
+
+type Job struct {
+	Executor    interface{}            `yaml:"executor,omitempty" json:"executor,omitempty"`
+	Docker      []DockerImage          `yaml:"docker,omitempty" json:"docker,omitempty"`
+	Machine     interface{}            `yaml:"machine,omitempty" json:"machine,omitempty"`
+	Macos       interface{}            `yaml:"macos,omitempty" json:"macos,omitempty"`
+	Windows     interface{}            `yaml:"windows,omitempty" json:"windows,omitempty"`
+	Steps       []Step                 `yaml:"steps" json:"steps"`
+	Environment interface{}            `yaml:"environment,omitempty" json:"environment,omitempty"`
+	Parameters  map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Parallelism int                    `yaml:"parallelism,omitempty" json:"parallelism,omitempty"`
+	ResourceClass string               `yaml:"resource_class,omitempty" json:"resource_class,omitempty"`
+
+	WorkingDirectory string `yaml:"working_directory,omitempty" json:"working_directory,omitempty"`
+	Shell            string `yaml:"shell,omitempty" json:"shell,omitempty"` // default shell of the job's run steps
+}
+
+type DockerImage struct {
+	Image       string      `yaml:"image" json:"image"`
+	Name        string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Entrypoint  interface{} `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"` // string or list
+	Command     interface{} `yaml:"command,omitempty" json:"command,omitempty"`       // string or list
+	User        string      `yaml:"user,omitempty" json:"user,omitempty"`
+	Environment interface{} `yaml:"environment,omitempty" json:"environment,omitempty"`
+	Auth        *DockerAuth `yaml:"auth,omitempty" json:"auth,omitempty"`
+	AwsAuth     map[string]interface{} `yaml:"aws_auth,omitempty" json:"aws_auth,omitempty"` // ECR credentials or OIDC role
+}
+
+// DockerAuth holds the credentials for pulling an image from a private registry
+type DockerAuth struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+}
+
+// definition returns the image as written in an executor's `docker:` list
+func (d DockerImage) definition() map[string]interface{} {
+	def := map[string]interface{}{"image": d.Image}
+	if d.Name != "" {
+		def["name"] = d.Name
+	}
+	if d.Entrypoint != nil {
+		def["entrypoint"] = d.Entrypoint
+	}
+	if d.Command != nil {
+		def["command"] = d.Command
+	}
+	if d.User != "" {
+		def["user"] = d.User
+	}
+	if d.Environment != nil {
+		def["environment"] = d.Environment
+	}
+	if d.Auth != nil {
+		def["auth"] = map[string]interface{}{"username": d.Auth.Username, "password": d.Auth.Password}
+	}
+	if d.AwsAuth != nil {
+		def["aws_auth"] = d.AwsAuth
+	}
+	return def
+}
+
+type Command struct {
+	Description string                 `yaml:"description,omitempty" json:"description,omitempty"`
+	Parameters  map[string]interface{} `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Steps       []Step                 `yaml:"steps" json:"steps"`
+}
+
+type Step interface{}
+
+// Workflow is one entry of the workflows map. The `version` key of 2.0 configs is not a
+// workflow: it has no jobs and IsWorkflow reports false. Entries are written back as
+// they were parsed.
+type Workflow struct {
+	Jobs     []WorkflowJob
+	Triggers []interface{} // scheduled triggers
+	When     interface{}   // logic statement the workflow runs under
+	Unless   interface{}
+
+	raw interface{} // the entry as written
+}
+
+// WorkflowJob is one entry of a workflow's jobs list
+type WorkflowJob struct {
+	Job       string                 // job (or orb job) invoked
+	Name      string                 // `name:` alias, empty when the job name is used
+	Type      string                 // "approval" for approval jobs
+	Requires  []string
+	Context   []string
+	Filters   WorkflowFilters
+	Matrix    *WorkflowMatrix
+	PreSteps  []Step
+	PostSteps []Step
+	Arguments map[string]interface{} // job parameter values
+	Config    map[string]interface{} // the settings as written (nil for plain job names)
+}
+
+// WorkflowFilters holds the branch and tag filters of a workflow job
+type WorkflowFilters struct {
+	Branches BranchFilter
+	Tags     BranchFilter
+}
+
+// WorkflowMatrix is the matrix stanza of a workflow job
+type WorkflowMatrix struct {
+	Alias      string
+	Parameters map[string][]string
+	Exclude    []map[string]string
+}
+
+// ConvertOptions controls optional conversion behavior
+type ConvertOptions struct {
+	AllowRisky bool         // emit risky commands (curl | bash, chmod 777, ...) instead of blocking them
+	Docker     bool         // run job commands in their executor's image (-docker)
+	HostDocker bool         // use the host's Docker daemon for setup_remote_docker (-host-docker)
+	Orbs       *OrbResolver // resolves orb sources; nil leaves orb steps as stubs
+	Retry      RetryPolicy  // opt-in retry policy applied to every generated command
+	ShellLib   bool         // call repeated commands as functions of scripts/ci-lib.sh instead of pattern tasks
+	StepMap    map[string]StepMapping // user mappings of steps to commands or tasks (-step-map)
+	Plugins    []Plugin               // external step converters, asked in order (-plugin)
+}
+
+// Taskfile structures
+type Taskfile struct {
+	Version string             `yaml:"version" json:"version"`
+	Tasks   map[string]Task    `yaml:"tasks" json:"tasks"`
+	Vars    map[string]interface{} `yaml:"vars,omitempty" json:"vars,omitempty"` // strings, or DynamicVar
+	Env     map[string]string  `yaml:"env,omitempty" json:"env,omitempty"`
+	Dotenv  []string           `yaml:"dotenv,omitempty" json:"dotenv,omitempty"`
+
+	shellLib   string   // scripts/ci-lib.sh contents with -shell-lib, written next to the Taskfile
+	envSecrets []string // secrets loaded from .env, listed in .env.example
+}
+
+type Task struct {
+	Desc          string            `yaml:"desc,omitempty" json:"desc,omitempty"`
+	Aliases       []string          `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Cmds          TaskCmds          `yaml:"cmds" json:"cmds"`
+	Deps          []string          `yaml:"deps,omitempty" json:"deps,omitempty"`
+	Dir           string            `yaml:"dir,omitempty" json:"dir,omitempty"`
+	Dotenv        []string          `yaml:"dotenv,omitempty" json:"dotenv,omitempty"`
+	Silent        bool              `yaml:"silent,omitempty" json:"silent,omitempty"`
+	Vars          map[string]string `yaml:"vars,omitempty" json:"vars,omitempty"`
+	Env           map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Preconditions []Precondition    `yaml:"preconditions,omitempty" json:"preconditions,omitempty"`
+	Platforms     []string          `yaml:"platforms,omitempty" json:"platforms,omitempty"`
+}
+
+// TaskCmds are the commands of a task
+type TaskCmds []TaskCmd
+
+// TaskCmd is one command of a task. Deferred commands, which go-task runs when the task
+// ends even if it failed, are written as `- defer: cmd`.
+type TaskCmd struct {
+	Cmd   string
+	Defer bool
+	raw   string // JSON of an entry the converter does not model, like `- task: build`
+
+	blocked bool // replaces a blocked risky command, so it is not retried
+}
+
+// shellCommands returns commands run in order, none of them deferred
+func shellCommands(cmds ...string) TaskCmds {
+	if cmds == nil {
+		return nil
+	}
+	taskCmds := make(TaskCmds, len(cmds))
+	for i, cmd := range cmds {
+		taskCmds[i] = TaskCmd{Cmd: cmd}
+	}
+	return taskCmds
+}
+
+// deferredCommand returns cmd as a deferred command
+func deferredCommand(cmd string) TaskCmd {
+	return TaskCmd{Cmd: cmd, Defer: true}
+}
+
+// withCmd returns the command with its shell command replaced, deferred if it was
+func (c TaskCmd) withCmd(cmd string) TaskCmd {
+	c.Cmd = cmd
+	return c
+}
+
+// String returns the command as shown to users, deferred commands prefixed by "defer: "
+// and entries kept verbatim as their JSON
+func (c TaskCmd) String() string {
+	if c.raw != "" {
+		return c.raw
+	}
+	if c.Defer {
+		return "defer: " + c.Cmd
+	}
+	return c.Cmd
+}
+
+// lines returns the commands as shown to users
+func (c TaskCmds) lines() []string {
+	lines := make([]string, len(c))
+	for i, cmd := range c {
+		lines[i] = cmd.String()
+	}
+	return lines
+}
+
+// entries returns the commands as written to the Taskfile. Multi-line commands lose
+// the trailing whitespace of their lines, so they are written as block scalars.
+func (c TaskCmds) entries() []interface{} {
+	entries := make([]interface{}, len(c))
+	for i, taskCmd := range c {
+		if taskCmd.raw != "" {
+			// Decoding JSON written by setEntries cannot fail
+			_ = json.Unmarshal([]byte(taskCmd.raw), &entries[i])
+			continue
+		}
+		cmd := taskCmd.Cmd
+		if strings.Contains(cmd, "\n") {
+			cmd = trimLineEnds(cmd)
+		}
+		if taskCmd.Defer {
+			entries[i] = map[string]string{"defer": cmd}
+		} else {
+			entries[i] = cmd
+		}
+	}
+	return entries
+}
+
+// MarshalYAML writes deferred commands as `- defer: cmd`
+func (c TaskCmds) MarshalYAML() (interface{}, error) {
+	return c.entries(), nil
+}
+
+// MarshalJSON writes deferred commands as {"defer": "cmd"}
+func (c TaskCmds) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("null"), nil
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(c.entries()); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(b.Bytes(), "\n"), nil
+}
+
+// UnmarshalYAML reads commands back from a Taskfile
+func (c *TaskCmds) UnmarshalYAML(node *yaml.Node) error {
+	var entries []interface{}
+	if err := node.Decode(&entries); err != nil {
+		return err
+	}
+	return c.setEntries(entries)
+}
+
+// UnmarshalJSON reads commands back from a lock file or Taskfile.json
+func (c *TaskCmds) UnmarshalJSON(data []byte) error {
+	var entries []interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	return c.setEntries(entries)
+}
+
+// setEntries sets the commands from their written form: strings, `- defer: cmd` and
+// `- cmd: cmd` entries. Other entries, like `- task: build` or commands with options,
+// are kept verbatim so hand-written tasks using them survive re-runs.
+func (c *TaskCmds) setEntries(entries []interface{}) error {
+	cmds := make(TaskCmds, 0, len(entries))
+	for _, entry := range entries {
+		if cmd, ok := entry.(string); ok {
+			cmds = append(cmds, TaskCmd{Cmd: cmd})
+			continue
+		}
+		fields, _ := entry.(map[string]interface{})
+		if cmd, ok := fields["defer"].(string); ok && len(fields) == 1 {
+			cmds = append(cmds, deferredCommand(cmd))
+		} else if cmd, ok := fields["cmd"].(string); ok && len(fields) == 1 {
+			cmds = append(cmds, TaskCmd{Cmd: cmd})
+		} else {
+			raw, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("unsupported command %v: %w", entry, err)
+			}
+			cmds = append(cmds, TaskCmd{raw: string(raw)})
+		}
+	}
+	*c = cmds
+	return nil
+}
+
+// DynamicVar is a variable go-task sets from a shell command's output
+type DynamicVar struct {
+	Sh string `yaml:"sh" json:"sh"`
+}
+
+type Precondition struct {
+	Sh  string `yaml:"sh" json:"sh"`
+	Msg string `yaml:"msg,omitempty" json:"msg,omitempty"`
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VSCodeTasks is the .vscode/tasks.json document
+type VSCodeTasks struct {
+	Version string       `json:"version"`
+	Tasks   []VSCodeTask `json:"tasks"`
+}
+
+// VSCodeTask runs one go-task task from the editor
+type VSCodeTask struct {
+	Label          string            `json:"label"`
+	Detail         string            `json:"detail,omitempty"`
+	Type           string            `json:"type"`
+	Command        string            `json:"command"`
+	Args           []string          `json:"args"`
+	Options        map[string]string `json:"options,omitempty"`
+	Group          string            `json:"group,omitempty"`
+	ProblemMatcher []string          `json:"problemMatcher"`
+}
+
+// problemMatchers maps tools found in task commands to VS Code problem matchers, so
+// compiler and linter errors link to the offending line
+var problemMatchers = []struct {
+	Matcher string
+	Regex   *regexp.Regexp
+}{
+	{"$go", regexp.MustCompile(`\bgo\s+(build|test|vet|run|install)\b|\bgolangci-lint\b`)},
+	{"$tsc", regexp.MustCompile(`\btsc\b`)},
+	{"$eslint-stylish", regexp.MustCompile(`\beslint\b`)},
+	{"$gcc", regexp.MustCompile(`\b(gcc|g\+\+|clang|make|cmake)\b`)},
+	{"$rustc", regexp.MustCompile(`\b(cargo|rustc)\b`)},
+	{"$msCompile", regexp.MustCompile(`\b(dotnet|msbuild)\b`)},
+}
+
+// taskProblemMatchers returns the problem matchers for the tools a task's commands use
+func taskProblemMatchers(task Task) []string {
+	cmds := strings.Join(task.Cmds.lines(), "\n")
+	matchers := []string{}
+	for _, pm := range problemMatchers {
+		if pm.Regex.MatchString(cmds) {
+			matchers = append(matchers, pm.Matcher)
+		}
+	}
+	return matchers
+}
+
+// taskGroup puts test and build tasks in VS Code's test and build groups
+func taskGroup(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "test"):
+		return "test"
+	case strings.Contains(lower, "build") || strings.Contains(lower, "compile"):
+		return "build"
+	}
+	return ""
+}
+
+// buildVSCodeTasks creates one VS Code task per go-task task
+func buildVSCodeTasks(taskfile Taskfile) VSCodeTasks {
+	var names []string
+	for name := range taskfile.Tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tasks := VSCodeTasks{Version: "2.0.0", Tasks: []VSCodeTask{}}
+	for _, name := range names {
+		task := taskfile.Tasks[name]
+		tasks.Tasks = append(tasks.Tasks, VSCodeTask{
+			Label:          "task: " + name,
+			Detail:         task.Desc,
+			Type:           "shell",
+			Command:        "task",
+			Args:           []string{name},
+			Options:        map[string]string{"cwd": "${workspaceFolder}"},
+			Group:          taskGroup(name),
+			ProblemMatcher: taskProblemMatchers(task),
+		})
+	}
+	return tasks
+}
+
+// generateVSCodeTasks writes .vscode/tasks.json to the output directory
+func generateVSCodeTasks(taskfile Taskfile, outputDir string) error {
+	dir := filepath.Join(outputDir, ".vscode")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating .vscode directory: %w", err)
+	}
+	return writeJSONFile(filepath.Join(dir, "tasks.json"), buildVSCodeTasks(taskfile))
+}
@@ -0,0 +1,145 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long -watch waits after a change for more of them, so an editor
+// saving a file in several writes triggers one conversion
+const watchSettle = 100 * time.Millisecond
+
+// watchedFiles lists the local files a conversion read: the input, the files it
+// includes and the vendored orbs it used
+func watchedFiles(inputFile string, config CircleCIConfig, orbs *OrbResolver) []string {
+	files := append([]string{inputFile}, config.includes...)
+	if orbs != nil && orbs.VendorDir != "" {
+		var refs []string
+		for ref := range orbs.sources {
+			refs = append(refs, ref)
+		}
+		sort.Strings(refs)
+		for _, ref := range refs {
+			path := vendorPath(orbs.VendorDir, ref)
+			if _, err := os.Stat(path); err == nil {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// watchDirectories points the watcher at the directories of files, rather than at the
+// files, since editors often save by replacing a file, which ends a watch on it. It
+// returns the set of watched files, by cleaned path as the watcher names them.
+func watchDirectories(watcher *fsnotify.Watcher, files []string) (map[string]bool, error) {
+	watched := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		file = filepath.Clean(file)
+		watched[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for _, dir := range watcher.WatchList() {
+		if !dirs[dir] {
+			watcher.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return nil, fmt.Errorf("error watching %s: %w", dir, err)
+		}
+	}
+	return watched, nil
+}
+
+// nextChange blocks until a watched file is written, created, removed or renamed, then
+// waits for the changes to settle and returns the first changed file
+func nextChange(watcher *fsnotify.Watcher, watched map[string]bool) (string, error) {
+	changed := ""
+	var settled <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return "", fmt.Errorf("file watcher closed")
+			}
+			if !watched[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if changed == "" {
+				changed = filepath.Clean(event.Name)
+			}
+			settled = time.After(watchSettle)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return "", fmt.Errorf("file watcher closed")
+			}
+			logger.Warn("file watcher error", "error", err)
+		case <-settled:
+			return changed, nil
+		}
+	}
+}
+
+// watchConfig converts inputFile again whenever it, or a file the last conversion read,
+// changes, printing one summary per run. Errors are printed and the watch goes on, so
+// a config can be fixed while it is watched. It runs until the process is interrupted,
+// and only returns when the files cannot be watched.
+func watchConfig(inputFile, project, from, outputDir string, config CircleCIConfig, settings conversionSettings) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	files := watchedFiles(inputFile, config, settings.Options.Orbs)
+	watched, err := watchDirectories(watcher, files)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n👀 Watching %s and %d included files for changes (Ctrl-C to stop)\n", inputFile, len(files)-1)
+	for {
+		changed, err := nextChange(watcher, watched)
+		if err != nil {
+			return err
+		}
+
+		started := time.Now()
+		fmt.Printf("\n🔄 %s changed at %s\n", changed, started.Format("15:04:05"))
+		data, err := os.ReadFile(inputFile)
+		if err != nil {
+			fmt.Printf("❌ error reading input file: %v\n", err)
+			continue
+		}
+		config, err := parseInput(inputFile, data, from)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
+		// Vendored orbs are read again, so edits to them are picked up
+		if settings.Options.Orbs != nil {
+			settings.Options.Orbs.sources = nil
+		}
+		result, err := convertInput(inputFile, project, data, config, outputDir, settings)
+		if err != nil {
+			fmt.Printf("❌ conversion failed: %v\n", err)
+			continue
+		}
+		for _, warning := range result.Warnings {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+		fmt.Printf("✅ %d jobs into %d tasks, %d notes in CONVERSION_REPORT.md (%s)\n", result.Jobs, result.Tasks, len(result.Report.Entries), time.Since(started).Round(time.Millisecond))
+
+		// The next conversion may read other files
+		files = watchedFiles(inputFile, config, settings.Options.Orbs)
+		if watched, err = watchDirectories(watcher, files); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// localBranchExpr resolves the branch a local run is on, honoring CIRCLE_BRANCH overrides
+const localBranchExpr = `${CIRCLE_BRANCH:-$(git rev-parse --abbrev-ref HEAD 2>/dev/null)}`
+
+// WorkflowJobInvocation is a workflow job together with the workflow invoking it
+type WorkflowJobInvocation struct {
+	Workflow string
+	WorkflowJob
+}
+
+// BranchFilter holds the branch (or tag) patterns from a workflow job's filters stanza
+type BranchFilter struct {
+	Only   []string
+	Ignore []string
+}
+
+// UnmarshalYAML reads a workflow, keeping the entry as written for output
+func (w *Workflow) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&w.raw); err != nil {
+		return err
+	}
+	body, ok := w.raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	w.Triggers, _ = body["triggers"].([]interface{})
+	w.When, w.Unless = body["when"], body["unless"]
+
+	jobs, _ := body["jobs"].([]interface{})
+	for _, entry := range jobs {
+		switch v := entry.(type) {
+		case string:
+			w.Jobs = append(w.Jobs, WorkflowJob{Job: v})
+		case map[string]interface{}:
+			for _, jobName := range sortedKeys(v) {
+				config, _ := v[jobName].(map[string]interface{})
+				w.Jobs = append(w.Jobs, newWorkflowJob(jobName, config))
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalYAML writes the workflow as it was parsed
+func (w Workflow) MarshalYAML() (interface{}, error) {
+	return w.raw, nil
+}
+
+// MarshalJSON writes the workflow as it was parsed. HTML is not escaped, so the
+// << parameters >> references stay readable wherever the encoder allows it.
+func (w Workflow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(w.raw); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// IsWorkflow reports whether the entry is a workflow rather than the `version` key
+func (w Workflow) IsWorkflow() bool {
+	_, ok := w.raw.(map[string]interface{})
+	return ok
+}
+
+// newWorkflowJob reads the settings of a workflow job entry
+func newWorkflowJob(jobName string, config map[string]interface{}) WorkflowJob {
+	job := WorkflowJob{
+		Job:       jobName,
+		Requires:  toStringList(config["requires"]),
+		Context:   toStringList(config["context"]),
+		Arguments: invocationArguments(config),
+		Config:    config,
+	}
+	job.Name, _ = config["name"].(string)
+	job.Type, _ = config["type"].(string)
+	if preSteps, ok := config["pre-steps"].([]interface{}); ok {
+		for _, step := range preSteps {
+			job.PreSteps = append(job.PreSteps, step)
+		}
+	}
+	if postSteps, ok := config["post-steps"].([]interface{}); ok {
+		for _, step := range postSteps {
+			job.PostSteps = append(job.PostSteps, step)
+		}
+	}
+	if filters, ok := config["filters"].(map[string]interface{}); ok {
+		job.Filters.Branches = parseBranchFilter(filters["branches"])
+		job.Filters.Tags = parseBranchFilter(filters["tags"])
+	}
+	job.Matrix = parseMatrix(config["matrix"])
+	return job
+}
+
+// DisplayName is the name the workflow knows the job by: its alias, or the job name
+func (j WorkflowJob) DisplayName() string {
+	if j.Name != "" {
+		return j.Name
+	}
+	return j.Job
+}
+
+// extractWorkflowJobs returns every job invocation of the workflows, by workflow name
+func extractWorkflowJobs(workflows map[string]Workflow) []WorkflowJobInvocation {
+	var invocations []WorkflowJobInvocation
+
+	var workflowNames []string
+	for name := range workflows {
+		workflowNames = append(workflowNames, name)
+	}
+	sort.Strings(workflowNames)
+
+	for _, workflowName := range workflowNames {
+		for _, job := range workflows[workflowName].Jobs {
+			invocations = append(invocations, WorkflowJobInvocation{Workflow: workflowName, WorkflowJob: job})
+		}
+	}
+
+	return invocations
+}
+
+// workflowJobKeys are workflow job settings that are not job parameters
+var workflowJobKeys = map[string]bool{
+	"requires": true, "filters": true, "name": true, "context": true, "matrix": true,
+	"pre-steps": true, "post-steps": true, "type": true, "serial-group": true, "override-with": true,
+}
+
+// invocationArguments returns the job parameter values passed by a workflow invocation
+func invocationArguments(jobConfig map[string]interface{}) map[string]interface{} {
+	args := make(map[string]interface{})
+	for key, value := range jobConfig {
+		if !workflowJobKeys[key] {
+			args[key] = value
+		}
+	}
+	return args
+}
+
+// parseBranchFilter extracts only/ignore patterns from a filters branches or tags entry
+func parseBranchFilter(value interface{}) BranchFilter {
+	var filter BranchFilter
+	if patterns, ok := value.(map[string]interface{}); ok {
+		filter.Only = toStringList(patterns["only"])
+		filter.Ignore = toStringList(patterns["ignore"])
+	}
+	return filter
+}
+
+// IsEmpty reports whether the filter has no patterns
+func (f BranchFilter) IsEmpty() bool {
+	return len(f.Only) == 0 && len(f.Ignore) == 0
+}
+
+// parseMatrix reads a matrix stanza; it returns nil when there are no parameters
+func parseMatrix(value interface{}) *WorkflowMatrix {
+	matrix, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	parameters, ok := matrix["parameters"].(map[string]interface{})
+	if !ok || len(parameters) == 0 {
+		return nil
+	}
+
+	result := &WorkflowMatrix{Parameters: make(map[string][]string)}
+	result.Alias, _ = matrix["alias"].(string)
+	for name, values := range parameters {
+		result.Parameters[name] = toStringList(values)
+	}
+	if excludeList, ok := matrix["exclude"].([]interface{}); ok {
+		for _, entry := range excludeList {
+			if entryMap, ok := entry.(map[string]interface{}); ok {
+				exclude := make(map[string]string)
+				for k, v := range entryMap {
+					exclude[k] = fmt.Sprintf("%v", v)
+				}
+				result.Exclude = append(result.Exclude, exclude)
+			}
+		}
+	}
+	return result
+}
+
+// toStringList converts a YAML scalar or sequence into a list of strings
+func toStringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, fmt.Sprintf("%v", item))
+		}
+		return result
+	}
+	return nil
+}
+
+// posixClasses rewrites the Perl character classes CircleCI (Java) regexes allow, such as
+// the \d of version tags, for grep -E
+var posixClasses = strings.NewReplacer(`\d`, "[0-9]", `\w`, "[A-Za-z0-9_]", `\s`, "[[:space:]]")
+
+// patternMatchExpr builds a shell test matching $<variable> against CircleCI branch or
+// tag patterns
+func patternMatchExpr(variable string, patterns []string) string {
+	var tests []string
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			// CircleCI regexes must match the whole branch or tag name
+			regex := posixClasses.Replace(strings.ReplaceAll(pattern[1:len(pattern)-1], `\/`, "/"))
+			tests = append(tests, fmt.Sprintf(`printf '%%s' "$%s" | grep -Eqx %s`, variable, shellQuote(regex)))
+		} else {
+			tests = append(tests, fmt.Sprintf(`[ "$%s" = %s ]`, variable, shellQuote(pattern)))
+		}
+	}
+	return strings.Join(tests, " || ")
+}
+
+// filterPreconditions converts the branch and tag filters of a job into go-task
+// preconditions. A local run is a tag build when CIRCLE_TAG is set: jobs with tag
+// filters then check the tag instead of the branch, as CircleCI does.
+func filterPreconditions(jobName string, filters WorkflowFilters) []Precondition {
+	var preconditions []Precondition
+	branch := filters.Branches
+	tags := filters.Tags
+
+	// Branch checks are skipped on tag builds of jobs that run on tags
+	branchCheck := func(test string) string {
+		check := fmt.Sprintf(`B="%s"; %s`, localBranchExpr, test)
+		if tags.IsEmpty() {
+			return check
+		}
+		return fmt.Sprintf(`[ -n "$CIRCLE_TAG" ] || { %s; }`, check)
+	}
+	override := "set CIRCLE_BRANCH to override"
+	if !tags.IsEmpty() {
+		override += ", or CIRCLE_TAG to run for a tag"
+	}
+
+	if len(branch.Only) > 0 {
+		preconditions = append(preconditions, Precondition{
+			Sh:  branchCheck(patternMatchExpr("B", branch.Only)),
+			Msg: fmt.Sprintf("%s only runs on branches: %s (%s)", jobName, strings.Join(branch.Only, ", "), override),
+		})
+	}
+
+	if len(branch.Ignore) > 0 {
+		preconditions = append(preconditions, Precondition{
+			Sh:  branchCheck(fmt.Sprintf("! { %s; }", patternMatchExpr("B", branch.Ignore))),
+			Msg: fmt.Sprintf("%s is ignored on branches: %s (%s)", jobName, strings.Join(branch.Ignore, ", "), override),
+		})
+	}
+
+	if len(tags.Only) > 0 {
+		preconditions = append(preconditions, Precondition{
+			Sh:  fmt.Sprintf(`[ -z "$CIRCLE_TAG" ] || { T="$CIRCLE_TAG"; %s; }`, patternMatchExpr("T", tags.Only)),
+			Msg: fmt.Sprintf("%s only runs on tags: %s", jobName, strings.Join(tags.Only, ", ")),
+		})
+	}
+
+	if len(tags.Ignore) > 0 {
+		preconditions = append(preconditions, Precondition{
+			Sh:  fmt.Sprintf(`[ -z "$CIRCLE_TAG" ] || { T="$CIRCLE_TAG"; ! { %s; }; }`, patternMatchExpr("T", tags.Ignore)),
+			Msg: fmt.Sprintf("%s is ignored on tags: %s", jobName, strings.Join(tags.Ignore, ", ")),
+		})
+	}
+
+	return preconditions
+}
+
+// collectJobFilters maps each job to the branch and tag filters of its workflow
+// invocations. A job invoked several times runs where any invocation does: on branch
+// builds, every branch when one invocation has no branch filter, and on tag builds,
+// the tags of the invocations with tag filters. Filters no single filter can merge are
+// reported and left unchecked. Jobs without any filter are left out.
+func collectJobFilters(workflows map[string]Workflow, report *ConversionReport) map[string]WorkflowFilters {
+	var jobs []string
+	invoked := make(map[string][]WorkflowFilters)
+	for _, invocation := range extractWorkflowJobs(workflows) {
+		if _, seen := invoked[invocation.Job]; !seen {
+			jobs = append(jobs, invocation.Job)
+		}
+		invoked[invocation.Job] = append(invoked[invocation.Job], invocation.Filters)
+	}
+
+	filters := make(map[string]WorkflowFilters)
+	for _, job := range jobs {
+		var branches, tags []BranchFilter
+		for _, filter := range invoked[job] {
+			branches = append(branches, filter.Branches)
+			// Invocations without tag filters do not run on tags
+			if !filter.Tags.IsEmpty() {
+				tags = append(tags, filter.Tags)
+			}
+		}
+		merged := WorkflowFilters{}
+		var ok bool
+		if merged.Branches, ok = mergeBranchFilters(branches); !ok {
+			report.Add("Branch filters", job, "invoked with branch filters no single filter can merge (%s); the task does not check the branch", describeFilters(branches))
+		}
+		if merged.Tags, ok = mergeBranchFilters(tags); !ok {
+			report.Add("Tag filters", job, "invoked with tag filters no single filter can merge (%s); the task does not check the tag", describeFilters(tags))
+		}
+		if !merged.Branches.IsEmpty() || !merged.Tags.IsEmpty() {
+			filters[job] = merged
+		}
+	}
+	return filters
+}
+
+// mergeBranchFilters merges the filters of several invocations of a job into one
+// matching wherever any of them does: the same filter, the union of only lists or the
+// names every ignore list has. An empty filter matches everything. It returns false,
+// and an empty filter, when they mix only and ignore lists differently.
+func mergeBranchFilters(filters []BranchFilter) (BranchFilter, bool) {
+	if len(filters) == 0 {
+		return BranchFilter{}, true
+	}
+	same, onlyLists, ignoreLists := true, true, true
+	for _, filter := range filters {
+		if filter.IsEmpty() {
+			return BranchFilter{}, true
+		}
+		same = same && strings.Join(filter.Only, "\n") == strings.Join(filters[0].Only, "\n") &&
+			strings.Join(filter.Ignore, "\n") == strings.Join(filters[0].Ignore, "\n")
+		onlyLists = onlyLists && len(filter.Ignore) == 0
+		ignoreLists = ignoreLists && len(filter.Only) == 0
+	}
+
+	switch {
+	case same:
+		return filters[0], true
+	case onlyLists:
+		var only []string
+		for _, filter := range filters {
+			for _, pattern := range filter.Only {
+				if !containsString(only, pattern) {
+					only = append(only, pattern)
+				}
+			}
+		}
+		return BranchFilter{Only: only}, true
+	case ignoreLists:
+		var ignore []string
+		for _, pattern := range filters[0].Ignore {
+			everywhere := true
+			for _, filter := range filters[1:] {
+				everywhere = everywhere && containsString(filter.Ignore, pattern)
+			}
+			if everywhere {
+				ignore = append(ignore, pattern)
+			}
+		}
+		return BranchFilter{Ignore: ignore}, true
+	}
+	return BranchFilter{}, false
+}
+
+// describeFilters renders the filters of several invocations for the report
+func describeFilters(filters []BranchFilter) string {
+	var parts []string
+	for _, filter := range filters {
+		var part []string
+		if len(filter.Only) > 0 {
+			part = append(part, "only "+strings.Join(filter.Only, ", "))
+		}
+		if len(filter.Ignore) > 0 {
+			part = append(part, "ignore "+strings.Join(filter.Ignore, ", "))
+		}
+		parts = append(parts, strings.Join(part, " and "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// workflowCondition returns the go-task template expression of a workflow's when and
+// unless, and false when the workflow is unconditional
+func workflowCondition(workflow Workflow, pipelineParams map[string]interface{}) (string, bool, error) {
+	types := parameterTypes("pipeline.parameters.", pipelineParams)
+	var parts []string
+	if workflow.When != nil {
+		condition, err := templateCondition(workflow.When, types)
+		if err != nil {
+			return "", true, err
+		}
+		parts = append(parts, condition)
+	}
+	if workflow.Unless != nil {
+		condition, err := templateCondition(workflow.Unless, types)
+		if err != nil {
+			return "", true, err
+		}
+		parts = append(parts, fmt.Sprintf("(not %s)", condition))
+	}
+	switch len(parts) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return parts[0], true, nil
+	}
+	return fmt.Sprintf("(and %s)", strings.Join(parts, " ")), true, nil
+}
+
+// describeCondition renders a CircleCI logic statement on one line for messages
+func describeCondition(condition interface{}) string {
+	switch v := condition.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			items, _ := value.([]interface{})
+			var described []string
+			for _, item := range items {
+				described = append(described, describeCondition(item))
+			}
+			switch key {
+			case "and", "or":
+				return "(" + strings.Join(described, " "+key+" ") + ")"
+			case "not":
+				return "not " + describeCondition(value)
+			case "equal":
+				return strings.Join(described, " = ")
+			case "matches":
+				args, _ := value.(map[string]interface{})
+				return fmt.Sprintf("%s matches /%v/", describeCondition(args["value"]), args["pattern"])
+			}
+		}
+	case string:
+		return strings.TrimSpace(v)
+	}
+	return fmt.Sprintf("%v", condition)
+}
+
+// addWorkflowConditionTasks adds a workflow:<name> task for each workflow guarded by
+// when/unless. It runs the workflow's jobs in dependency order when the condition
+// holds for the pipeline parameters, which are global vars (task workflow:nightly
+// RUN_NIGHTLY=true).
+func addWorkflowConditionTasks(taskfile *Taskfile, config CircleCIConfig, report *ConversionReport) {
+	for _, name := range sortedWorkflowNames(config.Workflows) {
+		workflow := config.Workflows[name]
+		condition, conditional, err := workflowCondition(workflow, config.Parameters)
+		if !conditional {
+			continue
+		}
+		described := describeWorkflowCondition(workflow)
+		if err != nil {
+			report.Add("Workflow conditions", name, "runs only when %s; not converted (%v), so it has no workflow task", described, err)
+			continue
+		}
+		nodes, err := buildWorkflowGraph(config, name)
+		if err != nil {
+			report.Add("Workflow conditions", name, "runs only when %s; no workflow task: %v", described, err)
+			continue
+		}
+
+		task := Task{
+			Desc: fmt.Sprintf("Run the jobs of workflow %s in order, when %s", name, described),
+			Preconditions: []Precondition{{
+				Sh:  fmt.Sprintf("{{if %s}}true{{else}}false{{end}}", condition),
+				Msg: fmt.Sprintf("workflow %s only runs when %s", name, described),
+			}},
+		}
+		task.Cmds = workflowTaskCmds(config, nodes)
+
+		taskName := "workflow:" + name
+		taskfile.Tasks[taskName] = task
+		report.Add("Workflow conditions", name, "runs only when %s; converted to task %s, guarded by a precondition", described, taskName)
+	}
+}
+
+// workflowTaskCmds calls the tasks of a workflow's jobs in dependency order; approval
+// jobs continue without waiting
+func workflowTaskCmds(config CircleCIConfig, nodes []RunNode) TaskCmds {
+	var cmds TaskCmds
+	for _, node := range nodes {
+		if node.Approval {
+			cmds = append(cmds, TaskCmd{Cmd: fmt.Sprintf("echo 'Approval job %s: continuing'", node.Name)})
+			continue
+		}
+		// Vars given on the command line do not reach nested task calls, so the
+		// pipeline parameters the job uses are passed on
+		args := quotedTaskArgs(node.Args)
+		if job, ok := config.Jobs[node.Task]; ok {
+			for _, ref := range jobPipelineRefs(job, config.Commands) {
+				name, _ := pipelineRefVar(ref)
+				args = append(args, fmt.Sprintf("%s='{{.%s}}'", name, name))
+			}
+		}
+		cmds = append(cmds, TaskCmd{Cmd: strings.TrimSpace("task " + node.Task + " " + strings.Join(args, " "))})
+	}
+	return cmds
+}
+
+// describeWorkflowCondition renders a workflow's when and unless for messages
+func describeWorkflowCondition(workflow Workflow) string {
+	var parts []string
+	if workflow.When != nil {
+		parts = append(parts, describeCondition(workflow.When))
+	}
+	if workflow.Unless != nil {
+		parts = append(parts, "not "+describeCondition(workflow.Unless))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// sortedWorkflowNames lists the workflows of a config (not the 2.0 version key) by name
+func sortedWorkflowNames(workflows map[string]Workflow) []string {
+	var names []string
+	for name, workflow := range workflows {
+		if workflow.IsWorkflow() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// workspaceDirName is the directory next to the Taskfile standing in for the CircleCI
+// workspace of a workflow run
+const workspaceDirName = "workspace"
+
+// workspaceDir is the workspace directory in task commands
+const workspaceDir = `"{{.ROOT_DIR}}/` + workspaceDirName + `"`
+
+// workspacePaths returns the `paths:` of a persist_to_workspace step
+func workspacePaths(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var paths []string
+		for _, item := range v {
+			if path, ok := item.(string); ok && path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
+// workspacePathArg quotes a workspace path for the shell, leaving globs unquoted so
+// the shell expands them in the root directory
+func workspacePathArg(path string) string {
+	if strings.ContainsAny(path, "*?[") && !strings.ContainsAny(path, " '\"$`") {
+		return path
+	}
+	return shellQuote(path)
+}
+
+// persistWorkspaceCommand copies the paths of a persist_to_workspace step, relative to
+// its root, into the workspace directory, keeping their place under the root. Files
+// persisted by later steps replace those of earlier ones, like CircleCI's layers.
+func persistWorkspaceCommand(value interface{}) string {
+	settings, _ := value.(map[string]interface{})
+	paths := workspacePaths(settings["paths"])
+	if len(paths) == 0 {
+		return "mkdir -p " + workspaceDir
+	}
+	root, _ := settings["root"].(string)
+	if root == "" {
+		root = "."
+	}
+
+	args := make([]string, len(paths))
+	for i, path := range paths {
+		args[i] = workspacePathArg(path)
+	}
+	// pipefail keeps the step failing on missing paths, as it does on CircleCI
+	return fmt.Sprintf("set -o pipefail; mkdir -p %s && (cd %s && tar -cf - %s) | tar -xf - -C %s",
+		workspaceDir, stepDirectory(root), strings.Join(args, " "), workspaceDir)
+}
+
+// attachWorkspaceCommand copies the workspace directory into the `at:` directory of an
+// attach_workspace step
+func attachWorkspaceCommand(value interface{}) string {
+	settings, _ := value.(map[string]interface{})
+	at, _ := settings["at"].(string)
+	if at == "" {
+		at = "."
+	}
+	dir := stepDirectory(at)
+	return fmt.Sprintf("if [ -d %s ]; then mkdir -p %s && cp -R %s/. %s/; else echo 'No workspace to attach: run the jobs persisting to it first' >&2; fi",
+		workspaceDir, dir, workspaceDir, dir)
+}